@@ -1,6 +1,8 @@
 package action
 
 import (
+	"strconv"
+
 	"github.com/ovh/cds/engine/api/database"
 	"github.com/ovh/cds/sdk"
 )
@@ -58,6 +60,17 @@ func LoadActionRequirements(db database.Querier, actionID int64) ([]sdk.Requirem
 
 // InsertActionRequirement inserts given requirement in database
 func InsertActionRequirement(db database.Executer, actionID int64, r sdk.Requirement) error {
+	switch r.Type {
+	case sdk.MemoryRequirement:
+		if m, err := strconv.ParseInt(r.Value, 10, 64); err != nil || m <= 0 {
+			return sdk.ErrInvalidResource
+		}
+	case sdk.CPURequirement:
+		if c, err := strconv.ParseFloat(r.Value, 64); err != nil || c <= 0 {
+			return sdk.ErrInvalidResource
+		}
+	}
+
 	query := `INSERT INTO action_requirement (action_id, name, type, value) VALUES ($1, $2, $3, $4)`
 
 	_, err := db.Exec(query, actionID, r.Name, string(r.Type), r.Value)
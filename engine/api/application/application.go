@@ -285,6 +285,9 @@ func loadDependencies(db database.Querier, app *sdk.Application, fargs ...FuncAr
 	if err != nil {
 		return err
 	}
+	for i := range notifs {
+		notification.RedactUserNotification(&notifs[i])
+	}
 	app.Notifications = notifs
 
 	pipelines, err := GetAllPipelinesByID(db, app.ID)
@@ -421,12 +424,34 @@ func DeleteApplication(db *sql.Tx, applicationID int64) error {
 		return err
 	}
 
+	// Delete received hooks
+	query = `DELETE FROM received_hook WHERE application_id = $1`
+	_, err = db.Exec(query, applicationID)
+	if err != nil {
+		log.Warning("DeleteApplication> Cannot delete received hooks: %s\n", err)
+		return err
+	}
+
 	// Delete triggers
 	err = trigger.DeleteApplicationTriggers(db, applicationID)
 	if err != nil {
 		return err
 	}
 
+	// Delete branch lifecycle
+	query = `DELETE FROM application_branch WHERE application_id = $1`
+	_, err = db.Exec(query, applicationID)
+	if err != nil {
+		log.Warning("DeleteApplication> Cannot delete application branches: %s\n", err)
+		return err
+	}
+	query = `DELETE FROM application_branch_lifecycle WHERE application_id = $1`
+	_, err = db.Exec(query, applicationID)
+	if err != nil {
+		log.Warning("DeleteApplication> Cannot delete application branch lifecycle: %s\n", err)
+		return err
+	}
+
 	query = `DELETE FROM application WHERE id=$1`
 	_, err = db.Exec(query, applicationID)
 	if err != nil {
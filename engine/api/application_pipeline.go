@@ -220,7 +220,7 @@ func removePipelineFromApplicationHandler(w http.ResponseWriter, r *http.Request
 }
 
 func getUserNotificationTypeHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
-	var types = []sdk.UserNotificationSettingsType{sdk.EmailUserNotification, sdk.JabberUserNotification}
+	var types = []sdk.UserNotificationSettingsType{sdk.EmailUserNotification, sdk.JabberUserNotification, sdk.WebhookUserNotification, sdk.ChatUserNotification}
 	WriteJSON(w, r, types, http.StatusOK)
 }
 
@@ -290,6 +290,7 @@ func getUserNotificationApplicationPipelineHandler(w http.ResponseWriter, r *htt
 		WriteJSON(w, r, nil, http.StatusNoContent)
 		return
 	}
+	notification.RedactUserNotification(notifs)
 
 	WriteJSON(w, r, notifs, http.StatusOK)
 	return
@@ -434,6 +435,15 @@ func updateUserNotificationApplicationPipelineHandler(w http.ResponseWriter, r *
 	}
 	defer tx.Rollback()
 
+	// Secrets are sent back redacted by clients which did not change them
+	stored, err := notification.LoadUserNotificationSettings(tx, applicationData.ID, pipeline.ID, notifs.Environment.ID)
+	if err != nil {
+		log.Warning("updateUserNotificationApplicationPipelineHandler> cannot load user notification %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+	notification.KeepRedactedSecrets(stored, notifs)
+
 	//Insert or update notification
 	if err := notification.InsertOrUpdateUserNotificationSettings(tx, applicationData.ID, pipeline.ID, notifs.Environment.ID, notifs); err != nil {
 		log.Warning("updateUserNotificationApplicationPipelineHandler> cannot update user notification %s\n", err)
@@ -458,6 +468,7 @@ func updateUserNotificationApplicationPipelineHandler(w http.ResponseWriter, r *
 	k := cache.Key("application", key, "*"+appName+"*")
 	cache.DeleteAll(k)
 
+	notification.RedactUserNotification(notifs)
 	WriteJSON(w, r, notifs, http.StatusOK)
 	return
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 
@@ -41,7 +42,7 @@ func uploadArtifactHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c
 	m := r.MultipartForm
 	envName := m.Value["env"][0]
 
-	var sizeStr, permStr, md5sum string
+	var sizeStr, permStr, md5sum, sha256sum string
 	if len(m.Value["size"]) > 0 {
 		sizeStr = m.Value["size"][0]
 	}
@@ -51,6 +52,9 @@ func uploadArtifactHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c
 	if len(m.Value["md5sum"]) > 0 {
 		md5sum = m.Value["md5sum"][0]
 	}
+	if len(m.Value["sha256sum"]) > 0 {
+		sha256sum = m.Value["sha256sum"][0]
+	}
 
 	if fileName == "" {
 		log.Warning("uploadArtifactHandler> %s header is not set", sdk.ArtifactFileName)
@@ -127,6 +131,7 @@ func uploadArtifactHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c
 		Size:         size,
 		Perm:         uint32(perm),
 		MD5sum:       md5sum,
+		SHA256sum:    sha256sum,
 	}
 
 	files := m.File[fileName]
@@ -138,6 +143,11 @@ func uploadArtifactHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c
 			return
 		}
 		err = artifact.SaveFile(db, p, a, art, file, env)
+		if err == sdk.ErrArtifactChecksumMismatch {
+			WriteError(w, r, err)
+			file.Close()
+			return
+		}
 		if err != nil {
 			log.Warning("uploadArtifactHandler> cannot save file: %s\n", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -186,15 +196,82 @@ func downloadArtifactHandler(w http.ResponseWriter, r *http.Request, db *sql.DB,
 		return
 	}
 
-	log.Info("downloadArtifactHandler: Serving %+v\n", art)
-	err = artifact.StreamFile(w, *art)
+	w.Header().Add("Content-Type", "application/octet-stream")
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", art.Name))
+	// Resume interrupted downloads
+	out, offset, ok := rangeWriter(w, r, art.Size)
+	if !ok {
+		return
+	}
+
+	log.Info("downloadArtifactHandler: Serving %+v from %d\n", art, offset)
+	err = artifact.StreamFileFrom(out, *art, offset)
 	if err != nil {
 		log.Warning("downloadArtifactHandler: Cannot stream artifact %s-%s-%s-%s-%s file: %s\n", art.Project, art.Application, art.Environment, art.Pipeline, art.Tag, err)
-		w.WriteHeader(http.StatusInternalServerError)
+		// Once content is sent, status cannot be changed anymore
+		if !out.wrote {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		return
 	}
-	w.Header().Add("Content-Type", "application/octet-stream")
-	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", art.Name))
+}
+
+// rangeWriter handles Range header of a download of content of given size. It returns
+// the offset to stream content from, and a writer sending the matching status with the
+// first byte of content. If range cannot be satisfied, it replies 416 and returns false.
+func rangeWriter(w http.ResponseWriter, r *http.Request, size int64) (*statusWriter, int64, bool) {
+	w.Header().Add("Accept-Ranges", "bytes")
+	out := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+	rg := r.Header.Get("Range")
+	if rg == "" || size <= 0 {
+		return out, 0, true
+	}
+
+	offset, err := parseRangeOffset(rg, size)
+	if err != nil {
+		log.Warning("rangeWriter> Invalid range %s on %s: %s\n", rg, r.URL.Path, err)
+		w.Header().Add("Content-Range", fmt.Sprintf("bytes */%d", size))
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return nil, 0, false
+	}
+
+	w.Header().Add("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, size-1, size))
+	out.status = http.StatusPartialContent
+	return out, offset, true
+}
+
+// statusWriter sends status with the first byte of content, so that an error
+// occurring before can still be reported
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (s *statusWriter) Write(p []byte) (int, error) {
+	if !s.wrote {
+		s.wrote = true
+		s.ResponseWriter.WriteHeader(s.status)
+	}
+	return s.ResponseWriter.Write(p)
+}
+
+// parseRangeOffset returns the start of a "bytes=N-" range header. Only this form,
+// used to resume downloads, is supported.
+func parseRangeOffset(rg string, size int64) (int64, error) {
+	if !strings.HasPrefix(rg, "bytes=") || !strings.HasSuffix(rg, "-") {
+		return 0, fmt.Errorf("unsupported range")
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(rg, "bytes="), "-"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 || offset >= size {
+		return 0, fmt.Errorf("range out of bounds")
+	}
+	return offset, nil
 }
 
 func listArtifactsBuildHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
@@ -1,9 +1,13 @@
 package artifact
 
 import (
+	"crypto/md5"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"strings"
 
 	"github.com/ovh/cds/engine/api/database"
@@ -17,7 +21,7 @@ func LoadArtifactByHash(db *sql.DB, hash string) (*sdk.Artifact, error) {
 	art := &sdk.Artifact{}
 	query := `SELECT artifact.id, artifact.name, artifact.tag, 
 		  pipeline.name, project.projectKey, application.name, environment.name,
-		  artifact.size, artifact.perm, artifact.md5sum, artifact.object_path
+		  artifact.size, artifact.perm, artifact.md5sum, artifact.sha256sum, artifact.object_path
 		  FROM artifact
 		  JOIN pipeline ON artifact.pipeline_id = pipeline.id
 		  JOIN project ON pipeline.project_id = project.id
@@ -25,15 +29,18 @@ func LoadArtifactByHash(db *sql.DB, hash string) (*sdk.Artifact, error) {
 		  JOIN environment ON environment.id = artifact.environment_id
 		  WHERE download_hash = $1`
 
-	var md5sum, objectpath sql.NullString
+	var md5sum, sha256sum, objectpath sql.NullString
 	var size, perm sql.NullInt64
-	err := db.QueryRow(query, hash).Scan(&art.ID, &art.Name, &art.Tag, &art.Pipeline, &art.Project, &art.Application, &art.Environment, &size, &perm, &md5sum, &objectpath)
+	err := db.QueryRow(query, hash).Scan(&art.ID, &art.Name, &art.Tag, &art.Pipeline, &art.Project, &art.Application, &art.Environment, &size, &perm, &md5sum, &sha256sum, &objectpath)
 	if err != nil {
 		return nil, err
 	}
 	if md5sum.Valid {
 		art.MD5sum = md5sum.String
 	}
+	if sha256sum.Valid {
+		art.SHA256sum = sha256sum.String
+	}
 	if objectpath.Valid {
 		art.ObjectPath = objectpath.String
 	}
@@ -48,7 +55,7 @@ func LoadArtifactByHash(db *sql.DB, hash string) (*sdk.Artifact, error) {
 
 // LoadArtifactsByBuildNumber Load artifact by pipeline ID and buildNUmber
 func LoadArtifactsByBuildNumber(db *sql.DB, pipelineID int64, applicationID int64, buildNumber int, environmentID int64) ([]sdk.Artifact, error) {
-	query := `SELECT id, name, tag, download_hash, size, perm, md5sum, object_path
+	query := `SELECT id, name, tag, download_hash, size, perm, md5sum, sha256sum, object_path
 	          FROM "artifact"
 	          WHERE build_number = $1 AND pipeline_id = $2 AND application_id = $3 AND environment_id = $4
 	          ORDER BY name`
@@ -62,15 +69,18 @@ func LoadArtifactsByBuildNumber(db *sql.DB, pipelineID int64, applicationID int6
 	arts := []sdk.Artifact{}
 	for rows.Next() {
 		art := sdk.Artifact{}
-		var md5sum, objectpath sql.NullString
+		var md5sum, sha256sum, objectpath sql.NullString
 		var size, perm sql.NullInt64
-		err = rows.Scan(&art.ID, &art.Name, &art.Tag, &art.DownloadHash, &size, &perm, &md5sum, &objectpath)
+		err = rows.Scan(&art.ID, &art.Name, &art.Tag, &art.DownloadHash, &size, &perm, &md5sum, &sha256sum, &objectpath)
 		if err != nil {
 			return nil, err
 		}
 		if md5sum.Valid {
 			art.MD5sum = md5sum.String
 		}
+		if sha256sum.Valid {
+			art.SHA256sum = sha256sum.String
+		}
 		if objectpath.Valid {
 			art.ObjectPath = objectpath.String
 		}
@@ -87,7 +97,7 @@ func LoadArtifactsByBuildNumber(db *sql.DB, pipelineID int64, applicationID int6
 
 // LoadArtifacts Load artifact by pipeline ID
 func LoadArtifacts(db *sql.DB, pipelineID int64, applicationID int64, environmentID int64, tag string) ([]sdk.Artifact, error) {
-	query := `SELECT id, name, download_hash, size, perm, md5sum, object_path
+	query := `SELECT id, name, download_hash, size, perm, md5sum, sha256sum, object_path
 		FROM "artifact" 
 		WHERE tag = $1 
 		AND pipeline_id = $2 
@@ -102,15 +112,18 @@ func LoadArtifacts(db *sql.DB, pipelineID int64, applicationID int64, environmen
 	var arts []sdk.Artifact
 	for rows.Next() {
 		art := sdk.Artifact{}
-		var md5sum, objectpath sql.NullString
+		var md5sum, sha256sum, objectpath sql.NullString
 		var size, perm sql.NullInt64
-		err = rows.Scan(&art.ID, &art.Name, &art.DownloadHash, &size, &perm, &md5sum, &objectpath)
+		err = rows.Scan(&art.ID, &art.Name, &art.DownloadHash, &size, &perm, &md5sum, &sha256sum, &objectpath)
 		if err != nil {
 			return nil, err
 		}
 		if md5sum.Valid {
 			art.MD5sum = md5sum.String
 		}
+		if sha256sum.Valid {
+			art.SHA256sum = sha256sum.String
+		}
 		if objectpath.Valid {
 			art.ObjectPath = objectpath.String
 		}
@@ -129,7 +142,7 @@ func LoadArtifacts(db *sql.DB, pipelineID int64, applicationID int64, environmen
 // LoadArtifact Load artifact by ID
 func LoadArtifact(db *sql.DB, id int64) (*sdk.Artifact, error) {
 	query := `SELECT 
-			artifact.name, artifact.tag, artifact.download_hash, artifact.size, artifact.perm, artifact.md5sum, artifact.object_path, 
+			artifact.name, artifact.tag, artifact.download_hash, artifact.size, artifact.perm, artifact.md5sum, artifact.sha256sum, artifact.object_path,
 			pipeline.name, project.projectKey, application.name, environment.name FROM artifact
 			JOIN pipeline ON artifact.pipeline_id = pipeline.id
 			JOIN project ON pipeline.project_id = project.id
@@ -138,13 +151,16 @@ func LoadArtifact(db *sql.DB, id int64) (*sdk.Artifact, error) {
 			WHERE artifact.id = $1`
 
 	s := &sdk.Artifact{}
-	var md5sum, objectpath sql.NullString
+	var md5sum, sha256sum, objectpath sql.NullString
 	var size, perm sql.NullInt64
-	err := db.QueryRow(query, id).Scan(&s.Name, &s.Tag, &s.DownloadHash, &size, &perm, &md5sum, &objectpath,
+	err := db.QueryRow(query, id).Scan(&s.Name, &s.Tag, &s.DownloadHash, &size, &perm, &md5sum, &sha256sum, &objectpath,
 		&s.Pipeline, &s.Project, &s.Application, &s.Environment)
 	if md5sum.Valid {
 		s.MD5sum = md5sum.String
 	}
+	if sha256sum.Valid {
+		s.SHA256sum = sha256sum.String
+	}
 	if objectpath.Valid {
 		s.ObjectPath = objectpath.String
 	}
@@ -161,27 +177,16 @@ func LoadArtifact(db *sql.DB, id int64) (*sdk.Artifact, error) {
 // then remove the actual object using storage driver,
 // finally remove artifact from database if actual delete is performed
 func DeleteArtifact(db database.QueryExecuter, id int64) error {
-
-	query := `SELECT artifact.name, artifact.tag, pipeline.name, project.projectKey, application.name, environment.name FROM artifact
-						JOIN pipeline ON artifact.pipeline_id = pipeline.id
-						JOIN project ON pipeline.project_id = project.id
-						JOIN application ON application.id = artifact.application_id
-						JOIN environment ON environment.id = artifact.environment_id
-						WHERE artifact.id = $1 FOR UPDATE`
-
-	s := sdk.Artifact{}
-	err := db.QueryRow(query, id).Scan(&s.Name, &s.Tag, &s.Pipeline, &s.Project, &s.Application, &s.Environment)
+	s, err := lockArtifact(db, id)
 	if err != nil {
 		return err
 	}
 
-	err = objectstore.DeleteArtifact(s)
-	// If it's 404, it's lost anyway...
-	if err != nil && !strings.Contains(err.Error(), "404") {
+	if err := DeleteArtifactObject(s); err != nil {
 		return err
 	}
 
-	query = `DELETE FROM artifact WHERE id = $1`
+	query := `DELETE FROM artifact WHERE id = $1`
 	_, err = db.Exec(query, id)
 	if err != nil {
 		return err
@@ -190,6 +195,42 @@ func DeleteArtifact(db database.QueryExecuter, id int64) error {
 	return nil
 }
 
+// DeleteArtifactRow lock the artifact in database and remove it, the actual object is kept.
+// Returned artifact is to be removed with DeleteArtifactObject once the transaction is committed
+func DeleteArtifactRow(db database.QueryExecuter, id int64) (sdk.Artifact, error) {
+	s, err := lockArtifact(db, id)
+	if err != nil {
+		return s, err
+	}
+
+	query := `DELETE FROM artifact WHERE id = $1`
+	_, err = db.Exec(query, id)
+	return s, err
+}
+
+// DeleteArtifactObject remove the actual object of an artifact using storage driver
+func DeleteArtifactObject(s sdk.Artifact) error {
+	err := objectstore.DeleteArtifact(s)
+	// If it's 404, it's lost anyway...
+	if err != nil && !strings.Contains(err.Error(), "404") {
+		return err
+	}
+	return nil
+}
+
+func lockArtifact(db database.QueryExecuter, id int64) (sdk.Artifact, error) {
+	query := `SELECT artifact.name, artifact.tag, pipeline.name, project.projectKey, application.name, environment.name FROM artifact
+						JOIN pipeline ON artifact.pipeline_id = pipeline.id
+						JOIN project ON pipeline.project_id = project.id
+						JOIN application ON application.id = artifact.application_id
+						JOIN environment ON environment.id = artifact.environment_id
+						WHERE artifact.id = $1 FOR UPDATE`
+
+	s := sdk.Artifact{}
+	err := db.QueryRow(query, id).Scan(&s.Name, &s.Tag, &s.Pipeline, &s.Project, &s.Application, &s.Environment)
+	return s, err
+}
+
 func insertArtifact(db database.Executer, pipelineID, applicationID int64, environmentID int64, art sdk.Artifact) error {
 	query := `DELETE FROM "artifact" WHERE name = $1 AND tag = $2 AND pipeline_id = $3 AND application_id = $4 AND environment_id = $5`
 	_, err := db.Exec(query, art.Name, art.Tag, pipelineID, applicationID, environmentID)
@@ -198,10 +239,10 @@ func insertArtifact(db database.Executer, pipelineID, applicationID int64, envir
 	}
 
 	query = `INSERT INTO "artifact" 
-			(name, tag, pipeline_id, application_id, build_number, environment_id, download_hash, size, perm, md5sum, object_path) 
+			(name, tag, pipeline_id, application_id, build_number, environment_id, download_hash, size, perm, md5sum, sha256sum, object_path) 
 			VALUES 
-			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	_, err = db.Exec(query, art.Name, art.Tag, pipelineID, applicationID, art.BuildNumber, environmentID, art.DownloadHash, art.Size, art.Perm, art.MD5sum, art.ObjectPath)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	_, err = db.Exec(query, art.Name, art.Tag, pipelineID, applicationID, art.BuildNumber, environmentID, art.DownloadHash, art.Size, art.Perm, art.MD5sum, art.SHA256sum, art.ObjectPath)
 	if err != nil {
 		fmt.Println(err)
 		return err
@@ -217,12 +258,33 @@ func SaveFile(db *sql.DB, p *sdk.Pipeline, a *sdk.Application, art sdk.Artifact,
 	}
 	defer tx.Rollback()
 
-	objectPath, err := objectstore.StoreArtifact(art, content)
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	counter := &countingReader{r: io.TeeReader(content, io.MultiWriter(md5Hash, sha256Hash))}
+	objectPath, err := objectstore.StoreArtifact(art, struct {
+		io.Reader
+		io.Closer
+	}{counter, content})
 	if err != nil {
 		return err
 	}
 	log.Debug("objectpath=%s\n", objectPath)
 	art.ObjectPath = objectPath
+
+	// Legacy uploads are checked like chunked ones: size and checksums sent by the worker must match
+	md5sum := hex.EncodeToString(md5Hash.Sum(nil))
+	sha256sum := hex.EncodeToString(sha256Hash.Sum(nil))
+	if (art.Size > 0 && counter.n != art.Size) || (art.MD5sum != "" && md5sum != art.MD5sum) || (art.SHA256sum != "" && sha256sum != art.SHA256sum) {
+		log.Warning("artifact.SaveFile> %s: got %d bytes (md5 %s, sha256 %s), expected %d bytes (md5 %s, sha256 %s)\n", art.Name, counter.n, md5sum, sha256sum, art.Size, art.MD5sum, art.SHA256sum)
+		if err := objectstore.DeleteArtifact(art); err != nil {
+			log.Warning("artifact.SaveFile> Cannot delete invalid artifact %s: %s\n", art.Name, err)
+		}
+		return sdk.ErrArtifactChecksumMismatch
+	}
+	art.Size = counter.n
+	art.MD5sum = md5sum
+	art.SHA256sum = sha256sum
+
 	if err = insertArtifact(tx, p.ID, a.ID, e.ID, art); err != nil {
 		return err
 	}
@@ -232,13 +294,36 @@ func SaveFile(db *sql.DB, p *sdk.Pipeline, a *sdk.Application, art sdk.Artifact,
 
 // StreamFile Stream artifact
 func StreamFile(w io.Writer, art sdk.Artifact) error {
+	return StreamFileFrom(w, art, 0)
+}
+
+// StreamFileFrom streams artifact content starting at offset
+func StreamFileFrom(w io.Writer, art sdk.Artifact, offset int64) error {
 	f, err := objectstore.FetchArtifact(art)
 	if err != nil {
 		return fmt.Errorf("cannot fetch artifact: %s", err)
 	}
 
+	if offset > 0 {
+		if _, err := io.CopyN(ioutil.Discard, f, offset); err != nil {
+			f.Close()
+			return fmt.Errorf("cannot seek artifact: %s", err)
+		}
+	}
+
 	if err := objectstore.StreamFile(w, f); err != nil {
 		return err
 	}
 	return f.Close()
 }
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
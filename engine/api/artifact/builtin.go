@@ -33,9 +33,36 @@ func CreateBuiltinArtifactActions(db *sql.DB) error {
 		}
 	}
 
+	// ArtifactDownload created before releases does not have release parameter
+	if err := addArtifactDownloadReleaseParameter(db); err != nil {
+		log.Warning("CreateBuiltinArtifactActions> addArtifactDownloadReleaseParameter err:%s", err.Error())
+		return err
+	}
+
 	return nil
 }
 
+var artifactDownloadReleaseParameter = sdk.Parameter{
+	Name:        "release",
+	Description: "Release from where artifacts will be downloaded (optional). If set, tag and pipeline are ignored",
+	Type:        sdk.StringParameter}
+
+func addArtifactDownloadReleaseParameter(db *sql.DB) error {
+	var actionID int64
+	if err := db.QueryRow(`SELECT id FROM action WHERE name = $1`, sdk.ArtifactDownload).Scan(&actionID); err != nil {
+		return err
+	}
+
+	var name string
+	query := `SELECT name FROM action_parameter WHERE action_id = $1 AND name = $2`
+	err := db.QueryRow(query, actionID, artifactDownloadReleaseParameter.Name).Scan(&name)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	return action.InsertActionParameter(db, actionID, artifactDownloadReleaseParameter)
+}
+
 func createBuiltinArtifactUploadAction(db *sql.DB) error {
 	upload := sdk.NewAction(sdk.ArtifactUpload)
 	upload.Type = sdk.BuiltinAction
@@ -83,6 +110,7 @@ func createBuiltinArtifactDownloadAction(db *sql.DB) error {
 		Name:        "application",
 		Description: "Application from where artifacts will be downloaded, generally {{.cds.application}}",
 		Type:        sdk.StringParameter})
+	dl.Parameter(artifactDownloadReleaseParameter)
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -0,0 +1,304 @@
+package artifact
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/objectstore"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// MaxChunkSize is the maximum size of a chunk accepted in an upload session
+const MaxChunkSize = 64 << 20
+
+// InsertUpload starts an upload session of artifact s.Name for given build
+func InsertUpload(db database.QueryExecuter, p *sdk.Pipeline, a *sdk.Application, e *sdk.Environment, s *sdk.ArtifactUploadSession) error {
+	if s.ChunkSize <= 0 || s.ChunkSize > MaxChunkSize || s.Size < 0 {
+		return sdk.ErrInvalidChunkSize
+	}
+
+	s.Pipeline = p.Name
+	s.Application = a.Name
+	s.Environment = e.Name
+	s.Created = time.Now()
+	s.Chunks = []sdk.ArtifactChunk{}
+
+	query := `INSERT INTO artifact_upload (upload_hash, pipeline_id, application_id, environment_id, build_number, tag, name, size, perm, md5sum, sha256sum, chunk_size, created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	_, err := db.Exec(query, s.ID, p.ID, a.ID, e.ID, s.BuildNumber, s.Tag, s.Name, s.Size, s.Perm, s.MD5sum, s.SHA256sum, s.ChunkSize, s.Created)
+	return err
+}
+
+// LoadUpload returns upload session identified by hash, with chunks received so far
+func LoadUpload(db database.Querier, hash string) (*sdk.ArtifactUploadSession, error) {
+	query := `SELECT artifact_upload.upload_hash, project.projectKey, pipeline.name, application.name, environment.name,
+		artifact_upload.build_number, artifact_upload.tag, artifact_upload.name, artifact_upload.size, artifact_upload.perm,
+		artifact_upload.md5sum, artifact_upload.sha256sum, artifact_upload.chunk_size, artifact_upload.created
+		FROM artifact_upload
+		JOIN pipeline ON artifact_upload.pipeline_id = pipeline.id
+		JOIN project ON pipeline.project_id = project.id
+		JOIN application ON application.id = artifact_upload.application_id
+		JOIN environment ON environment.id = artifact_upload.environment_id
+		WHERE artifact_upload.upload_hash = $1`
+
+	s := &sdk.ArtifactUploadSession{}
+	var md5sum, sha256sum sql.NullString
+	err := db.QueryRow(query, hash).Scan(&s.ID, &s.Project, &s.Pipeline, &s.Application, &s.Environment,
+		&s.BuildNumber, &s.Tag, &s.Name, &s.Size, &s.Perm, &md5sum, &sha256sum, &s.ChunkSize, &s.Created)
+	if err == sql.ErrNoRows {
+		return nil, sdk.ErrArtifactUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if md5sum.Valid {
+		s.MD5sum = md5sum.String
+	}
+	if sha256sum.Valid {
+		s.SHA256sum = sha256sum.String
+	}
+
+	query = `SELECT chunk_index, size, sha256sum, object_path FROM artifact_upload_chunk
+		WHERE upload_id = (SELECT id FROM artifact_upload WHERE upload_hash = $1)
+		ORDER BY chunk_index`
+	rows, err := db.Query(query, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s.Chunks = []sdk.ArtifactChunk{}
+	for rows.Next() {
+		c := sdk.ArtifactChunk{Upload: s.ID}
+		var objectpath sql.NullString
+		if err := rows.Scan(&c.Index, &c.Size, &c.SHA256sum, &objectpath); err != nil {
+			return nil, err
+		}
+		if objectpath.Valid {
+			c.ObjectPath = objectpath.String
+		}
+		s.Chunks = append(s.Chunks, c)
+	}
+
+	return s, nil
+}
+
+// StoreChunk checks chunk content against its expected size and c.SHA256sum, then stores it
+func StoreChunk(db database.Executer, s *sdk.ArtifactUploadSession, c *sdk.ArtifactChunk, content io.Reader) error {
+	if c.Index < 0 || c.Index >= s.ChunkCount() {
+		return sdk.ErrInvalidID
+	}
+	c.Upload = s.ID
+
+	// Chunks are small enough to be checked in memory before being stored
+	expected := s.ChunkLength(c.Index)
+	data, err := ioutil.ReadAll(io.LimitReader(content, expected+1))
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != expected || hex.EncodeToString(sum[:]) != c.SHA256sum {
+		log.Warning("artifact.StoreChunk> %s chunk %d: got %d bytes (sha256 %x), expected %d bytes (sha256 %s)\n", s.ID, c.Index, len(data), sum, expected, c.SHA256sum)
+		return sdk.ErrArtifactChecksumMismatch
+	}
+	c.Size = expected
+
+	c.ObjectPath, err = objectstore.StoreArtifactChunk(*c, ioutil.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM artifact_upload_chunk WHERE upload_id = (SELECT id FROM artifact_upload WHERE upload_hash = $1) AND chunk_index = $2`
+	if _, err := db.Exec(query, s.ID, c.Index); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO artifact_upload_chunk (upload_id, chunk_index, size, sha256sum, object_path)
+		VALUES ((SELECT id FROM artifact_upload WHERE upload_hash = $1), $2, $3, $4, $5)`
+	_, err = db.Exec(query, s.ID, c.Index, c.Size, c.SHA256sum, c.ObjectPath)
+	return err
+}
+
+// CompleteUpload checks received chunks against whole artifact size and sha256sum,
+// then stores the artifact and records it. Upload session is deleted on success.
+func CompleteUpload(db *sql.DB, s *sdk.ArtifactUploadSession, downloadHash string) (*sdk.Artifact, error) {
+	if len(s.Chunks) != s.ChunkCount() {
+		return nil, sdk.ErrArtifactUploadIncomplete
+	}
+
+	// First pass: check the whole content before touching existing artifact
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), &chunksReader{chunks: s.Chunks})
+	if err != nil {
+		return nil, err
+	}
+	md5sum := hex.EncodeToString(md5Hash.Sum(nil))
+	sha256sum := hex.EncodeToString(sha256Hash.Sum(nil))
+	if n != s.Size || sha256sum != s.SHA256sum || (s.MD5sum != "" && md5sum != s.MD5sum) {
+		log.Warning("artifact.CompleteUpload> %s: got %d bytes (sha256 %s), expected %d bytes (sha256 %s)\n", s.ID, n, sha256sum, s.Size, s.SHA256sum)
+		return nil, sdk.ErrArtifactChecksumMismatch
+	}
+
+	art := &sdk.Artifact{
+		Name:         s.Name,
+		Project:      s.Project,
+		Pipeline:     s.Pipeline,
+		Application:  s.Application,
+		Environment:  s.Environment,
+		Tag:          s.Tag,
+		BuildNumber:  s.BuildNumber,
+		DownloadHash: downloadHash,
+		Size:         s.Size,
+		Perm:         s.Perm,
+		MD5sum:       md5sum,
+		SHA256sum:    sha256sum,
+	}
+
+	var pipelineID, applicationID, environmentID int64
+	query := `SELECT pipeline_id, application_id, environment_id FROM artifact_upload WHERE upload_hash = $1`
+	if err := db.QueryRow(query, s.ID).Scan(&pipelineID, &applicationID, &environmentID); err != nil {
+		return nil, err
+	}
+
+	// Second pass: store it
+	art.ObjectPath, err = objectstore.StoreArtifact(*art, ioutil.NopCloser(&chunksReader{chunks: s.Chunks}))
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := insertArtifact(tx, pipelineID, applicationID, environmentID, *art); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM artifact_upload WHERE upload_hash = $1`, s.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Chunks are only dropped once the artifact is committed, so a failed commit can be retried
+	if err := deleteChunks(s); err != nil {
+		log.Warning("artifact.CompleteUpload> Cannot delete chunks of %s: %s\n", s.ID, err)
+	}
+
+	return art, nil
+}
+
+// DeleteUpload removes chunks of upload session from objectstore, then session from database
+func DeleteUpload(db database.Executer, s *sdk.ArtifactUploadSession) error {
+	if err := deleteChunks(s); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`DELETE FROM artifact_upload WHERE upload_hash = $1`, s.ID)
+	return err
+}
+
+func deleteChunks(s *sdk.ArtifactUploadSession) error {
+	for _, c := range s.Chunks {
+		err := objectstore.DeleteArtifactChunk(c)
+		// If it's 404, it's lost anyway...
+		if err != nil && !strings.Contains(err.Error(), "404") && !strings.Contains(err.Error(), "no such file") {
+			return err
+		}
+	}
+	return nil
+}
+
+// UploadCleaner deletes upload sessions not completed after a day
+func UploadCleaner() {
+	// If this goroutine exits, then it's a crash
+	defer log.Fatalf("Goroutine of artifact.UploadCleaner exited - Exit CDS Engine")
+
+	for {
+		time.Sleep(10 * time.Minute)
+		db := database.DB()
+		if db != nil {
+			if err := purgeUploads(db, time.Now().Add(-24*time.Hour)); err != nil {
+				log.Warning("UploadCleaner> Cannot purge uploads: %s\n", err)
+			}
+		}
+	}
+}
+
+// purgeUploads deletes upload sessions started before given date
+func purgeUploads(db *sql.DB, before time.Time) error {
+	rows, err := db.Query(`SELECT upload_hash FROM artifact_upload WHERE created < $1`, before)
+	if err != nil {
+		return err
+	}
+	var hashes []string
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			rows.Close()
+			return err
+		}
+		hashes = append(hashes, h)
+	}
+	rows.Close()
+
+	for _, h := range hashes {
+		s, err := LoadUpload(db, h)
+		if err != nil {
+			return err
+		}
+		log.Notice("artifact.PurgeUploads> Deleting upload %s of %s/%s started %s\n", s.ID, s.Pipeline, s.Name, s.Created)
+		if err := DeleteUpload(db, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunksReader reads chunks content from objectstore one after another
+type chunksReader struct {
+	chunks  []sdk.ArtifactChunk
+	current io.ReadCloser
+}
+
+func (r *chunksReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			f, err := objectstore.FetchArtifactChunk(r.chunks[0])
+			if err != nil {
+				return 0, err
+			}
+			r.current = f
+			r.chunks = r.chunks[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseRangeOffset(t *testing.T) {
+	offset, err := parseRangeOffset("bytes=1024-", 4096)
+	if err != nil {
+		t.Fatalf("parseRangeOffset should not fail: %s", err)
+	}
+	if offset != 1024 {
+		t.Fatalf("offset should be 1024, got %d", offset)
+	}
+
+	for _, rg := range []string{"bytes=4096-", "bytes=0-10", "items=10-", "bytes=-10"} {
+		if _, err := parseRangeOffset(rg, 4096); err == nil {
+			t.Fatalf("parseRangeOffset should fail on %s", rg)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/artifact"
+	"github.com/ovh/cds/engine/api/context"
+	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/permission"
+	"github.com/ovh/cds/engine/api/pipeline"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+func startArtifactUploadHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	project := vars["key"]
+	pipelineName := vars["permPipelineKey"]
+	appName := vars["permApplicationName"]
+	tag := vars["tag"]
+	buildNumberString := vars["buildNumber"]
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Warning("startArtifactUploadHandler> Cannot read body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var s sdk.ArtifactUploadSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		log.Warning("startArtifactUploadHandler> Cannot unmarshal upload: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if s.Name == "" {
+		log.Warning("startArtifactUploadHandler> artifact name is not set\n")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	p, err := pipeline.LoadPipeline(db, project, pipelineName, false)
+	if err != nil {
+		log.Warning("startArtifactUploadHandler> cannot load pipeline %s-%s: %s\n", project, pipelineName, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	a, err := application.LoadApplicationByName(db, project, appName)
+	if err != nil {
+		log.Warning("startArtifactUploadHandler> cannot load application %s-%s: %s\n", project, appName, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var env *sdk.Environment
+	if s.Environment == "" || s.Environment == sdk.DefaultEnv.Name {
+		env = &sdk.DefaultEnv
+	} else {
+		env, err = environment.LoadEnvironmentByName(db, project, s.Environment)
+		if err != nil {
+			log.Warning("startArtifactUploadHandler> Cannot load environment %s: %s\n", s.Environment, err)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	if env.ID != sdk.DefaultEnv.ID && !permission.AccessToEnvironment(env.ID, c.User, permission.PermissionReadExecute) {
+		log.Warning("startArtifactUploadHandler> No enought right on this environment %s: \n", s.Environment)
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	s.BuildNumber, err = strconv.Atoi(buildNumberString)
+	if err != nil {
+		log.Warning("startArtifactUploadHandler> BuildNumber must be an integer: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.ID, err = generateHash()
+	if err != nil {
+		log.Warning("startArtifactUploadHandler> Could not generate hash: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+	s.Project = project
+	s.Tag = tag
+
+	if err := artifact.InsertUpload(db, p, a, env, &s); err != nil {
+		log.Warning("startArtifactUploadHandler> Cannot start upload of %s: %s\n", s.Name, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, s, http.StatusCreated)
+}
+
+func getArtifactUploadHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	s, err := loadArtifactUpload(db, r)
+	if err != nil {
+		log.Warning("getArtifactUploadHandler> Cannot load upload: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, s, http.StatusOK)
+}
+
+func uploadArtifactChunkHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	defer r.Body.Close()
+
+	s, err := loadArtifactUpload(db, r)
+	if err != nil {
+		log.Warning("uploadArtifactChunkHandler> Cannot load upload: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	index, err := strconv.Atoi(vars["chunk"])
+	if err != nil {
+		log.Warning("uploadArtifactChunkHandler> Chunk must be an integer: %s\n", err)
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	chunk := &sdk.ArtifactChunk{
+		Index:     index,
+		SHA256sum: r.Header.Get(sdk.ArtifactChunkSHA256),
+	}
+	if err := artifact.StoreChunk(db, s, chunk, r.Body); err != nil {
+		log.Warning("uploadArtifactChunkHandler> Cannot store chunk %d of %s: %s\n", index, s.Name, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, chunk, http.StatusOK)
+}
+
+func completeArtifactUploadHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	s, err := loadArtifactUpload(db, r)
+	if err != nil {
+		log.Warning("completeArtifactUploadHandler> Cannot load upload: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	hash, err := generateHash()
+	if err != nil {
+		log.Warning("completeArtifactUploadHandler> Could not generate hash: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	art, err := artifact.CompleteUpload(db, s, hash)
+	if err != nil {
+		log.Warning("completeArtifactUploadHandler> Cannot complete upload of %s: %s\n", s.Name, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, art, http.StatusCreated)
+}
+
+// loadArtifactUpload loads upload session in url, checking it belongs to the pipeline in url
+func loadArtifactUpload(db *sql.DB, r *http.Request) (*sdk.ArtifactUploadSession, error) {
+	vars := mux.Vars(r)
+
+	s, err := artifact.LoadUpload(db, vars["uploadID"])
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Project != vars["key"] || s.Application != vars["permApplicationName"] || s.Pipeline != vars["permPipelineKey"] {
+		return nil, sdk.ErrArtifactUploadNotFound
+	}
+
+	return s, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/branch"
+	"github.com/ovh/cds/engine/api/context"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+func getBranchLifecycleHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+
+	a, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("getBranchLifecycleHandler> cannot load application %s/%s: %s\n", projectKey, appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	l, err := branch.LoadLifecycle(db, a.ID)
+	if err != nil {
+		log.Warning("getBranchLifecycleHandler> cannot load branch lifecycle: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, l, http.StatusOK)
+}
+
+func updateBranchLifecycleHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+
+	a, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("updateBranchLifecycleHandler> cannot load application %s/%s: %s\n", projectKey, appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Warning("updateBranchLifecycleHandler> Cannot read body: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	var l sdk.BranchLifecycle
+	if err := json.Unmarshal(data, &l); err != nil {
+		log.Warning("updateBranchLifecycleHandler> Cannot unmarshal body: %s\n", err)
+		WriteError(w, r, sdk.ErrInvalidBranchLifecycle)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Warning("updateBranchLifecycleHandler> Cannot start transaction: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := branch.UpdateLifecycle(tx, projectKey, a.ID, l); err != nil {
+		log.Warning("updateBranchLifecycleHandler> Cannot update branch lifecycle: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Warning("updateBranchLifecycleHandler> Cannot commit transaction: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, l, http.StatusOK)
+}
+
+func getApplicationTrackedBranchesHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+
+	a, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("getApplicationTrackedBranchesHandler> cannot load application %s/%s: %s\n", projectKey, appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	branches, err := branch.LoadBranches(db, a.ID)
+	if err != nil {
+		log.Warning("getApplicationTrackedBranchesHandler> cannot load branches: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, branches, http.StatusOK)
+}
@@ -0,0 +1,340 @@
+package branch
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"fmt"
+	"regexp"
+
+	"github.com/lib/pq"
+
+	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/group"
+	"github.com/ovh/cds/engine/api/pipeline"
+	"github.com/ovh/cds/engine/api/scheduler"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// EnvironmentName returns the name of the environment created from template for branch of an application.
+// It is suffixed by a hash of both, as branches of several applications, or branches only differing by
+// unsafe characters, would share the same name otherwise
+func EnvironmentName(template string, applicationID int64, branch string) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("%d/%s", applicationID, branch)))
+	return fmt.Sprintf("%s-%s-%x", template, unsafeChars.ReplaceAllString(branch, "-"), sum[:4])
+}
+
+// Pushed records a push on a branch of an application. On the first push, or when the branch has no environment yet,
+// an environment is created from the template of the branch lifecycle
+func Pushed(db *sql.DB, applicationID int64, name string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Concurrent pushes of a new branch insert it only once, the row is then locked by everyone in turn
+	query := `INSERT INTO application_branch (application_id, branch, created) VALUES ($1, $2, current_timestamp)
+		ON CONFLICT (application_id, branch) DO NOTHING`
+	if _, err := tx.Exec(query, applicationID, name); err != nil {
+		return err
+	}
+
+	var id int64
+	var envID sql.NullInt64
+	query = `SELECT id, environment_id FROM application_branch WHERE application_id = $1 AND branch = $2 FOR UPDATE`
+	if err := tx.QueryRow(query, applicationID, name).Scan(&id, &envID); err != nil {
+		return err
+	}
+
+	// Branch may have been created again before being cleaned up, or missed by a poller
+	query = `UPDATE application_branch SET deleted = NULL, missing_since = NULL WHERE id = $1 AND (deleted IS NOT NULL OR missing_since IS NOT NULL)`
+	if _, err := tx.Exec(query, id); err != nil {
+		return err
+	}
+
+	if !envID.Valid {
+		l, err := LoadLifecycle(tx, applicationID)
+		if err != nil {
+			return err
+		}
+		if l.EnvironmentTemplate != "" {
+			projectID, projectKey, err := loadProject(tx, applicationID)
+			if err != nil {
+				return err
+			}
+			env, err := createEnvironment(tx, projectID, projectKey, l.EnvironmentTemplate, applicationID, name)
+			if err != nil {
+				return err
+			}
+			log.Notice("branch.Pushed> Environment %s created for branch %s of application %d\n", env.Name, name, applicationID)
+
+			query = `UPDATE application_branch SET environment_id = $1 WHERE id = $2`
+			if _, err := tx.Exec(query, env.ID, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Deleted records the deletion of a branch of an application and triggers the cleanup pipeline of the branch lifecycle,
+// in the environment of the branch if any. Nothing is done if the branch is already known as deleted
+func Deleted(db *sql.DB, applicationID int64, name string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Branch may have been pushed before its application was tracked, still archive its builds
+	query := `INSERT INTO application_branch (application_id, branch, created) VALUES ($1, $2, current_timestamp)
+		ON CONFLICT (application_id, branch) DO NOTHING`
+	if _, err := tx.Exec(query, applicationID, name); err != nil {
+		return err
+	}
+
+	var id int64
+	var envID sql.NullInt64
+	var deleted pq.NullTime
+	query = `SELECT id, environment_id, deleted FROM application_branch WHERE application_id = $1 AND branch = $2 FOR UPDATE`
+	if err := tx.QueryRow(query, applicationID, name).Scan(&id, &envID, &deleted); err != nil {
+		return err
+	}
+	if deleted.Valid {
+		return nil
+	}
+
+	query = `UPDATE application_branch SET deleted = current_timestamp, missing_since = NULL WHERE id = $1`
+	if _, err := tx.Exec(query, id); err != nil {
+		return err
+	}
+
+	l, err := LoadLifecycle(tx, applicationID)
+	if err != nil {
+		return err
+	}
+	if l.CleanupPipeline != "" {
+		if err := runCleanup(tx, applicationID, l.CleanupPipeline, envID, name); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DetectDeleted records the deletion of the branches of an application which are not in the given branches of its repository.
+// A branch is only deleted once it has been missing from two consecutive listings
+func DetectDeleted(db *sql.DB, applicationID int64, branches []sdk.VCSBranch) error {
+	// An empty repository is more likely an error of the repositories manager
+	if len(branches) == 0 {
+		return nil
+	}
+
+	tracked, err := loadTrackedBranches(db, applicationID)
+	if err != nil {
+		return err
+	}
+
+	missing, deleted, found := detect(tracked, branches)
+	for _, b := range missing {
+		query := `UPDATE application_branch SET missing_since = current_timestamp
+			WHERE application_id = $1 AND branch = $2 AND deleted IS NULL AND missing_since IS NULL`
+		if _, err := db.Exec(query, applicationID, b); err != nil {
+			return err
+		}
+	}
+	for _, b := range found {
+		query := `UPDATE application_branch SET missing_since = NULL WHERE application_id = $1 AND branch = $2`
+		if _, err := db.Exec(query, applicationID, b); err != nil {
+			return err
+		}
+	}
+	for _, b := range deleted {
+		log.Info("branch.DetectDeleted> Branch %s of application %d has been deleted\n", b, applicationID)
+		if err := Deleted(db, applicationID, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trackedBranch is a branch of an application not deleted yet, which may have been missing from the last listing of its repository
+type trackedBranch struct {
+	name    string
+	missing bool
+}
+
+func loadTrackedBranches(db database.Querier, applicationID int64) ([]trackedBranch, error) {
+	query := `SELECT branch, missing_since IS NOT NULL FROM application_branch WHERE application_id = $1 AND deleted IS NULL`
+	rows, err := db.Query(query, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracked []trackedBranch
+	for rows.Next() {
+		var t trackedBranch
+		if err := rows.Scan(&t.name, &t.missing); err != nil {
+			return nil, err
+		}
+		tracked = append(tracked, t)
+	}
+	return tracked, nil
+}
+
+// detect compares tracked branches with branches of the repository, known by their ID or display ID. It returns
+// branches missing for the first time, branches missing again which are deleted, and missing branches found again
+func detect(tracked []trackedBranch, branches []sdk.VCSBranch) (missing, deleted, found []string) {
+	for _, t := range tracked {
+		exists := false
+		for _, b := range branches {
+			if b.ID == t.name || b.DisplayID == t.name {
+				exists = true
+				break
+			}
+		}
+		switch {
+		case exists && t.missing:
+			found = append(found, t.name)
+		case !exists && t.missing:
+			deleted = append(deleted, t.name)
+		case !exists:
+			missing = append(missing, t.name)
+		}
+	}
+	return missing, deleted, found
+}
+
+// LoadBranches loads branches known for an application, deleted ones included until they are cleaned up
+func LoadBranches(db database.Querier, applicationID int64) ([]sdk.ApplicationBranch, error) {
+	query := `SELECT application_branch.branch, environment.name, application_branch.created, application_branch.deleted
+		FROM application_branch
+		LEFT JOIN environment ON environment.id = application_branch.environment_id
+		WHERE application_branch.application_id = $1
+		ORDER BY application_branch.branch`
+	rows, err := db.Query(query, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	branches := []sdk.ApplicationBranch{}
+	for rows.Next() {
+		var b sdk.ApplicationBranch
+		var env sql.NullString
+		var deleted pq.NullTime
+		if err := rows.Scan(&b.Branch, &env, &b.Created, &deleted); err != nil {
+			return nil, err
+		}
+		b.Environment = env.String
+		if deleted.Valid {
+			t := deleted.Time
+			b.Deleted = &t
+		}
+		branches = append(branches, b)
+	}
+	return branches, nil
+}
+
+func loadProject(db database.Querier, applicationID int64) (int64, string, error) {
+	var id int64
+	var key string
+	query := `SELECT project.id, project.projectKey FROM project
+		JOIN application ON application.project_id = project.id
+		WHERE application.id = $1`
+	err := db.QueryRow(query, applicationID).Scan(&id, &key)
+	return id, key, err
+}
+
+// createEnvironment copies variables and groups of template in a new environment for branch
+func createEnvironment(db *sql.Tx, projectID int64, projectKey, template string, applicationID int64, branch string) (*sdk.Environment, error) {
+	tmpl, err := environment.LoadEnvironmentByName(db, projectKey, template)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := environment.GetAllVariableByID(db, tmpl.ID, environment.WithClearPassword())
+	if err != nil {
+		return nil, err
+	}
+
+	env := &sdk.Environment{
+		Name:      EnvironmentName(template, applicationID, branch),
+		ProjectID: projectID,
+	}
+	if err := environment.InsertEnvironment(db, env); err != nil {
+		return nil, err
+	}
+
+	for i := range variables {
+		if err := environment.InsertVariable(db, env.ID, &variables[i]); err != nil {
+			return nil, err
+		}
+	}
+	for _, g := range tmpl.EnvironmentGroups {
+		if err := group.InsertGroupInEnvironment(db, env.ID, g.Group.ID, g.Permission); err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+// runCleanup triggers the cleanup pipeline for a deleted branch, build pipelines always run without environment
+func runCleanup(db *sql.Tx, applicationID int64, pipelineName string, envID sql.NullInt64, branch string) error {
+	app, err := application.LoadApplicationByID(db, applicationID)
+	if err != nil {
+		return err
+	}
+	_, projectKey, err := loadProject(db, applicationID)
+	if err != nil {
+		return err
+	}
+	p, err := pipeline.LoadPipeline(db, projectKey, pipelineName, false)
+	if err != nil {
+		return err
+	}
+
+	var branchEnv sql.NullString
+	if envID.Valid {
+		query := `SELECT name FROM environment WHERE id = $1`
+		if err := db.QueryRow(query, envID.Int64).Scan(&branchEnv); err != nil {
+			return err
+		}
+	}
+	envName, ok := cleanupEnvironment(p.Type, branchEnv)
+	if !ok {
+		log.Warning("branch.runCleanup> Cannot run %s pipeline %s without environment for branch %s of %s/%s\n", p.Type, p.Name, branch, projectKey, app.Name)
+		return nil
+	}
+
+	params := []sdk.Parameter{
+		{Name: "git.branch", Type: sdk.StringParameter, Value: branch},
+	}
+	trigger := sdk.PipelineBuildTrigger{
+		VCSChangesBranch: branch,
+	}
+	pb, err := scheduler.Run(db, projectKey, app, p.Name, envName, params, 0, trigger, nil)
+	if err != nil {
+		return err
+	}
+	log.Notice("branch.runCleanup> Pipeline %s/%s/%s #%d triggered for deleted branch %s\n", projectKey, app.Name, p.Name, pb.BuildNumber, branch)
+	return nil
+}
+
+// cleanupEnvironment returns the environment in which the cleanup pipeline of a branch runs: build pipelines always run
+// without environment, others in the environment of the branch, false is returned when it has none
+func cleanupEnvironment(pipelineType sdk.PipelineType, branchEnv sql.NullString) (string, bool) {
+	if pipelineType == sdk.BuildPipeline {
+		return sdk.DefaultEnv.Name, true
+	}
+	if !branchEnv.Valid {
+		return "", false
+	}
+	return branchEnv.String, true
+}
@@ -0,0 +1,140 @@
+package branch
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestEnvironmentName(t *testing.T) {
+	tests := map[string]string{
+		"master":            "staging-master-",
+		"feature/login":     "staging-feature-login-",
+		"fix/#42 crash@api": "staging-fix-42-crash-api-",
+		"release-1.2_rc":    "staging-release-1.2_rc-",
+	}
+	for b, prefix := range tests {
+		got := EnvironmentName("staging", 1, b)
+		if len(got) != len(prefix)+8 || got[:len(prefix)] != prefix {
+			t.Errorf("EnvironmentName(staging, 1, %s) = %s, want %s followed by a hash", b, got, prefix)
+		}
+		if got != EnvironmentName("staging", 1, b) {
+			t.Errorf("EnvironmentName(staging, 1, %s) is not stable", b)
+		}
+	}
+
+	if EnvironmentName("staging", 1, "feature/x") == EnvironmentName("staging", 1, "feature-x") {
+		t.Errorf("branches only differing by unsafe characters share the same environment")
+	}
+	if EnvironmentName("staging", 1, "master") == EnvironmentName("staging", 2, "master") {
+		t.Errorf("branches of different applications share the same environment")
+	}
+}
+
+func TestDetectDeletedSkipsEmptyRepository(t *testing.T) {
+	// Nothing is loaded nor deleted for an empty list of branches
+	if err := DetectDeleted(nil, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := DetectDeleted(nil, 1, []sdk.VCSBranch{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tracked := []trackedBranch{
+		{name: "refs/heads/master"},
+		{name: "develop"},
+		{name: "feature/a"},
+		{name: "feature/b", missing: true},
+		{name: "feature/c", missing: true},
+	}
+	branches := []sdk.VCSBranch{
+		{ID: "refs/heads/master", DisplayID: "master"},
+		{ID: "refs/heads/develop", DisplayID: "develop"},
+		{ID: "refs/heads/feature/c", DisplayID: "feature/c"},
+	}
+
+	missing, deleted, found := detect(tracked, branches)
+	if !reflect.DeepEqual(missing, []string{"feature/a"}) {
+		t.Errorf("missing = %v, want [feature/a]", missing)
+	}
+	if !reflect.DeepEqual(deleted, []string{"feature/b"}) {
+		t.Errorf("deleted = %v, want [feature/b]", deleted)
+	}
+	if !reflect.DeepEqual(found, []string{"feature/c"}) {
+		t.Errorf("found = %v, want [feature/c]", found)
+	}
+}
+
+func TestGracePeriodOver(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		deleted      time.Duration
+		hours        int
+		defaultHours int
+		expected     bool
+	}{
+		{2 * time.Hour, 0, 24, false},
+		{25 * time.Hour, 0, 24, true},
+		{2 * time.Hour, 1, 24, true},
+		{25 * time.Hour, 48, 24, false},
+		{49 * time.Hour, 48, 24, true},
+	}
+	for _, tt := range tests {
+		if got := gracePeriodOver(now.Add(-tt.deleted), tt.hours, tt.defaultHours, now); got != tt.expected {
+			t.Errorf("gracePeriodOver(%s ago, %d, %d) = %t, want %t", tt.deleted, tt.hours, tt.defaultHours, got, tt.expected)
+		}
+	}
+}
+
+func TestCleanupEnvironment(t *testing.T) {
+	staging := sql.NullString{String: "staging-feature-a-0a1b2c3d", Valid: true}
+	tests := []struct {
+		pipelineType sdk.PipelineType
+		env          sql.NullString
+		expected     string
+		ok           bool
+	}{
+		{sdk.BuildPipeline, staging, sdk.DefaultEnv.Name, true},
+		{sdk.BuildPipeline, sql.NullString{}, sdk.DefaultEnv.Name, true},
+		{sdk.DeploymentPipeline, staging, staging.String, true},
+		{sdk.TestingPipeline, staging, staging.String, true},
+		{sdk.DeploymentPipeline, sql.NullString{}, "", false},
+	}
+	for _, tt := range tests {
+		env, ok := cleanupEnvironment(tt.pipelineType, tt.env)
+		if env != tt.expected || ok != tt.ok {
+			t.Errorf("cleanupEnvironment(%s, %v) = %s, %t, want %s, %t", tt.pipelineType, tt.env, env, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestValidateLifecycle(t *testing.T) {
+	tests := []struct {
+		lifecycle sdk.BranchLifecycle
+		valid     bool
+	}{
+		{sdk.BranchLifecycle{}, true},
+		{sdk.BranchLifecycle{EnvironmentTemplate: "staging", CleanupPipeline: "cleanup", GracePeriod: 24}, true},
+		{sdk.BranchLifecycle{GracePeriod: -1}, false},
+		{sdk.BranchLifecycle{EnvironmentTemplate: sdk.DefaultEnv.Name}, false},
+	}
+	for _, tt := range tests {
+		err := validateLifecycle(tt.lifecycle)
+		if tt.valid && err != nil {
+			t.Errorf("validateLifecycle(%+v) = %s, want no error", tt.lifecycle, err)
+		}
+		if !tt.valid && err != sdk.ErrInvalidBranchLifecycle {
+			t.Errorf("validateLifecycle(%+v) = %v, want %s", tt.lifecycle, err, sdk.ErrInvalidBranchLifecycle)
+		}
+	}
+
+	// Invalid lifecycles are rejected before touching the database
+	if err := UpdateLifecycle(nil, "KEY", 1, sdk.BranchLifecycle{GracePeriod: -1}); err != sdk.ErrInvalidBranchLifecycle {
+		t.Errorf("UpdateLifecycle = %v, want %s", err, sdk.ErrInvalidBranchLifecycle)
+	}
+}
@@ -0,0 +1,187 @@
+package branch
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ovh/cds/engine/api/archivist"
+	"github.com/ovh/cds/engine/api/artifact"
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// Cleaner archives builds, deletes artifacts and destroys environments of branches deleted for more than their grace period,
+// in hours, once their builds are over
+func Cleaner(gracePeriod int) {
+
+	// If this goroutine exits, then it's a crash
+	defer log.Fatalf("Goroutine of branch.Cleaner exited - Exit CDS Engine")
+
+	for {
+		time.Sleep(1 * time.Minute)
+		db := database.DB()
+		if db == nil {
+			continue
+		}
+
+		if err := archiveBranches(db, gracePeriod); err != nil {
+			log.Warning("branch.Cleaner> Cannot archive deleted branches: %s\n", err)
+		}
+	}
+}
+
+func archiveBranches(db *sql.DB, gracePeriod int) error {
+	query := `SELECT application_branch.id, application_branch.deleted, COALESCE(application_branch_lifecycle.grace_period_hours, 0)
+		FROM application_branch
+		LEFT JOIN application_branch_lifecycle ON application_branch_lifecycle.application_id = application_branch.application_id
+		WHERE application_branch.deleted IS NOT NULL`
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	now := time.Now()
+	for rows.Next() {
+		var id int64
+		var deleted time.Time
+		var hours int
+		if err := rows.Scan(&id, &deleted, &hours); err != nil {
+			return err
+		}
+		if gracePeriodOver(deleted, hours, gracePeriod, now) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := archiveBranch(db, id); err != nil {
+			log.Warning("branch.archiveBranches> Cannot archive branch %d: %s\n", id, err)
+		}
+	}
+	return nil
+}
+
+// gracePeriodOver returns true when a branch deleted at the given time has been deleted for more than the grace period
+// of its lifecycle, or the default one when its lifecycle has none
+func gracePeriodOver(deleted time.Time, hours, defaultHours int, now time.Time) bool {
+	if hours <= 0 {
+		hours = defaultHours
+	}
+	return deleted.Add(time.Duration(hours) * time.Hour).Before(now)
+}
+
+// archiveBranch moves builds of a deleted branch to history, deletes their artifacts and forgets the branch.
+// Its environment is destroyed last, along with builds which ran in it. Artifacts are removed from storage
+// once everything is committed
+func archiveBranch(db *sql.DB, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The branch may have been pushed again in the meantime
+	var appID int64
+	var branch string
+	var envID sql.NullInt64
+	query := `SELECT application_id, branch, environment_id FROM application_branch
+		WHERE id = $1 AND deleted IS NOT NULL FOR UPDATE`
+	if err := tx.QueryRow(query, id).Scan(&appID, &branch, &envID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	// Wait for running builds, the cleanup pipeline for instance
+	var running int
+	query = `SELECT COUNT(id) FROM pipeline_build
+		WHERE ((application_id = $1 AND vcs_changes_branch = $2) OR environment_id = $3) AND status IN ($4, $5)`
+	if err := tx.QueryRow(query, appID, branch, envID, sdk.StatusWaiting.String(), sdk.StatusBuilding.String()).Scan(&running); err != nil {
+		return err
+	}
+	if running > 0 {
+		return nil
+	}
+
+	query = `SELECT artifact.id FROM artifact
+		JOIN (
+			SELECT pipeline_id, environment_id, build_number FROM pipeline_build
+			WHERE application_id = $1 AND vcs_changes_branch = $2
+			UNION
+			SELECT pipeline_id, environment_id, build_number FROM pipeline_history
+			WHERE application_id = $1 AND vcs_changes_branch = $2
+		) AS b ON b.pipeline_id = artifact.pipeline_id AND b.environment_id = artifact.environment_id AND b.build_number = artifact.build_number
+		WHERE artifact.application_id = $1
+		UNION
+		SELECT artifact.id FROM artifact WHERE artifact.environment_id = $3`
+	artifactIDs, err := loadIDs(tx, query, appID, branch, envID)
+	if err != nil {
+		return err
+	}
+	// Objects are only removed from storage once their rows deletion is committed
+	artifacts := make([]sdk.Artifact, 0, len(artifactIDs))
+	for _, artifactID := range artifactIDs {
+		a, err := artifact.DeleteArtifactRow(tx, artifactID)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, a)
+	}
+
+	query = `SELECT id FROM pipeline_build WHERE application_id = $1 AND vcs_changes_branch = $2`
+	buildIDs, err := loadIDs(tx, query, appID, branch)
+	if err != nil {
+		return err
+	}
+	for _, buildID := range buildIDs {
+		if err := archivist.ArchiveBuild(tx, buildID); err != nil {
+			return err
+		}
+	}
+
+	query = `DELETE FROM application_branch WHERE id = $1`
+	if _, err := tx.Exec(query, id); err != nil {
+		return err
+	}
+	if envID.Valid {
+		if err := environment.DeleteEnvironment(tx, envID.Int64); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Notice("branch.archiveBranch> Branch %s of application %d archived: %d builds, %d artifacts deleted\n", branch, appID, len(buildIDs), len(artifactIDs))
+
+	for _, a := range artifacts {
+		if err := artifact.DeleteArtifactObject(a); err != nil {
+			log.Warning("branch.archiveBranch> Cannot delete artifact %s/%s/%s/%s %s: %s\n", a.Project, a.Application, a.Environment, a.Pipeline, a.Name, err)
+		}
+	}
+	return nil
+}
+
+func loadIDs(db database.Querier, query string, args ...interface{}) ([]int64, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
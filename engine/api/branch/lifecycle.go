@@ -0,0 +1,77 @@
+package branch
+
+import (
+	"database/sql"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/pipeline"
+	"github.com/ovh/cds/sdk"
+)
+
+// LoadLifecycle loads the branch lifecycle of an application, empty if none has been set
+func LoadLifecycle(db database.Querier, applicationID int64) (sdk.BranchLifecycle, error) {
+	var l sdk.BranchLifecycle
+	query := `SELECT environment.name, pipeline.name, application_branch_lifecycle.grace_period_hours
+		FROM application_branch_lifecycle
+		LEFT JOIN environment ON environment.id = application_branch_lifecycle.environment_template_id
+		LEFT JOIN pipeline ON pipeline.id = application_branch_lifecycle.cleanup_pipeline_id
+		WHERE application_branch_lifecycle.application_id = $1`
+
+	var template, cleanup sql.NullString
+	var grace sql.NullInt64
+	err := db.QueryRow(query, applicationID).Scan(&template, &cleanup, &grace)
+	if err == sql.ErrNoRows {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+
+	l.EnvironmentTemplate = template.String
+	l.CleanupPipeline = cleanup.String
+	l.GracePeriod = int(grace.Int64)
+	return l, nil
+}
+
+// UpdateLifecycle replaces the branch lifecycle of an application, environment template and cleanup pipeline
+// must belong to the project of the application
+func UpdateLifecycle(db database.QueryExecuter, projectKey string, applicationID int64, l sdk.BranchLifecycle) error {
+	if err := validateLifecycle(l); err != nil {
+		return err
+	}
+
+	var templateID, cleanupID sql.NullInt64
+	if l.EnvironmentTemplate != "" {
+		env, err := environment.LoadEnvironmentByName(db, projectKey, l.EnvironmentTemplate)
+		if err != nil {
+			return err
+		}
+		templateID = sql.NullInt64{Int64: env.ID, Valid: true}
+	}
+	if l.CleanupPipeline != "" {
+		p, err := pipeline.LoadPipeline(db, projectKey, l.CleanupPipeline, false)
+		if err != nil {
+			return err
+		}
+		cleanupID = sql.NullInt64{Int64: p.ID, Valid: true}
+	}
+
+	query := `DELETE FROM application_branch_lifecycle WHERE application_id = $1`
+	if _, err := db.Exec(query, applicationID); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO application_branch_lifecycle (application_id, environment_template_id, cleanup_pipeline_id, grace_period_hours)
+		VALUES ($1, $2, $3, $4)`
+	_, err := db.Exec(query, applicationID, templateID, cleanupID, l.GracePeriod)
+	return err
+}
+
+// validateLifecycle checks a branch lifecycle does not have a negative grace period, nor NoEnv as environment template
+func validateLifecycle(l sdk.BranchLifecycle) error {
+	if l.GracePeriod < 0 || l.EnvironmentTemplate == sdk.DefaultEnv.Name {
+		return sdk.ErrInvalidBranchLifecycle
+	}
+	return nil
+}
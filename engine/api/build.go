@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/ovh/cds/engine/api/build"
 	"github.com/ovh/cds/engine/api/context"
 	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/notification"
 	"github.com/ovh/cds/engine/api/permission"
 	"github.com/ovh/cds/engine/api/pipeline"
 	"github.com/ovh/cds/engine/api/project"
@@ -428,6 +430,27 @@ func takeActionBuildHandler(w http.ResponseWriter, r *http.Request, db *sql.DB,
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if caller.Draining {
+		log.Info("takeActionBuildHandler> worker %s is draining\n", caller.ID)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Body is optional, sent by workers booked for an action build
+	var form worker.TakeForm
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Warning("takeActionBuildHandler> cannot read body: %s\n", err)
+		WriteError(w, r, sdk.ErrWrongRequest)
+		return
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &form); err != nil {
+			log.Warning("takeActionBuildHandler> cannot unmarshal body: %s\n", err)
+			WriteError(w, r, sdk.ErrWrongRequest)
+			return
+		}
+	}
 
 	// update database
 	ab, err := build.TakeActionBuild(db, id, caller)
@@ -448,6 +471,10 @@ func takeActionBuildHandler(w http.ResponseWriter, r *http.Request, db *sql.DB,
 
 	log.Debug("Updated %s (PipelineAction %d) to %s\n", id, ab.PipelineActionID, sdk.StatusBuilding)
 
+	if form.SpawnLatency > 0 && caller.Model != 0 {
+		stats.SpawnLatencyEvent(db, caller.Model, time.Duration(form.SpawnLatency)*time.Millisecond)
+	}
+
 	// load action and return it to worker
 	a, err := action.LoadActionByPipelineActionID(db, ab.PipelineActionID)
 	if err != nil {
@@ -813,10 +840,24 @@ func addBuildTestResultsHandler(w http.ResponseWriter, r *http.Request, db *sql.
 		tests.TotalSkipped += ts.Skip
 	}
 
+	// Highlight tests which were not failing in previous build of the branch
+	tests.NewFailures, err = build.NewFailures(db, &pb, tests.TestSuites)
+	if err != nil {
+		log.Warning("addBuildTestResultsHandler> Cannot compute new failures: %s\n", err)
+	}
+
 	err = build.UpdateTestResults(db, pb.ID, tests)
 	if err != nil {
 		log.Warning("addBuildTestsResultsHandler> Cannot insert tests results: %s\n", err)
 		WriteError(w, r, err)
+		return
+	}
+
+	err = build.UpdateTestCases(db, &pb, new.TestSuites)
+	if err != nil {
+		log.Warning("addBuildTestResultsHandler> Cannot insert tests history: %s\n", err)
+		WriteError(w, r, err)
+		return
 	}
 
 	stats.TestEvent(db, p.ProjectID, a.ID, tests)
@@ -913,3 +954,74 @@ func getBuildTestResultsHandler(w http.ResponseWriter, r *http.Request, db *sql.
 
 	WriteJSON(w, r, tests, http.StatusOK)
 }
+
+func getBuildWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	pipelineName := vars["permPipelineKey"]
+	appName := vars["permApplicationName"]
+
+	buildNumber, err := strconv.ParseInt(vars["build"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	var env *sdk.Environment
+	envName := r.FormValue("envName")
+	if envName == "" || envName == sdk.DefaultEnv.Name {
+		env = &sdk.DefaultEnv
+	} else {
+		env, err = environment.LoadEnvironmentByName(db, projectKey, envName)
+		if err != nil {
+			log.Warning("getBuildWebhookDeliveriesHandler> Cannot load environment %s: %s\n", envName, err)
+			WriteError(w, r, sdk.ErrUnknownEnv)
+			return
+		}
+	}
+
+	if env.ID != sdk.DefaultEnv.ID && !permission.AccessToEnvironment(env.ID, c.User, permission.PermissionRead) {
+		log.Warning("getBuildWebhookDeliveriesHandler> No enought right on this environment %s: \n", envName)
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	p, err := pipeline.LoadPipeline(db, projectKey, pipelineName, false)
+	if err != nil {
+		log.Warning("getBuildWebhookDeliveriesHandler> Cannot load pipeline %s: %s\n", pipelineName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	a, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("getBuildWebhookDeliveriesHandler> Cannot load application %s: %s\n", appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	pb, err := pipeline.LoadPipelineBuild(db, p.ID, a.ID, buildNumber, env.ID)
+	if err != nil {
+		if err != sdk.ErrNoPipelineBuild {
+			log.Warning("getBuildWebhookDeliveriesHandler> Cannot load pipeline build: %s\n", err)
+			WriteError(w, r, err)
+			return
+		}
+
+		pb, err = pipeline.LoadPipelineHistoryBuild(db, p.ID, a.ID, buildNumber, env.ID)
+		if err != nil {
+			log.Warning("getBuildWebhookDeliveriesHandler> Cannot load pipeline build from history: %s\n", err)
+			WriteError(w, r, sdk.ErrNoPipelineBuild)
+			return
+		}
+	}
+
+	deliveries, err := notification.LoadWebhookDeliveries(db, pb.ID)
+	if err != nil {
+		log.Warning("getBuildWebhookDeliveriesHandler> Cannot load webhook deliveries: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, deliveries, http.StatusOK)
+}
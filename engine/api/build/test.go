@@ -3,6 +3,7 @@ package build
 import (
 	"database/sql"
 	"encoding/json"
+	"time"
 
 	"github.com/ovh/cds/engine/api/database"
 	"github.com/ovh/cds/sdk"
@@ -99,6 +100,154 @@ func DeletePipelineTestResults(db database.Executer, pipID int64) error {
 	return nil
 }
 
+// UpdateTestCases records outcome of each test case of given suites in test history,
+// replacing results previously recorded for these suites in the same build
+func UpdateTestCases(db *sql.DB, pb *sdk.PipelineBuild, suites []sdk.TestSuite) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, s := range suites {
+		query := `DELETE FROM pipeline_build_test_case WHERE pipeline_build_id = $1 AND suite = $2`
+		if _, err := tx.Exec(query, pb.ID, s.Name); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO pipeline_build_test_case (pipeline_build_id, application_id, pipeline_id, environment_id, build_number, branch, hash, suite, name, status, duration, created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+		for _, t := range s.Tests {
+			if _, err := tx.Exec(query, pb.ID, pb.Application.ID, pb.Pipeline.ID, pb.Environment.ID, pb.BuildNumber,
+				pb.Trigger.VCSChangesBranch, pb.Trigger.VCSChangesHash, s.Name, t.Name, t.Status(), t.Time, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LoadTestCaseHistory retrieves the last results of a test case, most recent first
+func LoadTestCaseHistory(db database.Querier, appID, pipID, envID int64, suite, name string, limit int) (sdk.TestCaseHistory, error) {
+	h := sdk.TestCaseHistory{TestCaseRef: sdk.TestCaseRef{Suite: suite, Name: name}}
+
+	query := `SELECT build_number, branch, hash, status, duration, created FROM pipeline_build_test_case
+	WHERE application_id = $1 AND pipeline_id = $2 AND environment_id = $3 AND suite = $4 AND name = $5
+	ORDER BY build_number DESC, id DESC LIMIT $6`
+	rows, err := db.Query(query, appID, pipID, envID, suite, name, limit)
+	if err != nil {
+		return h, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r sdk.TestCaseResult
+		var branch, hash, duration sql.NullString
+		if err := rows.Scan(&r.BuildNumber, &branch, &hash, &r.Status, &duration, &r.Date); err != nil {
+			return h, err
+		}
+		r.Branch = branch.String
+		r.Hash = hash.String
+		r.Time = duration.String
+		h.Results = append(h.Results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return h, err
+	}
+
+	h.Flaky = isFlaky(h.Results)
+	return h, nil
+}
+
+// isFlaky returns true if a test both passed and failed on the same commit
+func isFlaky(results []sdk.TestCaseResult) bool {
+	status := map[string]string{}
+	for _, r := range results {
+		if r.Hash == "" || r.Status == sdk.TestStatusSkipped {
+			continue
+		}
+		if s, ok := status[r.Hash]; ok && s != r.Status {
+			return true
+		}
+		status[r.Hash] = r.Status
+	}
+	return false
+}
+
+// LoadFlakyTests retrieves test cases which both passed and failed on the same commit since given date
+func LoadFlakyTests(db database.Querier, appID, pipID, envID int64, since time.Time) ([]sdk.FlakyTest, error) {
+	query := `SELECT suite, name, hash,
+		SUM(CASE WHEN status = $4 THEN 1 ELSE 0 END) AS success,
+		SUM(CASE WHEN status = $5 THEN 1 ELSE 0 END) AS failures
+	FROM pipeline_build_test_case
+	WHERE application_id = $1 AND pipeline_id = $2 AND environment_id = $3 AND hash <> '' AND created > $6
+	GROUP BY suite, name, hash
+	HAVING SUM(CASE WHEN status = $4 THEN 1 ELSE 0 END) > 0 AND SUM(CASE WHEN status = $5 THEN 1 ELSE 0 END) > 0
+	ORDER BY suite, name`
+	rows, err := db.Query(query, appID, pipID, envID, sdk.TestStatusSuccess, sdk.TestStatusFail, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flaky := []sdk.FlakyTest{}
+	for rows.Next() {
+		var f sdk.FlakyTest
+		if err := rows.Scan(&f.Suite, &f.Name, &f.Hash, &f.Success, &f.Failures); err != nil {
+			return nil, err
+		}
+		flaky = append(flaky, f)
+	}
+	return flaky, rows.Err()
+}
+
+// NewFailures returns tests failing in given suites which did not fail in the previous build on the same branch.
+// Nothing is returned for the first build of a branch.
+func NewFailures(db database.Querier, pb *sdk.PipelineBuild, suites []sdk.TestSuite) ([]sdk.TestCaseRef, error) {
+	var previous sql.NullInt64
+	query := `SELECT MAX(build_number) FROM pipeline_build_test_case
+	WHERE application_id = $1 AND pipeline_id = $2 AND environment_id = $3 AND branch = $4 AND build_number < $5`
+	if err := db.QueryRow(query, pb.Application.ID, pb.Pipeline.ID, pb.Environment.ID, pb.Trigger.VCSChangesBranch, pb.BuildNumber).Scan(&previous); err != nil {
+		return nil, err
+	}
+	if !previous.Valid {
+		return nil, nil
+	}
+
+	query = `SELECT suite, name FROM pipeline_build_test_case
+	WHERE application_id = $1 AND pipeline_id = $2 AND environment_id = $3 AND build_number = $4 AND status = $5`
+	rows, err := db.Query(query, pb.Application.ID, pb.Pipeline.ID, pb.Environment.ID, previous.Int64, sdk.TestStatusFail)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failed := map[sdk.TestCaseRef]bool{}
+	for rows.Next() {
+		var t sdk.TestCaseRef
+		if err := rows.Scan(&t.Suite, &t.Name); err != nil {
+			return nil, err
+		}
+		failed[t] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var news []sdk.TestCaseRef
+	for _, s := range suites {
+		for _, t := range s.Tests {
+			ref := sdk.TestCaseRef{Suite: s.Name, Name: t.Name}
+			if t.Status() == sdk.TestStatusFail && !failed[ref] {
+				news = append(news, ref)
+			}
+		}
+	}
+	return news, nil
+}
+
 /*
 // DeleteApplicationPipelineTestResults removes from database test results for a specific pipeline linked to a specific application
 func DeleteApplicationPipelineTestResults(db database.Executer, appID int64, pipID int64) error {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 
@@ -51,10 +52,90 @@ func refreshHatcheryHandler(w http.ResponseWriter, r *http.Request, db *sql.DB,
 	vars := mux.Vars(r)
 	hatcheryID := vars["id"]
 
-	err := hatchery.RefreshHatchery(db, hatcheryID)
+	draining, err := hatchery.RefreshHatchery(db, hatcheryID)
 	if err != nil {
 		log.Warning("refreshHatcheryHandler> cannot refresh last beat of %s: %s\n", hatcheryID, err)
 		WriteError(w, r, err)
 		return
 	}
+
+	WriteJSON(w, r, hatchery.Hatchery{Draining: draining}, http.StatusOK)
+}
+
+func drainHatcheryHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	if !c.User.Admin {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	if err := hatchery.DrainHatchery(db, id); err != nil {
+		log.Warning("drainHatcheryHandler> cannot drain hatchery %d: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+	log.Notice("drainHatcheryHandler> hatchery %d is draining\n", id)
+}
+
+func undrainHatcheryHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	if !c.User.Admin {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	if err := hatchery.UndrainHatchery(db, id); err != nil {
+		log.Warning("undrainHatcheryHandler> cannot undrain hatchery %d: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+	log.Notice("undrainHatcheryHandler> hatchery %d is not draining anymore\n", id)
+}
+
+// unregisterHatcheryHandler is called by drained hatcheries once all their workers are gone
+func unregisterHatcheryHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	h, err := callerHatchery(db, c, id)
+	if err != nil {
+		log.Warning("unregisterHatcheryHandler> cannot load hatchery %d: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := hatchery.DeleteHatchery(db, h.ID, h.Model.ID); err != nil {
+		log.Warning("unregisterHatcheryHandler> cannot delete hatchery %d: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+	log.Notice("unregisterHatcheryHandler> hatchery %s unregistered\n", h.Name)
+}
+
+// callerHatchery returns hatchery id, which must be run by the authenticated user
+func callerHatchery(db *sql.DB, c *context.Context, id int64) (*hatchery.Hatchery, error) {
+	h, err := hatchery.LoadHatcheryByID(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if h.OwnerID != c.User.ID {
+		return nil, sdk.ErrForbidden
+	}
+	return h, nil
 }
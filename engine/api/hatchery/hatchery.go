@@ -19,6 +19,8 @@ type Hatchery struct {
 	OwnerID  int64     `json:"owner_id"`
 	LastBeat time.Time `json:"-"`
 	Model    sdk.Model `json:"model"`
+	// Draining hatcheries do not spawn workers anymore, their workers finish their jobs then unregister
+	Draining bool `json:"draining"`
 }
 
 // InsertHatchery registers in database new hatchery
@@ -117,6 +119,29 @@ func Exists(db *sql.DB, id int64) error {
 	return db.QueryRow(query, id).Scan(&id)
 }
 
+// LoadHatcheryByID retrieves in database hatchery with given id
+func LoadHatcheryByID(db *sql.DB, id int64) (*Hatchery, error) {
+	var h Hatchery
+	var draining sql.NullBool
+	var wmID sql.NullInt64
+	query := `SELECT id, name, last_beat, owner_id, draining, worker_model_id
+		FROM hatchery
+		LEFT JOIN hatchery_model ON hatchery_model.hatchery_id = hatchery.id
+		WHERE id = $1`
+	err := db.QueryRow(query, id).Scan(&h.ID, &h.Name, &h.LastBeat, &h.OwnerID, &draining, &wmID)
+	if err == sql.ErrNoRows {
+		return nil, sdk.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.Draining = draining.Bool
+	if wmID.Valid {
+		h.Model.ID = wmID.Int64
+	}
+	return &h, nil
+}
+
 // LoadDeadHatcheries load hatchery with refresh last beat > timeout
 func LoadDeadHatcheries(db *sql.DB, timeout float64) ([]Hatchery, error) {
 	var hatcheries []Hatchery
@@ -177,22 +202,74 @@ func LoadHatcheries(db *sql.DB) ([]Hatchery, error) {
 	return hatcheries, nil
 }
 
-// RefreshHatchery Update hatchery last_beat
-func RefreshHatchery(db *sql.DB, hatchID string) error {
-	query := `UPDATE hatchery SET last_beat = NOW() WHERE id = $1`
-	res, err := db.Exec(query, hatchID)
+// DrainHatchery stops hatchery from spawning workers, and drains its workers
+func DrainHatchery(db *sql.DB, id int64) error {
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
+	query := `UPDATE hatchery SET draining = true WHERE id = $1`
+	res, err := tx.Exec(query, id)
+	if err != nil {
+		return err
+	}
 	n, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return sdk.ErrNotFound
+	}
+
+	query = `UPDATE worker SET draining = true WHERE hatchery_id = $1`
+	if _, err := tx.Exec(query, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UndrainHatchery lets hatchery spawn workers again, and stops draining its workers
+func UndrainHatchery(db *sql.DB, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	query := `UPDATE hatchery SET draining = false WHERE id = $1`
+	res, err := tx.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
 	if n == 0 {
 		return sdk.ErrNotFound
 	}
 
-	return nil
+	query = `UPDATE worker SET draining = false WHERE hatchery_id = $1`
+	if _, err := tx.Exec(query, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RefreshHatchery Update hatchery last_beat, and returns whether hatchery is draining
+func RefreshHatchery(db *sql.DB, hatchID string) (bool, error) {
+	var draining sql.NullBool
+	query := `UPDATE hatchery SET last_beat = NOW() WHERE id = $1 RETURNING draining`
+	if err := db.QueryRow(query, hatchID).Scan(&draining); err != nil {
+		if err == sql.ErrNoRows {
+			return false, sdk.ErrNotFound
+		}
+		return false, err
+	}
+
+	return draining.Bool, nil
 }
@@ -7,21 +7,29 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/branch"
 	"github.com/ovh/cds/engine/api/cache"
 	"github.com/ovh/cds/engine/api/context"
 	"github.com/ovh/cds/engine/api/database"
 	"github.com/ovh/cds/engine/api/hook"
 	"github.com/ovh/cds/engine/api/pipeline"
 	"github.com/ovh/cds/engine/api/project"
+	"github.com/ovh/cds/engine/api/repositoriesmanager"
 	"github.com/ovh/cds/engine/log"
 	"github.com/ovh/cds/sdk"
 )
 
+const (
+	// maxHookDeliveries is the maximum number of hook deliveries listed at once
+	maxHookDeliveries = 200
+)
+
 func receiveHook(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
 	// Get body
 	data, err := ioutil.ReadAll(r.Body)
@@ -53,12 +61,21 @@ func receiveHook(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.
 		return
 	}
 
-	if err := processHook(rh); err != nil {
-		hook.Recovery(rh, err)
-		WriteError(w, r, err)
+	hooks, err := hook.ParseReceivedHook(r.Header, rh)
+	if err != nil {
+		log.Warning("receiveHook> cannot parse payload: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	for _, h := range hooks {
+		if _, err := processHook(h); err != nil {
+			hook.Recovery(h, err)
+			WriteError(w, r, err)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -81,6 +98,13 @@ func addHook(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Cont
 
 	h.Enabled = true
 
+	vars := mux.Vars(r)
+	if err := checkHookPathFilters(db, vars["key"], vars["permApplicationName"], &h); err != nil {
+		log.Warning("addHook: path filters cannot be checked: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
 	// Insert hook in database
 	err = hook.InsertHook(db, &h)
 	if err != nil {
@@ -110,6 +134,13 @@ func updateHookHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *co
 		return
 	}
 
+	vars := mux.Vars(r)
+	if err := checkHookPathFilters(db, vars["key"], vars["permApplicationName"], &h); err != nil {
+		log.Warning("updateHookHandler: path filters cannot be checked: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
 	// Update hook in database
 	err = hook.UpdateHook(db, h)
 	if err != nil {
@@ -119,6 +150,26 @@ func updateHookHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *co
 	}
 }
 
+// checkHookPathFilters rejects path filters of a hook when changed files cannot be fetched
+// from the repositories manager of the application
+func checkHookPathFilters(db *sql.DB, projectKey, appName string, h *sdk.Hook) error {
+	if len(h.IncludePaths) == 0 && len(h.ExcludePaths) == 0 {
+		return nil
+	}
+
+	a, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		return err
+	}
+	if a.RepositoriesManager == nil || a.RepositoryFullname == "" {
+		return sdk.ErrPathFiltersWithoutClient
+	}
+	if client, err := repositoriesmanager.AuthorizedClient(db, projectKey, a.RepositoriesManager.Name); err != nil || client == nil {
+		return sdk.ErrPathFiltersWithoutClient
+	}
+	return nil
+}
+
 func getApplicationHooksHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
 	vars := mux.Vars(r)
 	projectName := vars["key"]
@@ -198,13 +249,166 @@ func deleteHook(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.C
 	}
 }
 
+func getApplicationHookDeliveriesHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+
+	limit := 50
+	if l := r.FormValue("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			log.Warning("getApplicationHookDeliveriesHandler> Invalid limit %s\n", l)
+			WriteError(w, r, sdk.ErrWrongRequest)
+			return
+		}
+	}
+	if limit > maxHookDeliveries {
+		limit = maxHookDeliveries
+	}
+
+	a, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("getApplicationHookDeliveriesHandler> cannot load application %s/%s: %s\n", projectKey, appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	deliveries, err := hook.LoadApplicationReceivedHooks(db, a.ID, limit)
+	if err != nil {
+		log.Warning("getApplicationHookDeliveriesHandler> cannot load received hooks: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := loadHookDeliveriesHooks(db, a.ID, deliveries); err != nil {
+		log.Warning("getApplicationHookDeliveriesHandler> cannot load hooks: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, deliveries, http.StatusOK)
+}
+
+func getApplicationHookDeliveryHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	a, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("getApplicationHookDeliveryHandler> cannot load application %s/%s: %s\n", projectKey, appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	d, err := hook.LoadApplicationReceivedHook(db, a.ID, id)
+	if err != nil {
+		log.Warning("getApplicationHookDeliveryHandler> cannot load received hook %d: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	deliveries := []sdk.HookDelivery{d}
+	if err := loadHookDeliveriesHooks(db, a.ID, deliveries); err != nil {
+		log.Warning("getApplicationHookDeliveryHandler> cannot load hooks: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, deliveries[0], http.StatusOK)
+}
+
+func replayApplicationHookDeliveryHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	a, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("replayApplicationHookDeliveryHandler> cannot load application %s/%s: %s\n", projectKey, appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	d, err := hook.LoadApplicationReceivedHook(db, a.ID, id)
+	if err != nil {
+		log.Warning("replayApplicationHookDeliveryHandler> cannot load received hook %d: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	h, err := hook.Replay(d)
+	if err != nil {
+		log.Warning("replayApplicationHookDeliveryHandler> cannot replay received hook %d: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	// Failures are recorded in the new delivery
+	replayedID, err := processHook(h)
+	if replayedID == 0 {
+		log.Warning("replayApplicationHookDeliveryHandler> cannot process received hook %d: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	replayed, err := hook.LoadApplicationReceivedHook(db, a.ID, replayedID)
+	if err != nil {
+		log.Warning("replayApplicationHookDeliveryHandler> cannot load received hook %d: %s\n", replayedID, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	deliveries := []sdk.HookDelivery{replayed}
+	if err := loadHookDeliveriesHooks(db, a.ID, deliveries); err != nil {
+		log.Warning("replayApplicationHookDeliveryHandler> cannot load hooks: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, deliveries[0], http.StatusOK)
+}
+
+// loadHookDeliveriesHooks replaces hooks matched by deliveries, only known by their ID, with hooks of the application
+func loadHookDeliveriesHooks(db *sql.DB, applicationID int64, deliveries []sdk.HookDelivery) error {
+	hooks, err := hook.LoadApplicationHooks(db, applicationID)
+	if err != nil {
+		return err
+	}
+
+	for i := range deliveries {
+		for j := range deliveries[i].Hooks {
+			for _, h := range hooks {
+				if h.ID == deliveries[i].Hooks[j].ID {
+					deliveries[i].Hooks[j] = h
+				}
+			}
+		}
+	}
+	return nil
+}
+
 //hookRecoverer is the go-routine which catches on-error hook
 func hookRecoverer() {
 	for {
 		h := hook.ReceivedHook{}
 		cache.Dequeue("hook:recovery", &h)
 		if h.Repository != "" {
-			if err := processHook(h); err != nil {
+			if _, err := processHook(h); err != nil {
 				hook.Recovery(h, err)
 			}
 		}
@@ -212,57 +416,129 @@ func hookRecoverer() {
 	}
 }
 
-//processHook is the core function for hook processing
-func processHook(h hook.ReceivedHook) error {
+//processHook is the core function for hook processing.
+//The received hook is recorded with the hooks it matched, the builds it triggered or why it did not, its ID is returned
+func processHook(h hook.ReceivedHook) (id int64, err error) {
 	db := database.DB()
 	if db == nil {
-		return fmt.Errorf("database not available")
+		return 0, fmt.Errorf("database not available")
 	}
 
 	// Logging stuff
-	if err := hook.InsertReceivedHook(db, h.URL.String(), string(h.Data)); err != nil {
+	receivedID, err := hook.InsertReceivedHook(db, h)
+	if err != nil {
 		log.Warning("processHook> cannot insert received hook in db: %s\n", err)
-		return err
+		return 0, err
 	}
 
+	d := sdk.HookDelivery{ID: receivedID}
+	var applicationID int64
+	var skipReasons []string
+	defer func() {
+		if err != nil {
+			// Nothing has been committed
+			d.Builds = nil
+			d.Error = err.Error()
+		}
+		d.SkipReason = strings.Join(skipReasons, "; ")
+		if err := hook.UpdateReceivedHookResult(db, applicationID, d); err != nil {
+			log.Warning("processHook> cannot save result of received hook %d: %s\n", receivedID, err)
+		}
+	}()
+
 	// Actual search of hook binding
 	hooks, err := hook.LoadHooks(db, h.ProjectKey, h.Repository)
 	if err != nil {
 		log.Warning("processHook> cannot load hook for %s/%s: %s\n", h.ProjectKey, h.Repository, err)
-		return err
+		return receivedID, err
+	}
+	for i := range hooks {
+		if hooks[i].UID == h.UID {
+			applicationID = hooks[i].ApplicationID
+		}
 	}
 
-	// If branch is DELETE'd, remove all builds related to this branch
+	// If branch is DELETE'd, its builds are archived once the grace period of its application is over
 	if h.Message == "DELETE" {
-		log.Warning("processHook> Removing builds in %s/%s on branch %s\n", h.ProjectKey, h.Repository, h.Branch)
-		if err := hook.DeleteBranchBuilds(db, hooks, h.Branch); err != nil {
-			return err
+		log.Notice("processHook> Branch %s deleted in %s/%s\n", h.Branch, h.ProjectKey, h.Repository)
+		done := map[int64]bool{}
+		for i := range hooks {
+			if hooks[i].UID != h.UID {
+				continue
+			}
+			d.Hooks = append(d.Hooks, hooks[i])
+			if done[hooks[i].ApplicationID] {
+				continue
+			}
+			done[hooks[i].ApplicationID] = true
+			if err := branch.Deleted(db, hooks[i].ApplicationID, h.Branch); err != nil {
+				log.Warning("processHook> Cannot delete branch %s of application %d: %s\n", h.Branch, hooks[i].ApplicationID, err)
+				return receivedID, err
+			}
 		}
-		return nil
+		if len(done) == 0 {
+			log.Warning("processHook> Bad uid for hook [%s/%s], got uid='%s'", h.ProjectKey, h.Repository, h.UID)
+			return receivedID, sdk.ErrUnauthorized
+		}
+		skipReasons = append(skipReasons, fmt.Sprintf("branch %s deleted, its builds will be archived", h.Branch))
+		return receivedID, nil
 	}
 
-	log.Info("Executing %d hooks for %s/%s on branch %s\n", len(hooks), h.ProjectKey, h.Repository, h.Branch)
+	// Track pushed branches, before triggering pipelines which may use their environment
+	if h.Tag == "" {
+		done := map[int64]bool{}
+		for i := range hooks {
+			if hooks[i].UID != h.UID || done[hooks[i].ApplicationID] {
+				continue
+			}
+			done[hooks[i].ApplicationID] = true
+			if err := branch.Pushed(db, hooks[i].ApplicationID, h.Branch); err != nil {
+				log.Warning("processHook> Cannot track branch %s of application %d: %s\n", h.Branch, hooks[i].ApplicationID, err)
+			}
+		}
+	}
+
+	if h.Tag != "" {
+		log.Info("Executing %d hooks for %s/%s on tag %s\n", len(hooks), h.ProjectKey, h.Repository, h.Tag)
+	} else {
+		log.Info("Executing %d hooks for %s/%s on branch %s\n", len(hooks), h.ProjectKey, h.Repository, h.Branch)
+	}
 	found := false
 	//begin a tx
 	tx, err := db.Begin()
+	if err != nil {
+		return receivedID, err
+	}
 	defer tx.Rollback()
 
 	for i := range hooks {
-		if !hooks[i].Enabled {
-			continue
-		}
-
 		if hooks[i].UID != h.UID {
 			continue
 		}
 
 		found = true
+		d.Hooks = append(d.Hooks, hooks[i])
 
 		// create pipeline object
 		p, err := pipeline.LoadPipelineByID(tx, hooks[i].Pipeline.ID)
 		if err != nil {
 			log.Warning("processHook> Cannot load pipeline: %s\n", err)
-			return err
+			return receivedID, err
+		}
+
+		if !hooks[i].Enabled {
+			skipReasons = append(skipReasons, fmt.Sprintf("pipeline %s skipped: hook is disabled", p.Name))
+			continue
+		}
+
+		kind := sdk.PushEvent
+		if h.Tag != "" {
+			kind = sdk.TagEvent
+		}
+		if !sdk.HasEvent(hooks[i].Events, kind) {
+			log.Debug("processHook> Hook %d is not triggered by %s events\n", hooks[i].ID, kind)
+			skipReasons = append(skipReasons, fmt.Sprintf("pipeline %s skipped: hook is not triggered by %s events", p.Name, kind))
+			continue
 		}
 
 		// get Project
@@ -270,37 +546,43 @@ func processHook(h hook.ReceivedHook) error {
 		projectData, err := project.LoadProjectByPipelineID(tx, p.ID)
 		if err != nil {
 			log.Warning("processHook> Cannot load project for pipeline %s: %s\n", p.Name, err)
-			return err
+			return receivedID, err
 		}
 
 		projectsVar, err := project.GetAllVariableInProject(tx, projectData.ID)
 		if err != nil {
 			log.Warning("processHook> Cannot load project variable: %s\n", err)
-			return err
+			return receivedID, err
 		}
 		projectData.Variable = projectsVar
 
-		ok, err := hook.TriggerPipeline(tx, hooks[i], h.Branch, h.Hash, h.Author, p, projectData)
+		pb, reason, err := hook.TriggerPipeline(tx, hooks[i], h.Branch, h.Tag, h.Before, h.Hash, h.Author, p, projectData)
 		if err != nil {
 			log.Warning("processHook> cannot trigger pipeline %d: %s\n", hooks[i].Pipeline.ID, err)
-			return err
+			return receivedID, err
 		}
-		if ok {
+		if pb != nil {
 			log.Debug("processHook> Triggered %s/%s/%s", h.ProjectKey, h.Repository, h.Branch)
+			d.Builds = append(d.Builds, sdk.HookDeliveryBuild{
+				PipelineBuildID: pb.ID,
+				Pipeline:        p.Name,
+				BuildNumber:     pb.BuildNumber,
+			})
 		} else {
 			log.Notice("processHook> Did not trigger %s/%s/%s", h.ProjectKey, h.Repository, h.Branch)
+			skipReasons = append(skipReasons, fmt.Sprintf("pipeline %s skipped: %s", p.Name, reason))
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
 		log.Critical("processHook> Cannot commit tx; %s", err)
-		return err
+		return receivedID, err
 	}
 
 	if !found {
 		log.Warning("processHook> Bad uid for hook [%s/%s], got uid='%s'", h.ProjectKey, h.Repository, h.UID)
-		return sdk.ErrUnauthorized
+		return receivedID, sdk.ErrUnauthorized
 	}
 
-	return nil
+	return receivedID, nil
 }
@@ -0,0 +1,132 @@
+package hook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/sdk"
+)
+
+// InsertReceivedHook insert raw data received from public handler in database, with the details parsed from it
+func InsertReceivedHook(db database.QueryExecuter, h ReceivedHook) (int64, error) {
+	query := `INSERT INTO received_hook (link, data, created, uid, project_key, repository, branch, tag, hash, author, message)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+
+	var id int64
+	err := db.QueryRow(query, h.URL.String(), string(h.Data), time.Now(), h.UID, h.ProjectKey, h.Repository, h.Branch, h.Tag, h.Hash, h.Author, h.Message).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// UpdateReceivedHookResult records the application of the hook matched by a received hook, hooks matched, the builds it triggered,
+// and why pipelines were skipped or processing failed
+func UpdateReceivedHookResult(db database.Executer, applicationID int64, d sdk.HookDelivery) error {
+	query := `UPDATE received_hook SET application_id = $1, hooks = $2, builds = $3, skip_reason = $4, error = $5 WHERE id = $6`
+
+	ids := []int64{}
+	for _, h := range d.Hooks {
+		ids = append(ids, h.ID)
+	}
+	hooks, _ := json.Marshal(ids)
+	builds, _ := json.Marshal(d.Builds)
+
+	var application sql.NullInt64
+	if applicationID != 0 {
+		application = sql.NullInt64{Int64: applicationID, Valid: true}
+	}
+
+	_, err := db.Exec(query, application, string(hooks), string(builds), d.SkipReason, d.Error, d.ID)
+	return err
+}
+
+// LoadApplicationReceivedHooks loads the last hooks received for the application, newest first, without their payload.
+// Matched hooks are only loaded with their ID
+func LoadApplicationReceivedHooks(db database.Querier, applicationID int64, limit int) ([]sdk.HookDelivery, error) {
+	query := `SELECT id, link, '', created, project_key, repository, branch, tag, hash, author, message, hooks, builds, skip_reason, error
+		FROM received_hook
+		WHERE application_id = $1
+		ORDER BY id DESC
+		LIMIT $2`
+
+	rows, err := db.Query(query, applicationID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []sdk.HookDelivery{}
+	for rows.Next() {
+		d, err := scanReceivedHook(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// LoadApplicationReceivedHook loads a hook received for the application, with its payload
+func LoadApplicationReceivedHook(db database.Querier, applicationID, id int64) (sdk.HookDelivery, error) {
+	query := `SELECT id, link, data, created, project_key, repository, branch, tag, hash, author, message, hooks, builds, skip_reason, error
+		FROM received_hook
+		WHERE application_id = $1
+		AND id = $2`
+
+	d, err := scanReceivedHook(db.QueryRow(query, applicationID, id))
+	if err == sql.ErrNoRows {
+		return d, sdk.ErrNotFound
+	}
+	return d, err
+}
+
+func scanReceivedHook(s database.Scanner) (sdk.HookDelivery, error) {
+	var d sdk.HookDelivery
+	var hooks, builds, skipReason, errorMessage sql.NullString
+	err := s.Scan(&d.ID, &d.URL, &d.Payload, &d.Created, &d.Project, &d.Repository, &d.Branch, &d.Tag, &d.Hash, &d.Author, &d.Message, &hooks, &builds, &skipReason, &errorMessage)
+	if err != nil {
+		return d, err
+	}
+
+	d.Hooks = []sdk.Hook{}
+	if hooks.Valid {
+		ids := []int64{}
+		json.Unmarshal([]byte(hooks.String), &ids)
+		for _, id := range ids {
+			d.Hooks = append(d.Hooks, sdk.Hook{ID: id})
+		}
+	}
+	d.Builds = []sdk.HookDeliveryBuild{}
+	if builds.Valid {
+		json.Unmarshal([]byte(builds.String), &d.Builds)
+	}
+	d.SkipReason = skipReason.String
+	d.Error = errorMessage.String
+	return d, nil
+}
+
+// Replay returns the hook to process again a received hook
+func Replay(d sdk.HookDelivery) (ReceivedHook, error) {
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return ReceivedHook{}, err
+	}
+
+	return ReceivedHook{
+		URL:        *u,
+		Data:       []byte(d.Payload),
+		ProjectKey: d.Project,
+		Repository: d.Repository,
+		Branch:     d.Branch,
+		Tag:        d.Tag,
+		Hash:       d.Hash,
+		Author:     d.Author,
+		Message:    d.Message,
+		UID:        u.Query().Get("uid"),
+	}, nil
+}
@@ -0,0 +1,33 @@
+package hook
+
+import (
+	"testing"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestReplay(t *testing.T) {
+	d := sdk.HookDelivery{
+		ID:         12,
+		URL:        "/hook?uid=abc&project=ovh&name=api",
+		Payload:    `{"ref": "refs/heads/master"}`,
+		Project:    "ovh",
+		Repository: "api",
+		Branch:     "master",
+		Hash:       "9fceb02d0ae598e95dc970b74767f19372d61af8",
+		Author:     "jdoe",
+		Message:    "UPDATE",
+	}
+
+	h, err := Replay(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.UID != "abc" || h.URL.String() != d.URL || string(h.Data) != d.Payload {
+		t.Errorf("unexpected hook %+v", h)
+	}
+	if h.Branch != "master" || h.Hash != d.Hash || h.Author != "jdoe" || h.Message != "UPDATE" {
+		t.Errorf("unexpected hook %v", summary([]ReceivedHook{h}))
+	}
+
+}
@@ -4,13 +4,13 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"regexp"
 	"strings"
 
 	"github.com/ovh/cds/engine/api/application"
-	"github.com/ovh/cds/engine/api/artifact"
 	"github.com/ovh/cds/engine/api/cache"
 	"github.com/ovh/cds/engine/api/database"
 	"github.com/ovh/cds/engine/api/pipeline"
@@ -27,6 +27,8 @@ type ReceivedHook struct {
 	ProjectKey string
 	Repository string
 	Branch     string
+	Tag        string
+	Before     string
 	Hash       string
 	Author     string
 	Message    string
@@ -36,23 +38,15 @@ type ReceivedHook struct {
 // HookLink format in stash/bitbucket
 const HookLink = "/hook?uid=%s&project=%s&name=%s&branch=${refChange.name}&hash=${refChange.toHash}&message=${refChange.type}&author=${user.name}"
 
-// InsertReceivedHook insert raw data received from public handler in database
-func InsertReceivedHook(db *sql.DB, link string, data string) error {
-	query := `INSERT INTO received_hook (link, data) VALUES ($1, $2)`
-
-	_, err := db.Exec(query, link, data)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
+// HookLinkPayload format in repositories managers posting details of the push as JSON payload
+const HookLinkPayload = "/hook?uid=%s&project=%s&name=%s"
 
 // UpdateHook update the given hook
 func UpdateHook(db *sql.DB, h sdk.Hook) error {
-	query := `UPDATE hook set pipeline_id=$1, kind=$2, host=$3, project=$4, repository=$5, application_id=$6, enabled=$7 WHERE id=$8`
+	query := `UPDATE hook set pipeline_id=$1, kind=$2, host=$3, project=$4, repository=$5, application_id=$6, enabled=$7, include_paths=$8, exclude_paths=$9, events=$10 WHERE id=$11`
 
-	res, err := db.Exec(query, h.Pipeline.ID, h.Kind, h.Host, h.Project, h.Repository, h.ApplicationID, h.Enabled, h.ID)
+	include, exclude, events := marshalFilters(h)
+	res, err := db.Exec(query, h.Pipeline.ID, h.Kind, h.Host, h.Project, h.Repository, h.ApplicationID, h.Enabled, include, exclude, events, h.ID)
 	if err != nil {
 		return err
 	}
@@ -68,7 +62,7 @@ func UpdateHook(db *sql.DB, h sdk.Hook) error {
 
 // InsertHook add link between git repository and pipeline in database
 func InsertHook(db database.QueryExecuter, h *sdk.Hook) error {
-	query := `INSERT INTO hook (pipeline_id, kind, host, project, repository, application_id,enabled, uid) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+	query := `INSERT INTO hook (pipeline_id, kind, host, project, repository, application_id,enabled, uid, include_paths, exclude_paths, events) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
 
 	// Generate UID
 	uid, err := generateHash()
@@ -77,7 +71,8 @@ func InsertHook(db database.QueryExecuter, h *sdk.Hook) error {
 	}
 	h.UID = uid
 
-	err = db.QueryRow(query, h.Pipeline.ID, h.Kind, h.Host, h.Project, h.Repository, h.ApplicationID, h.Enabled, h.UID).Scan(&h.ID)
+	include, exclude, events := marshalFilters(*h)
+	err = db.QueryRow(query, h.Pipeline.ID, h.Kind, h.Host, h.Project, h.Repository, h.ApplicationID, h.Enabled, h.UID, include, exclude, events).Scan(&h.ID)
 	if err != nil {
 		return err
 	}
@@ -139,7 +134,7 @@ func DeleteHook(db database.QueryExecuter, id int64) error {
 // LoadApplicationHooks will load all hooks related to given application
 func LoadApplicationHooks(db database.Querier, applicationID int64) ([]sdk.Hook, error) {
 	hooks := []sdk.Hook{}
-	query := `SELECT hook.id, hook.kind, hook.host, hook.project, hook.repository, hook.enabled, hook.uid, pipeline.id, pipeline.name
+	query := `SELECT hook.id, hook.kind, hook.host, hook.project, hook.repository, hook.enabled, hook.uid, hook.include_paths, hook.exclude_paths, hook.events, pipeline.id, pipeline.name
 		  FROM hook
 		  JOIN pipeline ON pipeline.id = hook.pipeline_id
 		  WHERE application_id= $1
@@ -153,11 +148,13 @@ func LoadApplicationHooks(db database.Querier, applicationID int64) ([]sdk.Hook,
 
 	for rows.Next() {
 		var h sdk.Hook
+		var include, exclude, events sql.NullString
 		h.ApplicationID = applicationID
-		err = rows.Scan(&h.ID, &h.Kind, &h.Host, &h.Project, &h.Repository, &h.Enabled, &h.UID, &h.Pipeline.ID, &h.Pipeline.Name)
+		err = rows.Scan(&h.ID, &h.Kind, &h.Host, &h.Project, &h.Repository, &h.Enabled, &h.UID, &include, &exclude, &events, &h.Pipeline.ID, &h.Pipeline.Name)
 		if err != nil {
 			return hooks, err
 		}
+		unmarshalFilters(&h, include, exclude, events)
 		link := viper.GetString("api_url") + HookLink
 		h.Link = fmt.Sprintf(link, h.UID, h.Project, h.Repository)
 		hooks = append(hooks, h)
@@ -168,7 +165,7 @@ func LoadApplicationHooks(db database.Querier, applicationID int64) ([]sdk.Hook,
 
 // LoadPipelineHooks will load all hooks related to given pipeline
 func LoadPipelineHooks(db *sql.DB, pipelineID int64, applicationID int64) ([]sdk.Hook, error) {
-	query := `SELECT id, kind, host, project, repository, uid FROM hook WHERE pipeline_id = $1 AND application_id= $2`
+	query := `SELECT id, kind, host, project, repository, enabled, uid, include_paths, exclude_paths, events FROM hook WHERE pipeline_id = $1 AND application_id= $2`
 
 	rows, err := db.Query(query, pipelineID, applicationID)
 	if err != nil {
@@ -179,12 +176,14 @@ func LoadPipelineHooks(db *sql.DB, pipelineID int64, applicationID int64) ([]sdk
 	var hooks []sdk.Hook
 	for rows.Next() {
 		var h sdk.Hook
+		var include, exclude, events sql.NullString
 		h.Pipeline.ID = pipelineID
 		h.ApplicationID = applicationID
-		err = rows.Scan(&h.ID, &h.Kind, &h.Host, &h.Project, &h.Repository, &h.UID)
+		err = rows.Scan(&h.ID, &h.Kind, &h.Host, &h.Project, &h.Repository, &h.Enabled, &h.UID, &include, &exclude, &events)
 		if err != nil {
 			return nil, err
 		}
+		unmarshalFilters(&h, include, exclude, events)
 		hooks = append(hooks, h)
 	}
 
@@ -193,7 +192,7 @@ func LoadPipelineHooks(db *sql.DB, pipelineID int64, applicationID int64) ([]sdk
 
 // LoadHooks related to given repository
 func LoadHooks(db *sql.DB, project string, repository string) ([]sdk.Hook, error) {
-	query := `SELECT id, pipeline_id, application_id, kind, host, enabled, uid FROM hook WHERE project = $1 AND repository = $2`
+	query := `SELECT id, pipeline_id, application_id, kind, host, enabled, uid, include_paths, exclude_paths, events FROM hook WHERE project = $1 AND repository = $2`
 
 	rows, err := db.Query(query, project, repository)
 	if err != nil {
@@ -204,20 +203,46 @@ func LoadHooks(db *sql.DB, project string, repository string) ([]sdk.Hook, error
 	var hooks []sdk.Hook
 	for rows.Next() {
 		var h sdk.Hook
+		var include, exclude, events sql.NullString
 		h.Project = project
 		h.Repository = repository
-		err = rows.Scan(&h.ID, &h.Pipeline.ID, &h.ApplicationID, &h.Kind, &h.Host, &h.Enabled, &h.UID)
+		err = rows.Scan(&h.ID, &h.Pipeline.ID, &h.ApplicationID, &h.Kind, &h.Host, &h.Enabled, &h.UID, &include, &exclude, &events)
 		if err != nil {
 			return nil, err
 		}
+		unmarshalFilters(&h, include, exclude, events)
 		hooks = append(hooks, h)
 	}
 
 	return hooks, nil
 }
 
-// TriggerPipeline linked to received hook
-func TriggerPipeline(tx *sql.Tx, h sdk.Hook, branch string, hash string, author string, p *sdk.Pipeline, projectData *sdk.Project) (bool, error) {
+func marshalFilters(h sdk.Hook) (string, string, string) {
+	include, _ := json.Marshal(h.IncludePaths)
+	exclude, _ := json.Marshal(h.ExcludePaths)
+	events, _ := json.Marshal(h.Events)
+	return string(include), string(exclude), string(events)
+}
+
+func unmarshalFilters(h *sdk.Hook, include, exclude, events sql.NullString) {
+	if include.Valid {
+		json.Unmarshal([]byte(include.String), &h.IncludePaths)
+	}
+	if exclude.Valid {
+		json.Unmarshal([]byte(exclude.String), &h.ExcludePaths)
+	}
+	if events.Valid {
+		json.Unmarshal([]byte(events.String), &h.Events)
+	}
+}
+
+// TriggerPipeline linked to received hook. before is the previous hash of the branch, when known.
+// When a tag is given, git.branch is left empty so that the tag is checked out, and path filters are not applied.
+// The triggered build is returned, or the reason why the build is skipped
+func TriggerPipeline(tx *sql.Tx, h sdk.Hook, branch string, tag string, before string, hash string, author string, p *sdk.Pipeline, projectData *sdk.Project) (*sdk.PipelineBuild, string, error) {
+	if tag != "" {
+		branch = ""
+	}
 
 	// Create pipeline args
 	var args []sdk.Parameter
@@ -225,6 +250,10 @@ func TriggerPipeline(tx *sql.Tx, h sdk.Hook, branch string, hash string, author
 		Name:  "git.branch",
 		Value: branch,
 	})
+	args = append(args, sdk.Parameter{
+		Name:  "git.tag",
+		Value: tag,
+	})
 	args = append(args, sdk.Parameter{
 		Name:  "git.hash",
 		Value: hash,
@@ -249,18 +278,18 @@ func TriggerPipeline(tx *sql.Tx, h sdk.Hook, branch string, hash string, author
 	// Load pipeline Argument
 	parameters, err := pipeline.GetAllParametersInPipeline(tx, p.ID)
 	if err != nil {
-		return false, err
+		return nil, "", err
 	}
 	p.Parameter = parameters
 
 	// get application
 	a, err := application.LoadApplicationByID(tx, h.ApplicationID)
 	if err != nil {
-		return false, err
+		return nil, "", err
 	}
 	applicationPipelineArgs, err := application.GetAllPipelineParam(tx, h.ApplicationID, p.ID)
 	if err != nil {
-		return false, err
+		return nil, "", err
 	}
 
 	trigger := sdk.PipelineBuildTrigger{
@@ -286,7 +315,18 @@ func TriggerPipeline(tx *sql.Tx, h sdk.Hook, branch string, hash string, author
 				}
 				if match {
 					log.Notice("hook> Skipping build of %s/%s for commit %s by %s", projectData.Key, a.Name, hash, author)
-					return false, nil
+					return nil, "commit message contains [ci skip] or [cd skip]", nil
+				}
+
+				// Check files changed by the push against path filters of the hook
+				if tag == "" {
+					if before == "" {
+						before = previousHash(tx, a.ID, p.ID, branch)
+					}
+					if reason := repositoriesmanager.PathsSkipReason(client, a.RepositoryFullname, before, hash, h.IncludePaths, h.ExcludePaths); reason != "" {
+						log.Notice("hook> Skipping build of %s/%s for commit %s by %s: %s", projectData.Key, a.Name, hash, author, reason)
+						return nil, reason, nil
+					}
 				}
 			}
 		} else {
@@ -295,12 +335,25 @@ func TriggerPipeline(tx *sql.Tx, h sdk.Hook, branch string, hash string, author
 	}
 
 	// FIXME add possibility to trigger a pipeline on a specific env
-	_, err = pipeline.InsertPipelineBuild(tx, projectData, p, a, applicationPipelineArgs, args, &sdk.DefaultEnv, 0, trigger)
+	pb, err := pipeline.InsertPipelineBuild(tx, projectData, p, a, applicationPipelineArgs, args, &sdk.DefaultEnv, 0, trigger)
 	if err != nil {
-		return false, err
+		return nil, "", err
 	}
 
-	return true, nil
+	return &pb, "", nil
+}
+
+//previousHash returns the hash of the last build of the pipeline on the branch
+func previousHash(db database.Querier, appID, pipID int64, branch string) string {
+	pbs, err := pipeline.LoadPipelineBuildHistoryByApplicationAndPipeline(db, appID, pipID, sdk.DefaultEnv.ID, 1, "", branch)
+	if err != nil {
+		log.Warning("previousHash> Cannot load last build on branch %s: %s", branch, err)
+		return ""
+	}
+	if len(pbs) == 0 {
+		return ""
+	}
+	return pbs[0].Trigger.VCSChangesHash
 }
 
 func generateHash() (string, error) {
@@ -318,105 +371,6 @@ func generateHash() (string, error) {
 	return string(token), nil
 }
 
-// DeleteBranchBuilds deletes all builds related to given branch in given applications
-// in pipeline_build and pipeline_history
-func DeleteBranchBuilds(db *sql.DB, hooks []sdk.Hook, branch string) error {
-
-	for i := range hooks {
-		err := deleteBranchBuilds(db, hooks[i].ApplicationID, branch)
-		if err != nil {
-			log.Warning("DeleteBranchBuilds> Cannot delete branch builds for branch %s in %d\n", branch, hooks[i].ApplicationID)
-		}
-	}
-	return nil
-}
-
-func deleteBranchBuilds(db *sql.DB, appID int64, branch string) error {
-	var artIDs []int64
-
-	// Remove artifact and builds from history
-	query := `SELECT build_number, pipeline_id, environment_id
-		FROM pipeline_history WHERE vcs_changes_branch = $1 AND application_id = $2`
-	rows, err := db.Query(query, branch, appID)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	var pbs []sdk.PipelineBuild
-	for rows.Next() {
-		var pb sdk.PipelineBuild
-		err = rows.Scan(&pb.BuildNumber, &pb.Pipeline.ID, &pb.Environment.ID)
-		if err != nil {
-			return err
-		}
-		pbs = append(pbs, pb)
-	}
-	rows.Close()
-
-	// For each pipeline build in history, load and delete related artifacts
-	query = `SELECT id FROM artifact
-	WHERE build_number = $1
-	AND application_id = $2
-	AND pipeline_id = $3
-	AND environment_id = $4`
-	for _, pb := range pbs {
-		rows, err := db.Query(query, pb.BuildNumber, appID, pb.Pipeline.ID, pb.Environment.ID)
-		if err != nil {
-			return err
-		}
-		defer rows.Close()
-		for rows.Next() {
-			var id int64
-			err = rows.Scan(&id)
-			if err != nil {
-				return err
-			}
-			artIDs = append(artIDs, id)
-		}
-		rows.Close()
-	}
-
-	// Delete all related artifacts
-	for _, id := range artIDs {
-		err := artifact.DeleteArtifact(db, id)
-		if err != nil {
-			log.Warning("deleteBranchBuilds> Cannot delete artifact %d: %s\n", id, err)
-		}
-	}
-
-	// Now delete in pipeline_history
-	query = `DELETE FROM pipeline_history WHERE vcs_changes_branch = $1 AND application_id = $2`
-	_, err = db.Query(query, branch, appID)
-	if err != nil {
-		return err
-	}
-
-	// Now select all related build in pipeline build
-	query = `SELECT id	FROM pipeline_build WHERE vcs_changes_branch = $1 AND application_id = $2`
-	rows, err = db.Query(query, branch, appID)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var id int64
-		err = rows.Scan(&id)
-		if err != nil {
-			return err
-		}
-
-		err = pipeline.DeletePipelineBuild(db, id)
-		if err != nil {
-			log.Warning("deleteBranchBuilds> Cannot delete PipelineBuild %d: %s\n", id, err)
-		}
-	}
-	rows.Close()
-
-	return nil
-}
-
 // CreateHook in CDS db + repo manager webhook
 func CreateHook(tx *sql.Tx, projectKey string, rm *sdk.RepositoriesManager, repoFullName string, application *sdk.Application, pipeline *sdk.Pipeline) (*sdk.Hook, error) {
 	client, err := repositoriesmanager.AuthorizedClient(tx, projectKey, rm.Name)
@@ -454,8 +408,7 @@ func CreateHook(tx *sql.Tx, projectKey string, rm *sdk.RepositoriesManager, repo
 		return nil, err
 	}
 
-	s := viper.GetString("api_url") + HookLink
-	link := fmt.Sprintf(s, h.UID, t[0], t[1])
+	link := Link(rm.Type, h.UID, t[0], t[1])
 
 	h.Link = link
 
@@ -467,6 +420,15 @@ func CreateHook(tx *sql.Tx, projectKey string, rm *sdk.RepositoriesManager, repo
 	return &h, nil
 }
 
+// Link returns the URL called by repositories manager of given type on push
+func Link(t sdk.RepositoriesManagerType, uid, project, repository string) string {
+	s := viper.GetString("api_url") + HookLink
+	if t == sdk.BitbucketCloud || t == sdk.Gitea {
+		s = viper.GetString("api_url") + HookLinkPayload
+	}
+	return fmt.Sprintf(s, uid, project, repository)
+}
+
 //Recovery try to recovers hook in case of error
 func Recovery(h ReceivedHook, err error) {
 	log.Debug("hook.Recovery> %s", h.Repository)
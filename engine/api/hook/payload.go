@@ -0,0 +1,201 @@
+package hook
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Headers identifying webhooks sent by repositories managers posting a JSON payload
+const (
+	BitbucketCloudEventHeader = "X-Event-Key"
+	GiteaEventHeader          = "X-Gitea-Event"
+)
+
+// git sends this hash as new hash of deleted branches
+const nullHash = "0000000000000000000000000000000000000000"
+
+type bitbucketRef struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Target struct {
+		Hash    string `json:"hash"`
+		Message string `json:"message"`
+	} `json:"target"`
+}
+
+type bitbucketPush struct {
+	Actor struct {
+		Nickname string `json:"nickname"`
+		Username string `json:"username"`
+	} `json:"actor"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Push struct {
+		Changes []struct {
+			New     *bitbucketRef `json:"new"`
+			Old     *bitbucketRef `json:"old"`
+			Created bool          `json:"created"`
+			Closed  bool          `json:"closed"`
+		} `json:"changes"`
+	} `json:"push"`
+}
+
+type giteaEvent struct {
+	Ref        string `json:"ref"`
+	RefType    string `json:"ref_type"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Pusher struct {
+		Login    string `json:"login"`
+		Username string `json:"username"`
+	} `json:"pusher"`
+	Sender struct {
+		Login    string `json:"login"`
+		Username string `json:"username"`
+	} `json:"sender"`
+}
+
+// ParseReceivedHook reads the JSON payload of hooks sent by Bitbucket Cloud and Gitea.
+// Stash sends details of the push in query string: its hooks are returned as is, unless they push a tag.
+// A push may update several branches, each one is returned as a hook. Created tags are returned with their Tag set
+// and an empty Branch, deleted tags are ignored
+func ParseReceivedHook(header http.Header, h ReceivedHook) ([]ReceivedHook, error) {
+	if event := header.Get(BitbucketCloudEventHeader); event != "" {
+		if event != "repo:push" {
+			return nil, nil
+		}
+		return parseBitbucketPush(h)
+	}
+	if event := header.Get(GiteaEventHeader); event != "" {
+		return parseGiteaEvent(event, h)
+	}
+	return parseStashHook(h), nil
+}
+
+func parseStashHook(h ReceivedHook) []ReceivedHook {
+	if !strings.HasPrefix(h.Branch, "refs/tags/") {
+		return []ReceivedHook{h}
+	}
+	if h.Message == "DELETE" {
+		return nil
+	}
+	h.Tag = strings.TrimPrefix(h.Branch, "refs/tags/")
+	h.Branch = ""
+	return []ReceivedHook{h}
+}
+
+// withRepository sets project and repository of hook from full name of repository, unless given in hook URL
+func withRepository(h ReceivedHook, fullname string) ReceivedHook {
+	t := strings.SplitN(fullname, "/", 2)
+	if h.ProjectKey == "" && len(t) == 2 {
+		h.ProjectKey = t[0]
+	}
+	if h.Repository == "" && len(t) == 2 {
+		h.Repository = t[1]
+	}
+	return h
+}
+
+func parseBitbucketPush(h ReceivedHook) ([]ReceivedHook, error) {
+	var p bitbucketPush
+	if err := json.Unmarshal(h.Data, &p); err != nil {
+		return nil, err
+	}
+
+	author := p.Actor.Nickname
+	if author == "" {
+		author = p.Actor.Username
+	}
+
+	hooks := []ReceivedHook{}
+	for _, c := range p.Push.Changes {
+		rh := withRepository(h, p.Repository.FullName)
+		rh.Author = author
+
+		switch {
+		case c.New == nil && c.Old != nil && c.Old.Type == "branch":
+			rh.Branch = c.Old.Name
+			rh.Hash = c.Old.Target.Hash
+			rh.Message = "DELETE"
+		case c.New != nil && c.New.Type == "branch":
+			rh.Branch = c.New.Name
+			rh.Hash = c.New.Target.Hash
+			rh.Message = "UPDATE"
+			if c.Created {
+				rh.Message = "ADD"
+			}
+			if c.Old != nil {
+				rh.Before = c.Old.Target.Hash
+			}
+		case c.New != nil && c.New.Type == "tag":
+			rh.Tag = c.New.Name
+			rh.Hash = c.New.Target.Hash
+			rh.Message = "UPDATE"
+			if c.Created {
+				rh.Message = "ADD"
+			}
+		default:
+			continue
+		}
+		hooks = append(hooks, rh)
+	}
+	return hooks, nil
+}
+
+func parseGiteaEvent(event string, h ReceivedHook) ([]ReceivedHook, error) {
+	var e giteaEvent
+	if err := json.Unmarshal(h.Data, &e); err != nil {
+		return nil, err
+	}
+
+	rh := withRepository(h, e.Repository.FullName)
+	rh.Author = e.Pusher.Login
+	if rh.Author == "" {
+		rh.Author = e.Pusher.Username
+	}
+	if rh.Author == "" {
+		rh.Author = e.Sender.Login
+	}
+
+	switch event {
+	case "push":
+		if strings.HasPrefix(e.Ref, "refs/tags/") {
+			if e.After == nullHash {
+				return nil, nil
+			}
+			rh.Tag = strings.TrimPrefix(e.Ref, "refs/tags/")
+			rh.Hash = e.After
+			rh.Message = "ADD"
+			return []ReceivedHook{rh}, nil
+		}
+		if !strings.HasPrefix(e.Ref, "refs/heads/") {
+			return nil, nil
+		}
+		rh.Branch = strings.TrimPrefix(e.Ref, "refs/heads/")
+		rh.Hash = e.After
+		switch {
+		case e.After == nullHash:
+			rh.Hash = e.Before
+			rh.Message = "DELETE"
+		case e.Before == nullHash:
+			rh.Message = "ADD"
+		default:
+			rh.Before = e.Before
+			rh.Message = "UPDATE"
+		}
+	case "delete":
+		if e.RefType != "branch" {
+			return nil, nil
+		}
+		rh.Branch = e.Ref
+		rh.Message = "DELETE"
+	default:
+		return nil, nil
+	}
+	return []ReceivedHook{rh}, nil
+}
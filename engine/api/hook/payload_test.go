@@ -0,0 +1,146 @@
+package hook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func receivedHook(t *testing.T, fixture string) ReceivedHook {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ReceivedHook{Data: b, UID: "uid"}
+}
+
+func summary(hooks []ReceivedHook) [][]string {
+	s := [][]string{}
+	for _, h := range hooks {
+		s = append(s, []string{h.ProjectKey, h.Repository, h.Branch, h.Tag, h.Before, h.Hash, h.Author, h.Message, h.UID})
+	}
+	return s
+}
+
+func TestParseReceivedHook(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		event    string
+		fixture  string
+		expected [][]string
+	}{
+		{
+			name:    "bitbucket push",
+			header:  BitbucketCloudEventHeader,
+			event:   "repo:push",
+			fixture: "bitbucket_push.json",
+			expected: [][]string{
+				{"ovh", "api", "master", "", "6dcb09b5b57875f334f61aebed695e2e4193db5e", "9fceb02d0ae598e95dc970b74767f19372d61af8", "jdoe", "UPDATE", "uid"},
+				{"ovh", "api", "feature/new", "", "", "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678", "jdoe", "ADD", "uid"},
+				{"ovh", "api", "feature/old", "", "", "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c", "jdoe", "DELETE", "uid"},
+				{"ovh", "api", "", "v1.0.0", "", "9fceb02d0ae598e95dc970b74767f19372d61af8", "jdoe", "ADD", "uid"},
+			},
+		},
+		{
+			name:     "bitbucket other event",
+			header:   BitbucketCloudEventHeader,
+			event:    "pullrequest:created",
+			fixture:  "bitbucket_push.json",
+			expected: [][]string{},
+		},
+		{
+			name:    "gitea push",
+			header:  GiteaEventHeader,
+			event:   "push",
+			fixture: "gitea_push.json",
+			expected: [][]string{
+				{"ovh", "api", "master", "", "6dcb09b5b57875f334f61aebed695e2e4193db5e", "9fceb02d0ae598e95dc970b74767f19372d61af8", "jdoe", "UPDATE", "uid"},
+			},
+		},
+		{
+			name:    "gitea push deleting branch",
+			header:  GiteaEventHeader,
+			event:   "push",
+			fixture: "gitea_push_deleted.json",
+			expected: [][]string{
+				{"ovh", "api", "feature/old", "", "", "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c", "jdoe", "DELETE", "uid"},
+			},
+		},
+		{
+			name:    "gitea push tag",
+			header:  GiteaEventHeader,
+			event:   "push",
+			fixture: "gitea_push_tag.json",
+			expected: [][]string{
+				{"ovh", "api", "", "v1.0.0", "", "9fceb02d0ae598e95dc970b74767f19372d61af8", "jdoe", "ADD", "uid"},
+			},
+		},
+		{
+			name:    "gitea delete",
+			header:  GiteaEventHeader,
+			event:   "delete",
+			fixture: "gitea_delete.json",
+			expected: [][]string{
+				{"ovh", "api", "feature/old", "", "", "", "jdoe", "DELETE", "uid"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Set(tt.header, tt.event)
+		hooks, err := ParseReceivedHook(header, receivedHook(t, tt.fixture))
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got := summary(hooks); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestParseReceivedHookKeepsProjectFromURL(t *testing.T) {
+	h := receivedHook(t, "gitea_push.json")
+	h.ProjectKey, h.Repository = "OVH", "cds-api"
+
+	header := http.Header{}
+	header.Set(GiteaEventHeader, "push")
+	hooks, err := ParseReceivedHook(header, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 || hooks[0].ProjectKey != "OVH" || hooks[0].Repository != "cds-api" {
+		t.Errorf("unexpected hooks %v", summary(hooks))
+	}
+}
+
+func TestParseReceivedHookStash(t *testing.T) {
+	h := ReceivedHook{ProjectKey: "OVH", Repository: "api", Branch: "master", Hash: "abc", Message: "UPDATE"}
+	hooks, err := ParseReceivedHook(http.Header{}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 || !reflect.DeepEqual(hooks[0], h) {
+		t.Errorf("unexpected hooks %v", summary(hooks))
+	}
+}
+
+func TestParseReceivedHookStashTag(t *testing.T) {
+	h := ReceivedHook{ProjectKey: "OVH", Repository: "api", Branch: "refs/tags/v1.0.0", Hash: "abc", Message: "ADD"}
+	hooks, err := ParseReceivedHook(http.Header{}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 || hooks[0].Tag != "v1.0.0" || hooks[0].Branch != "" {
+		t.Errorf("unexpected hooks %v", summary(hooks))
+	}
+
+	h.Message = "DELETE"
+	if hooks, _ := ParseReceivedHook(http.Header{}, h); len(hooks) != 0 {
+		t.Errorf("deleted tag should be ignored, got %v", summary(hooks))
+	}
+}
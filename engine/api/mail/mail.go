@@ -171,6 +171,18 @@ func createTemplate(templ, callbackURL string) (bytes.Buffer, error) {
 
 //SendEmail is the core function to send an email
 func SendEmail(subject string, mailContent *bytes.Buffer, userMail string) error {
+	return sendEmail(subject, mailContent, userMail, nil)
+}
+
+//SendHTMLEmail sends an email with an HTML body
+func SendHTMLEmail(subject string, mailContent *bytes.Buffer, userMail string) error {
+	return sendEmail(subject, mailContent, userMail, map[string]string{
+		"MIME-Version": "1.0",
+		"Content-Type": "text/html; charset=\"UTF-8\"",
+	})
+}
+
+func sendEmail(subject string, mailContent *bytes.Buffer, userMail string, extraHeaders map[string]string) error {
 
 	from := mail.Address{
 		Name:    "",
@@ -186,6 +198,9 @@ func SendEmail(subject string, mailContent *bytes.Buffer, userMail string) error
 	headers["From"] = smtpFrom
 	headers["To"] = to.String()
 	headers["Subject"] = subject
+	for k, v := range extraHeaders {
+		headers[k] = v
+	}
 
 	// Setup message
 	message := ""
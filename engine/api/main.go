@@ -17,6 +17,7 @@ import (
 	"github.com/ovh/cds/engine/api/archivist"
 	"github.com/ovh/cds/engine/api/artifact"
 	"github.com/ovh/cds/engine/api/auth"
+	"github.com/ovh/cds/engine/api/branch"
 	"github.com/ovh/cds/engine/api/cache"
 	"github.com/ovh/cds/engine/api/database"
 	"github.com/ovh/cds/engine/api/hatchery"
@@ -31,6 +32,7 @@ import (
 	"github.com/ovh/cds/engine/api/sessionstore"
 	"github.com/ovh/cds/engine/api/stats"
 	"github.com/ovh/cds/engine/api/worker"
+	"github.com/ovh/cds/engine/api/workspacecache"
 	"github.com/ovh/cds/engine/log"
 )
 
@@ -77,6 +79,10 @@ var mainCmd = &cobra.Command{
 				log.Critical("Cannot setup builtin Artifact actions: %s\n", err)
 			}
 
+			if err = workspacecache.CreateBuiltinActions(db); err != nil {
+				log.Critical("Cannot setup builtin workspace cache actions: %s\n", err)
+			}
+
 			if err = worker.CreateBuiltinActions(db); err != nil {
 				log.Critical("Cannot setup builtin actions: %s\n", err)
 			}
@@ -181,6 +187,8 @@ var mainCmd = &cobra.Command{
 		go hookRecoverer()
 		go polling.Initialize()
 		go polling.ExecutionCleaner()
+		go artifact.UploadCleaner()
+		go branch.Cleaner(viper.GetInt("branch_grace_period_hours"))
 
 		s := &http.Server{
 			Addr:           ":" + viper.GetString("listen_port"),
@@ -216,6 +224,7 @@ func (router *Router) init() {
 	router.ServeAbsoluteFile("/download/cli/x86_64", path.Join(viper.GetString("download_directory"), "cds"), "cds")
 	router.ServeAbsoluteFile("/download/worker/x86_64", path.Join(viper.GetString("download_directory"), "worker"), "worker")
 	router.ServeAbsoluteFile("/download/worker/windows_x86_64", path.Join(viper.GetString("download_directory"), "worker.exe"), "worker.exe")
+	router.Handle("/download/worker/{arch}/checksum", GET(downloadWorkerChecksumHandler))
 	router.ServeAbsoluteFile("/download/hatchery/x86_64", path.Join(viper.GetString("download_directory"), "hatchery", "x86_64"), "hatchery")
 
 	// Group
@@ -227,7 +236,9 @@ func (router *Router) init() {
 
 	// Hatchery
 	router.Handle("/hatchery", POST(registerHatchery))
-	router.Handle("/hatchery/{id}", PUT(refreshHatcheryHandler))
+	router.Handle("/hatchery/{id}", PUT(refreshHatcheryHandler), DELETE(unregisterHatcheryHandler))
+	router.Handle("/hatchery/{id}/drain", POST(drainHatcheryHandler))
+	router.Handle("/hatchery/{id}/undrain", POST(undrainHatcheryHandler))
 
 	// Hooks
 	router.Handle("/hook", Auth(false) /* Public handler called by third parties */, POST(receiveHook))
@@ -242,6 +253,7 @@ func (router *Router) init() {
 	router.Handle("/mon/error", Auth(false), GET(getError))
 	router.Handle("/mon/stats", Auth(false), GET(getStats))
 	router.Handle("/mon/models", Auth(false), GET(getWorkerModelsStatsHandler))
+	router.Handle("/mon/models/latency", Auth(false), GET(getWorkerModelsSpawnLatencyHandler))
 	router.Handle("/mon/building", GET(getBuildingPipelines))
 	router.Handle("/mon/building/{hash}", GET(getPipelineBuildingCommit))
 	router.Handle("/mon/warning", GET(getUserWarnings))
@@ -259,7 +271,14 @@ func (router *Router) init() {
 	router.Handle("/project/{key}/variable/audit", GET(getVariablesAuditInProjectnHandler))
 	router.Handle("/project/{key}/variable/audit/{auditID}", PUT(restoreProjectVariableAuditHandler))
 	router.Handle("/project/{permProjectKey}/variable/{name}", POST(addVariableInProjectHandler), PUT(updateVariableInProjectHandler), DELETE(deleteVariableFromProjectHandler))
+	router.Handle("/project/{permProjectKey}/notification/template", GET(getNotificationTemplatesHandler))
+	router.Handle("/project/{permProjectKey}/notification/template/preview", POST(previewNotificationTemplateHandler))
+	router.Handle("/project/{permProjectKey}/notification/template/{name}", GET(getNotificationTemplateHandler), PUT(updateNotificationTemplateHandler), DELETE(deleteNotificationTemplateHandler))
 	router.Handle("/project/{permProjectKey}/applications", GET(getApplicationsHandler), POST(addApplicationHandler))
+	router.Handle("/project/{permProjectKey}/cache-quota", GET(getWorkspaceCacheQuotaHandler), PUT(updateWorkspaceCacheQuotaHandler))
+	router.Handle("/project/{permProjectKey}/cache", GET(getWorkspaceCachesHandler))
+	router.Handle("/project/{permProjectKey}/cache/{cacheKey}", GET(getWorkspaceCacheHandler), POST(uploadWorkspaceCacheHandler), DELETE(deleteWorkspaceCacheHandler))
+	router.Handle("/project/{permProjectKey}/cache/{cacheKey}/download", GET(downloadWorkspaceCacheHandler))
 
 	// Application
 	router.Handle("/project/{key}/application/{permApplicationName}", GET(getApplicationHandler), PUT(updateApplicationHandler), DELETE(deleteApplicationHandler))
@@ -270,6 +289,8 @@ func (router *Router) init() {
 	router.Handle("/project/{key}/application/{permApplicationName}/group/{group}", PUT(updateGroupRoleOnApplicationHandler), DELETE(deleteGroupFromApplicationHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/history", GET(getApplicationHistoryHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/history/branch", GET(getPipelineBuildBranchHistoryHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/branch/lifecycle", GET(getBranchLifecycleHandler), PUT(updateBranchLifecycleHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/branch/tracked", GET(getApplicationTrackedBranchesHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/history/env/deploy", GET(getApplicationDeployHistoryHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline", GET(getPipelinesInApplicationHandler), PUT(updatePipelinesToApplicationHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}", POST(attachPipelineToApplicationHandler), PUT(updatePipelineToApplicationHandler), DELETE(removePipelineFromApplicationHandler))
@@ -284,7 +305,10 @@ func (router *Router) init() {
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/history", GET(getPipelineHistoryHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/build/{build}/log", GET(getBuildLogsHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/build/{build}/test", POST(addBuildTestResultsHandler), GET(getBuildTestResultsHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/test/history", GET(getTestHistoryHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/test/flaky", GET(getFlakyTestsHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/build/{build}/variable", POST(addBuildVariableHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/build/{build}/webhook", GET(getBuildWebhookDeliveriesHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/build/{build}/action/{actionID}/log", GET(getActionBuildLogsHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/build/{build}", GET(getBuildStateHandler), DELETE(deleteBuildHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/build/{build}/triggered", GET(getPipelineBuildTriggeredHandler))
@@ -328,11 +352,24 @@ func (router *Router) init() {
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/artifact/{tag}", GET(listArtifactsHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/{buildNumber}/artifact", GET(listArtifactsBuildHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/{buildNumber}/artifact/{tag}", POST(uploadArtifactHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/{buildNumber}/artifact/{tag}/upload", POST(startArtifactUploadHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/artifact/upload/{uploadID}", GET(getArtifactUploadHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/artifact/upload/{uploadID}/complete", POST(completeArtifactUploadHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/artifact/upload/{uploadID}/{chunk:[0-9]+}", PUT(uploadArtifactChunkHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/artifact/download/{id}", GET(downloadArtifactHandler))
 	router.Handle("/artifact/{hash}", Auth(false), GET(downloadArtifactDirectHandler))
 
+	// Releases
+	router.Handle("/project/{key}/application/{permApplicationName}/release", GET(getReleasesHandler), POST(promoteReleaseHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/release/{release}", GET(getReleaseHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/release/{release}/artifact/{id}/download", GET(downloadReleaseArtifactHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/release/{release}/consumer", POST(addReleaseConsumerHandler))
+
 	// Hooks
 	router.Handle("/project/{key}/application/{permApplicationName}/hook", GET(getApplicationHooksHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/hook/delivery", GET(getApplicationHookDeliveriesHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/hook/delivery/{id}", GET(getApplicationHookDeliveryHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/hook/delivery/{id}/replay", POST(replayApplicationHookDeliveryHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/hook", POST(addHook), GET(getHooks))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/hook/{id}", PUT(updateHookHandler), DELETE(deleteHook))
 
@@ -352,6 +389,10 @@ func (router *Router) init() {
 	router.Handle("/pipeline/type", GET(getPipelineTypeHandler))
 	router.Handle("/notification/type", GET(getUserNotificationTypeHandler))
 	router.Handle("/notification/state", GET(getUserNotificationStateValueHandler))
+	router.Handle("/notification/outbox", GET(getNotificationOutboxHandler))
+	router.Handle("/notification/outbox/replay", POST(replayNotificationOutboxHandler))
+	router.Handle("/notification/outbox/{id}", DELETE(deleteNotificationOutboxHandler))
+	router.Handle("/notification/outbox/{id}/replay", POST(replayNotificationOutboxHandler))
 
 	// RepositoriesManager
 	router.Handle("/repositories_manager", GET(getRepositoriesManagerHandler))
@@ -386,6 +427,7 @@ func (router *Router) init() {
 	router.Handle("/user", GET(GetUsers))
 	router.Handle("/user/signup", Auth(false), POST(AddUser))
 	router.Handle("/user/{name}", NeedAdmin(true), GET(GetUserHandler), PUT(UpdateUserHandler), DELETE(DeleteUserHandler))
+	router.Handle("/user/{name}/notification", GET(getUserNotificationPreferencesHandler), PUT(updateUserNotificationPreferencesHandler))
 	router.Handle("/user/{name}/confirm/{token}", Auth(false), GET(ConfirmUser))
 	router.Handle("/user/{name}/reset", Auth(false), POST(ResetUser))
 	router.Handle("/user/worker/key/{expiry}", POST(generateUserKeyHandler))
@@ -394,13 +436,21 @@ func (router *Router) init() {
 	// Workers
 	router.Handle("/worker", Auth(false), GET(getWorkersHandler), POST(registerWorkerHandler))
 	router.Handle("/worker/status", GET(getWorkerModelStatus))
+	router.Handle("/worker/version", GET(getWorkerVersionsHandler))
 	router.Handle("/worker/refresh", POST(refreshWorkerHandler))
 	router.Handle("/worker/unregister", POST(unregisterWorkerHandler))
 	router.Handle("/worker/{id}/disable", POST(disableWorkerHandler))
+	router.Handle("/worker/{id}/drain", POST(drainWorkerHandler))
+	router.Handle("/worker/{id}/undrain", POST(undrainWorkerHandler))
 	router.Handle("/worker/model", POST(addWorkerModel), GET(getWorkerModels))
 	router.Handle("/worker/model/type", GET(getWorkerModelTypes))
 	router.Handle("/worker/model/{id}", PUT(updateWorkerModel), DELETE(deleteWorkerModel))
 	router.Handle("/worker/model/{id}/capability", POST(addWorkerModelCapa))
+	router.Handle("/worker/model/{id}/error", POST(reportSpawnErrorHandler), GET(getSpawnErrorsHandler))
+	router.Handle("/worker/model/{id}/validation", POST(requestWorkerModelValidationHandler), GET(getWorkerModelValidationHandler))
+	router.Handle("/worker/model/{id}/validation/report", POST(addWorkerModelValidationReportHandler))
+	router.Handle("/worker/model/{id}/pool", PUT(updateWorkerModelPoolHandler))
+	router.Handle("/worker/model/{id}/reservation", POST(reserveWorkersHandler))
 	router.Handle("/worker/model/capability/type", GET(getWorkerModelCapaTypes))
 	router.Handle("/worker/model/{id}/capability/{capa}", PUT(updateWorkerModelCapa), DELETE(deleteWorkerModelCapa))
 }
@@ -434,6 +484,9 @@ func init() {
 	viper.BindPFlag("artifact_password", flags.Lookup("artifact-password"))
 	viper.BindPFlag("artifact_basedir", flags.Lookup("artifact-basedir"))
 
+	flags.Int("workspace-cache-size", 2048, "Default workspace cache quota per project, in MB. Least recently used caches are evicted beyond")
+	viper.BindPFlag("workspace_cache_size", flags.Lookup("workspace-cache-size"))
+
 	flags.String("db-user", "cds", "DB User")
 	flags.String("db-password", "", "DB Password")
 	flags.String("db-name", "cds", "DB Name")
@@ -472,6 +525,9 @@ func init() {
 	flags.Int("archived-build-hours", 24, "After n hours, build is archived")
 	viper.BindPFlag("archived_build_hours", flags.Lookup("archived-build-hours"))
 
+	flags.Int("branch-grace-period-hours", 24, "After n hours, builds of a deleted branch are archived, unless its application sets its own grace period")
+	viper.BindPFlag("branch_grace_period_hours", flags.Lookup("branch-grace-period-hours"))
+
 	flags.String("download-directory", "/app", "Directory prefix for cds binaries")
 	viper.BindPFlag("download_directory", flags.Lookup("download-directory"))
 
@@ -535,6 +591,9 @@ func init() {
 	flags.Int("session-ttl", 60, "Session Time to Live (minutes)")
 	viper.BindPFlag("session_ttl", flags.Lookup("session-ttl"))
 
+	flags.String("worker-model-discover", "bash,curl,docker,gcc,git,go,java,make,mvn,node,npm,python,python3,ssh,tar,wget,zip", "Binaries looked up by workers validating their model")
+	viper.BindPFlag("worker_model_discover", flags.Lookup("worker-model-discover"))
+
 }
 
 func main() {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 
@@ -64,3 +65,77 @@ func notifHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context
 	}
 	notification.SendBuiltinNotif(db, &ab, notif)
 }
+
+func getNotificationOutboxHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	if !c.User.Admin {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	status := r.FormValue("status")
+	if status == "" {
+		status = "dead"
+	}
+
+	entries, err := notification.LoadOutbox(db, status)
+	if err != nil {
+		log.Warning("getNotificationOutboxHandler> Cannot load notifications: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, entries, http.StatusOK)
+}
+
+func replayNotificationOutboxHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	if !c.User.Admin {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	// Replay all dead notifications, unless one is given
+	var id int64
+	if s, ok := mux.Vars(r)["id"]; ok {
+		var err error
+		id, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			WriteError(w, r, sdk.ErrInvalidID)
+			return
+		}
+	}
+
+	n, err := notification.ReplayOutbox(db, id)
+	if err != nil {
+		log.Warning("replayNotificationOutboxHandler> Cannot replay notifications: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+	if id != 0 && n == 0 {
+		WriteError(w, r, sdk.ErrNotFound)
+		return
+	}
+
+	log.Notice("replayNotificationOutboxHandler> %s replayed %d notifications\n", c.User.Username, n)
+	WriteJSON(w, r, map[string]int64{"replayed": n}, http.StatusOK)
+}
+
+func deleteNotificationOutboxHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	if !c.User.Admin {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	if err := notification.DeleteOutbox(db, id); err != nil {
+		log.Warning("deleteNotificationOutboxHandler> Cannot delete notification %d: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -25,5 +25,5 @@ func SendActionBuild(db database.QueryExecuter, ab *sdk.ActionBuild, event sdk.N
 		NotifType:   sdk.ActionBuildNotif,
 	}
 
-	go post(n)
+	post(db, n)
 }
@@ -17,5 +17,5 @@ func SendBuiltinNotif(db database.QueryExecuter, ab *sdk.ActionBuild, notif sdk.
 		notif.Destination, notif.Title, notif.Message)
 
 	notif.NotifType = sdk.BuiltinNotif
-	go post(&notif)
+	post(db, &notif)
 }
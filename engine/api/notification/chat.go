@@ -0,0 +1,313 @@
+package notification
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/secret"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// encryptedTokenPrefix marks chat tokens encrypted in stored notification settings
+const encryptedTokenPrefix = "encrypted:"
+
+// encryptToken encrypts a chat token to be stored
+func encryptToken(token string) (string, error) {
+	if token == "" || strings.HasPrefix(token, encryptedTokenPrefix) {
+		return token, nil
+	}
+	data, err := secret.Encrypt([]byte(token))
+	if err != nil {
+		return "", err
+	}
+	return encryptedTokenPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// decryptToken decrypts a stored chat token, tokens stored before encryption are returned as is
+func decryptToken(token string) (string, error) {
+	if !strings.HasPrefix(token, encryptedTokenPrefix) {
+		return token, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(token, encryptedTokenPrefix))
+	if err != nil {
+		return "", err
+	}
+	clear, err := secret.Decrypt(data)
+	if err != nil {
+		return "", err
+	}
+	return string(clear), nil
+}
+
+// encryptChatTokens returns a copy of chat settings with encrypted tokens, to be stored
+func encryptChatTokens(cn *sdk.ChatUserNotificationSettings) (*sdk.ChatUserNotificationSettings, error) {
+	res := &sdk.ChatUserNotificationSettings{Channels: make([]sdk.ChatChannel, len(cn.Channels))}
+	for i, ch := range cn.Channels {
+		var err error
+		if ch.Token, err = encryptToken(ch.Token); err != nil {
+			return nil, err
+		}
+		res.Channels[i] = ch
+	}
+	return res, nil
+}
+
+// decryptChatTokens decrypts tokens of stored chat settings
+func decryptChatTokens(cn *sdk.ChatUserNotificationSettings) error {
+	for i := range cn.Channels {
+		token, err := decryptToken(cn.Channels[i].Token)
+		if err != nil {
+			return err
+		}
+		cn.Channels[i].Token = token
+	}
+	return nil
+}
+
+// chatMessage is the payload of Slack and Mattermost incoming webhooks, and of Slack chat.postMessage
+type chatMessage struct {
+	Channel     string           `json:"channel,omitempty"`
+	Username    string           `json:"username,omitempty"`
+	ThreadTS    string           `json:"thread_ts,omitempty"`
+	Attachments []chatAttachment `json:"attachments"`
+}
+
+type chatAttachment struct {
+	Fallback  string      `json:"fallback"`
+	Color     string      `json:"color"`
+	Title     string      `json:"title"`
+	TitleLink string      `json:"title_link,omitempty"`
+	Text      string      `json:"text,omitempty"`
+	Fields    []chatField `json:"fields,omitempty"`
+	Timestamp int64       `json:"ts"`
+}
+
+type chatField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// chatAPIResponse is the answer of Slack Web API
+type chatAPIResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	TS    string `json:"ts"`
+}
+
+func chatColor(s sdk.Status) string {
+	switch s {
+	case sdk.StatusSuccess:
+		return "good"
+	case sdk.StatusFail:
+		return "danger"
+	case sdk.StatusBuilding:
+		return "#439FE0"
+	}
+	return "warning"
+}
+
+// param returns the first value found for given names
+func param(params map[string]string, names ...string) string {
+	for _, n := range names {
+		if v, ok := params[n]; ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// failingStage returns the name of the first stage with a failed action
+func failingStage(pb *sdk.PipelineBuild) string {
+	for _, s := range pb.Stages {
+		for _, ab := range s.ActionBuilds {
+			if ab.Status == sdk.StatusFail {
+				return s.Name
+			}
+		}
+	}
+	return ""
+}
+
+// newChatMessage computes the attachment describing a pipeline build
+func newChatMessage(pb *sdk.PipelineBuild, params map[string]string) chatMessage {
+	title := fmt.Sprintf("%s/%s %s #%d", pb.Pipeline.ProjectKey, pb.Application.Name, pb.Pipeline.Name, pb.BuildNumber)
+	if pb.Environment.Name != "" && pb.Environment.Name != sdk.DefaultEnv.Name {
+		title += " on " + pb.Environment.Name
+	}
+
+	a := chatAttachment{
+		Fallback:  fmt.Sprintf("%s: %s", title, pb.Status),
+		Color:     chatColor(pb.Status),
+		Title:     title,
+		TitleLink: params["cds.buildURL"],
+		Text:      param(params, "git.message", ".git.message"),
+		Timestamp: time.Now().Unix(),
+	}
+
+	a.Fields = append(a.Fields, chatField{Title: "Status", Value: pb.Status.String(), Short: true})
+	if author := params["cds.author"]; author != "" {
+		a.Fields = append(a.Fields, chatField{Title: "Author", Value: author, Short: true})
+	}
+	branch := param(params, "git.branch", ".git.branch")
+	if branch == "" {
+		branch = pb.Trigger.VCSChangesBranch
+	}
+	if branch != "" {
+		a.Fields = append(a.Fields, chatField{Title: "Branch", Value: branch, Short: true})
+	}
+	if stage := failingStage(pb); stage != "" {
+		a.Fields = append(a.Fields, chatField{Title: "Failing stage", Value: stage, Short: true})
+	}
+	if f, ok := params["cds.tests.newFailures"]; ok {
+		a.Fields = append(a.Fields, chatField{Title: "New test failures", Value: f})
+	}
+
+	return chatMessage{Username: "CDS", Attachments: []chatAttachment{a}}
+}
+
+// chatThreadAttempts is the number of attempts to post the end of a deployment
+// waiting for its start to be posted, before posting it out of thread
+const chatThreadAttempts = 3
+
+// chatPayload is the outbox payload of a chat notification
+type chatPayload struct {
+	PipelineBuildID int64 `json:"pipeline_build_id"`
+	// StartThread is set on start of threaded deployments, InThread on their end
+	StartThread bool            `json:"start_thread,omitempty"`
+	InThread    bool            `json:"in_thread,omitempty"`
+	Channel     sdk.ChatChannel `json:"channel"`
+	Message     chatMessage     `json:"message"`
+}
+
+// sendChat writes in outbox pipeline build notification for channels whose rules match.
+// Deployment pipelines post their end in the thread of their start on threaded channels
+func sendChat(db database.Querier, cn *sdk.ChatUserNotificationSettings, pb *sdk.PipelineBuild, previous *sdk.PipelineBuild, params map[string]string) {
+	for i := range cn.Channels {
+		ch := cn.Channels[i]
+		threaded := ch.Thread && ch.Token != "" && pb.Pipeline.Type == sdk.DeploymentPipeline
+		if !ShouldSendUserNotification(&ch, pb, previous) && !(threaded && pb.Status == sdk.StatusBuilding) {
+			continue
+		}
+
+		token, err := encryptToken(ch.Token)
+		if err != nil {
+			log.Critical("notification.sendChat> Cannot encrypt token of channel %s: %s\n", ch.Channel, err)
+			continue
+		}
+		ch.Token = token
+
+		p := chatPayload{
+			PipelineBuildID: pb.ID,
+			StartThread:     threaded && pb.Status == sdk.StatusBuilding,
+			InThread:        threaded && pb.Status != sdk.StatusBuilding,
+			Channel:         ch,
+			Message:         newChatMessage(pb, params),
+		}
+		p.Message.Channel = ch.Channel
+		if err := enqueue(db, outboxChat, ch.URL, p, 0); err != nil {
+			log.Critical("notification.sendChat> Cannot write chat notification of channel %s in outbox: %s\n", ch.Channel, err)
+		}
+	}
+}
+
+// deliverChat posts a chat notification of outbox, in the thread of its deployment when needed
+func deliverChat(db *sql.DB, e outboxEntry) error {
+	var p chatPayload
+	if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+		return permanentError{err}
+	}
+	token, err := decryptToken(p.Channel.Token)
+	if err != nil {
+		return permanentError{err}
+	}
+	p.Channel.Token = token
+
+	if p.InThread {
+		ts, err := loadChatThread(db, p.PipelineBuildID, p.Channel.URL, p.Channel.Channel)
+		if err != nil {
+			return err
+		}
+		// Start of deployment may still wait in outbox
+		if ts == "" && e.Attempts < chatThreadAttempts {
+			return fmt.Errorf("thread of pb:%d is not started yet", p.PipelineBuildID)
+		}
+		p.Message.ThreadTS = ts
+	}
+
+	body, err := json.Marshal(p.Message)
+	if err != nil {
+		return permanentError{err}
+	}
+	ts, err := postChatOnce(&p.Channel, body)
+	if err != nil {
+		return err
+	}
+
+	if p.StartThread {
+		if err := insertChatThread(db, p.PipelineBuildID, p.Channel.URL, p.Channel.Channel, ts); err != nil {
+			log.Warning("notification.deliverChat> Cannot store thread of pb:%d: %s\n", p.PipelineBuildID, err)
+		}
+	}
+	return nil
+}
+
+func postChatOnce(ch *sdk.ChatChannel, body []byte) (string, error) {
+	req, err := http.NewRequest("POST", ch.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if ch.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+ch.Token)
+	}
+
+	resp, err := deliveryHTTPClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	if ch.Token == "" {
+		return "", nil
+	}
+
+	var r chatAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", err
+	}
+	if !r.OK {
+		return "", fmt.Errorf("%s", r.Error)
+	}
+	return r.TS, nil
+}
+
+func insertChatThread(db database.Executer, pbID int64, url, channel, ts string) error {
+	query := `INSERT INTO notification_chat_thread (pipeline_build_id, url, channel, thread_ts, created) VALUES ($1, $2, $3, $4, $5)`
+	_, err := db.Exec(query, pbID, url, channel, ts, time.Now())
+	return err
+}
+
+// loadChatThread returns the message starting the thread of a pipeline build on a channel, if any
+func loadChatThread(db database.Querier, pbID int64, url, channel string) (string, error) {
+	var ts string
+	query := `SELECT thread_ts FROM notification_chat_thread WHERE pipeline_build_id = $1 AND url = $2 AND channel = $3`
+	if err := db.QueryRow(query, pbID, url, channel).Scan(&ts); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return ts, nil
+}
@@ -0,0 +1,127 @@
+package notification
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ovh/cds/engine/api/secret"
+	"github.com/ovh/cds/sdk"
+)
+
+func TestNewChatMessage(t *testing.T) {
+	pb := &sdk.PipelineBuild{
+		BuildNumber: 12,
+		Status:      sdk.StatusFail,
+		Pipeline:    sdk.Pipeline{Name: "deploy", ProjectKey: "PRJ"},
+		Application: sdk.Application{Name: "app"},
+		Environment: sdk.Environment{Name: "prod"},
+		Stages: []sdk.Stage{
+			{Name: "Build", ActionBuilds: []sdk.ActionBuild{{Status: sdk.StatusSuccess}}},
+			{Name: "Deploy", ActionBuilds: []sdk.ActionBuild{{Status: sdk.StatusFail}}},
+		},
+		Trigger: sdk.PipelineBuildTrigger{VCSChangesBranch: "master"},
+	}
+	params := map[string]string{
+		"cds.buildURL": "http://cds/build",
+		"cds.author":   "john",
+		"git.message":  "fix deploy",
+	}
+
+	msg := newChatMessage(pb, params)
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("expected 1 attachment, got %d", len(msg.Attachments))
+	}
+	a := msg.Attachments[0]
+	if a.Title != "PRJ/app deploy #12 on prod" || a.TitleLink != "http://cds/build" || a.Color != "danger" || a.Text != "fix deploy" {
+		t.Errorf("unexpected attachment %+v", a)
+	}
+
+	fields := map[string]string{}
+	for _, f := range a.Fields {
+		fields[f.Title] = f.Value
+	}
+	want := map[string]string{"Status": "Fail", "Author": "john", "Branch": "master", "Failing stage": "Deploy"}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("field %s = %q, want %q", k, fields[k], v)
+		}
+	}
+}
+
+func TestChatTokenEncryption(t *testing.T) {
+	if err := secret.Init("", "local-insecure", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	cn := &sdk.ChatUserNotificationSettings{Channels: []sdk.ChatChannel{
+		{URL: "https://slack.com/api/chat.postMessage", Channel: "#deploy", Token: "xoxb-token"},
+		{URL: "https://hooks.slack.com/services/x"},
+	}}
+	stored, err := encryptChatTokens(cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn.Channels[0].Token != "xoxb-token" {
+		t.Errorf("encryption modified settings in use")
+	}
+	if !strings.HasPrefix(stored.Channels[0].Token, encryptedTokenPrefix) || strings.Contains(stored.Channels[0].Token, "xoxb-token") {
+		t.Errorf("token not encrypted: %s", stored.Channels[0].Token)
+	}
+	if stored.Channels[1].Token != "" {
+		t.Errorf("empty token encrypted")
+	}
+
+	if err := decryptChatTokens(stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Channels[0].Token != "xoxb-token" {
+		t.Errorf("unexpected decrypted token %s", stored.Channels[0].Token)
+	}
+
+	// Tokens stored before encryption are read as is
+	legacy := &sdk.ChatUserNotificationSettings{Channels: []sdk.ChatChannel{{Token: "xoxb-legacy"}}}
+	if err := decryptChatTokens(legacy); err != nil || legacy.Channels[0].Token != "xoxb-legacy" {
+		t.Errorf("unexpected legacy token %s (%v)", legacy.Channels[0].Token, err)
+	}
+}
+
+func TestDeliverChat(t *testing.T) {
+	if err := secret.Init("", "local-insecure", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var auth string
+	var msg chatMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &msg)
+		w.Write([]byte(`{"ok":true,"ts":"1234.5"}`))
+	}))
+	defer server.Close()
+
+	token, err := encryptToken("xoxb-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := chatPayload{
+		PipelineBuildID: 1,
+		Channel:         sdk.ChatChannel{URL: server.URL, Channel: "#deploy", Token: token},
+		Message:         chatMessage{Channel: "#deploy", Username: "CDS"},
+	}
+	payload, _ := json.Marshal(p)
+
+	if err := deliverChat(nil, outboxEntry{Kind: outboxChat, Payload: string(payload), Attempts: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer xoxb-token" {
+		t.Errorf("unexpected authorization %q", auth)
+	}
+	if msg.Channel != "#deploy" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+}
@@ -0,0 +1,321 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+const (
+	digestInterval = 10 * time.Minute
+	// digestHour is the hour of the day after which digests are sent
+	digestHour = 8
+	// digestSlowest is the number of slowest pipelines in digest
+	digestSlowest = 5
+)
+
+// digestFailure counts failed builds of a pipeline
+type digestFailure struct {
+	Application, Pipeline, Environment string
+	Count                              int64
+	LastBuildNumber                    int64
+}
+
+// digestDuration is the mean duration of builds of a pipeline
+type digestDuration struct {
+	Application, Pipeline string
+	Seconds               float64
+	Count                 int64
+}
+
+// digestApproval is a successful build waiting for a manual trigger
+type digestApproval struct {
+	Application, Pipeline                  string
+	DestApplication, DestPipeline, DestEnv string
+	Version                                int64
+}
+
+// digestProject is the summary of a project over the period of a digest
+type digestProject struct {
+	Key      string
+	Failures []digestFailure
+	Slowest  []digestDuration
+	Pending  []digestApproval
+}
+
+// builds of current and archived pipeline builds
+const digestBuilds = `WITH builds AS (
+	SELECT pipeline_build_id AS id, application_id, pipeline_id, environment_id, build_number, version, status, start, done, parent_pipeline_build_id FROM pipeline_history
+	UNION ALL
+	SELECT id, application_id, pipeline_id, environment_id, build_number, version, status, start, done, parent_pipeline_build_id FROM pipeline_build
+)`
+
+// digestPeriod returns the period summarized by a digest sent at given time, false when no digest is due that day
+func digestPeriod(freq sdk.DigestFrequency, now time.Time) (time.Duration, bool) {
+	if now.Hour() < digestHour {
+		return 0, false
+	}
+	switch freq {
+	case sdk.DigestDaily:
+		return 24 * time.Hour, true
+	case sdk.DigestWeekly:
+		return 7 * 24 * time.Hour, now.Weekday() == time.Monday
+	}
+	return 0, false
+}
+
+// digestScheduler sends digests to users who asked for them
+func digestScheduler() {
+	for {
+		time.Sleep(digestInterval)
+		db := database.DB()
+		if db == nil {
+			continue
+		}
+
+		users, err := loadDigestUsers(db)
+		if err != nil {
+			log.Warning("notification.digestScheduler> Cannot load users: %s\n", err)
+			continue
+		}
+
+		now := time.Now()
+		for i := range users {
+			u := &users[i]
+			period, ok := digestPeriod(u.Digest, now)
+			if !ok || u.Email == "" {
+				continue
+			}
+			due, err := claimDigest(db, u.ID, now)
+			if err != nil {
+				log.Warning("notification.digestScheduler> Cannot claim digest of %s: %s\n", u.Username, err)
+				continue
+			}
+			if due {
+				sendDigest(db, u, now.Add(-period))
+			}
+		}
+	}
+}
+
+// loadDigestUsers loads users asking for a digest
+func loadDigestUsers(db database.Querier) ([]sdk.User, error) {
+	query := `SELECT id, username, admin, data FROM "user" WHERE data LIKE $1`
+	rows, err := db.Query(query, `%"digest":"%`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []sdk.User{}
+	for rows.Next() {
+		var data string
+		var admin bool
+		u := sdk.User{}
+		if err := rows.Scan(&u.ID, &u.Username, &admin, &data); err != nil {
+			return nil, err
+		}
+		if _, err := u.FromJSON([]byte(data)); err != nil {
+			log.Warning("notification.loadDigestUsers> Cannot parse user %s: %s\n", u.Username, err)
+			continue
+		}
+		u.Admin = admin
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// claimDigest returns true when digest of user has not been sent yet today, and marks it sent.
+// Only one API instance gets it
+func claimDigest(db database.QueryExecuter, userID int64, now time.Time) (bool, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	query := `UPDATE user_digest SET last_sent = $2 WHERE user_id = $1 AND last_sent < $3`
+	res, err := db.Exec(query, userID, now, today)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return n > 0, err
+	}
+
+	// Another instance may insert it concurrently
+	query = `INSERT INTO user_digest (user_id, last_sent) VALUES ($1, $2) ON CONFLICT (user_id) DO NOTHING`
+	res, err = db.Exec(query, userID, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// sendDigest mails summary of subscribed projects since given time
+func sendDigest(db database.QueryExecuter, u *sdk.User, since time.Time) {
+	keys := []string{}
+	found := map[string]bool{}
+	for _, s := range u.Subscriptions {
+		if !found[s.ProjectKey] {
+			found[s.ProjectKey] = true
+			keys = append(keys, s.ProjectKey)
+		}
+	}
+
+	projects := []digestProject{}
+	for _, k := range keys {
+		ok, err := canReadProject(db, u, k)
+		if err != nil {
+			log.Warning("notification.sendDigest> Cannot check permission of %s on project %s: %s\n", u.Username, k, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		p, err := loadDigestProject(db, k, since)
+		if err != nil {
+			log.Warning("notification.sendDigest> Cannot load digest of project %s: %s\n", k, err)
+			continue
+		}
+		if len(p.Failures) > 0 || len(p.Slowest) > 0 || len(p.Pending) > 0 {
+			projects = append(projects, p)
+		}
+	}
+	if len(projects) == 0 {
+		return
+	}
+
+	notif := sdk.Notif{
+		DateNotif:  time.Now().Unix(),
+		NotifType:  sdk.UserNotif,
+		Title:      fmt.Sprintf("[CDS] Your %s digest", u.Digest),
+		Message:    renderDigest(projects, since),
+		Recipients: []string{u.Email},
+	}
+	SendMailNotif(db, &notif)
+}
+
+func loadDigestProject(db database.Querier, key string, since time.Time) (digestProject, error) {
+	p := digestProject{Key: key}
+
+	query := digestBuilds + `
+		SELECT application.name, pipeline.name, environment.name, COUNT(builds.id), MAX(builds.build_number)
+		FROM builds
+		JOIN application ON application.id = builds.application_id
+		JOIN project ON project.id = application.project_id
+		JOIN pipeline ON pipeline.id = builds.pipeline_id
+		JOIN environment ON environment.id = builds.environment_id
+		WHERE project.projectkey = $1 AND builds.status = $2 AND builds.start > $3
+		GROUP BY application.name, pipeline.name, environment.name
+		ORDER BY COUNT(builds.id) DESC, application.name, pipeline.name`
+	rows, err := db.Query(query, key, sdk.StatusFail.String(), since)
+	if err != nil {
+		return p, err
+	}
+	for rows.Next() {
+		var f digestFailure
+		if err := rows.Scan(&f.Application, &f.Pipeline, &f.Environment, &f.Count, &f.LastBuildNumber); err != nil {
+			rows.Close()
+			return p, err
+		}
+		p.Failures = append(p.Failures, f)
+	}
+	rows.Close()
+
+	query = digestBuilds + `
+		SELECT application.name, pipeline.name, AVG(EXTRACT(EPOCH FROM (builds.done - builds.start))), COUNT(builds.id)
+		FROM builds
+		JOIN application ON application.id = builds.application_id
+		JOIN project ON project.id = application.project_id
+		JOIN pipeline ON pipeline.id = builds.pipeline_id
+		WHERE project.projectkey = $1 AND builds.start > $2 AND builds.done > builds.start
+		AND builds.status IN ($3, $4)
+		GROUP BY application.name, pipeline.name
+		ORDER BY 3 DESC LIMIT $5`
+	rows, err = db.Query(query, key, since, sdk.StatusSuccess.String(), sdk.StatusFail.String(), digestSlowest)
+	if err != nil {
+		return p, err
+	}
+	for rows.Next() {
+		var d digestDuration
+		if err := rows.Scan(&d.Application, &d.Pipeline, &d.Seconds, &d.Count); err != nil {
+			rows.Close()
+			return p, err
+		}
+		p.Slowest = append(p.Slowest, d)
+	}
+	rows.Close()
+
+	// Last build of source pipeline succeeded, and manual trigger has not been run for it
+	query = digestBuilds + `
+		SELECT sa.name, sp.name, da.name, dp.name, de.name, b.version
+		FROM pipeline_trigger t
+		JOIN application sa ON sa.id = t.src_application_id
+		JOIN project ON project.id = sa.project_id
+		JOIN pipeline sp ON sp.id = t.src_pipeline_id
+		JOIN application da ON da.id = t.dest_application_id
+		JOIN pipeline dp ON dp.id = t.dest_pipeline_id
+		JOIN environment de ON de.id = t.dest_environment_id
+		JOIN builds b ON b.application_id = t.src_application_id AND b.pipeline_id = t.src_pipeline_id AND b.environment_id = t.src_environment_id
+		WHERE project.projectkey = $1 AND t.manual = $2 AND b.status = $3 AND b.start > $4
+		AND NOT EXISTS (
+			SELECT 1 FROM builds d WHERE d.parent_pipeline_build_id = b.id
+			AND d.application_id = t.dest_application_id AND d.pipeline_id = t.dest_pipeline_id AND d.environment_id = t.dest_environment_id
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM builds n WHERE n.application_id = b.application_id AND n.pipeline_id = b.pipeline_id
+			AND n.environment_id = b.environment_id AND n.start > b.start
+		)
+		ORDER BY sa.name, sp.name`
+	rows, err = db.Query(query, key, true, sdk.StatusSuccess.String(), since)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a digestApproval
+		if err := rows.Scan(&a.Application, &a.Pipeline, &a.DestApplication, &a.DestPipeline, &a.DestEnv, &a.Version); err != nil {
+			return p, err
+		}
+		p.Pending = append(p.Pending, a)
+	}
+	return p, nil
+}
+
+// renderDigest writes the mail summarizing projects
+func renderDigest(projects []digestProject, since time.Time) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Activity of your projects since %s\n", since.Format("Mon Jan 2 15:04"))
+
+	for _, p := range projects {
+		fmt.Fprintf(&b, "\n== %s ==\n", p.Key)
+
+		if len(p.Failures) > 0 {
+			fmt.Fprintf(&b, "\nFailures:\n")
+			for _, f := range p.Failures {
+				fmt.Fprintf(&b, "  - %s %s/%s: %d failed builds, last #%d\n", f.Application, f.Pipeline, f.Environment, f.Count, f.LastBuildNumber)
+			}
+		}
+
+		if len(p.Slowest) > 0 {
+			fmt.Fprintf(&b, "\nSlowest pipelines:\n")
+			for _, d := range p.Slowest {
+				fmt.Fprintf(&b, "  - %s %s: %s on average over %d builds\n", d.Application, d.Pipeline, time.Duration(d.Seconds)*time.Second, d.Count)
+			}
+		}
+
+		if len(p.Pending) > 0 {
+			fmt.Fprintf(&b, "\nPending approvals:\n")
+			for _, a := range p.Pending {
+				fmt.Fprintf(&b, "  - %s %s v%d -> %s %s/%s\n", a.Application, a.Pipeline, a.Version, a.DestApplication, a.DestPipeline, a.DestEnv)
+			}
+		}
+	}
+
+	if baseURL != "" {
+		fmt.Fprintf(&b, "\n%s\n", baseURL)
+	}
+	return b.String()
+}
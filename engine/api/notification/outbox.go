@@ -0,0 +1,384 @@
+package notification
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/mail"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// Kinds of notifications in outbox
+const (
+	outboxSystem  = "system"
+	outboxMail    = "email"
+	outboxWebhook = "webhook"
+	outboxChat    = "chat"
+	// outboxPipeline computes user notifications of a pipeline build
+	outboxPipeline = "pipeline"
+)
+
+// Status of notifications in outbox
+const (
+	outboxPending = "pending"
+	outboxDead    = "dead"
+)
+
+const (
+	outboxPollInterval = 5 * time.Second
+	outboxBatchSize    = 50
+	// outboxWorkers deliver claimed notifications concurrently, each delivery within outboxTimeout,
+	// so that a whole batch is delivered well before the end of its lease
+	outboxWorkers = 10
+	outboxTimeout = 30 * time.Second
+	// outboxLease is the time given to dispatcher to deliver claimed notifications,
+	// after which they are delivered again (ie. API restarted while delivering)
+	outboxLease        = 5 * time.Minute
+	outboxMaxAttempts  = 10
+	outboxBackoffStart = 10 * time.Second
+	outboxBackoffMax   = time.Hour
+)
+
+// outboxEntry is a notification waiting in outbox
+type outboxEntry struct {
+	ID                 int64
+	Kind               string
+	Target             string
+	Payload            string
+	Attempts           int
+	UserNotificationID int64
+}
+
+// webhookPayload is the outbox payload of a webhook notification
+type webhookPayload struct {
+	PipelineBuildID int64                               `json:"pipeline_build_id"`
+	Webhook         sdk.WebhookUserNotificationSettings `json:"webhook"`
+	Body            string                              `json:"body"`
+}
+
+// permanentError is a delivery error that retries cannot fix
+type permanentError struct {
+	error
+}
+
+// enqueue writes a notification in outbox, with the executer of the change causing it,
+// so that notification is sent if and only if the change is committed
+func enqueue(db database.Querier, kind, target string, payload interface{}, userNotificationID int64) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var id int64
+	now := time.Now()
+	query := `INSERT INTO notification_outbox (kind, target, payload, status, attempts, next_attempt, last_error, user_notification_id, created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $6) RETURNING id`
+	return db.QueryRow(query, kind, target, string(b), outboxPending, 0, now, "", userNotificationID).Scan(&id)
+}
+
+// outboxBackoff returns the time to wait before next delivery, after given number of attempts
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxBackoffStart
+	for i := 1; i < attempts && d < outboxBackoffMax; i++ {
+		d *= 2
+	}
+	if d > outboxBackoffMax {
+		d = outboxBackoffMax
+	}
+	return d
+}
+
+// dispatcher delivers notifications of outbox
+func dispatcher() {
+	// If this goroutine exits, then it's a crash
+	defer log.Fatalf("Goroutine of notification.dispatcher exited - Exit CDS Engine")
+
+	for {
+		time.Sleep(outboxPollInterval)
+		db := database.DB()
+		if db == nil {
+			continue
+		}
+
+		entries, err := claimOutbox(db, time.Now())
+		if err != nil {
+			log.Warning("notification.dispatcher> Cannot claim notifications: %s\n", err)
+			continue
+		}
+		deliverBatch(db, entries)
+	}
+}
+
+// deliverBatch delivers claimed notifications with outboxWorkers goroutines
+func deliverBatch(db *sql.DB, entries []outboxEntry) {
+	queue := make(chan outboxEntry)
+	var wg sync.WaitGroup
+	for i := 0; i < outboxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range queue {
+				deliverOutbox(db, e)
+			}
+		}()
+	}
+	for _, e := range entries {
+		queue <- e
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// deliveryHTTPClient returns the client posting notifications of outbox
+func deliveryHTTPClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{}, Timeout: outboxTimeout}
+}
+
+// claimOutbox takes pending notifications due for delivery, for the duration of a lease
+func claimOutbox(db *sql.DB, now time.Time) ([]outboxEntry, error) {
+	query := `UPDATE notification_outbox SET next_attempt = $2, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM notification_outbox
+			WHERE status = $3 AND next_attempt <= $1
+			ORDER BY id LIMIT $4 FOR UPDATE
+		)
+		RETURNING id, kind, target, payload, attempts, user_notification_id`
+	rows, err := db.Query(query, now, now.Add(outboxLease), outboxPending, outboxBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []outboxEntry
+	for rows.Next() {
+		var e outboxEntry
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Target, &e.Payload, &e.Attempts, &e.UserNotificationID); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// deliverOutbox delivers a notification, then removes it from outbox,
+// schedules its next attempt or moves it to dead letters
+func deliverOutbox(db *sql.DB, e outboxEntry) {
+	err := deliver(db, e)
+	if err == nil {
+		if _, err := db.Exec(`DELETE FROM notification_outbox WHERE id = $1`, e.ID); err != nil {
+			log.Warning("notification.deliverOutbox> Cannot delete notification %d: %s\n", e.ID, err)
+		}
+		updateUserNotificationStatus(db, e.UserNotificationID, "SUCCESS")
+		return
+	}
+
+	_, permanent := err.(permanentError)
+	if permanent || e.Attempts >= outboxMaxAttempts {
+		log.Warning("notification.deliverOutbox> Notification %d (%s %s) is dead after %d attempts: %s\n", e.ID, e.Kind, e.Target, e.Attempts, err)
+		query := `UPDATE notification_outbox SET status = $2, last_error = $3 WHERE id = $1`
+		if _, err := db.Exec(query, e.ID, outboxDead, err.Error()); err != nil {
+			log.Warning("notification.deliverOutbox> Cannot update notification %d: %s\n", e.ID, err)
+		}
+		updateUserNotificationStatus(db, e.UserNotificationID, "ERROR : "+err.Error())
+		return
+	}
+
+	log.Notice("notification.deliverOutbox> Attempt %d of notification %d (%s %s) failed: %s\n", e.Attempts, e.ID, e.Kind, e.Target, err)
+	query := `UPDATE notification_outbox SET next_attempt = $2, last_error = $3 WHERE id = $1`
+	if _, err := db.Exec(query, e.ID, time.Now().Add(outboxBackoff(e.Attempts)), err.Error()); err != nil {
+		log.Warning("notification.deliverOutbox> Cannot update notification %d: %s\n", e.ID, err)
+	}
+}
+
+func deliver(db *sql.DB, e outboxEntry) error {
+	switch e.Kind {
+	case outboxSystem:
+		url, ok := notifsSystems[e.Target]
+		if !ok {
+			return permanentError{fmt.Errorf("notifications system %s is not configured", e.Target)}
+		}
+		var n sdk.Notif
+		if err := json.Unmarshal([]byte(e.Payload), &n); err != nil {
+			return permanentError{err}
+		}
+		return deliverSystem(url+getPath(e.Target, n.NotifType), []byte(e.Payload))
+
+	case outboxMail:
+		var n sdk.Notif
+		if err := json.Unmarshal([]byte(e.Payload), &n); err != nil {
+			return permanentError{err}
+		}
+		return deliverMail(&n)
+
+	case outboxWebhook:
+		var p webhookPayload
+		if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+			return permanentError{err}
+		}
+		d := sdk.WebhookDelivery{
+			PipelineBuildID: p.PipelineBuildID,
+			URL:             p.Webhook.URL,
+			Attempt:         e.Attempts,
+			Created:         time.Now(),
+		}
+		var err error
+		d.StatusCode, err = postWebhook(&p.Webhook, []byte(p.Body))
+		d.Duration = time.Since(d.Created).Nanoseconds() / int64(time.Millisecond)
+		if err != nil {
+			d.Error = err.Error()
+		}
+		if errI := InsertWebhookDelivery(db, &d); errI != nil {
+			log.Warning("notification.deliver> Cannot insert delivery of pb:%d: %s\n", p.PipelineBuildID, errI)
+		}
+		return err
+
+	case outboxChat:
+		return deliverChat(db, e)
+
+	case outboxPipeline:
+		var p pipelinePayload
+		if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+			return permanentError{err}
+		}
+		if p.Build == nil {
+			return permanentError{fmt.Errorf("pipeline build notification without build")}
+		}
+		return sendUserNotifications(db, p.Build, p.Status, p.Previous)
+	}
+	return permanentError{fmt.Errorf("unknown kind of notification %s", e.Kind)}
+}
+
+// deliverSystem posts a notification to a CDS notifications system
+func deliverSystem(url string, payload []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return permanentError{err}
+	}
+	initRequest(req)
+
+	resp, err := deliveryHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s: %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+// deliverMail sends a user notification by mail to all its recipients
+func deliverMail(n *sdk.Notif) error {
+	errors := []string{}
+	send := mail.SendEmail
+	if n.HTML {
+		send = mail.SendHTMLEmail
+	}
+	for _, recipient := range n.Recipients {
+		if err := send(n.Title, bytes.NewBufferString(n.Message), recipient); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	if len(errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(errors, ", "))
+	}
+	return nil
+}
+
+func updateUserNotificationStatus(db database.Executer, id int64, status string) {
+	if id == 0 {
+		return
+	}
+	if _, err := db.Exec(`UPDATE user_notification SET status = $2 WHERE id = $1`, id, status); err != nil {
+		log.Warning("notification.updateUserNotificationStatus> Cannot update notification %d: %s\n", id, err)
+	}
+}
+
+// redactPayload hides secrets of webhook and chat notifications
+func redactPayload(kind, payload string) string {
+	switch kind {
+	case outboxWebhook:
+		var p webhookPayload
+		if err := json.Unmarshal([]byte(payload), &p); err != nil {
+			return ""
+		}
+		redactWebhook(&p.Webhook)
+		b, _ := json.Marshal(p)
+		return string(b)
+	case outboxChat:
+		var p chatPayload
+		if err := json.Unmarshal([]byte(payload), &p); err != nil {
+			return ""
+		}
+		if p.Channel.Token != "" {
+			p.Channel.Token = sdk.PasswordPlaceholder
+		}
+		b, _ := json.Marshal(p)
+		return string(b)
+	}
+	return payload
+}
+
+// LoadOutbox loads notifications of outbox with given status, secrets of payloads are redacted
+func LoadOutbox(db database.Querier, status string) ([]sdk.NotificationOutboxEntry, error) {
+	query := `SELECT id, kind, target, payload, status, attempts, next_attempt, last_error, created
+		FROM notification_outbox WHERE status = $1 ORDER BY id`
+	rows, err := db.Query(query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []sdk.NotificationOutboxEntry{}
+	for rows.Next() {
+		var e sdk.NotificationOutboxEntry
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Target, &e.Payload, &e.Status, &e.Attempts, &e.NextAttempt, &e.LastError, &e.Created); err != nil {
+			return nil, err
+		}
+		e.Payload = redactPayload(e.Kind, e.Payload)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// ReplayOutbox puts back dead notifications in delivery, all of them when id is 0
+func ReplayOutbox(db database.Executer, id int64) (int64, error) {
+	query := `UPDATE notification_outbox SET status = $1, attempts = 0, next_attempt = $2
+		WHERE status = $3 AND (id = $4 OR $4 = 0)`
+	res, err := db.Exec(query, outboxPending, time.Now(), outboxDead, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteOutbox removes a notification from outbox
+func DeleteOutbox(db database.Executer, id int64) error {
+	res, err := db.Exec(`DELETE FROM notification_outbox WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sdk.ErrNotFound
+	}
+	return nil
+}
+
+// outboxStatus returns the number of notifications pending and dead in outbox
+func outboxStatus(db database.Querier) (int64, int64, error) {
+	var pending, dead int64
+	query := `SELECT COALESCE(SUM(CASE WHEN status = $1 THEN 1 ELSE 0 END), 0), COALESCE(SUM(CASE WHEN status = $2 THEN 1 ELSE 0 END), 0) FROM notification_outbox`
+	err := db.QueryRow(query, outboxPending, outboxDead).Scan(&pending, &dead)
+	return pending, dead, err
+}
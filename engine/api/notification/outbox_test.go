@@ -0,0 +1,44 @@
+package notification
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{10, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempt); got != tt.want {
+			t.Errorf("outboxBackoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRedactPayload(t *testing.T) {
+	wh, _ := json.Marshal(webhookPayload{Webhook: sdk.WebhookUserNotificationSettings{URL: "http://hook", Secret: "s3cr3t", Headers: map[string]string{"X-Token": "t0ken"}}})
+	ch, _ := json.Marshal(chatPayload{Channel: sdk.ChatChannel{URL: "http://chat", Token: "xoxb-token"}})
+
+	for kind, payload := range map[string]string{outboxWebhook: string(wh), outboxChat: string(ch)} {
+		got := redactPayload(kind, payload)
+		for _, secret := range []string{"s3cr3t", "t0ken", "xoxb-token"} {
+			if strings.Contains(got, secret) {
+				t.Errorf("%s payload not redacted: %s", kind, got)
+			}
+		}
+		if !strings.Contains(got, "http://") {
+			t.Errorf("%s payload lost its url: %s", kind, got)
+		}
+	}
+}
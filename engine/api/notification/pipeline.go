@@ -26,33 +26,41 @@ func SendPipeline(db database.QueryExecuter, pb *sdk.PipelineBuild, event sdk.No
 		NotifType: sdk.PipelineBuildNotif,
 	}
 
-	go post(n)
+	post(db, n)
 
-	//Send UserNotif
+	//User notifications are computed by the dispatcher, out of the transaction of the build,
+	//as their templates may call repositories managers
+	p := pipelinePayload{Build: pb, Previous: previous, Status: status}
+	if err := enqueue(db, outboxPipeline, "", p, 0); err != nil {
+		log.Critical("notification.SendPipelineBuild> error while writing user notifications in outbox: %s", err)
+	}
+}
+
+// pipelinePayload is the outbox payload of user notifications of a pipeline build
+type pipelinePayload struct {
+	Build    *sdk.PipelineBuild `json:"build"`
+	Previous *sdk.PipelineBuild `json:"previous,omitempty"`
+	Status   sdk.Status         `json:"status"`
+}
+
+// sendUserNotifications sends notifications of pipeline build to subscribers and to recipients of user notification settings
+func sendUserNotifications(db database.QueryExecuter, pb *sdk.PipelineBuild, status sdk.Status, previous *sdk.PipelineBuild) error {
 	//Load notif
 	userNotifs, err := LoadUserNotificationSettings(db, pb.Application.ID, pb.Pipeline.ID, pb.Environment.ID)
 	if err != nil {
-		log.Critical("notification.SendPipelineBuild> error while loading user notification settings : %s", err)
-		return
-	}
-	if userNotifs == nil {
-		log.Debug("notification.SendPipelineBuild> no user notification on pipeline %d, app %d, env %d", pb.Application.ID, pb.Pipeline.ID, pb.Environment.ID)
-		return
+		return fmt.Errorf("cannot load user notification settings: %s", err)
 	}
 
 	//Compute notification
-	params := map[string]string{}
-	for _, p := range pb.Parameters {
-		params[p.Name] = p.Value
-	}
-	params["cds.status"] = n.Status.String()
-	//Set PipelineBuild UI URL
-	params["cds.buildURL"] = fmt.Sprintf("%s/#/project/%s/application/%s/pipeline/%s/build/%d?env=%s&tab=detail", baseURL, pb.Pipeline.ProjectKey, pb.Application.Name, pb.Pipeline.Name, pb.BuildNumber, pb.Environment.Name)
-	//find author (triggeredBy user or changes author)
-	if pb.Trigger.TriggeredBy != nil {
-		params["cds.author"] = pb.Trigger.TriggeredBy.Username
-	} else if pb.Trigger.VCSChangesAuthor != "" {
-		params["cds.author"] = pb.Trigger.VCSChangesAuthor
+	params := PipelineParameters(db, pb, status)
+
+	//Send to users subscribed to the project
+	sendSubscriptions(db, pb, previous, params)
+
+	//Send UserNotif
+	if userNotifs == nil {
+		log.Debug("notification.SendPipelineBuild> no user notification on pipeline %d, app %d, env %d", pb.Application.ID, pb.Pipeline.ID, pb.Environment.ID)
+		return nil
 	}
 
 	for t, notif := range userNotifs.Notifications {
@@ -68,9 +76,12 @@ func SendPipeline(db database.QueryExecuter, pb *sdk.PipelineBuild, event sdk.No
 					u, err := permission.ApplicationPipelineEnvironmentUsers(db, pb.Application.ID, pb.Pipeline.ID, pb.Environment.ID, permission.PermissionRead)
 					if err != nil {
 						log.Critical("notification[Jabber].SendPipelineBuild> error while loading permission :%s", err.Error())
-						return
+						return nil
 					}
 					for i := range u {
+						if muted(&u[i], pb) {
+							continue
+						}
 						jn.Recipients = append(jn.Recipients, u[i].Username)
 					}
 				}
@@ -79,9 +90,12 @@ func SendPipeline(db database.QueryExecuter, pb *sdk.PipelineBuild, event sdk.No
 					u, err := permission.ApplicationPipelineEnvironmentUsers(db, pb.Application.ID, pb.Pipeline.ID, pb.Environment.ID, permission.PermissionRead)
 					if err != nil {
 						log.Critical("notification[Jabber].SendPipelineBuild> error while loading permission :%s", err.Error())
-						return
+						return nil
 					}
 					for i := range u {
+						if muted(&u[i], pb) {
+							continue
+						}
 						jn.Recipients = append(jn.Recipients, u[i].Username)
 					}
 				}
@@ -96,13 +110,14 @@ func SendPipeline(db database.QueryExecuter, pb *sdk.PipelineBuild, event sdk.No
 				//Finally deduplicate everyone
 				removeDuplicates(&jn.Recipients)
 
-				notif, err := jabberEmailNotif(pb, jn, params)
+				notif, err := jabberEmailNotif(db, pb, previous, jn, params, false)
 				if err != nil {
 					log.Critical("notification[Jabber].SendPipelineBuild> error getting jabber/email notification %s", err.Error())
+					continue
 				}
 
 				log.Notice("Notification[Jabber]> Send jabber notif '%s'", notif.Title)
-				go post(&notif)
+				post(db, &notif)
 
 			case sdk.EmailUserNotification:
 				jn, ok := notif.(*sdk.JabberEmailUserNotificationSettings)
@@ -114,9 +129,12 @@ func SendPipeline(db database.QueryExecuter, pb *sdk.PipelineBuild, event sdk.No
 					u, err := permission.ApplicationPipelineEnvironmentUsers(db, pb.Application.ID, pb.Pipeline.ID, pb.Environment.ID, permission.PermissionRead)
 					if err != nil {
 						log.Critical("notification[Email].SendPipelineBuild> error while loading permission :%s", err.Error())
-						return
+						return nil
 					}
 					for i := range u {
+						if muted(&u[i], pb) {
+							continue
+						}
 						jn.Recipients = append(jn.Recipients, u[i].Email)
 					}
 				}
@@ -139,16 +157,68 @@ func SendPipeline(db database.QueryExecuter, pb *sdk.PipelineBuild, event sdk.No
 				//Finally deduplicate everyone
 				removeDuplicates(&jn.Recipients)
 
-				notif, err := jabberEmailNotif(pb, jn, params)
+				notif, err := jabberEmailNotif(db, pb, previous, jn, params, true)
 				if err != nil {
 					log.Critical("notification[Email].SendPipelineBuild> error getting jabber/email notification %s", err.Error())
+					continue
 				}
 
 				log.Notice("Notification[Email]> Send mail notif '%s'", notif.Title)
-				go SendMailNotif(&notif)
+				SendMailNotif(db, &notif)
+
+			case sdk.WebhookUserNotification:
+				wh, ok := notif.(*sdk.WebhookUserNotificationSettings)
+				if !ok {
+					log.Critical("notification.SendPipelineBuild> cannot deal with %s", notif)
+					continue
+				}
+
+				log.Notice("Notification[Webhook]> Send webhook notif to %s", wh.URL)
+				sendWebhook(db, pb, previous, wh, params)
+
+			case sdk.ChatUserNotification:
+				cn, ok := notif.(*sdk.ChatUserNotificationSettings)
+				if !ok {
+					log.Critical("notification.SendPipelineBuild> cannot deal with %s", notif)
+					continue
+				}
+
+				log.Notice("Notification[Chat]> Send chat notif to %d channels", len(cn.Channels))
+				sendChat(db, cn, pb, previous, params)
 			}
 		}
 	}
+	return nil
+}
+
+// PipelineParameters computes parameters of a pipeline build exposed to notification templates
+func PipelineParameters(db database.Querier, pb *sdk.PipelineBuild, status sdk.Status) map[string]string {
+	params := map[string]string{}
+	for _, p := range pb.Parameters {
+		params[p.Name] = p.Value
+	}
+	params["cds.status"] = status.String()
+	//Set PipelineBuild UI URL
+	params["cds.buildURL"] = fmt.Sprintf("%s/#/project/%s/application/%s/pipeline/%s/build/%d?env=%s&tab=detail", baseURL, pb.Pipeline.ProjectKey, pb.Application.Name, pb.Pipeline.Name, pb.BuildNumber, pb.Environment.Name)
+	//find author (triggeredBy user or changes author)
+	if pb.Trigger.TriggeredBy != nil {
+		params["cds.author"] = pb.Trigger.TriggeredBy.Username
+	} else if pb.Trigger.VCSChangesAuthor != "" {
+		params["cds.author"] = pb.Trigger.VCSChangesAuthor
+	}
+
+	//Highlight tests failing since this build
+	if pb.Status == sdk.StatusFail {
+		failures, err := newTestFailures(db, pb.ID)
+		if err != nil {
+			log.Warning("notification.PipelineParameters> Cannot load new test failures of pb:%d: %s", pb.ID, err)
+		}
+		if len(failures) > 0 {
+			params["cds.tests.newFailures"] = strings.Join(failures, "\n")
+			params["cds.tests.newFailuresCount"] = fmt.Sprintf("%d", len(failures))
+		}
+	}
+	return params
 }
 
 func removeDuplicates(xs *[]string) {
@@ -196,13 +266,22 @@ func ShouldSendUserNotification(notif sdk.UserNotificationSettings, current *sdk
 	return false
 }
 
-func jabberEmailNotif(pb *sdk.PipelineBuild, notif *sdk.JabberEmailUserNotificationSettings, params map[string]string) (sdk.Notif, error) {
-	title := notif.Template.Subject
-	message := notif.Template.Body
-	for k, value := range params {
-		key := "{{." + k + "}}"
-		title = strings.Replace(title, key, value, -1)
-		message = strings.Replace(message, key, value, -1)
+// jabberEmailNotif renders notification of user notification settings, as HTML only when allowed
+// by the destination and asked by the template
+func jabberEmailNotif(db database.Querier, pb *sdk.PipelineBuild, previous *sdk.PipelineBuild, notif *sdk.JabberEmailUserNotificationSettings, params map[string]string, allowHTML bool) (sdk.Notif, error) {
+	tmpl, err := resolveTemplate(db, pb.Pipeline.ProjectKey, notif.Template)
+	if err != nil {
+		return sdk.Notif{}, fmt.Errorf("cannot load template %s: %s", notif.Template.Name, err)
+	}
+
+	html := allowHTML && tmpl.HTML
+	c := newTemplateContext(db, pb, previous, params)
+	title := c.render(tmpl.Subject, false)
+	message := c.render(tmpl.Body, html)
+
+	//Append new test failures unless template already shows them
+	if f, ok := params["cds.tests.newFailures"]; ok && !html && !strings.Contains(tmpl.Body, "newFailures") && !strings.Contains(tmpl.Body, "failingTests") {
+		message += fmt.Sprintf("\n\nNew test failures:\n%s", f)
 	}
 
 	n := sdk.Notif{
@@ -212,6 +291,7 @@ func jabberEmailNotif(pb *sdk.PipelineBuild, notif *sdk.JabberEmailUserNotificat
 		Destination: "jabber",
 		Title:       title,
 		Message:     message,
+		HTML:        html,
 	}
 	for _, r := range notif.Recipients {
 		n.Recipients = append(n.Recipients, r)
@@ -291,6 +371,44 @@ func ParseUserNotificationSettings(settings []byte) (map[sdk.UserNotificationSet
 				}
 				notifications[sdk.UserNotificationSettingsType(k)] = &x
 			}
+		case string(sdk.WebhookUserNotification):
+			if v != nil {
+				var x sdk.WebhookUserNotificationSettings
+				tmp, err := json.Marshal(v)
+				if err != nil {
+					log.Warning("ParseUserNotificationSettings> unable to parse WebhookUserNotificationSettings : %s", err)
+					return nil, sdk.ErrParseUserNotification
+				}
+				if err := json.Unmarshal(tmp, &x); err != nil {
+					log.Warning("ParseUserNotificationSettings> unable to parse WebhookUserNotificationSettings : %s", err)
+					return nil, sdk.ErrParseUserNotification
+				}
+				if x.URL == "" {
+					log.Warning("ParseUserNotificationSettings> webhook without url\n")
+					return nil, sdk.ErrParseUserNotification
+				}
+				notifications[sdk.UserNotificationSettingsType(k)] = &x
+			}
+		case string(sdk.ChatUserNotification):
+			if v != nil {
+				var x sdk.ChatUserNotificationSettings
+				tmp, err := json.Marshal(v)
+				if err != nil {
+					log.Warning("ParseUserNotificationSettings> unable to parse ChatUserNotificationSettings : %s", err)
+					return nil, sdk.ErrParseUserNotification
+				}
+				if err := json.Unmarshal(tmp, &x); err != nil {
+					log.Warning("ParseUserNotificationSettings> unable to parse ChatUserNotificationSettings : %s", err)
+					return nil, sdk.ErrParseUserNotification
+				}
+				for _, c := range x.Channels {
+					if c.URL == "" {
+						log.Warning("ParseUserNotificationSettings> chat channel without url\n")
+						return nil, sdk.ErrParseUserNotification
+					}
+				}
+				notifications[sdk.UserNotificationSettingsType(k)] = &x
+			}
 		default:
 			log.Critical("ParseUserNotificationSettings> unsupported %s", k)
 			return nil, sdk.ErrNotSupportedUserNotification
@@ -329,6 +447,9 @@ func LoadAllUserNotificationSettings(db database.Querier, appID int64) ([]sdk.Us
 		if err != nil {
 			return nil, err
 		}
+		if err := decryptSecrets(un.Notifications); err != nil {
+			return nil, err
+		}
 		n = append(n, un)
 	}
 
@@ -366,10 +487,95 @@ func LoadUserNotificationSettings(db database.Querier, appID, pipID, envID int64
 		log.Warning("notification.LoadUserNotificationSettings>2> %s", err)
 		return nil, err
 	}
+	if err := decryptSecrets(n.Notifications); err != nil {
+		log.Warning("notification.LoadUserNotificationSettings>3> %s", err)
+		return nil, err
+	}
 
 	return n, nil
 }
 
+// encryptSecrets returns a copy of notification settings to be stored, with encrypted secrets
+func encryptSecrets(notifs map[sdk.UserNotificationSettingsType]sdk.UserNotificationSettings) (map[sdk.UserNotificationSettingsType]sdk.UserNotificationSettings, error) {
+	res := make(map[sdk.UserNotificationSettingsType]sdk.UserNotificationSettings, len(notifs))
+	for t, settings := range notifs {
+		if cn, ok := settings.(*sdk.ChatUserNotificationSettings); ok {
+			encrypted, err := encryptChatTokens(cn)
+			if err != nil {
+				return nil, err
+			}
+			settings = encrypted
+		}
+		res[t] = settings
+	}
+	return res, nil
+}
+
+// decryptSecrets decrypts secrets of stored notification settings
+func decryptSecrets(notifs map[sdk.UserNotificationSettingsType]sdk.UserNotificationSettings) error {
+	for _, settings := range notifs {
+		if cn, ok := settings.(*sdk.ChatUserNotificationSettings); ok {
+			if err := decryptChatTokens(cn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RedactUserNotification hides secrets of notification settings returned by the API
+func RedactUserNotification(n *sdk.UserNotification) {
+	if n == nil {
+		return
+	}
+	for _, settings := range n.Notifications {
+		switch x := settings.(type) {
+		case *sdk.WebhookUserNotificationSettings:
+			redactWebhook(x)
+		case *sdk.ChatUserNotificationSettings:
+			for i := range x.Channels {
+				if x.Channels[i].Token != "" {
+					x.Channels[i].Token = sdk.PasswordPlaceholder
+				}
+			}
+		}
+	}
+}
+
+// KeepRedactedSecrets restores stored secrets of notification settings sent back redacted
+func KeepRedactedSecrets(stored, updated *sdk.UserNotification) {
+	if stored == nil || updated == nil {
+		return
+	}
+	for t, settings := range updated.Notifications {
+		switch x := settings.(type) {
+		case *sdk.WebhookUserNotificationSettings:
+			if s, ok := stored.Notifications[t].(*sdk.WebhookUserNotificationSettings); ok {
+				keepWebhookSecrets(s, x)
+			}
+		case *sdk.ChatUserNotificationSettings:
+			s, _ := stored.Notifications[t].(*sdk.ChatUserNotificationSettings)
+			for i := range x.Channels {
+				ch := &x.Channels[i]
+				if ch.Token != sdk.PasswordPlaceholder {
+					continue
+				}
+				// Channels are matched by url and name, a redacted token of a new channel is dropped
+				ch.Token = ""
+				if s == nil {
+					continue
+				}
+				for _, old := range s.Channels {
+					if old.URL == ch.URL && old.Channel == ch.Channel {
+						ch.Token = old.Token
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
 // DeleteNotification Delete a notifications for the given application/pipeline/environment
 func DeleteNotification(db database.QueryExecuter, appID, pipID, envID int64) error {
 	query := `
@@ -425,7 +631,12 @@ func InsertOrUpdateUserNotificationSettings(db database.QueryExecuter, appID, pi
 		notif.Environment.ID = envID
 	}
 
-	bytes, err := json.Marshal(notif.Notifications)
+	settings, err := encryptSecrets(notif.Notifications)
+	if err != nil {
+		log.Critical("notification.InsertOrUpdateUserNotificationSettings> Error encrypting notifications settings : %s", err)
+		return err
+	}
+	bytes, err := json.Marshal(settings)
 	if err != nil {
 		log.Critical("notification.InsertOrUpdateUserNotificationSettings> Error marshalling notifications settings : %s", err)
 		return err
@@ -453,6 +664,29 @@ func InsertOrUpdateUserNotificationSettings(db database.QueryExecuter, appID, pi
 	return nil
 }
 
+// newTestFailures returns suite/name of tests failing in given build which did not fail in previous build of the branch
+func newTestFailures(db database.Querier, pbID int64) ([]string, error) {
+	var data string
+	query := `SELECT tests FROM pipeline_build_test WHERE pipeline_build_id = $1`
+	if err := db.QueryRow(query, pbID).Scan(&data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var tests sdk.Tests
+	if err := json.Unmarshal([]byte(data), &tests); err != nil {
+		return nil, err
+	}
+
+	failures := make([]string, len(tests.NewFailures))
+	for i, t := range tests.NewFailures {
+		failures[i] = t.Suite + "/" + t.Name
+	}
+	return failures, nil
+}
+
 func pipelineInitiator(db database.Querier, username string) (*sdk.User, error) {
 	query := `
 		SELECT data FROM "user"
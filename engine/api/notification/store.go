@@ -38,6 +38,20 @@ func storeCleaner() {
 		if len(ids) > 0 {
 			Delete(db, ids)
 		}
+
+		//Webhook deliveries are kept longer, to investigate failures
+		query = `DELETE FROM notification_webhook_delivery WHERE created < $1`
+		if _, err := db.Exec(query, time.Now().Add(-30*24*time.Hour)); err != nil {
+			log.Warning("notification.storeCleaner> unable to delete webhook deliveries %s", err)
+		}
+		query = `DELETE FROM notification_outbox WHERE status = $1 AND created < $2`
+		if _, err := db.Exec(query, outboxDead, time.Now().Add(-30*24*time.Hour)); err != nil {
+			log.Warning("notification.storeCleaner> unable to delete dead notifications %s", err)
+		}
+		query = `DELETE FROM notification_chat_thread WHERE created < $1`
+		if _, err := db.Exec(query, time.Now().Add(-7*24*time.Hour)); err != nil {
+			log.Warning("notification.storeCleaner> unable to delete chat threads %s", err)
+		}
 	}
 }
 
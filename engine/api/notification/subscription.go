@@ -0,0 +1,158 @@
+package notification
+
+import (
+	"strings"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/permission"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// subscriptionTemplate is the mail sent to users subscribed to a project
+var subscriptionTemplate = sdk.UserNotificationTemplate{
+	Subject: "[CDS] {{.cds.project}}/{{.cds.application}} {{.cds.pipeline}}#{{.cds.buildNumber}} {{.cds.status}}",
+	Body: `Project     : {{.cds.project}}
+Application : {{.cds.application}}
+Pipeline    : {{.cds.pipeline}}#{{.cds.buildNumber}}
+Environment : {{.cds.environment}}
+Status      : {{.cds.status}}
+Details     : {{.cds.buildURL}}`,
+}
+
+// subscription returns the subscription of user covering pipeline build, the most specific one first
+func subscription(u *sdk.User, pb *sdk.PipelineBuild) *sdk.NotificationSubscription {
+	var found *sdk.NotificationSubscription
+	var score int
+	for i := range u.Subscriptions {
+		s := &u.Subscriptions[i]
+		if !s.Covers(pb.Pipeline.ProjectKey, pb.Application.Name, pb.Pipeline.Name) {
+			continue
+		}
+		sc := 1
+		if s.Application != "" {
+			sc++
+		}
+		if s.Pipeline != "" {
+			sc++
+		}
+		if sc > score {
+			found, score = s, sc
+		}
+	}
+	return found
+}
+
+// muted returns true when user muted notifications of pipeline build
+func muted(u *sdk.User, pb *sdk.PipelineBuild) bool {
+	s := subscription(u, pb)
+	return s != nil && s.Mute
+}
+
+// shouldNotifySubscriber returns true when subscription of user asks for a notification of the build
+func shouldNotifySubscriber(s *sdk.NotificationSubscription, username, author string, pb, previous *sdk.PipelineBuild) bool {
+	if s == nil || s.Mute {
+		return false
+	}
+	if s.OnlyMine && author != username {
+		return false
+	}
+	return ShouldSendUserNotification(s, pb, previous)
+}
+
+// loadSubscribers loads users with a subscription to given project
+func loadSubscribers(db database.Querier, projectKey string) ([]sdk.User, error) {
+	query := `SELECT "user".id, "user".username, "user".admin, "user".data FROM "user"
+		JOIN user_subscription ON user_subscription.user_id = "user".id
+		WHERE user_subscription.project_key = $1`
+	rows, err := db.Query(query, projectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []sdk.User{}
+	for rows.Next() {
+		var data string
+		var admin bool
+		u := sdk.User{}
+		if err := rows.Scan(&u.ID, &u.Username, &admin, &data); err != nil {
+			return nil, err
+		}
+		if _, err := u.FromJSON([]byte(data)); err != nil {
+			log.Warning("notification.loadSubscribers> Cannot parse user %s: %s\n", u.Username, err)
+			continue
+		}
+		u.Admin = admin
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// readers returns IDs of users allowed to read pipeline build
+func readers(db database.Querier, pb *sdk.PipelineBuild) (map[int64]bool, error) {
+	users, err := permission.ApplicationPipelineEnvironmentUsers(db, pb.Application.ID, pb.Pipeline.ID, pb.Environment.ID, permission.PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int64]bool, len(users))
+	for _, u := range users {
+		ids[u.ID] = true
+	}
+	return ids, nil
+}
+
+// canReadProject returns true when user still has read access on project
+func canReadProject(db database.Querier, u *sdk.User, projectKey string) (bool, error) {
+	if u.Admin {
+		return true, nil
+	}
+	query := `SELECT COUNT(project_group.group_id) FROM project_group
+		JOIN project ON project.id = project_group.project_id
+		JOIN group_user ON group_user.group_id = project_group.group_id
+		WHERE project.projectkey = $1 AND group_user.user_id = $2 AND project_group.role >= $3`
+	var n int64
+	if err := db.QueryRow(query, projectKey, u.ID, permission.PermissionRead).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// sendSubscriptions mails pipeline build to users whose subscription asks for it
+func sendSubscriptions(db database.QueryExecuter, pb *sdk.PipelineBuild, previous *sdk.PipelineBuild, params map[string]string) {
+	users, err := loadSubscribers(db, pb.Pipeline.ProjectKey)
+	if err != nil {
+		log.Warning("notification.sendSubscriptions> Cannot load subscribers of %s: %s\n", pb.Pipeline.ProjectKey, err)
+		return
+	}
+
+	allowed, err := readers(db, pb)
+	if err != nil {
+		log.Warning("notification.sendSubscriptions> Cannot load readers of %s: %s\n", pb.Application.Name, err)
+		return
+	}
+
+	settings := &sdk.JabberEmailUserNotificationSettings{Template: subscriptionTemplate}
+	for i := range users {
+		u := &users[i]
+		if u.Email == "" || !shouldNotifySubscriber(subscription(u, pb), u.Username, params["cds.author"], pb, previous) {
+			continue
+		}
+		// Permissions may have been revoked since subscription
+		if !u.Admin && !allowed[u.ID] {
+			continue
+		}
+		settings.Recipients = append(settings.Recipients, u.Email)
+	}
+	if len(settings.Recipients) == 0 {
+		return
+	}
+
+	notif, err := jabberEmailNotif(db, pb, previous, settings, params, true)
+	if err != nil {
+		log.Warning("notification.sendSubscriptions> Cannot compute notification: %s\n", err)
+		return
+	}
+	log.Notice("notification.sendSubscriptions> Send mail notif '%s' to %s", notif.Title, strings.Join(notif.Recipients, ","))
+	SendMailNotif(db, &notif)
+}
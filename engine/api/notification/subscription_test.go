@@ -0,0 +1,115 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestSubscription(t *testing.T) {
+	pb := &sdk.PipelineBuild{
+		Status:      sdk.StatusFail,
+		Pipeline:    sdk.Pipeline{Name: "build", ProjectKey: "PRJ"},
+		Application: sdk.Application{Name: "app"},
+	}
+	u := &sdk.User{
+		Username: "john",
+		Subscriptions: []sdk.NotificationSubscription{
+			{ProjectKey: "OTHER", OnFailure: sdk.UserNotificationAlways},
+			{ProjectKey: "PRJ", OnFailure: sdk.UserNotificationAlways},
+			{ProjectKey: "PRJ", Application: "app", OnFailure: sdk.UserNotificationAlways, OnlyMine: true},
+		},
+	}
+
+	s := subscription(u, pb)
+	if s == nil || s.Application != "app" {
+		t.Fatalf("expected subscription to application, got %+v", s)
+	}
+	if shouldNotifySubscriber(s, "john", "jane", pb, nil) {
+		t.Errorf("john should not be notified of builds of jane")
+	}
+	if !shouldNotifySubscriber(s, "john", "john", pb, nil) {
+		t.Errorf("john should be notified of his failed build")
+	}
+
+	s.Mute = true
+	if shouldNotifySubscriber(s, "john", "john", pb, nil) {
+		t.Errorf("muted subscription should not notify")
+	}
+
+	pb.Pipeline.ProjectKey = "NONE"
+	if subscription(u, pb) != nil {
+		t.Errorf("no subscription expected")
+	}
+}
+
+func TestMuted(t *testing.T) {
+	pb := &sdk.PipelineBuild{
+		Pipeline:    sdk.Pipeline{Name: "build", ProjectKey: "PRJ"},
+		Application: sdk.Application{Name: "app"},
+	}
+	u := &sdk.User{
+		Username: "john",
+		Subscriptions: []sdk.NotificationSubscription{
+			{ProjectKey: "PRJ", OnFailure: sdk.UserNotificationAlways},
+		},
+	}
+
+	// A subscriber still receives notifications sent to groups
+	if muted(u, pb) {
+		t.Errorf("subscription without mute should not skip group notifications")
+	}
+
+	u.Subscriptions = append(u.Subscriptions, sdk.NotificationSubscription{ProjectKey: "PRJ", Application: "app", Mute: true})
+	if !muted(u, pb) {
+		t.Errorf("muted application should skip group notifications")
+	}
+
+	pb.Application.Name = "other"
+	if muted(u, pb) {
+		t.Errorf("only muted application should be skipped")
+	}
+}
+
+func TestDigestPeriod(t *testing.T) {
+	monday := time.Date(2016, time.October, 17, 9, 0, 0, 0, time.UTC)
+
+	if _, ok := digestPeriod(sdk.DigestDaily, monday.Add(-2*time.Hour)); ok {
+		t.Errorf("no digest expected before %dh", digestHour)
+	}
+	if p, ok := digestPeriod(sdk.DigestDaily, monday); !ok || p != 24*time.Hour {
+		t.Errorf("daily digest expected")
+	}
+	if p, ok := digestPeriod(sdk.DigestWeekly, monday); !ok || p != 7*24*time.Hour {
+		t.Errorf("weekly digest expected on monday")
+	}
+	if _, ok := digestPeriod(sdk.DigestWeekly, monday.Add(24*time.Hour)); ok {
+		t.Errorf("no weekly digest expected on tuesday")
+	}
+	if _, ok := digestPeriod(sdk.DigestNever, monday); ok {
+		t.Errorf("no digest expected")
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	projects := []digestProject{{
+		Key:      "PRJ",
+		Failures: []digestFailure{{Application: "app", Pipeline: "build", Environment: "NoEnv", Count: 3, LastBuildNumber: 42}},
+		Slowest:  []digestDuration{{Application: "app", Pipeline: "build", Seconds: 90, Count: 10}},
+		Pending:  []digestApproval{{Application: "app", Pipeline: "build", DestApplication: "app", DestPipeline: "deploy", DestEnv: "prod", Version: 12}},
+	}}
+
+	out := renderDigest(projects, time.Date(2016, time.October, 17, 9, 0, 0, 0, time.UTC))
+	for _, want := range []string{
+		"== PRJ ==",
+		"app build/NoEnv: 3 failed builds, last #42",
+		"app build: 1m30s on average over 10 builds",
+		"app build v12 -> app deploy/prod",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("digest does not contain %q:\n%s", want, out)
+		}
+	}
+}
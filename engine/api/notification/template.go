@@ -0,0 +1,337 @@
+package notification
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/repositoriesmanager"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// templateContext renders notification templates of a pipeline build.
+// Parameters are exposed as nested maps, so {{.cds.status}} reads parameter cds.status,
+// and the build itself as {{.Build}}
+type templateContext struct {
+	db       database.Querier
+	pb       *sdk.PipelineBuild
+	previous *sdk.PipelineBuild
+	params   map[string]string
+
+	commits []sdk.VCSCommit
+	loaded  bool
+}
+
+func newTemplateContext(db database.Querier, pb, previous *sdk.PipelineBuild, params map[string]string) *templateContext {
+	return &templateContext{db: db, pb: pb, previous: previous, params: params}
+}
+
+// templateData splits parameter names on dots. When a name is both a value and
+// the prefix of other names, the nested names win
+func templateData(pb *sdk.PipelineBuild, params map[string]string) map[string]interface{} {
+	data := map[string]interface{}{}
+	for k, v := range params {
+		parts := []string{}
+		for _, p := range strings.Split(k, ".") {
+			if p != "" {
+				parts = append(parts, p)
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		m := data
+		for _, p := range parts[:len(parts)-1] {
+			sub, ok := m[p].(map[string]interface{})
+			if !ok {
+				sub = map[string]interface{}{}
+				m[p] = sub
+			}
+			m = sub
+		}
+		last := parts[len(parts)-1]
+		if _, ok := m[last].(map[string]interface{}); !ok {
+			m[last] = v
+		}
+	}
+	if pb != nil {
+		data["Build"] = pb
+	}
+	return data
+}
+
+func (c *templateContext) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"param":        func(name string) string { return c.params[name] },
+		"duration":     formatDuration,
+		"commits":      c.loadCommits,
+		"failingTests": c.failingTests,
+		"link":         link,
+		"short":        short,
+		"join":         strings.Join,
+		"upper":        strings.ToUpper,
+		"lower":        strings.ToLower,
+	}
+}
+
+// formatDuration formats time elapsed between from and to, rounded to the second
+func formatDuration(from, to time.Time) string {
+	if from.IsZero() || to.Before(from) {
+		return ""
+	}
+	return (to.Sub(from) / time.Second * time.Second).String()
+}
+
+// link formats a link as label (url)
+func link(label, url string) string {
+	if url == "" {
+		return label
+	}
+	return fmt.Sprintf("%s (%s)", label, url)
+}
+
+// short returns the abbreviated form of a commit hash
+func short(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+func (c *templateContext) failingTests() []string {
+	f := c.params["cds.tests.newFailures"]
+	if f == "" {
+		return nil
+	}
+	return strings.Split(f, "\n")
+}
+
+// loadCommits loads commits built since previous build from the repositories manager of the application
+func (c *templateContext) loadCommits() []sdk.VCSCommit {
+	if c.loaded {
+		return c.commits
+	}
+	c.loaded = true
+
+	if c.db == nil || c.pb == nil || c.pb.Trigger.VCSChangesHash == "" {
+		return nil
+	}
+
+	var rmName, repo sql.NullString
+	query := `SELECT repositories_manager.name, application.repo_fullname FROM application
+		JOIN repositories_manager ON repositories_manager.id = application.repositories_manager_id
+		WHERE application.id = $1`
+	if err := c.db.QueryRow(query, c.pb.Application.ID).Scan(&rmName, &repo); err != nil {
+		if err != sql.ErrNoRows {
+			log.Warning("notification.loadCommits> Cannot load repository of application %d: %s\n", c.pb.Application.ID, err)
+		}
+		return nil
+	}
+	if !repo.Valid || repo.String == "" {
+		return nil
+	}
+
+	client, err := repositoriesmanager.AuthorizedClient(c.db, c.pb.Pipeline.ProjectKey, rmName.String)
+	if err != nil {
+		log.Warning("notification.loadCommits> Cannot get client of %s: %s\n", rmName.String, err)
+		return nil
+	}
+
+	hash := c.pb.Trigger.VCSChangesHash
+	if c.previous != nil && c.previous.Trigger.VCSChangesHash != "" && c.previous.Trigger.VCSChangesHash != hash {
+		commits, err := client.Commits(repo.String, c.previous.Trigger.VCSChangesHash, hash)
+		if err != nil {
+			log.Warning("notification.loadCommits> Cannot load commits of %s: %s\n", repo.String, err)
+			return nil
+		}
+		c.commits = commits
+		return c.commits
+	}
+
+	commit, err := client.Commit(repo.String, hash)
+	if err != nil {
+		log.Warning("notification.loadCommits> Cannot load commit %s of %s: %s\n", hash, repo.String, err)
+		return nil
+	}
+	c.commits = []sdk.VCSCommit{commit}
+	return c.commits
+}
+
+// execute renders tmpl, with html/template escaping when html is set
+func (c *templateContext) execute(tmpl string, html bool) (string, error) {
+	var b bytes.Buffer
+	data := templateData(c.pb, c.params)
+
+	if html {
+		t, err := htmltemplate.New("notification").Funcs(htmltemplate.FuncMap(c.funcs())).Parse(tmpl)
+		if err != nil {
+			return "", err
+		}
+		if err := t.Execute(&b, data); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+
+	t, err := texttemplate.New("notification").Funcs(texttemplate.FuncMap(c.funcs())).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// render renders tmpl, falling back to substitution of {{.param}} when it is not a valid template,
+// as parameter names are not always valid template identifiers
+func (c *templateContext) render(tmpl string, html bool) string {
+	s, err := c.execute(tmpl, html)
+	if err != nil {
+		log.Debug("notification.render> Cannot execute template, falling back to parameters substitution: %s", err)
+		return applyTemplate(tmpl, c.params)
+	}
+	return s
+}
+
+// ValidateTemplate checks subject and body of a notification template parse
+func ValidateTemplate(t *sdk.NotificationTemplate) error {
+	c := newTemplateContext(nil, nil, nil, nil)
+	if _, err := texttemplate.New("subject").Funcs(texttemplate.FuncMap(c.funcs())).Parse(t.Subject); err != nil {
+		return err
+	}
+	if t.HTML {
+		_, err := htmltemplate.New("body").Funcs(htmltemplate.FuncMap(c.funcs())).Parse(t.Body)
+		return err
+	}
+	_, err := texttemplate.New("body").Funcs(texttemplate.FuncMap(c.funcs())).Parse(t.Body)
+	return err
+}
+
+// resolveTemplate returns the template of project named by t, or t itself
+func resolveTemplate(db database.Querier, projectKey string, t sdk.UserNotificationTemplate) (sdk.UserNotificationTemplate, error) {
+	if t.Name == "" {
+		return t, nil
+	}
+	pt, err := LoadProjectTemplate(db, projectKey, t.Name)
+	if err != nil {
+		return t, err
+	}
+	return sdk.UserNotificationTemplate{Name: pt.Name, Subject: pt.Subject, Body: pt.Body, HTML: pt.HTML}, nil
+}
+
+// RenderTemplate renders a notification template against a pipeline build
+func RenderTemplate(db database.Querier, pb, previous *sdk.PipelineBuild, t sdk.UserNotificationTemplate, params map[string]string) (sdk.NotificationTemplateRendering, error) {
+	t, err := resolveTemplate(db, pb.Pipeline.ProjectKey, t)
+	if err != nil {
+		return sdk.NotificationTemplateRendering{}, err
+	}
+
+	c := newTemplateContext(db, pb, previous, params)
+	r := sdk.NotificationTemplateRendering{HTML: t.HTML}
+	if r.Subject, err = c.execute(t.Subject, false); err != nil {
+		return r, err
+	}
+	r.Body, err = c.execute(t.Body, t.HTML)
+	return r, err
+}
+
+// LoadProjectTemplates loads notification templates of a project
+func LoadProjectTemplates(db database.Querier, projectKey string) ([]sdk.NotificationTemplate, error) {
+	query := `SELECT t.id, t.name, t.subject, t.body, t.html, t.last_modified
+		FROM project_notification_template t
+		JOIN project ON project.id = t.project_id
+		WHERE project.projectkey = $1 ORDER BY t.name`
+	rows, err := db.Query(query, projectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []sdk.NotificationTemplate{}
+	for rows.Next() {
+		var t sdk.NotificationTemplate
+		if err := rows.Scan(&t.ID, &t.Name, &t.Subject, &t.Body, &t.HTML, &t.LastModified); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// LoadProjectTemplate loads a notification template of a project by its name
+func LoadProjectTemplate(db database.Querier, projectKey, name string) (*sdk.NotificationTemplate, error) {
+	query := `SELECT t.id, t.name, t.subject, t.body, t.html, t.last_modified
+		FROM project_notification_template t
+		JOIN project ON project.id = t.project_id
+		WHERE project.projectkey = $1 AND t.name = $2`
+	var t sdk.NotificationTemplate
+	err := db.QueryRow(query, projectKey, name).Scan(&t.ID, &t.Name, &t.Subject, &t.Body, &t.HTML, &t.LastModified)
+	if err == sql.ErrNoRows {
+		return nil, sdk.ErrNotificationTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// InsertProjectTemplate creates a notification template in a project
+func InsertProjectTemplate(db database.Querier, projectID int64, t *sdk.NotificationTemplate) error {
+	t.LastModified = time.Now()
+	query := `INSERT INTO project_notification_template (project_id, name, subject, body, html, last_modified)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	return db.QueryRow(query, projectID, t.Name, t.Subject, t.Body, t.HTML, t.LastModified).Scan(&t.ID)
+}
+
+// UpdateProjectTemplate updates a notification template of a project
+func UpdateProjectTemplate(db database.Executer, t *sdk.NotificationTemplate) error {
+	t.LastModified = time.Now()
+	query := `UPDATE project_notification_template SET subject = $2, body = $3, html = $4, last_modified = $5 WHERE id = $1`
+	_, err := db.Exec(query, t.ID, t.Subject, t.Body, t.HTML, t.LastModified)
+	return err
+}
+
+// ProjectTemplateUsed returns true when notifications of an application of project refer to template by its name
+func ProjectTemplateUsed(db database.Querier, projectKey, name string) (bool, error) {
+	query := `SELECT application_pipeline_notif.settings FROM application_pipeline_notif
+		JOIN application_pipeline ON application_pipeline.id = application_pipeline_notif.application_pipeline_id
+		JOIN application ON application.id = application_pipeline.application_id
+		JOIN project ON project.id = application.project_id
+		WHERE project.projectkey = $1`
+	rows, err := db.Query(query, projectKey)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var settings string
+		if err := rows.Scan(&settings); err != nil {
+			return false, err
+		}
+		notifs, err := ParseUserNotificationSettings([]byte(settings))
+		if err != nil {
+			return false, err
+		}
+		for _, n := range notifs {
+			if jn, ok := n.(*sdk.JabberEmailUserNotificationSettings); ok && jn.Template.Name == name {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// DeleteProjectTemplate removes a notification template of a project
+func DeleteProjectTemplate(db database.Executer, id int64) error {
+	_, err := db.Exec(`DELETE FROM project_notification_template WHERE id = $1`, id)
+	return err
+}
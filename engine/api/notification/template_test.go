@@ -0,0 +1,97 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestTemplateData(t *testing.T) {
+	data := templateData(nil, map[string]string{
+		"cds.status":            "Success",
+		"cds.tests":             "ignored",
+		"cds.tests.newFailures": "a",
+		"foo":                   "bar",
+	})
+
+	cds := data["cds"].(map[string]interface{})
+	if cds["status"] != "Success" {
+		t.Errorf("unexpected status %v", cds["status"])
+	}
+	tests, ok := cds["tests"].(map[string]interface{})
+	if !ok || tests["newFailures"] != "a" {
+		t.Errorf("unexpected tests %v", cds["tests"])
+	}
+	if data["foo"] != "bar" {
+		t.Errorf("unexpected foo %v", data["foo"])
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	pb := &sdk.PipelineBuild{
+		BuildNumber: 42,
+		Start:       time.Date(2016, 1, 1, 10, 0, 0, 0, time.UTC),
+		Done:        time.Date(2016, 1, 1, 10, 1, 30, 500, time.UTC),
+	}
+	params := map[string]string{
+		"cds.status":            "Fail",
+		"cds.application":       "app",
+		"cds.app.my-var":        "value",
+		"cds.tests.newFailures": "TestA\nTestB",
+	}
+	c := newTemplateContext(nil, pb, nil, params)
+
+	tests := []struct {
+		tmpl string
+		html bool
+		want string
+	}{
+		{`{{.cds.application}} #{{.Build.BuildNumber}} {{.cds.status}}`, false, "app #42 Fail"},
+		{`{{param "cds.app.my-var"}}`, false, "value"},
+		{`{{duration .Build.Start .Build.Done}}`, false, "1m30s"},
+		{`{{range failingTests}}- {{.}} {{end}}`, false, "- TestA - TestB "},
+		{`{{link "build" "http://cds"}} {{short "0123456789"}}`, false, "build (http://cds) 0123456"},
+		{`<b>{{.cds.application}}</b>{{"<i>"}}`, true, "<b>app</b>&lt;i&gt;"},
+		// not a valid template, parameters are substituted
+		{`{{.cds.app.my-var}} {{.cds.status}}`, false, "value Fail"},
+	}
+	for _, test := range tests {
+		if got := c.render(test.tmpl, test.html); got != test.want {
+			t.Errorf("render(%q) = %q, want %q", test.tmpl, got, test.want)
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	if err := ValidateTemplate(&sdk.NotificationTemplate{Subject: "{{.cds.status}}", Body: "{{range commits}}{{.Message}}{{end}}"}); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+	if err := ValidateTemplate(&sdk.NotificationTemplate{Subject: "{{if .cds.status}}", Body: ""}); err == nil {
+		t.Errorf("expected error on unclosed action")
+	}
+}
+
+func TestJabberEmailNotifHTML(t *testing.T) {
+	pb := &sdk.PipelineBuild{Pipeline: sdk.Pipeline{ProjectKey: "PRJ"}}
+	settings := &sdk.JabberEmailUserNotificationSettings{
+		Template: sdk.UserNotificationTemplate{Subject: "{{.cds.status}}", Body: "<b>{{.cds.status}}</b>", HTML: true},
+	}
+	params := map[string]string{"cds.status": "<Fail>"}
+
+	mail, err := jabberEmailNotif(nil, pb, nil, settings, params, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mail.HTML || mail.Message != "<b>&lt;Fail&gt;</b>" {
+		t.Errorf("unexpected mail %v %q", mail.HTML, mail.Message)
+	}
+
+	jabber, err := jabberEmailNotif(nil, pb, nil, settings, params, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jabber.HTML || jabber.Message != "<b><Fail></b>" {
+		t.Errorf("jabber notification should not be HTML: %v %q", jabber.HTML, jabber.Message)
+	}
+}
@@ -2,7 +2,6 @@ package notification
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -10,7 +9,6 @@ import (
 	"time"
 
 	"github.com/ovh/cds/engine/api/database"
-	"github.com/ovh/cds/engine/api/mail"
 	"github.com/ovh/cds/engine/log"
 	"github.com/ovh/cds/sdk"
 )
@@ -82,8 +80,12 @@ func Initialize(urls, key, base string) {
 
 		notifON = true
 		go statusChecker()
-		go storeCleaner()
 	}
+
+	//Mails and webhooks are delivered without notifications systems
+	go dispatcher()
+	go storeCleaner()
+	go digestScheduler()
 }
 
 func statusChecker() {
@@ -119,60 +121,43 @@ func Status() []string {
 	for k, v := range notifsSystemStatus {
 		ret = append(ret, "Notif "+k+": "+v)
 	}
+	if db := database.DB(); db != nil {
+		pending, dead, err := outboxStatus(db)
+		if err != nil {
+			ret = append(ret, fmt.Sprintf("Notif outbox: KO (error: %s)", err))
+		} else {
+			ret = append(ret, fmt.Sprintf("Notif outbox: %d pending, %d dead", pending, dead))
+		}
+	}
 	return ret
 }
 
-func post(notif *sdk.Notif) {
-	db := database.DB()
-	if db == nil {
-		return
-	}
-	var jsonStr []byte
-
-	jsonStr, err := json.Marshal(notif)
-	if err != nil {
-		log.Critical("notification.post> error while marshalling json for a user notification", err.Error())
+// post writes notification in outbox, for each notifications system accepting it
+func post(db database.QueryExecuter, notif *sdk.Notif) {
+	if !notifON {
 		return
 	}
 
 	var sent bool
-	if !notifON {
-		return
-	}
 	//notifsURLs is set on startup
-	for system, url := range notifsSystems {
+	for system := range notifsSystems {
 		//Only actionBuild, pipelineBuild and Builtin may be sent to tat.
 		//Only pipeline notif may be sent to stash
 		//Only user notif may be sent to jabber
 		if (notif.NotifType != sdk.UserNotif && system == cds2tat) ||
 			(notif.NotifType == sdk.PipelineBuildNotif && system == cds2stash) ||
 			(notif.NotifType == sdk.UserNotif && system == cds2xmpp) {
+			var userNotificationID int64
 			if notif.NotifType == sdk.UserNotif {
 				if err := Insert(db, notif, system); err != nil {
 					log.Critical("notification.post> error while inserting user notification in DB", err.Error())
 				}
-
+				userNotificationID = notif.ID
+			}
+			if err := enqueue(db, outboxSystem, system, notif, userNotificationID); err != nil {
+				log.Critical("notification.post> error while writing notification in outbox: %s", err)
+				continue
 			}
-			path := getPath(system, notif.NotifType)
-			_innerPost(url+path, jsonStr, func(resp *http.Response, err error) {
-				if notif.NotifType == sdk.UserNotif {
-					if err != nil {
-						if err := Update(db, notif, "ERROR : "+err.Error()); err != nil {
-							log.Warning("notification.post> update failed : %s", err)
-						}
-					} else {
-						if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusBadRequest {
-							if err := Update(db, notif, "SUCCESS"); err != nil {
-								log.Warning("notification.post> update failed : %s", err)
-							}
-						} else {
-							if err := Update(db, notif, "ERROR : "+resp.Status); err != nil {
-								log.Warning("notification.post> update failed : %s", err)
-							}
-						}
-					}
-				}
-			})
 			sent = true
 		}
 	}
@@ -250,24 +235,13 @@ func _innerPost(requestPath string, jsonStr []byte, callback func(*http.Response
 	}
 }
 
-// SendMailNotif Send user notification by mail
-func SendMailNotif(notif *sdk.Notif) {
-	db := database.DB()
-	if db == nil {
-		return
+// SendMailNotif writes user notification by mail in outbox
+func SendMailNotif(db database.QueryExecuter, notif *sdk.Notif) {
+	if err := Insert(db, notif, "email"); err != nil {
+		log.Warning("notification.SendMailNotif> error while inserting user notification in DB: %s", err)
 	}
-	Insert(db, notif, "email")
 	log.Notice("notification.SendMailNotif> Send notif '%s'", notif.Title)
-	errors := []string{}
-	for _, recipient := range notif.Recipients {
-		if err := mail.SendEmail(notif.Title, bytes.NewBufferString(notif.Message), recipient); err != nil {
-			errors = append(errors, err.Error())
-		}
+	if err := enqueue(db, outboxMail, "email", notif, notif.ID); err != nil {
+		log.Critical("notification.SendMailNotif> error while writing notification in outbox: %s", err)
 	}
-	if len(errors) > 0 {
-		Update(db, notif, "ERROR : "+strings.Join(errors, ", "))
-	} else {
-		Update(db, notif, "SUCCESS")
-	}
-
 }
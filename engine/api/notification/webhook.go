@@ -0,0 +1,200 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// webhookSignatureHeader holds the HMAC-SHA256 of the body, computed with the secret of the webhook
+const webhookSignatureHeader = "X-CDS-Signature"
+
+// applyTemplate replaces {{.key}} in tmpl with params values
+func applyTemplate(tmpl string, params map[string]string) string {
+	for k, value := range params {
+		tmpl = strings.Replace(tmpl, "{{."+k+"}}", value, -1)
+	}
+	return tmpl
+}
+
+// jsonString escapes v to be written inside a JSON string
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(fmt.Sprint(v))
+	return string(b[1 : len(b)-1])
+}
+
+// escapeJSON pipes the output of every action of a template tree to jsonString,
+// so values rendered between quotes of a JSON template keep it valid
+func escapeJSON(n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			escapeJSON(c)
+		}
+	case *parse.ActionNode:
+		// Variable declarations do not output anything
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		cmd := &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos}
+		cmd.Args = []parse.Node{parse.NewIdentifier("jsonString").SetPos(n.Pos)}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, cmd)
+	case *parse.IfNode:
+		escapeJSON(n.List)
+		escapeJSON(n.ElseList)
+	case *parse.RangeNode:
+		escapeJSON(n.List)
+		escapeJSON(n.ElseList)
+	case *parse.WithNode:
+		escapeJSON(n.List)
+		escapeJSON(n.ElseList)
+	}
+}
+
+// renderJSON renders a JSON template, escaping all rendered values. It falls back to
+// substitution of escaped {{.param}} when tmpl is not a valid template
+func (c *templateContext) renderJSON(tmpl string) string {
+	funcs := c.funcs()
+	funcs["jsonString"] = jsonString
+	t, err := texttemplate.New("webhook").Funcs(texttemplate.FuncMap(funcs)).Parse(tmpl)
+	if err == nil {
+		escapeJSON(t.Tree.Root)
+		var b bytes.Buffer
+		if err = t.Execute(&b, templateData(c.pb, c.params)); err == nil {
+			return b.String()
+		}
+	}
+	log.Debug("notification.renderJSON> Cannot execute template, falling back to parameters substitution: %s", err)
+
+	params := make(map[string]string, len(c.params))
+	for k, v := range c.params {
+		params[k] = jsonString(v)
+	}
+	return applyTemplate(tmpl, params)
+}
+
+// webhookBody renders the template of the webhook, or the JSON of build parameters when there is none
+func webhookBody(c *templateContext, wh *sdk.WebhookUserNotificationSettings) ([]byte, error) {
+	if wh.Template == "" {
+		return json.Marshal(c.params)
+	}
+	return []byte(c.renderJSON(wh.Template)), nil
+}
+
+// webhookSignature returns the value of signature header for given body
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhook writes webhook notification of a pipeline build in outbox,
+// each attempt to post it is stored as a delivery of the build
+func sendWebhook(db database.QueryExecuter, pb, previous *sdk.PipelineBuild, wh *sdk.WebhookUserNotificationSettings, params map[string]string) {
+	body, err := webhookBody(newTemplateContext(db, pb, previous, params), wh)
+	if err != nil {
+		log.Warning("notification.sendWebhook> Cannot render body of webhook %s: %s\n", wh.URL, err)
+		return
+	}
+
+	p := webhookPayload{PipelineBuildID: pb.ID, Webhook: *wh, Body: string(body)}
+	if err := enqueue(db, outboxWebhook, wh.URL, p, 0); err != nil {
+		log.Critical("notification.sendWebhook> Cannot write webhook %s in outbox: %s\n", wh.URL, err)
+	}
+}
+
+// redactWebhook hides secret and header values of webhook settings
+func redactWebhook(wh *sdk.WebhookUserNotificationSettings) {
+	if wh.Secret != "" {
+		wh.Secret = sdk.PasswordPlaceholder
+	}
+	if len(wh.Headers) == 0 {
+		return
+	}
+	headers := make(map[string]string, len(wh.Headers))
+	for k := range wh.Headers {
+		headers[k] = sdk.PasswordPlaceholder
+	}
+	wh.Headers = headers
+}
+
+// keepWebhookSecrets restores secret and header values left redacted in updated settings
+func keepWebhookSecrets(stored, updated *sdk.WebhookUserNotificationSettings) {
+	if updated.Secret == sdk.PasswordPlaceholder {
+		updated.Secret = stored.Secret
+	}
+	for k, v := range updated.Headers {
+		if v == sdk.PasswordPlaceholder {
+			updated.Headers[k] = stored.Headers[k]
+		}
+	}
+}
+
+func postWebhook(wh *sdk.WebhookUserNotificationSettings, body []byte) (int, error) {
+	req, err := http.NewRequest("POST", wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range wh.Headers {
+		req.Header.Set(k, v)
+	}
+	if wh.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, webhookSignature(wh.Secret, body))
+	}
+
+	resp, err := deliveryHTTPClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("%s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// InsertWebhookDelivery stores an attempt to post a webhook
+func InsertWebhookDelivery(db database.Querier, d *sdk.WebhookDelivery) error {
+	query := `INSERT INTO notification_webhook_delivery (pipeline_build_id, url, attempt, status_code, error, duration, created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	return db.QueryRow(query, d.PipelineBuildID, d.URL, d.Attempt, d.StatusCode, d.Error, d.Duration, d.Created).Scan(&d.ID)
+}
+
+// LoadWebhookDeliveries loads attempts to post webhooks of a pipeline build
+func LoadWebhookDeliveries(db database.Querier, pbID int64) ([]sdk.WebhookDelivery, error) {
+	query := `SELECT id, pipeline_build_id, url, attempt, status_code, error, duration, created
+		FROM notification_webhook_delivery WHERE pipeline_build_id = $1 ORDER BY id`
+	rows, err := db.Query(query, pbID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []sdk.WebhookDelivery{}
+	for rows.Next() {
+		var d sdk.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.PipelineBuildID, &d.URL, &d.Attempt, &d.StatusCode, &d.Error, &d.Duration, &d.Created); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,87 @@
+package notification
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	// echo -n '{"a":"b"}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=5782eb1e80a9c0cc789474a5ab9faf38384866cac1c0151663b2ec9d0082743e"
+	if got := webhookSignature("secret", []byte(`{"a":"b"}`)); got != want {
+		t.Errorf("webhookSignature = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookBody(t *testing.T) {
+	params := map[string]string{"cds.status": "Success", "cds.application": "app"}
+
+	c := newTemplateContext(nil, nil, nil, params)
+
+	wh := &sdk.WebhookUserNotificationSettings{Template: `{"text":"{{.cds.application}} is {{.cds.status}}"}`}
+	body, err := webhookBody(c, wh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"text":"app is Success"}` {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	// Values are escaped to keep body valid JSON
+	c = newTemplateContext(nil, nil, nil, map[string]string{"cds.status": "Fail", "git.message": "fix \"quotes\"\nand lines"})
+	wh = &sdk.WebhookUserNotificationSettings{Template: `{"text":"{{.git.message}}{{if eq .cds.status "Fail"}} {{.cds.status | upper}}{{end}}"}`}
+	body, err = webhookBody(c, wh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"text":"fix \"quotes\"\nand lines FAIL"}` {
+		t.Errorf("unexpected escaped body %s", body)
+	}
+	var v map[string]string
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Errorf("body is not valid JSON: %s", err)
+	}
+
+	// Fallback substitution escapes values too
+	c = newTemplateContext(nil, nil, nil, map[string]string{"cds.my-param": `a"b`})
+	body, err = webhookBody(c, &sdk.WebhookUserNotificationSettings{Template: `{"text":"{{.cds.my-param}}"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"text":"a\"b"}` {
+		t.Errorf("unexpected substituted body %s", body)
+	}
+
+	c = newTemplateContext(nil, nil, nil, params)
+	body, err = webhookBody(c, &sdk.WebhookUserNotificationSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"cds.application":"app","cds.status":"Success"}` {
+		t.Errorf("unexpected default body %s", body)
+	}
+}
+
+func TestRedactWebhook(t *testing.T) {
+	stored := &sdk.UserNotification{Notifications: map[sdk.UserNotificationSettingsType]sdk.UserNotificationSettings{
+		sdk.WebhookUserNotification: &sdk.WebhookUserNotificationSettings{URL: "http://hook", Secret: "s3cr3t", Headers: map[string]string{"X-Token": "t0ken"}},
+	}}
+	redacted := &sdk.UserNotification{Notifications: map[sdk.UserNotificationSettingsType]sdk.UserNotificationSettings{
+		sdk.WebhookUserNotification: &sdk.WebhookUserNotificationSettings{URL: "http://hook", Secret: "s3cr3t", Headers: map[string]string{"X-Token": "t0ken"}},
+	}}
+	RedactUserNotification(redacted)
+
+	wh := redacted.Notifications[sdk.WebhookUserNotification].(*sdk.WebhookUserNotificationSettings)
+	if wh.Secret != sdk.PasswordPlaceholder || wh.Headers["X-Token"] != sdk.PasswordPlaceholder {
+		t.Fatalf("webhook secrets not redacted: %+v", wh)
+	}
+
+	// Client sends back redacted values, and a new header
+	wh.Headers["X-Other"] = "other"
+	KeepRedactedSecrets(stored, redacted)
+	if wh.Secret != "s3cr3t" || wh.Headers["X-Token"] != "t0ken" || wh.Headers["X-Other"] != "other" {
+		t.Errorf("secrets not restored: %+v", wh)
+	}
+}
@@ -3,9 +3,11 @@ package objectstore
 import (
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 
 	"github.com/ovh/cds/engine/log"
 	"github.com/ovh/cds/sdk"
@@ -113,6 +115,117 @@ func (fss *FilesystemStore) DeletePlugin(art sdk.ActionPlugin) error {
 	return os.RemoveAll(dst)
 }
 
+// StoreWorkspaceCache create a new file on disk with cache tarball
+func (fss *FilesystemStore) StoreWorkspaceCache(c sdk.WorkspaceCache, data io.ReadCloser) (string, error) {
+	p := fss.cachePath(c)
+	log.Notice("FilesystemStore.StoreWorkspaceCache> New cache '%s' in %s\n", c.Key, p)
+
+	dir, _ := filepath.Split(p)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, data); err != nil {
+		os.Remove(p)
+		return "", err
+	}
+
+	return p, nil
+}
+
+// FetchWorkspaceCache lookup on disk for cache tarball
+func (fss *FilesystemStore) FetchWorkspaceCache(c sdk.WorkspaceCache) (io.ReadCloser, error) {
+	return os.Open(c.ObjectPath)
+}
+
+// DeleteWorkspaceCache remove cache tarball from disk
+func (fss *FilesystemStore) DeleteWorkspaceCache(c sdk.WorkspaceCache) error {
+	return os.Remove(c.ObjectPath)
+}
+
+// StoreArtifactChunk create a new file on disk with artifact chunk data
+func (fss *FilesystemStore) StoreArtifactChunk(c sdk.ArtifactChunk, data io.ReadCloser) (string, error) {
+	p := fss.chunkPath(c)
+
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, data); err != nil {
+		return "", err
+	}
+
+	return p, nil
+}
+
+// FetchArtifactChunk lookup on disk for artifact chunk data
+func (fss *FilesystemStore) FetchArtifactChunk(c sdk.ArtifactChunk) (io.ReadCloser, error) {
+	return os.Open(fss.chunkPath(c))
+}
+
+// DeleteArtifactChunk remove artifact chunk data from disk
+func (fss *FilesystemStore) DeleteArtifactChunk(c sdk.ArtifactChunk) error {
+	return os.Remove(fss.chunkPath(c))
+}
+
+// StoreReleaseArtifact create a new file on disk with release artifact data
+func (fss *FilesystemStore) StoreReleaseArtifact(r sdk.Release, art sdk.Artifact, data io.ReadCloser) (string, error) {
+	p := fss.releasePath(r, art)
+	log.Notice("FilesystemStore.StoreReleaseArtifact> New release artifact '%s' in %s\n", art.Name, p)
+
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, data); err != nil {
+		return "", err
+	}
+
+	return p, nil
+}
+
+// FetchReleaseArtifact lookup on disk for release artifact data
+func (fss *FilesystemStore) FetchReleaseArtifact(r sdk.Release, art sdk.Artifact) (io.ReadCloser, error) {
+	return os.Open(fss.releasePath(r, art))
+}
+
+// DeleteReleaseArtifact remove release artifact data from disk
+func (fss *FilesystemStore) DeleteReleaseArtifact(r sdk.Release, art sdk.Artifact) error {
+	return os.Remove(fss.releasePath(r, art))
+}
+
+func (fss *FilesystemStore) releasePath(r sdk.Release, art sdk.Artifact) string {
+	return path.Join(fss.basedir, "release", r.Project, r.Application, url.QueryEscape(r.Name), art.Name)
+}
+
+func (fss *FilesystemStore) chunkPath(c sdk.ArtifactChunk) string {
+	return path.Join(fss.basedir, "chunks", c.Upload, strconv.Itoa(c.Index))
+}
+
+// cachePath is unique per upload, so a new upload of a key never overwrites the tarball in use
+func (fss *FilesystemStore) cachePath(c sdk.WorkspaceCache) string {
+	name := url.QueryEscape(c.Key) + "." + strconv.FormatInt(c.Created.UnixNano(), 10)
+	return path.Join(fss.basedir, "cache", c.Project, name)
+}
+
 func (fss *FilesystemStore) path(art sdk.Artifact) string {
 	dir := fmt.Sprintf("%s/%s/%s/%s", art.Project, art.Application, art.Environment, art.Pipeline)
 	return path.Join(fss.basedir, dir, art.Tag, art.Name)
@@ -66,6 +66,78 @@ func DeletePlugin(art sdk.ActionPlugin) error {
 	return fmt.Errorf("store not initialized")
 }
 
+//StoreWorkspaceCache call StoreWorkspaceCache on the common driver
+func StoreWorkspaceCache(c sdk.WorkspaceCache, data io.ReadCloser) (string, error) {
+	if storage != nil {
+		return storage.StoreWorkspaceCache(c, data)
+	}
+	return "", fmt.Errorf("store not initialized")
+}
+
+//FetchWorkspaceCache call FetchWorkspaceCache on the common driver
+func FetchWorkspaceCache(c sdk.WorkspaceCache) (io.ReadCloser, error) {
+	if storage != nil {
+		return storage.FetchWorkspaceCache(c)
+	}
+	return nil, fmt.Errorf("store not initialized")
+}
+
+//DeleteWorkspaceCache call DeleteWorkspaceCache on the common driver
+func DeleteWorkspaceCache(c sdk.WorkspaceCache) error {
+	if storage != nil {
+		return storage.DeleteWorkspaceCache(c)
+	}
+	return fmt.Errorf("store not initialized")
+}
+
+//StoreArtifactChunk call StoreArtifactChunk on the common driver
+func StoreArtifactChunk(c sdk.ArtifactChunk, data io.ReadCloser) (string, error) {
+	if storage != nil {
+		return storage.StoreArtifactChunk(c, data)
+	}
+	return "", fmt.Errorf("store not initialized")
+}
+
+//FetchArtifactChunk call FetchArtifactChunk on the common driver
+func FetchArtifactChunk(c sdk.ArtifactChunk) (io.ReadCloser, error) {
+	if storage != nil {
+		return storage.FetchArtifactChunk(c)
+	}
+	return nil, fmt.Errorf("store not initialized")
+}
+
+//DeleteArtifactChunk call DeleteArtifactChunk on the common driver
+func DeleteArtifactChunk(c sdk.ArtifactChunk) error {
+	if storage != nil {
+		return storage.DeleteArtifactChunk(c)
+	}
+	return fmt.Errorf("store not initialized")
+}
+
+//StoreReleaseArtifact call StoreReleaseArtifact on the common driver
+func StoreReleaseArtifact(r sdk.Release, art sdk.Artifact, data io.ReadCloser) (string, error) {
+	if storage != nil {
+		return storage.StoreReleaseArtifact(r, art, data)
+	}
+	return "", fmt.Errorf("store not initialized")
+}
+
+//FetchReleaseArtifact call FetchReleaseArtifact on the common driver
+func FetchReleaseArtifact(r sdk.Release, art sdk.Artifact) (io.ReadCloser, error) {
+	if storage != nil {
+		return storage.FetchReleaseArtifact(r, art)
+	}
+	return nil, fmt.Errorf("store not initialized")
+}
+
+//DeleteReleaseArtifact call DeleteReleaseArtifact on the common driver
+func DeleteReleaseArtifact(r sdk.Release, art sdk.Artifact) error {
+	if storage != nil {
+		return storage.DeleteReleaseArtifact(r, art)
+	}
+	return fmt.Errorf("store not initialized")
+}
+
 // Driver allows artifact to be stored and retrieve the same way to any backend
 // - Openstack ObjectStore
 // - Filesystem
@@ -77,6 +149,15 @@ type Driver interface {
 	StorePlugin(art sdk.ActionPlugin, data io.ReadCloser) (string, error)
 	FetchPlugin(art sdk.ActionPlugin) (io.ReadCloser, error)
 	DeletePlugin(art sdk.ActionPlugin) error
+	StoreWorkspaceCache(c sdk.WorkspaceCache, data io.ReadCloser) (string, error)
+	FetchWorkspaceCache(c sdk.WorkspaceCache) (io.ReadCloser, error)
+	DeleteWorkspaceCache(c sdk.WorkspaceCache) error
+	StoreArtifactChunk(c sdk.ArtifactChunk, data io.ReadCloser) (string, error)
+	FetchArtifactChunk(c sdk.ArtifactChunk) (io.ReadCloser, error)
+	DeleteArtifactChunk(c sdk.ArtifactChunk) error
+	StoreReleaseArtifact(r sdk.Release, art sdk.Artifact, data io.ReadCloser) (string, error)
+	FetchReleaseArtifact(r sdk.Release, art sdk.Artifact) (io.ReadCloser, error)
+	DeleteReleaseArtifact(r sdk.Release, art sdk.Artifact) error
 }
 
 // Initialize setup wanted ObjectStore driver
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -162,6 +163,117 @@ func (ops *OpenstackStore) DeletePlugin(art sdk.ActionPlugin) error {
 	return nil
 }
 
+// StoreWorkspaceCache store a cache tarball in openstack
+func (ops *OpenstackStore) StoreWorkspaceCache(c sdk.WorkspaceCache, data io.ReadCloser) (string, error) {
+	// Object name is unique per upload, so a new upload of a key never overwrites the tarball in use
+	container, object := ops.format(c.Key+"."+strconv.FormatInt(c.Created.UnixNano(), 10), c.Project, "cache")
+	log.Info("OpenstackStore> Storing /%s/%s\n", container, object)
+
+	// Create container if it doesn't exist
+	err := createContainer(ops.token.ID, ops.endpoint, container)
+	if err != nil {
+		log.Warning("OpenstackStore.StoreWorkspaceCache> Cannot create container: %s\n", err)
+		return "", err
+	}
+
+	// Create object
+	err = createObject(ops.token.ID, ops.endpoint, container, object, data)
+	if err != nil {
+		log.Warning("OpenstackStore.StoreWorkspaceCache> Cannot create object: %s\n", err)
+		return "", err
+	}
+
+	return container + "/" + object, nil
+}
+
+// FetchWorkspaceCache retrieves cache tarball from openstack
+func (ops *OpenstackStore) FetchWorkspaceCache(c sdk.WorkspaceCache) (io.ReadCloser, error) {
+	container, object := splitObjectPath(c.ObjectPath)
+	log.Info("OpenstackStore> Fetching /%s/%s\n", container, object)
+
+	return fetchObject(ops.token.ID, ops.endpoint, container, object)
+}
+
+// DeleteWorkspaceCache removes cache tarball from openstack
+func (ops *OpenstackStore) DeleteWorkspaceCache(c sdk.WorkspaceCache) error {
+	container, object := splitObjectPath(c.ObjectPath)
+	log.Info("OpenstackStore> Deleting /%s/%s\n", container, object)
+
+	return deleteObject(ops.token.ID, ops.endpoint, container, object)
+}
+
+// StoreArtifactChunk store an artifact chunk in openstack
+func (ops *OpenstackStore) StoreArtifactChunk(c sdk.ArtifactChunk, data io.ReadCloser) (string, error) {
+	container, object := ops.format(strconv.Itoa(c.Index), c.Upload, "chunks")
+	log.Info("OpenstackStore> Storing /%s/%s\n", container, object)
+
+	// Create container if it doesn't exist
+	err := createContainer(ops.token.ID, ops.endpoint, container)
+	if err != nil {
+		log.Warning("OpenstackStore.StoreArtifactChunk> Cannot create container: %s\n", err)
+		return "", err
+	}
+
+	// Create object
+	err = createObject(ops.token.ID, ops.endpoint, container, object, data)
+	if err != nil {
+		log.Warning("OpenstackStore.StoreArtifactChunk> Cannot create object: %s\n", err)
+		return "", err
+	}
+
+	return container + "/" + object, nil
+}
+
+// FetchArtifactChunk retrieves artifact chunk from openstack
+func (ops *OpenstackStore) FetchArtifactChunk(c sdk.ArtifactChunk) (io.ReadCloser, error) {
+	container, object := ops.format(strconv.Itoa(c.Index), c.Upload, "chunks")
+	return fetchObject(ops.token.ID, ops.endpoint, container, object)
+}
+
+// DeleteArtifactChunk removes artifact chunk from openstack
+func (ops *OpenstackStore) DeleteArtifactChunk(c sdk.ArtifactChunk) error {
+	container, object := ops.format(strconv.Itoa(c.Index), c.Upload, "chunks")
+	return deleteObject(ops.token.ID, ops.endpoint, container, object)
+}
+
+// StoreReleaseArtifact store a release artifact in openstack
+func (ops *OpenstackStore) StoreReleaseArtifact(r sdk.Release, art sdk.Artifact, data io.ReadCloser) (string, error) {
+	container, object := ops.format(art.Name, r.Project, r.Application, "release", r.Name)
+	log.Info("OpenstackStore> Storing /%s/%s\n", container, object)
+
+	// Create container if it doesn't exist
+	err := createContainer(ops.token.ID, ops.endpoint, container)
+	if err != nil {
+		log.Warning("OpenstackStore.StoreReleaseArtifact> Cannot create container: %s\n", err)
+		return "", err
+	}
+
+	// Create object
+	err = createObject(ops.token.ID, ops.endpoint, container, object, data)
+	if err != nil {
+		log.Warning("OpenstackStore.StoreReleaseArtifact> Cannot create object: %s\n", err)
+		return "", err
+	}
+
+	return container + "/" + object, nil
+}
+
+// FetchReleaseArtifact retrieves release artifact from openstack
+func (ops *OpenstackStore) FetchReleaseArtifact(r sdk.Release, art sdk.Artifact) (io.ReadCloser, error) {
+	container, object := ops.format(art.Name, r.Project, r.Application, "release", r.Name)
+	log.Info("OpenstackStore> Fetching /%s/%s\n", container, object)
+
+	return fetchObject(ops.token.ID, ops.endpoint, container, object)
+}
+
+// DeleteReleaseArtifact removes release artifact from openstack
+func (ops *OpenstackStore) DeleteReleaseArtifact(r sdk.Release, art sdk.Artifact) error {
+	container, object := ops.format(art.Name, r.Project, r.Application, "release", r.Name)
+	log.Info("OpenstackStore> Deleting /%s/%s\n", container, object)
+
+	return deleteObject(ops.token.ID, ops.endpoint, container, object)
+}
+
 func (ops *OpenstackStore) format(x string, y ...string) (container string, object string) {
 	container = strings.Join(y, "-")
 
@@ -173,6 +285,15 @@ func (ops *OpenstackStore) format(x string, y ...string) (container string, obje
 	return
 }
 
+// splitObjectPath returns container and object of a "container/object" path returned by a Store method
+func splitObjectPath(p string) (container string, object string) {
+	i := strings.Index(p, "/")
+	if i < 0 {
+		return "", p
+	}
+	return p[:i], p[i+1:]
+}
+
 //////////// OPENSTACK HANDLERS //////////
 
 type auth struct {
@@ -891,13 +891,40 @@ func InsertPipelineBuild(tx *sql.Tx, project *sdk.Project, p *sdk.Pipeline, appl
 		})
 	}
 
-	if pb.Trigger.VCSChangesBranch != "" {
-		// child inherit git.branch from parent
+	// git.tag is always set, empty when not building a tag, so that prerequisites on it are never met by other builds.
+	// child inherit git.tag from parent
+	tag := ""
+	tagFound := false
+	for _, p := range params {
+		if p.Name == "git.tag" {
+			tagFound = true
+			tag = p.Value
+		}
+	}
+	if !tagFound {
+		if pb.Trigger.ParentPipelineBuild != nil {
+			for _, p := range pb.Trigger.ParentPipelineBuild.Parameters {
+				if p.Name == "git.tag" {
+					tag = p.Value
+				}
+			}
+		}
 		params = append(params, sdk.Parameter{
-			Name:  "git.branch",
-			Value: pb.Trigger.VCSChangesBranch,
+			Name:  "git.tag",
+			Value: tag,
 			Type:  sdk.StringParameter,
 		})
+	}
+
+	if pb.Trigger.VCSChangesBranch != "" || tag != "" {
+		// child inherit git.branch from parent, builds of a tag have no branch
+		if pb.Trigger.VCSChangesBranch != "" {
+			params = append(params, sdk.Parameter{
+				Name:  "git.branch",
+				Value: pb.Trigger.VCSChangesBranch,
+				Type:  sdk.StringParameter,
+			})
+		}
 		// child inherit git.hash from parent
 		params = append(params, sdk.Parameter{
 			Name:  "git.hash",
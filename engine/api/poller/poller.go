@@ -1,6 +1,9 @@
 package poller
 
 import (
+	"database/sql"
+	"encoding/json"
+
 	"github.com/ovh/cds/engine/api/application"
 	"github.com/ovh/cds/engine/api/database"
 	"github.com/ovh/cds/engine/api/pipeline"
@@ -11,11 +14,12 @@ import (
 //InsertPoller insert or update a new poller in DB
 func InsertPoller(db database.Executer, poller *sdk.RepositoryPoller) error {
 	query := `
-        INSERT INTO poller (application_id, pipeline_id, name, enabled, date_creation)
-        VALUES ($1, $2, $3, $4, now())
+        INSERT INTO poller (application_id, pipeline_id, name, enabled, date_creation, include_paths, exclude_paths, events)
+        VALUES ($1, $2, $3, $4, now(), $5, $6, $7)
 		RETURNING application_id, pipeline_id
     `
-	if _, err := db.Exec(query, poller.Application.ID, poller.Pipeline.ID, poller.Name, poller.Enabled); err != nil {
+	include, exclude, events := marshalFilters(poller)
+	if _, err := db.Exec(query, poller.Application.ID, poller.Pipeline.ID, poller.Name, poller.Enabled, include, exclude, events); err != nil {
 		log.Warning("InsertPoller> Error :%s", err)
 		return err
 	}
@@ -40,11 +44,12 @@ func DeletePoller(db database.Executer, poller *sdk.RepositoryPoller) error {
 func UpdatePoller(db database.Executer, poller *sdk.RepositoryPoller) error {
 	query := `
         UPDATE  poller 
-        SET enabled = $3, name = $4
+        SET enabled = $3, name = $4, include_paths = $5, exclude_paths = $6, events = $7
         WHERE application_id = $1
         AND pipeline_id  = $2
     `
-	if _, err := db.Exec(query, poller.Application.ID, poller.Pipeline.ID, poller.Enabled, poller.Name); err != nil {
+	include, exclude, events := marshalFilters(poller)
+	if _, err := db.Exec(query, poller.Application.ID, poller.Pipeline.ID, poller.Enabled, poller.Name, include, exclude, events); err != nil {
 		log.Warning("UpdatePoller> Error :%s", err)
 		return err
 	}
@@ -54,7 +59,7 @@ func UpdatePoller(db database.Executer, poller *sdk.RepositoryPoller) error {
 //LoadEnabledPollers load all RepositoryPoller
 func LoadEnabledPollers(db database.Querier) ([]sdk.RepositoryPoller, error) {
 	query := `
-        SELECT application_id, pipeline_id, name, enabled, date_creation
+        SELECT application_id, pipeline_id, name, enabled, date_creation, include_paths, exclude_paths, events
         FROM poller
         WHERE enabled = true
     `
@@ -64,7 +69,7 @@ func LoadEnabledPollers(db database.Querier) ([]sdk.RepositoryPoller, error) {
 //LoadPollersByApplication loads all pollers for an application
 func LoadPollersByApplication(db database.Querier, applicationID int64) ([]sdk.RepositoryPoller, error) {
 	query := `
-        SELECT application_id, pipeline_id, name, enabled, date_creation
+        SELECT application_id, pipeline_id, name, enabled, date_creation, include_paths, exclude_paths, events
         FROM poller
         WHERE application_id = $1
     `
@@ -74,7 +79,7 @@ func LoadPollersByApplication(db database.Querier, applicationID int64) ([]sdk.R
 //LoadPollerByApplicationAndPipeline loads all pollers for an application/pipeline
 func LoadPollerByApplicationAndPipeline(db database.Querier, applicationID, pipelineID int64) (*sdk.RepositoryPoller, error) {
 	query := `
-        SELECT application_id, pipeline_id, name, enabled, date_creation
+        SELECT application_id, pipeline_id, name, enabled, date_creation, include_paths, exclude_paths, events
         FROM poller
         WHERE application_id = $1
 		AND pipeline_id = $2
@@ -101,11 +106,21 @@ func loadPollersByQUery(db database.Querier, query string, args ...interface{})
 
 	for rows.Next() {
 		var applicationID, pipelineID int64
+		var include, exclude, events sql.NullString
 		poller := sdk.RepositoryPoller{}
-		if err := rows.Scan(&applicationID, &pipelineID, &poller.Name, &poller.Enabled, &poller.DateCreation); err != nil {
+		if err := rows.Scan(&applicationID, &pipelineID, &poller.Name, &poller.Enabled, &poller.DateCreation, &include, &exclude, &events); err != nil {
 			log.Warning("loadPollersByQuery> error scanning poller : %s", err)
 			return nil, err
 		}
+		if include.Valid {
+			json.Unmarshal([]byte(include.String), &poller.IncludePaths)
+		}
+		if exclude.Valid {
+			json.Unmarshal([]byte(exclude.String), &poller.ExcludePaths)
+		}
+		if events.Valid {
+			json.Unmarshal([]byte(events.String), &poller.Events)
+		}
 		app, err := application.LoadApplicationByID(db, applicationID)
 		if err != nil {
 			log.Warning("loadPollersByQuery> error loading application %d : %s", applicationID, err)
@@ -122,3 +137,10 @@ func loadPollersByQUery(db database.Querier, query string, args ...interface{})
 	}
 	return pollers, nil
 }
+
+func marshalFilters(poller *sdk.RepositoryPoller) (string, string, string) {
+	include, _ := json.Marshal(poller.IncludePaths)
+	exclude, _ := json.Marshal(poller.ExcludePaths)
+	events, _ := json.Marshal(poller.Events)
+	return string(include), string(exclude), string(events)
+}
@@ -692,6 +692,12 @@ func DeleteProject(db database.QueryExecuter, key string) error {
 		return err
 	}
 
+	query = `DELETE FROM project_notification_template WHERE project_id = $1`
+	_, err = db.Exec(query, projectID)
+	if err != nil {
+		return err
+	}
+
 	query = `DELETE FROM project WHERE project.id = $1`
 	_, err = db.Exec(query, projectID)
 	if err != nil {
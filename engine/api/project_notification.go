@@ -0,0 +1,228 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/context"
+	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/notification"
+	"github.com/ovh/cds/engine/api/permission"
+	"github.com/ovh/cds/engine/api/pipeline"
+	"github.com/ovh/cds/engine/api/project"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+func getNotificationTemplatesHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+
+	templates, err := notification.LoadProjectTemplates(db, key)
+	if err != nil {
+		log.Warning("getNotificationTemplatesHandler> Cannot load notification templates of %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, templates, http.StatusOK)
+}
+
+func getNotificationTemplateHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["name"]
+
+	t, err := notification.LoadProjectTemplate(db, key, name)
+	if err != nil {
+		log.Warning("getNotificationTemplateHandler> Cannot load notification template %s of %s: %s\n", name, key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, t, http.StatusOK)
+}
+
+func updateNotificationTemplateHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["name"]
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var t sdk.NotificationTemplate
+	if err := json.Unmarshal(data, &t); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t.Name = name
+
+	if !regexp.MustCompile(sdk.NamePattern).MatchString(t.Name) {
+		log.Warning("updateNotificationTemplateHandler> Template name %s do not respect pattern %s\n", t.Name, sdk.NamePattern)
+		WriteError(w, r, sdk.ErrInvalidName)
+		return
+	}
+
+	if err := notification.ValidateTemplate(&t); err != nil {
+		log.Warning("updateNotificationTemplateHandler> Invalid template %s: %s\n", t.Name, err)
+		WriteError(w, r, sdk.ErrInvalidNotificationTemplate)
+		return
+	}
+
+	p, err := project.LoadProject(db, key, c.User)
+	if err != nil {
+		log.Warning("updateNotificationTemplateHandler> Cannot load project %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	old, err := notification.LoadProjectTemplate(db, key, name)
+	switch err {
+	case nil:
+		t.ID = old.ID
+		err = notification.UpdateProjectTemplate(db, &t)
+	case sdk.ErrNotificationTemplateNotFound:
+		err = notification.InsertProjectTemplate(db, p.ID, &t)
+	}
+	if err != nil {
+		log.Warning("updateNotificationTemplateHandler> Cannot save notification template %s of %s: %s\n", name, key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, t, http.StatusOK)
+}
+
+func deleteNotificationTemplateHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["name"]
+
+	t, err := notification.LoadProjectTemplate(db, key, name)
+	if err != nil {
+		log.Warning("deleteNotificationTemplateHandler> Cannot load notification template %s of %s: %s\n", name, key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	used, err := notification.ProjectTemplateUsed(db, key, name)
+	if err != nil {
+		log.Warning("deleteNotificationTemplateHandler> Cannot check usage of notification template %s of %s: %s\n", name, key, err)
+		WriteError(w, r, err)
+		return
+	}
+	if used {
+		WriteError(w, r, sdk.ErrNotificationTemplateUsed)
+		return
+	}
+
+	if err := notification.DeleteProjectTemplate(db, t.ID); err != nil {
+		log.Warning("deleteNotificationTemplateHandler> Cannot delete notification template %s of %s: %s\n", name, key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// previewNotificationTemplateHandler renders a template against a past pipeline build
+func previewNotificationTemplateHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var preview sdk.NotificationTemplatePreview
+	if err := json.Unmarshal(data, &preview); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var env *sdk.Environment
+	if preview.Environment == "" || preview.Environment == sdk.DefaultEnv.Name {
+		env = &sdk.DefaultEnv
+	} else {
+		env, err = environment.LoadEnvironmentByName(db, key, preview.Environment)
+		if err != nil {
+			log.Warning("previewNotificationTemplateHandler> Cannot load environment %s: %s\n", preview.Environment, err)
+			WriteError(w, r, sdk.ErrUnknownEnv)
+			return
+		}
+	}
+
+	if env.ID != sdk.DefaultEnv.ID && !permission.AccessToEnvironment(env.ID, c.User, permission.PermissionRead) {
+		log.Warning("previewNotificationTemplateHandler> No enought right on this environment %s: \n", preview.Environment)
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	p, err := pipeline.LoadPipeline(db, key, preview.Pipeline, false)
+	if err != nil {
+		log.Warning("previewNotificationTemplateHandler> Cannot load pipeline %s: %s\n", preview.Pipeline, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	a, err := application.LoadApplicationByName(db, key, preview.Application)
+	if err != nil {
+		log.Warning("previewNotificationTemplateHandler> Cannot load application %s: %s\n", preview.Application, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if !permission.AccessToApplication(a.ID, c.User, permission.PermissionRead) {
+		log.Warning("previewNotificationTemplateHandler> No enought right on application %s\n", a.Name)
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	pb, err := loadAnyPipelineBuild(db, p.ID, a.ID, preview.BuildNumber, env.ID)
+	if err != nil {
+		log.Warning("previewNotificationTemplateHandler> Cannot load pipeline build: %s\n", err)
+		WriteError(w, r, sdk.ErrNoPipelineBuild)
+		return
+	}
+	pb.Pipeline, pb.Application, pb.Environment = *p, *a, *env
+	pb.Pipeline.ProjectKey = key
+
+	var previous *sdk.PipelineBuild
+	if prev, err := loadAnyPipelineBuild(db, p.ID, a.ID, preview.BuildNumber-1, env.ID); err == nil {
+		previous = &prev
+	}
+
+	params := notification.PipelineParameters(db, &pb, pb.Status)
+	rendering, err := notification.RenderTemplate(db, &pb, previous, preview.Template, params)
+	if err != nil {
+		log.Warning("previewNotificationTemplateHandler> Cannot render template: %s\n", err)
+		if err != sdk.ErrNotificationTemplateNotFound {
+			err = sdk.ErrInvalidNotificationTemplate
+		}
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, rendering, http.StatusOK)
+}
+
+// loadAnyPipelineBuild loads a pipeline build, running or archived in history
+func loadAnyPipelineBuild(db *sql.DB, pipelineID, applicationID, buildNumber, environmentID int64) (sdk.PipelineBuild, error) {
+	pb, err := pipeline.LoadPipelineBuild(db, pipelineID, applicationID, buildNumber, environmentID)
+	if err != sdk.ErrNoPipelineBuild {
+		return pb, err
+	}
+	return pipeline.LoadPipelineHistoryBuild(db, pipelineID, applicationID, buildNumber, environmentID)
+}
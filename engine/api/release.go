@@ -0,0 +1,227 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/context"
+	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/permission"
+	"github.com/ovh/cds/engine/api/pipeline"
+	"github.com/ovh/cds/engine/api/release"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+func getReleasesHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+
+	app, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("getReleasesHandler> Cannot load application %s: %s\n", appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	releases, err := release.LoadAll(db, projectKey, app)
+	if err != nil {
+		log.Warning("getReleasesHandler> Cannot load releases of %s: %s\n", appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, releases, http.StatusOK)
+}
+
+func getReleaseHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+	releaseName := vars["release"]
+
+	app, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("getReleaseHandler> Cannot load application %s: %s\n", appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	rel, err := release.Load(db, projectKey, app, releaseName)
+	if err != nil {
+		log.Warning("getReleaseHandler> Cannot load release %s of %s: %s\n", releaseName, appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, rel, http.StatusOK)
+}
+
+func promoteReleaseHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Warning("promoteReleaseHandler> Cannot read body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var rel sdk.Release
+	if err := json.Unmarshal(data, &rel); err != nil {
+		log.Warning("promoteReleaseHandler> Cannot unmarshal release: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	app, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("promoteReleaseHandler> Cannot load application %s: %s\n", appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	pip, err := pipeline.LoadPipeline(db, projectKey, rel.Pipeline, false)
+	if err != nil {
+		log.Warning("promoteReleaseHandler> Cannot load pipeline %s: %s\n", rel.Pipeline, err)
+		WriteError(w, r, sdk.ErrPipelineNotFound)
+		return
+	}
+
+	var env *sdk.Environment
+	if rel.Environment == "" || rel.Environment == sdk.DefaultEnv.Name || pip.Type == sdk.BuildPipeline {
+		env = &sdk.DefaultEnv
+	} else {
+		env, err = environment.LoadEnvironmentByName(db, projectKey, rel.Environment)
+		if err != nil {
+			log.Warning("promoteReleaseHandler> Cannot load environment %s: %s\n", rel.Environment, err)
+			WriteError(w, r, sdk.ErrUnknownEnv)
+			return
+		}
+	}
+
+	if !permission.AccessToPipeline(env.ID, pip.ID, c.User, permission.PermissionRead) {
+		log.Warning("promoteReleaseHandler> No enought right on pipeline %s\n", rel.Pipeline)
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	rel.Author = c.User.Username
+	if err := release.Promote(db, projectKey, app, pip, env, &rel); err != nil {
+		log.Warning("promoteReleaseHandler> Cannot promote %s build %d to release %s: %s\n", rel.Pipeline, rel.BuildNumber, rel.Name, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, rel, http.StatusCreated)
+}
+
+func downloadReleaseArtifactHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+	releaseName := vars["release"]
+
+	artifactID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		log.Warning("downloadReleaseArtifactHandler> Cannot convert '%s' into int: %s\n", vars["id"], err)
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	app, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("downloadReleaseArtifactHandler> Cannot load application %s: %s\n", appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	rel, err := release.Load(db, projectKey, app, releaseName)
+	if err != nil {
+		log.Warning("downloadReleaseArtifactHandler> Cannot load release %s of %s: %s\n", releaseName, appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	var art *sdk.Artifact
+	for i := range rel.Artifacts {
+		if rel.Artifacts[i].ID == artifactID {
+			art = &rel.Artifacts[i]
+		}
+	}
+	if art == nil {
+		WriteError(w, r, sdk.ErrNotFound)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/octet-stream")
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", art.Name))
+
+	// Resume interrupted downloads
+	out, offset, ok := rangeWriter(w, r, art.Size)
+	if !ok {
+		return
+	}
+
+	if err := release.StreamFile(out, *rel, *art, offset); err != nil {
+		log.Warning("downloadReleaseArtifactHandler> Cannot stream %s of release %s: %s\n", art.Name, releaseName, err)
+		// Once content is sent, status cannot be changed anymore
+		if !out.wrote {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+}
+
+func addReleaseConsumerHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	appName := vars["permApplicationName"]
+	releaseName := vars["release"]
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Warning("addReleaseConsumerHandler> Cannot read body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var consumer sdk.ReleaseConsumer
+	if err := json.Unmarshal(data, &consumer); err != nil {
+		log.Warning("addReleaseConsumerHandler> Cannot unmarshal consumer: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	app, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("addReleaseConsumerHandler> Cannot load application %s: %s\n", appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	rel, err := release.Load(db, projectKey, app, releaseName)
+	if err != nil {
+		log.Warning("addReleaseConsumerHandler> Cannot load release %s of %s: %s\n", releaseName, appName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := release.AddConsumer(db, rel, &consumer); err != nil {
+		log.Warning("addReleaseConsumerHandler> Cannot record consumer of release %s: %s\n", releaseName, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, consumer, http.StatusCreated)
+}
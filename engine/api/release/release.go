@@ -0,0 +1,266 @@
+package release
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/ovh/cds/engine/api/artifact"
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/objectstore"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// Promote copies artifacts of build r.BuildNumber of pipeline p into release r.
+// Once created, a release cannot be changed.
+func Promote(db *sql.DB, projectKey string, a *sdk.Application, p *sdk.Pipeline, e *sdk.Environment, r *sdk.Release) error {
+	if !sdk.ReleaseNamePattern.MatchString(r.Name) {
+		return sdk.ErrInvalidReleaseName
+	}
+
+	if _, err := Load(db, projectKey, a, r.Name); err != sdk.ErrReleaseNotFound {
+		if err == nil {
+			return sdk.ErrReleaseExists
+		}
+		return err
+	}
+
+	arts, err := artifact.LoadArtifactsByBuildNumber(db, p.ID, a.ID, r.BuildNumber, e.ID)
+	if err != nil {
+		return err
+	}
+	if len(arts) == 0 {
+		return sdk.ErrReleaseNoArtifact
+	}
+
+	r.Project = projectKey
+	r.Application = a.Name
+	r.Pipeline = p.Name
+	r.Environment = e.Name
+	r.Created = time.Now()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO artifact_release (application_id, name, pipeline, environment, build_number, author, created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	if err := tx.QueryRow(query, a.ID, r.Name, r.Pipeline, r.Environment, r.BuildNumber, r.Author, r.Created).Scan(&r.ID); err != nil {
+		return err
+	}
+
+	r.Artifacts = nil
+	for _, art := range arts {
+		art.Project = projectKey
+		art.Application = a.Name
+		art.Pipeline = p.Name
+		art.Environment = e.Name
+
+		if err := copyArtifact(r, &art); err != nil {
+			deleteFiles(r)
+			return err
+		}
+		r.Artifacts = append(r.Artifacts, art)
+
+		query := `INSERT INTO artifact_release_file (release_id, name, size, perm, md5sum, sha256sum, object_path)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+		if err := tx.QueryRow(query, r.ID, art.Name, art.Size, art.Perm, art.MD5sum, art.SHA256sum, art.ObjectPath).Scan(&r.Artifacts[len(r.Artifacts)-1].ID); err != nil {
+			deleteFiles(r)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		deleteFiles(r)
+		return err
+	}
+	return nil
+}
+
+// copyArtifact copies art content into release r, checking it against art checksums
+func copyArtifact(r *sdk.Release, art *sdk.Artifact) error {
+	f, err := objectstore.FetchArtifact(*art)
+	if err != nil {
+		return fmt.Errorf("cannot fetch artifact %s: %s", art.Name, err)
+	}
+	defer f.Close()
+
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	art.ObjectPath, err = objectstore.StoreReleaseArtifact(*r, *art, ioutil.NopCloser(io.TeeReader(f, io.MultiWriter(md5Hash, sha256Hash))))
+	if err != nil {
+		return err
+	}
+
+	md5sum := hex.EncodeToString(md5Hash.Sum(nil))
+	sha256sum := hex.EncodeToString(sha256Hash.Sum(nil))
+	if (art.SHA256sum != "" && art.SHA256sum != sha256sum) || (art.MD5sum != "" && art.MD5sum != md5sum) {
+		log.Warning("release.Promote> %s of %s/%s is corrupted: sha256 %s, expected %s\n", art.Name, r.Application, r.Name, sha256sum, art.SHA256sum)
+		if err := objectstore.DeleteReleaseArtifact(*r, *art); err != nil {
+			log.Warning("release.Promote> Cannot delete %s: %s\n", art.Name, err)
+		}
+		return sdk.ErrArtifactChecksumMismatch
+	}
+
+	art.MD5sum = md5sum
+	art.SHA256sum = sha256sum
+	art.Tag = r.Name
+	return nil
+}
+
+// deleteFiles removes artifacts already copied in release r after a failed promotion
+func deleteFiles(r *sdk.Release) {
+	for _, art := range r.Artifacts {
+		if err := objectstore.DeleteReleaseArtifact(*r, art); err != nil {
+			log.Warning("release.Promote> Cannot delete %s of %s: %s\n", art.Name, r.Name, err)
+		}
+	}
+}
+
+// Load returns release name of given application, with its artifacts and consumers
+func Load(db database.Querier, projectKey string, a *sdk.Application, name string) (*sdk.Release, error) {
+	query := `SELECT id, name, pipeline, environment, build_number, author, created
+		FROM artifact_release WHERE application_id = $1 AND name = $2`
+	r, err := scan(db.QueryRow(query, a.ID, name))
+	if err == sql.ErrNoRows {
+		return nil, sdk.ErrReleaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Project = projectKey
+	r.Application = a.Name
+
+	if r.Artifacts, err = loadFiles(db, r); err != nil {
+		return nil, err
+	}
+	if r.Consumers, err = loadConsumers(db, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LoadAll returns all releases of given application, most recent first
+func LoadAll(db database.Querier, projectKey string, a *sdk.Application) ([]sdk.Release, error) {
+	query := `SELECT id, name, pipeline, environment, build_number, author, created
+		FROM artifact_release WHERE application_id = $1 ORDER BY created DESC`
+	rows, err := db.Query(query, a.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := []sdk.Release{}
+	for rows.Next() {
+		r, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		r.Project = projectKey
+		r.Application = a.Name
+		releases = append(releases, *r)
+	}
+	return releases, nil
+}
+
+func scan(s database.Scanner) (*sdk.Release, error) {
+	r := &sdk.Release{}
+	var author sql.NullString
+	if err := s.Scan(&r.ID, &r.Name, &r.Pipeline, &r.Environment, &r.BuildNumber, &author, &r.Created); err != nil {
+		return nil, err
+	}
+	if author.Valid {
+		r.Author = author.String
+	}
+	return r, nil
+}
+
+func loadFiles(db database.Querier, r *sdk.Release) ([]sdk.Artifact, error) {
+	query := `SELECT id, name, size, perm, md5sum, sha256sum, object_path
+		FROM artifact_release_file WHERE release_id = $1 ORDER BY name`
+	rows, err := db.Query(query, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	arts := []sdk.Artifact{}
+	for rows.Next() {
+		art := sdk.Artifact{
+			Project:     r.Project,
+			Application: r.Application,
+			Pipeline:    r.Pipeline,
+			Environment: r.Environment,
+			BuildNumber: r.BuildNumber,
+			Tag:         r.Name,
+		}
+		var md5sum, sha256sum, objectpath sql.NullString
+		if err := rows.Scan(&art.ID, &art.Name, &art.Size, &art.Perm, &md5sum, &sha256sum, &objectpath); err != nil {
+			return nil, err
+		}
+		if md5sum.Valid {
+			art.MD5sum = md5sum.String
+		}
+		if sha256sum.Valid {
+			art.SHA256sum = sha256sum.String
+		}
+		if objectpath.Valid {
+			art.ObjectPath = objectpath.String
+		}
+		arts = append(arts, art)
+	}
+	return arts, nil
+}
+
+func loadConsumers(db database.Querier, r *sdk.Release) ([]sdk.ReleaseConsumer, error) {
+	query := `SELECT pipeline, environment, build_number, consumed
+		FROM artifact_release_consumer WHERE release_id = $1 ORDER BY consumed DESC`
+	rows, err := db.Query(query, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consumers := []sdk.ReleaseConsumer{}
+	for rows.Next() {
+		var c sdk.ReleaseConsumer
+		if err := rows.Scan(&c.Pipeline, &c.Environment, &c.BuildNumber, &c.Date); err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, c)
+	}
+	return consumers, nil
+}
+
+// AddConsumer records that a build fetched artifacts of release r
+func AddConsumer(db database.Executer, r *sdk.Release, c *sdk.ReleaseConsumer) error {
+	c.Date = time.Now()
+	query := `INSERT INTO artifact_release_consumer (release_id, pipeline, environment, build_number, consumed) VALUES ($1, $2, $3, $4, $5)`
+	_, err := db.Exec(query, r.ID, c.Pipeline, c.Environment, c.BuildNumber, c.Date)
+	return err
+}
+
+// StreamFile writes content of release artifact art into w, starting at offset
+func StreamFile(w io.Writer, r sdk.Release, art sdk.Artifact, offset int64) error {
+	f, err := objectstore.FetchReleaseArtifact(r, art)
+	if err != nil {
+		return fmt.Errorf("cannot fetch release artifact: %s", err)
+	}
+	defer f.Close()
+
+	if offset > 0 {
+		if _, err := io.CopyN(ioutil.Discard, f, offset); err != nil {
+			return fmt.Errorf("cannot seek release artifact: %s", err)
+		}
+	}
+
+	return objectstore.StreamFile(w, f)
+}
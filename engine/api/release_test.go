@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/artifact"
+	"github.com/ovh/cds/engine/api/context"
+	"github.com/ovh/cds/engine/api/objectstore"
+	"github.com/ovh/cds/engine/api/pipeline"
+	test "github.com/ovh/cds/engine/api/testwithdb"
+	"github.com/ovh/cds/sdk"
+)
+
+func TestRangeWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/download", nil)
+	out, offset, ok := rangeWriter(w, req, 4096)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), offset)
+	out.Write([]byte("content"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+
+	w = httptest.NewRecorder()
+	req.Header.Set("Range", "bytes=1024-")
+	out, offset, ok = rangeWriter(w, req, 4096)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1024), offset)
+	out.Write([]byte("content"))
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 1024-4095/4096", w.Header().Get("Content-Range"))
+
+	w = httptest.NewRecorder()
+	req.Header.Set("Range", "bytes=4096-")
+	_, _, ok = rangeWriter(w, req, 4096)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.Equal(t, "bytes */4096", w.Header().Get("Content-Range"))
+}
+
+func testReleaseRouter(db *sql.DB, u *sdk.User) *mux.Router {
+	c := &context.Context{User: u}
+	wrap := func(h func(http.ResponseWriter, *http.Request, *sql.DB, *context.Context)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { h(w, r, db, c) }
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/project/{key}/application/{permApplicationName}/release", wrap(getReleasesHandler)).Methods("GET")
+	router.HandleFunc("/project/{key}/application/{permApplicationName}/release", wrap(promoteReleaseHandler)).Methods("POST")
+	router.HandleFunc("/project/{key}/application/{permApplicationName}/release/{release}", wrap(getReleaseHandler)).Methods("GET")
+	router.HandleFunc("/project/{key}/application/{permApplicationName}/release/{release}/artifact/{id}/download", wrap(downloadReleaseArtifactHandler)).Methods("GET")
+	return router
+}
+
+func TestPromoteAndDownloadRelease(t *testing.T) {
+	if test.DBDriver == "" {
+		t.SkipNow()
+		return
+	}
+	db, err := test.SetupPG(t)
+	assert.NoError(t, err)
+
+	basedir, err := ioutil.TempDir("", "cds-release")
+	assert.NoError(t, err)
+	defer os.RemoveAll(basedir)
+	assert.NoError(t, objectstore.Initialize("filesystem", "", "", "", basedir))
+
+	key := "TEST_RELEASE_" + test.RandomString(t, 5)
+	proj, err := test.InsertTestProject(t, db, key, key)
+	assert.NoError(t, err)
+	defer deleteAll(t, db, key)
+
+	pip := &sdk.Pipeline{Name: "build", Type: sdk.BuildPipeline, ProjectKey: proj.Key, ProjectID: proj.ID}
+	assert.NoError(t, pipeline.InsertPipeline(db, pip))
+	app := &sdk.Application{Name: "app"}
+	assert.NoError(t, application.InsertApplication(db, proj, app))
+	assert.NoError(t, application.AttachPipeline(db, app.ID, pip.ID))
+
+	content := []byte("0123456789")
+	art := sdk.Artifact{
+		Name:        "bin",
+		Project:     proj.Key,
+		Pipeline:    pip.Name,
+		Application: app.Name,
+		Environment: sdk.DefaultEnv.Name,
+		Tag:         "master",
+		BuildNumber: 1,
+		Size:        int64(len(content)),
+	}
+	assert.NoError(t, artifact.SaveFile(db, pip, app, art, ioutil.NopCloser(bytes.NewReader(content)), &sdk.DefaultEnv))
+
+	u, _, err := test.InsertAdminUser(t, db)
+	assert.NoError(t, err)
+	router := testReleaseRouter(db, u)
+	uri := fmt.Sprintf("/project/%s/application/%s/release", proj.Key, app.Name)
+
+	promote := func(name string) int {
+		data, _ := json.Marshal(sdk.Release{Name: name, Pipeline: pip.Name, BuildNumber: 1})
+		req, _ := http.NewRequest("POST", uri, bytes.NewReader(data))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, promote("v1.0.0"))
+	// Releases are immutable
+	assert.Equal(t, sdk.ErrReleaseExists.Status, promote("v1.0.0"))
+	assert.Equal(t, sdk.ErrInvalidReleaseName.Status, promote("v1 0"))
+
+	req, _ := http.NewRequest("GET", uri+"/v1.0.0", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var rel sdk.Release
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rel))
+	if !assert.Len(t, rel.Artifacts, 1) {
+		return
+	}
+	assert.NotEmpty(t, rel.Artifacts[0].SHA256sum)
+
+	download := fmt.Sprintf("%s/v1.0.0/artifact/%d/download", uri, rel.Artifacts[0].ID)
+	req, _ = http.NewRequest("GET", download, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, content, w.Body.Bytes())
+
+	req, _ = http.NewRequest("GET", download, nil)
+	req.Header.Set("Range", "bytes=4-")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, content[4:], w.Body.Bytes())
+}
@@ -10,7 +10,6 @@ import (
 	"strings"
 
 	"github.com/gorilla/mux"
-	"github.com/spf13/viper"
 
 	"github.com/ovh/cds/engine/api/application"
 	"github.com/ovh/cds/engine/api/cache"
@@ -443,8 +442,7 @@ func detachRepositoriesManager(w http.ResponseWriter, r *http.Request, db *sql.D
 	}
 
 	for _, h := range hooks {
-		s := viper.GetString("api_url") + hook.HookLink
-		link := fmt.Sprintf(s, h.UID, h.Project, h.Repository)
+		link := hook.Link(rm.Type, h.UID, h.Project, h.Repository)
 
 		if err = client.DeleteHook(h.Project+"/"+h.Repository, link); err != nil {
 			log.Warning("detachRepositoriesManager> Cannot delete hook on stash: %s", err)
@@ -681,8 +679,7 @@ func deleteHookOnRepositoriesManagerHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	s := viper.GetString("api_url") + hook.HookLink
-	link := fmt.Sprintf(s, h.UID, t[0], t[1])
+	link := hook.Link(rm.Type, h.UID, t[0], t[1])
 
 	if err = client.DeleteHook(repoFullname, link); err != nil {
 		log.Warning("deleteHookOnRepositoriesManagerHandler> Cannot delete hook on stash: %s", err)
@@ -244,6 +244,60 @@ func SaveDataForProject(db *sql.DB, rm *sdk.RepositoriesManager, projectKey stri
 	return nil
 }
 
+//UpdateTokensForProjects replaces tokens of projects authorized on repositories manager with given refresh token
+func UpdateTokensForProjects(db database.QueryExecuter, rmID int64, oldRefreshToken, accessToken, refreshToken string) error {
+	query := `SELECT id_project, data FROM repositories_manager_project WHERE id_repositories_manager = $1`
+	rows, err := db.Query(query, rmID)
+	if err != nil {
+		return err
+	}
+
+	updated := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		var clientData map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &clientData); err != nil {
+			continue
+		}
+		if clientData["access_token_secret"] != oldRefreshToken {
+			continue
+		}
+		clientData["access_token"] = accessToken
+		clientData["access_token_secret"] = refreshToken
+		b, _ := json.Marshal(clientData)
+		updated[id] = string(b)
+	}
+	rows.Close()
+
+	query = `UPDATE repositories_manager_project SET data = $1 WHERE id_repositories_manager = $2 AND id_project = $3`
+	for id, data := range updated {
+		if _, err := db.Exec(query, data, rmID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//saveRefreshedTokens returns the callback storing tokens refreshed by clients of a repositories manager,
+//so that next clients do not use a revoked refresh token
+func saveRefreshedTokens(rmID int64) func(string, string, string) {
+	return func(oldRefreshToken, accessToken, refreshToken string) {
+		db := database.DB()
+		if db == nil {
+			log.Warning("saveRefreshedTokens> Database unavailable, cannot save tokens of repositories manager %d\n", rmID)
+			return
+		}
+		if err := UpdateTokensForProjects(db, rmID, oldRefreshToken, accessToken, refreshToken); err != nil {
+			log.Warning("saveRefreshedTokens> Cannot save tokens of repositories manager %d: %s\n", rmID, err)
+		}
+	}
+}
+
 //AuthorizedClient returns instance of client with the granted token
 func AuthorizedClient(db database.Querier, projectKey, rmName string) (sdk.RepositoriesManagerClient, error) {
 
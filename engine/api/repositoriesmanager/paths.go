@@ -0,0 +1,94 @@
+package repositoriesmanager
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+//globToRegexp converts a path glob to a regular expression. "*" and "?" do not match "/", "**" matches any number of directories.
+//A glob matching a directory matches all the files below it
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	glob = strings.Trim(strings.TrimSpace(glob), "/")
+	var re string
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re += "(.*/)?"
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re += ".*"
+			i++
+		case glob[i] == '*':
+			re += "[^/]*"
+		case glob[i] == '?':
+			re += "[^/]"
+		default:
+			re += regexp.QuoteMeta(glob[i : i+1])
+		}
+	}
+	return regexp.Compile("^" + re + "(/.*)?$")
+}
+
+func matchAny(globs []string, file string) bool {
+	for _, g := range globs {
+		re, err := globToRegexp(g)
+		if err != nil {
+			log.Warning("matchAny> Invalid glob %s: %s", g, err)
+			continue
+		}
+		if re.MatchString(file) {
+			return true
+		}
+	}
+	return false
+}
+
+//MatchPaths returns true if at least one of the files is matched by include globs and by none of exclude globs.
+//Without include globs, all files are included. Without files, ie. an empty commit, it returns true
+func MatchPaths(include, exclude, files []string) bool {
+	if len(files) == 0 {
+		return true
+	}
+	for _, f := range files {
+		f = strings.TrimPrefix(f, "/")
+		if len(include) > 0 && !matchAny(include, f) {
+			continue
+		}
+		if matchAny(exclude, f) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+//PathsSkipReason checks files changed by commits from since (excluded) to until against include and exclude globs.
+//It returns why the pipeline should not be triggered, or an empty string if it should.
+//When since is empty, only the changes of until are checked. When changed files cannot be fetched or are unknown, the pipeline is triggered
+func PathsSkipReason(client sdk.RepositoriesManagerClient, repo, since, until string, include, exclude []string) string {
+	if len(include) == 0 && len(exclude) == 0 {
+		return ""
+	}
+	if client == nil {
+		return ""
+	}
+
+	files, err := client.ChangedFiles(repo, since, until)
+	if err != nil {
+		log.Warning("PathsSkipReason> Cannot get files changed on %s from %s to %s: %s", repo, since, until, err)
+		return ""
+	}
+	if len(files) == 0 {
+		log.Debug("PathsSkipReason> No changed files known on %s from %s to %s", repo, since, until)
+		return ""
+	}
+
+	if MatchPaths(include, exclude, files) {
+		return ""
+	}
+	return fmt.Sprintf("none of the %d changed files matches path filters (include: %s, exclude: %s)", len(files), strings.Join(include, ","), strings.Join(exclude, ","))
+}
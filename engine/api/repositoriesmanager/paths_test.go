@@ -0,0 +1,37 @@
+package repositoriesmanager
+
+import "testing"
+
+func TestMatchPaths(t *testing.T) {
+	tests := []struct {
+		include  []string
+		exclude  []string
+		files    []string
+		expected bool
+	}{
+		{nil, nil, []string{"README.md"}, true},
+		{nil, nil, []string{}, true},
+		{[]string{"services/api"}, nil, nil, true},
+		{[]string{"services/api"}, nil, []string{"services/api/main.go"}, true},
+		{[]string{"services/api/"}, nil, []string{"services/ui/main.go"}, false},
+		{[]string{"services/api"}, nil, []string{"services/api-gateway/main.go"}, false},
+		{[]string{"services/*/main.go"}, nil, []string{"services/api/main.go"}, true},
+		{[]string{"services/*.go"}, nil, []string{"services/api/main.go"}, false},
+		{[]string{"**/*.go"}, nil, []string{"main.go"}, true},
+		{[]string{"**/*.go"}, nil, []string{"services/api/main.go"}, true},
+		{[]string{"services/**/*_test.go"}, nil, []string{"services/api/handler/handler_test.go"}, true},
+		{[]string{"docs/v?.md"}, nil, []string{"docs/v1.md"}, true},
+		{[]string{"/libs"}, nil, []string{"/libs/log/log.go"}, true},
+		{[]string{"services/api"}, []string{"**/*.md"}, []string{"services/api/README.md"}, false},
+		{[]string{"services/api"}, []string{"**/*.md"}, []string{"services/api/README.md", "services/api/main.go"}, true},
+		{nil, []string{"docs"}, []string{"docs/index.md"}, false},
+		{nil, []string{"docs"}, []string{"docs/index.md", "Makefile"}, true},
+		{[]string{"a+b/[x]"}, nil, []string{"a+b/[x]/file"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchPaths(tt.include, tt.exclude, tt.files); got != tt.expected {
+			t.Errorf("MatchPaths(%v, %v, %v) = %t, expected %t", tt.include, tt.exclude, tt.files, got, tt.expected)
+		}
+	}
+}
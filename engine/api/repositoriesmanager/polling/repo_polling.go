@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/branch"
 	"github.com/ovh/cds/engine/api/cache"
 	"github.com/ovh/cds/engine/api/database"
 	"github.com/ovh/cds/engine/api/pipeline"
@@ -22,6 +23,9 @@ import (
 	"github.com/ovh/cds/sdk"
 )
 
+// branchesDetectionDelay is the delay, in seconds, between two detections of deleted branches of an application
+const branchesDetectionDelay = 300
+
 //RunningPollers is the map of all runningPollers
 var RunningPollers = struct {
 	Workers map[string]*Worker
@@ -191,6 +195,10 @@ func (w *Worker) poll(rm *sdk.RepositoriesManager, appID, pipID int64, quit chan
 			var events []sdk.VCSPushEvent
 			events, delay, err = client.PushEvents(p.Application.RepositoryFullname, p.DateCreation)
 
+			if err := trackBranches(db, client, w.ProjectKey, p, events); err != nil {
+				log.Warning("Polling> Unable to track branches of repository %s: %s\n", p.Application.RepositoryFullname, err)
+			}
+
 			s, err := triggerPipelines(db, w.ProjectKey, rm, p, events)
 			if err != nil {
 				log.Warning("Polling> Unable to trigger pipeline %s for repository %s\n", p.Pipeline.Name, p.Application.RepositoryFullname)
@@ -218,6 +226,14 @@ func (w *Worker) poll(rm *sdk.RepositoriesManager, appID, pipID int64, quit chan
 func triggerPipelines(db *sql.DB, projectKey string, rm *sdk.RepositoriesManager, poller *sdk.RepositoryPoller, events []sdk.VCSPushEvent) (string, error) {
 	status := ""
 	for _, event := range events {
+		kind := sdk.PushEvent
+		if event.Tag != "" {
+			kind = sdk.TagEvent
+		}
+		if !sdk.HasEvent(poller.Events, kind) {
+			continue
+		}
+
 		projectData, err := project.LoadProjectByPipelineID(db, poller.Pipeline.ID)
 		if err != nil {
 			log.Warning("Polling.triggerPipelines> Cannot load project for pipeline %s: %s\n", poller.Pipeline.Name, err)
@@ -237,7 +253,7 @@ func triggerPipelines(db *sql.DB, projectKey string, rm *sdk.RepositoriesManager
 			return "Error", err
 		}
 
-		ok, err := TriggerPipeline(tx, rm, poller, event, projectData)
+		ok, reason, err := TriggerPipeline(tx, rm, poller, event, projectData)
 		if err != nil {
 			log.Warning("Polling.triggerPipelines> cannot trigger pipeline %d: %s\n", poller.Pipeline.ID, err)
 			tx.Rollback()
@@ -250,29 +266,73 @@ func triggerPipelines(db *sql.DB, projectKey string, rm *sdk.RepositoriesManager
 			return "Error", err
 		}
 
+		ref := event.Branch.DisplayID
+		if event.Tag != "" {
+			ref = "tag " + event.Tag
+		}
 		if ok {
-			log.Debug("Polling.triggerPipelines> Triggered %s/%s/%s", projectKey, poller.Application.RepositoryFullname, event.Branch)
-			status = fmt.Sprintf("%s Pipeline %s triggered on %s (%s)", status, poller.Pipeline.Name, event.Branch.DisplayID, event.Commit.Hash)
+			log.Debug("Polling.triggerPipelines> Triggered %s/%s/%s", projectKey, poller.Application.RepositoryFullname, ref)
+			status = fmt.Sprintf("%s Pipeline %s triggered on %s (%s)", status, poller.Pipeline.Name, ref, event.Commit.Hash)
 		} else {
-			log.Info("Polling.triggerPipelines> Did not trigger %s/%s/%s\n", projectKey, poller.Application.RepositoryFullname, event.Branch.ID)
-			status = fmt.Sprintf("%s Pipeline %s skipped on %s (%s)", status, poller.Pipeline.Name, event.Branch.DisplayID, event.Commit.Hash)
+			log.Info("Polling.triggerPipelines> Did not trigger %s/%s/%s\n", projectKey, poller.Application.RepositoryFullname, ref)
+			status = fmt.Sprintf("%s Pipeline %s skipped on %s (%s)", status, poller.Pipeline.Name, ref, event.Commit.Hash)
+			if reason != "" {
+				status = fmt.Sprintf("%s: %s", status, reason)
+			}
 		}
 	}
 
 	return status, nil
 }
 
-// TriggerPipeline linked to received hook
-func TriggerPipeline(tx *sql.Tx, rm *sdk.RepositoriesManager, poller *sdk.RepositoryPoller, e sdk.VCSPushEvent, projectData *sdk.Project) (bool, error) {
+// trackBranches records branches pushed on the repository of the poller, and the ones deleted since they were pushed.
+// Deleted branches are detected once per application every branchesDetectionDelay seconds, whatever its number of pollers
+func trackBranches(db *sql.DB, client sdk.RepositoriesManagerClient, projectKey string, poller *sdk.RepositoryPoller, events []sdk.VCSPushEvent) error {
+	for _, e := range events {
+		if e.Tag != "" {
+			continue
+		}
+		if err := branch.Pushed(db, poller.Application.ID, e.Branch.ID); err != nil {
+			log.Warning("Polling.trackBranches> Cannot track branch %s of application %d: %s\n", e.Branch.ID, poller.Application.ID, err)
+		}
+	}
+
+	var detected string
+	k := cache.Key("reposmanager", "branches", projectKey, poller.Application.Name)
+	cache.Get(k, &detected)
+	if detected != "" {
+		return nil
+	}
+	cache.SetWithTTL(k, "true", branchesDetectionDelay)
+
+	branches, err := client.Branches(poller.Application.RepositoryFullname)
+	if err != nil {
+		return err
+	}
+	return branch.DetectDeleted(db, poller.Application.ID, branches)
+}
+
+// TriggerPipeline linked to received hook. If the build is skipped because of the commits, the reason is returned.
+// Tag events leave git.branch empty so that the tag is checked out, and are not filtered on paths
+func TriggerPipeline(tx *sql.Tx, rm *sdk.RepositoriesManager, poller *sdk.RepositoryPoller, e sdk.VCSPushEvent, projectData *sdk.Project) (bool, string, error) {
 	client, err := repositoriesmanager.AuthorizedClient(tx, projectData.Key, rm.Name)
 	if err != nil {
-		return false, err
+		return false, "", err
 	}
+	branch := e.Branch.ID
+	if e.Tag != "" {
+		branch = ""
+	}
+
 	// Create pipeline args
 	var args []sdk.Parameter
 	args = append(args, sdk.Parameter{
 		Name:  "git.branch",
-		Value: e.Branch.ID,
+		Value: branch,
+	})
+	args = append(args, sdk.Parameter{
+		Name:  "git.tag",
+		Value: e.Tag,
 	})
 	args = append(args, sdk.Parameter{
 		Name:  "git.hash",
@@ -301,18 +361,18 @@ func TriggerPipeline(tx *sql.Tx, rm *sdk.RepositoriesManager, poller *sdk.Reposi
 	// Load pipeline Argument
 	parameters, err := pipeline.GetAllParametersInPipeline(tx, poller.Pipeline.ID)
 	if err != nil {
-		return false, err
+		return false, "", err
 	}
 	poller.Pipeline.Parameter = parameters
 
 	applicationPipelineArgs, err := application.GetAllPipelineParam(tx, poller.Application.ID, poller.Pipeline.ID)
 	if err != nil {
-		return false, err
+		return false, "", err
 	}
 
 	trigger := sdk.PipelineBuildTrigger{
 		ManualTrigger:    false,
-		VCSChangesBranch: e.Branch.ID,
+		VCSChangesBranch: branch,
 		VCSChangesHash:   e.Commit.Hash,
 		VCSChangesAuthor: e.Commit.Author.DisplayName,
 	}
@@ -324,22 +384,37 @@ func TriggerPipeline(tx *sql.Tx, rm *sdk.RepositoriesManager, poller *sdk.Reposi
 	}
 	if match {
 		log.Debug("polling> Skipping build of %s/%s for commit %s by %s\n", projectData.Key, poller.Application.Name, trigger.VCSChangesHash, trigger.VCSChangesAuthor)
-		return false, nil
+		return false, "commit message contains [ci skip] or [cd skip]", nil
 	}
 
 	if b, err := pipeline.BuildExists(tx, poller.Application.ID, poller.Pipeline.ID, sdk.DefaultEnv.ID, &trigger); err != nil || b {
 		if err != nil {
 			log.Warning("Polling> Error checking existing build : %s", err)
 		}
-		return false, nil
+		return false, "", nil
+	}
+
+	// Check files changed since the last build of the branch against path filters of the poller
+	if e.Tag == "" {
+		var since string
+		pbs, err := pipeline.LoadPipelineBuildHistoryByApplicationAndPipeline(tx, poller.Application.ID, poller.Pipeline.ID, sdk.DefaultEnv.ID, 1, "", branch)
+		if err != nil {
+			log.Warning("polling> Cannot load last build of %s/%s on %s: %s\n", projectData.Key, poller.Application.Name, branch, err)
+		} else if len(pbs) == 1 {
+			since = pbs[0].Trigger.VCSChangesHash
+		}
+		if reason := repositoriesmanager.PathsSkipReason(client, poller.Application.RepositoryFullname, since, e.Commit.Hash, poller.IncludePaths, poller.ExcludePaths); reason != "" {
+			log.Debug("polling> Skipping build of %s/%s for commit %s by %s: %s\n", projectData.Key, poller.Application.Name, trigger.VCSChangesHash, trigger.VCSChangesAuthor, reason)
+			return false, reason, nil
+		}
 	}
 
 	_, err = pipeline.InsertPipelineBuild(tx, projectData, &poller.Pipeline, &poller.Application, applicationPipelineArgs, args, &sdk.DefaultEnv, 0, trigger)
 	if err != nil {
-		return false, err
+		return false, "", err
 	}
 
-	return true, nil
+	return true, "", nil
 }
 
 func insertExecution(db database.QueryExecuter, app *sdk.Application, pip *sdk.Pipeline, e *WorkerExecution) error {
@@ -0,0 +1,337 @@
+package repobitbucket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+//pollingInterval is the number of seconds between two calls of PushEvents, Bitbucket has no events API
+const pollingInterval time.Duration = 60
+
+//BitbucketClient is a Bitbucket Cloud wrapper for CDS RepositoriesManagerClient interface
+type BitbucketClient struct {
+	consumer     *BitbucketConsumer
+	accessToken  string
+	refreshToken string
+	mutex        sync.Mutex
+}
+
+func toVCSRepo(r Repository) sdk.VCSRepo {
+	repo := sdk.VCSRepo{
+		ID:       r.UUID,
+		Name:     r.Name,
+		Slug:     strings.Split(r.FullName, "/")[0],
+		Fullname: r.FullName,
+		URL:      r.Links.HTML.Href,
+	}
+	for _, l := range r.Links.Clone {
+		switch l.Name {
+		case "https":
+			repo.HTTPCloneURL = l.Href
+		case "ssh":
+			repo.SSHCloneURL = l.Href
+		}
+	}
+	return repo
+}
+
+func toVCSCommit(c Commit) sdk.VCSCommit {
+	name, email := parseAuthor(c.Author.Raw)
+	commit := sdk.VCSCommit{
+		Hash:      c.Hash,
+		Message:   c.Message,
+		Timestamp: c.Date.Unix() * 1000,
+		URL:       c.Links.HTML.Href,
+		Author: sdk.VCSAuthor{
+			Name:        name,
+			DisplayName: name,
+			Email:       email,
+		},
+	}
+	if c.Author.User != nil {
+		commit.Author.Name = c.Author.User.Nickname
+		commit.Author.DisplayName = c.Author.User.DisplayName
+		commit.Author.Avatar = c.Author.User.Links.Avatar.Href
+	}
+	return commit
+}
+
+// Repos list repositories the authenticated user is member of
+// https://developer.atlassian.com/bitbucket/api/2/reference/resource/repositories
+func (c *BitbucketClient) Repos() ([]sdk.VCSRepo, error) {
+	repos := []sdk.VCSRepo{}
+	err := c.getAll("/repositories?role=member&pagelen=100", func(values json.RawMessage) error {
+		page := []Repository{}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, r := range page {
+			repos = append(repos, toVCSRepo(r))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warning("BitbucketClient.Repos> Error %s", err)
+		return nil, err
+	}
+	return repos, nil
+}
+
+func (c *BitbucketClient) repoByFullname(fullname string) (Repository, error) {
+	var r Repository
+	_, err := c.do(http.MethodGet, "/repositories/"+fullname, nil, &r)
+	return r, err
+}
+
+// RepoByFullname Get only one repo
+func (c *BitbucketClient) RepoByFullname(fullname string) (sdk.VCSRepo, error) {
+	r, err := c.repoByFullname(fullname)
+	if err != nil {
+		log.Warning("BitbucketClient.RepoByFullname> Error %s", err)
+		return sdk.VCSRepo{}, err
+	}
+	return toVCSRepo(r), nil
+}
+
+// Branches returns list of branches for a repo
+// https://developer.atlassian.com/bitbucket/api/2/reference/resource/repositories/%7Busername%7D/%7Brepo_slug%7D/refs/branches
+func (c *BitbucketClient) Branches(fullname string) ([]sdk.VCSBranch, error) {
+	repo, err := c.repoByFullname(fullname)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := []sdk.VCSBranch{}
+	err = c.getAll("/repositories/"+fullname+"/refs/branches?pagelen=100", func(values json.RawMessage) error {
+		page := []Branch{}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, b := range page {
+			branches = append(branches, sdk.VCSBranch{
+				ID:           b.Name,
+				DisplayID:    b.Name,
+				LatestCommit: b.Target.Hash,
+				Default:      b.Name == repo.Mainbranch.Name,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warning("BitbucketClient.Branches> Error %s", err)
+		return nil, err
+	}
+	return branches, nil
+}
+
+// Branch returns only detail of a branch
+func (c *BitbucketClient) Branch(fullname, branch string) (sdk.VCSBranch, error) {
+	repo, err := c.repoByFullname(fullname)
+	if err != nil {
+		return sdk.VCSBranch{}, err
+	}
+
+	var b Branch
+	if _, err := c.do(http.MethodGet, "/repositories/"+fullname+"/refs/branches/"+url.QueryEscape(branch), nil, &b); err != nil {
+		if err == sdk.ErrRepoNotFound {
+			return sdk.VCSBranch{}, sdk.ErrNoBranch
+		}
+		return sdk.VCSBranch{}, err
+	}
+	return sdk.VCSBranch{
+		ID:           b.Name,
+		DisplayID:    b.Name,
+		LatestCommit: b.Target.Hash,
+		Default:      b.Name == repo.Mainbranch.Name,
+	}, nil
+}
+
+// Commits returns the commits reachable from until and not from since, newest first
+// https://developer.atlassian.com/bitbucket/api/2/reference/resource/repositories/%7Busername%7D/%7Brepo_slug%7D/commits/%7Brevision%7D
+func (c *BitbucketClient) Commits(repo, since, until string) ([]sdk.VCSCommit, error) {
+	if since == "" {
+		commit, err := c.Commit(repo, until)
+		if err != nil {
+			return nil, err
+		}
+		return []sdk.VCSCommit{commit}, nil
+	}
+
+	val := url.Values{}
+	val.Add("exclude", since)
+	commits := []sdk.VCSCommit{}
+	err := c.getAll("/repositories/"+repo+"/commits/"+until+"?"+val.Encode(), func(values json.RawMessage) error {
+		page := []Commit{}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, cm := range page {
+			commits = append(commits, toVCSCommit(cm))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warning("BitbucketClient.Commits> Error %s", err)
+		return nil, err
+	}
+	return commits, nil
+}
+
+// Commit Get a single commit
+func (c *BitbucketClient) Commit(repo, hash string) (sdk.VCSCommit, error) {
+	var cm Commit
+	if _, err := c.do(http.MethodGet, "/repositories/"+repo+"/commit/"+hash, nil, &cm); err != nil {
+		log.Warning("BitbucketClient.Commit> Error %s", err)
+		return sdk.VCSCommit{}, err
+	}
+	return toVCSCommit(cm), nil
+}
+
+// ChangedFiles returns the paths of files changed by commits from since excluded to until.
+// When since is empty, the changes of until compared to its parent are returned
+// https://developer.atlassian.com/bitbucket/api/2/reference/resource/repositories/%7Busername%7D/%7Brepo_slug%7D/diffstat/%7Bspec%7D
+func (c *BitbucketClient) ChangedFiles(repo, since, until string) ([]string, error) {
+	spec := until
+	if since != "" {
+		spec = until + ".." + since
+	}
+
+	files := []string{}
+	err := c.getAll("/repositories/"+repo+"/diffstat/"+spec, func(values json.RawMessage) error {
+		page := []DiffStat{}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, d := range page {
+			if d.New != nil {
+				files = append(files, d.New.Path)
+			}
+			if d.Old != nil && (d.New == nil || d.Old.Path != d.New.Path) {
+				files = append(files, d.Old.Path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warning("BitbucketClient.ChangedFiles> Error %s", err)
+		return nil, err
+	}
+	return files, nil
+}
+
+func (c *BitbucketClient) hooks(repo string) ([]Hook, error) {
+	hooks := []Hook{}
+	err := c.getAll("/repositories/"+repo+"/hooks", func(values json.RawMessage) error {
+		page := []Hook{}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		hooks = append(hooks, page...)
+		return nil
+	})
+	return hooks, err
+}
+
+//CreateHook creates a webhook on push events of the repository, unless it already exists
+// https://developer.atlassian.com/bitbucket/api/2/reference/resource/repositories/%7Busername%7D/%7Brepo_slug%7D/hooks
+func (c *BitbucketClient) CreateHook(repo, hookURL string) error {
+	hooks, err := c.hooks(repo)
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.URL == hookURL {
+			return nil
+		}
+	}
+
+	log.Notice("CreateHook> Ask Bitbucket to create Hook on %s : %s", repo, hookURL)
+	h := Hook{Description: "CDS", URL: hookURL, Active: true, Events: []string{"repo:push"}}
+	_, err = c.do(http.MethodPost, "/repositories/"+repo+"/hooks", h, &h)
+	return err
+}
+
+//DeleteHook removes the webhook of the repository with given url
+func (c *BitbucketClient) DeleteHook(repo, hookURL string) error {
+	hooks, err := c.hooks(repo)
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.URL == hookURL {
+			log.Notice("DeleteHook> Ask Bitbucket to delete Hook %s on %s", h.UUID, repo)
+			_, err := c.do(http.MethodDelete, "/repositories/"+repo+"/hooks/"+url.QueryEscape(h.UUID), nil, nil)
+			return err
+		}
+	}
+	return nil
+}
+
+//PushEvents returns the last commit of branches updated after dateRef, and tags created after dateRef.
+//The date of lightweight tags is the date of their commit
+func (c *BitbucketClient) PushEvents(fullname string, dateRef time.Time) ([]sdk.VCSPushEvent, time.Duration, error) {
+	repo, err := c.repoByFullname(fullname)
+	if err != nil {
+		return nil, pollingInterval, err
+	}
+
+	events := []sdk.VCSPushEvent{}
+	err = c.getAll("/repositories/"+fullname+"/refs/branches?pagelen=100&sort=-target.date", func(values json.RawMessage) error {
+		page := []Branch{}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, b := range page {
+			if !b.Target.Date.After(dateRef) {
+				continue
+			}
+			events = append(events, sdk.VCSPushEvent{
+				Branch: sdk.VCSBranch{
+					ID:           b.Name,
+					DisplayID:    b.Name,
+					LatestCommit: b.Target.Hash,
+					Default:      b.Name == repo.Mainbranch.Name,
+				},
+				Commit: toVCSCommit(b.Target),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warning("BitbucketClient.PushEvents> Error %s", err)
+		return nil, pollingInterval, err
+	}
+
+	err = c.getAll("/repositories/"+fullname+"/refs/tags?pagelen=100&sort=-target.date", func(values json.RawMessage) error {
+		page := []Tag{}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, t := range page {
+			date := t.Target.Date
+			if t.Date != nil {
+				date = *t.Date
+			}
+			if !date.After(dateRef) {
+				continue
+			}
+			events = append(events, sdk.VCSPushEvent{
+				Commit: toVCSCommit(t.Target),
+				Tag:    t.Name,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warning("BitbucketClient.PushEvents> Error %s", err)
+		return nil, pollingInterval, err
+	}
+	return events, pollingInterval, nil
+}
@@ -0,0 +1,249 @@
+package repobitbucket
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// fixtureServer replays responses recorded from Bitbucket Cloud API, stored in testdata
+type fixtureServer struct {
+	*httptest.Server
+	t        *testing.T
+	requests []string
+	bodies   []string
+}
+
+func newFixtureServer(t *testing.T) *fixtureServer {
+	s := &fixtureServer{t: t}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *fixtureServer) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	s.bodies = append(s.bodies, string(body))
+
+	if r.Header.Get("Authorization") == "Bearer expired-token" {
+		s.reply(w, http.StatusUnauthorized, "unauthorized.json")
+		return
+	}
+
+	switch p := r.URL.Path; {
+	case r.Method == "POST" && p == "/site/oauth2/access_token":
+		s.reply(w, http.StatusOK, "token.json")
+	case p == "/repositories" && r.URL.Query().Get("page") == "2":
+		s.reply(w, http.StatusOK, "repositories_2.json")
+	case p == "/repositories":
+		s.reply(w, http.StatusOK, "repositories_1.json")
+	case p == "/repositories/ovh/api":
+		s.reply(w, http.StatusOK, "repository.json")
+	case strings.HasPrefix(p, "/repositories/ovh/api/refs/branches"):
+		s.reply(w, http.StatusOK, "branches.json")
+	case strings.HasPrefix(p, "/repositories/ovh/api/refs/tags"):
+		s.reply(w, http.StatusOK, "tags.json")
+	case strings.HasPrefix(p, "/repositories/ovh/api/commits/"):
+		s.reply(w, http.StatusOK, "commits.json")
+	case strings.HasPrefix(p, "/repositories/ovh/api/commit/"):
+		s.reply(w, http.StatusOK, "commit.json")
+	case strings.HasPrefix(p, "/repositories/ovh/api/diffstat/"):
+		s.reply(w, http.StatusOK, "diffstat.json")
+	case r.Method == "GET" && p == "/repositories/ovh/api/hooks":
+		s.reply(w, http.StatusOK, "hooks.json")
+	case r.Method == "POST" && p == "/repositories/ovh/api/hooks":
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	case r.Method == "DELETE" && strings.HasPrefix(p, "/repositories/ovh/api/hooks/"):
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *fixtureServer) reply(w http.ResponseWriter, status int, fixture string) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(strings.Replace(string(b), "{{.URL}}", s.URL, -1)))
+}
+
+func (s *fixtureServer) client(accessToken string) *BitbucketClient {
+	consumer := New("client-id", filepath.Join("testdata", "client_secret"))
+	consumer.url, consumer.apiURL = s.URL, s.URL
+	return &BitbucketClient{
+		consumer:     consumer,
+		accessToken:  accessToken,
+		refreshToken: "refresh-token",
+	}
+}
+
+func TestRepos(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+
+	repos, err := s.client("token").Repos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("expected 2 repositories over 2 pages, got %d", len(repos))
+	}
+	r := repos[0]
+	if r.Fullname != "ovh/api" || r.HTTPCloneURL != "https://bitbucket.org/ovh/api.git" || r.SSHCloneURL != "git@bitbucket.org:ovh/api.git" {
+		t.Errorf("unexpected repository %+v", r)
+	}
+}
+
+func TestBranches(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+
+	branches, err := s.client("token").Branches("ovh/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 2 {
+		t.Fatalf("expected 2 branches, got %d", len(branches))
+	}
+	if !branches[0].Default || branches[0].LatestCommit != "9fceb02d0ae598e95dc970b74767f19372d61af8" {
+		t.Errorf("unexpected branch %+v", branches[0])
+	}
+	if branches[1].Default {
+		t.Errorf("branch %s should not be default", branches[1].DisplayID)
+	}
+}
+
+func TestCommits(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+
+	commits, err := s.client("token").Commits("ovh/api", "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c", "9fceb02d0ae598e95dc970b74767f19372d61af8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(commits))
+	}
+	if got := s.requests[len(s.requests)-1]; got != "GET /repositories/ovh/api/commits/9fceb02d0ae598e95dc970b74767f19372d61af8?exclude=0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c" {
+		t.Errorf("unexpected request %s", got)
+	}
+	if a := commits[0].Author; a.Name != "jdoe" || a.Email != "jane.doe@example.com" || a.Avatar != "https://avatar/jdoe" {
+		t.Errorf("unexpected author %+v", a)
+	}
+	if a := commits[1].Author; a.Name != "John Smith" || a.Email != "john@example.com" {
+		t.Errorf("unexpected author %+v", a)
+	}
+
+	c, err := s.client("token").Commit("ovh/api", "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Timestamp != time.Date(2016, 11, 1, 18, 40, 22, 0, time.UTC).Unix()*1000 {
+		t.Errorf("unexpected timestamp %d", c.Timestamp)
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+
+	files, err := s.client("token").ChangedFiles("ovh/api", "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c", "9fceb02d0ae598e95dc970b74767f19372d61af8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.requests[len(s.requests)-1]; got != "GET /repositories/ovh/api/diffstat/9fceb02d0ae598e95dc970b74767f19372d61af8..0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c" {
+		t.Errorf("unexpected request %s", got)
+	}
+	expected := []string{"services/api/main.go", "services/api/notification.go", "docs/old.md", "libs/log/log.go", "libs/log.go"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+	c := s.client("token")
+
+	// Existing hook is not created again
+	if err := c.CreateHook("ovh/api", "https://cds.example.com/hook?uid=existing&project=ovh&name=api"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.requests) != 1 {
+		t.Errorf("unexpected requests %v", s.requests)
+	}
+
+	if err := c.CreateHook("ovh/api", "https://cds.example.com/hook?uid=new&project=ovh&name=api"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.requests[len(s.requests)-1]; got != "POST /repositories/ovh/api/hooks" {
+		t.Errorf("unexpected request %s", got)
+	}
+	if body := s.bodies[len(s.bodies)-1]; !strings.Contains(body, `"events":["repo:push"]`) || !strings.Contains(body, `uid=new`) {
+		t.Errorf("unexpected hook %s", body)
+	}
+
+	if err := c.DeleteHook("ovh/api", "https://cds.example.com/hook?uid=existing&project=ovh&name=api"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.requests[len(s.requests)-1]; got != "DELETE /repositories/ovh/api/hooks/%7B4f3c2b1a-0e9d-4c8b-a7f6-e5d4c3b2a190%7D" {
+		t.Errorf("unexpected request %s", got)
+	}
+}
+
+func TestPushEvents(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+
+	events, _, err := s.client("token").PushEvents("ovh/api", time.Date(2016, 10, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Branch.DisplayID != "master" || events[0].Commit.Hash != "9fceb02d0ae598e95dc970b74767f19372d61af8" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	// Annotated tag v1.0.0 is more recent than its commit, lightweight tag v0.9.0 is too old
+	if e := events[1]; e.Tag != "v1.0.0" || e.Branch.ID != "" || e.Commit.Hash != "6dcb09b5b57875f334f61aebed695e2e4193db5e" {
+		t.Errorf("unexpected tag event %+v", e)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+	c := s.client("expired-token")
+	var saved []string
+	c.consumer.TokenRefreshed = func(old, access, refresh string) {
+		saved = []string{old, access, refresh}
+	}
+
+	if _, err := c.RepoByFullname("ovh/api"); err != nil {
+		t.Fatal(err)
+	}
+	if c.accessToken != "refreshed-access-token" {
+		t.Errorf("access token was not refreshed")
+	}
+	if !reflect.DeepEqual(saved, []string{"refresh-token", "refreshed-access-token", "refresh-token"}) {
+		t.Errorf("refreshed tokens were not saved: %v", saved)
+	}
+	if s.bodies[1] != "grant_type=refresh_token&refresh_token=refresh-token" {
+		t.Errorf("unexpected refresh request %s", s.bodies[1])
+	}
+
+	c.refreshToken = ""
+	c.accessToken = "expired-token"
+	if _, err := c.RepoByFullname("ovh/api"); err != sdk.ErrNoReposManagerClientAuth {
+		t.Errorf("expected ErrNoReposManagerClientAuth, got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package repobitbucket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/facebookgo/httpcontrol"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+var httpClient = &http.Client{
+	Transport: &httpcontrol.Transport{
+		RequestTimeout: time.Second * 30,
+		MaxTries:       3,
+	},
+}
+
+//accessToken is the response of the token endpoint
+type accessToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+//apiError wraps Bitbucket error format
+type apiError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func errorAPI(status int, body []byte) error {
+	e := apiError{}
+	if err := json.Unmarshal(body, &e); err == nil && e.Error.Message != "" {
+		return fmt.Errorf("bitbucket error (%d) %s", status, e.Error.Message)
+	}
+	return fmt.Errorf("bitbucket error (%d) %s", status, string(body))
+}
+
+func (b *BitbucketConsumer) token(params url.Values) (*accessToken, error) {
+	secret, err := b.getClientSecretValue()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.url+"/site/oauth2/access_token", strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(b.ClientID, secret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		return nil, errorAPI(res.StatusCode, body)
+	}
+
+	t := &accessToken{}
+	if err := json.Unmarshal(body, t); err != nil {
+		return nil, fmt.Errorf("Unable to parse bitbucket response (%d) %s", res.StatusCode, string(body))
+	}
+	return t, nil
+}
+
+//do sends a request to Bitbucket API, refreshing access token once when it has expired.
+//When out is not nil, the response is decoded in it
+func (c *BitbucketClient) do(method, path string, in, out interface{}) (int, error) {
+	c.mutex.Lock()
+	refreshToken := c.refreshToken
+	c.mutex.Unlock()
+
+	status, body, err := c.send(method, path, in)
+	if err == nil && status == http.StatusUnauthorized && refreshToken != "" {
+		if err := c.refresh(refreshToken); err != nil {
+			log.Warning("BitbucketClient> Cannot refresh access token: %s", err)
+			return status, sdk.ErrNoReposManagerClientAuth
+		}
+		status, body, err = c.send(method, path, in)
+	}
+	if err != nil {
+		return status, err
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return status, sdk.ErrNoReposManagerClientAuth
+	case status == http.StatusNotFound:
+		return status, sdk.NewError(sdk.ErrRepoNotFound, errorAPI(status, body))
+	case status >= 400:
+		return status, sdk.NewError(sdk.ErrUnknownError, errorAPI(status, body))
+	}
+
+	if out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			log.Warning("BitbucketClient> Unable to parse response of %s: %s", path, err)
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+//refresh gets a new access token unless another request already did it since refreshToken was read,
+//then stores new tokens
+func (c *BitbucketClient) refresh(refreshToken string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.refreshToken != refreshToken {
+		return nil
+	}
+
+	log.Debug("Bitbucket API>> Refresh access token")
+	access, refresh, err := c.consumer.refresh(refreshToken)
+	if err != nil {
+		return err
+	}
+	c.accessToken = access
+	if refresh != "" && refresh != refreshToken {
+		c.refreshToken = refresh
+		instancesMutex.Lock()
+		instancesAuthorizedClient[refresh] = c
+		instancesMutex.Unlock()
+	}
+	if c.consumer.TokenRefreshed != nil {
+		c.consumer.TokenRefreshed(refreshToken, c.accessToken, c.refreshToken)
+	}
+	return nil
+}
+
+func (c *BitbucketClient) send(method, path string, in interface{}) (int, []byte, error) {
+	if !strings.HasPrefix(path, "http") {
+		path = c.consumer.apiURL + path
+	}
+
+	var reqBody *bytes.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, nil, err
+		}
+		reqBody = bytes.NewReader(b)
+	} else {
+		reqBody = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, path, reqBody)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	c.mutex.Lock()
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	c.mutex.Unlock()
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	log.Debug("Bitbucket API>> Request %s %s", method, req.URL.String())
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	return res.StatusCode, body, err
+}
+
+//page is a page of a paginated Bitbucket response
+type page struct {
+	Next   string          `json:"next"`
+	Values json.RawMessage `json:"values"`
+}
+
+//getAll follows pagination of path, each page of values is passed to f
+func (c *BitbucketClient) getAll(path string, f func(values json.RawMessage) error) error {
+	for path != "" {
+		var p page
+		if _, err := c.do(http.MethodGet, path, nil, &p); err != nil {
+			return err
+		}
+		if err := f(p.Values); err != nil {
+			return err
+		}
+		path = p.Next
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package repobitbucket
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sync"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+//Bitbucket Cloud URLs
+const (
+	URL    = "https://bitbucket.org"
+	APIURL = "https://api.bitbucket.org/2.0"
+)
+
+//BitbucketConsumer embeds a Bitbucket Cloud oauth2 consumer
+type BitbucketConsumer struct {
+	ClientID     string `json:"client-id"`
+	ClientSecret string `json:"client-secret"`
+	//TokenRefreshed is called with the new tokens of a client whose access token has been refreshed
+	TokenRefreshed func(oldRefreshToken, accessToken, refreshToken string) `json:"-"`
+	url            string
+	apiURL         string
+}
+
+//New creates a new BitbucketConsumer
+func New(clientID, clientSecret string) *BitbucketConsumer {
+	return &BitbucketConsumer{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		url:          URL,
+		apiURL:       APIURL,
+	}
+}
+
+func generateState() (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		log.Critical("repobitbucket.generateState> rand.Read failed: %s\n", err)
+		return "", err
+	}
+	return hex.EncodeToString(bs), nil
+}
+
+//getClientSecretValue reads client secret written from Vault
+func (b *BitbucketConsumer) getClientSecretValue() (string, error) {
+	s, err := ioutil.ReadFile(b.ClientSecret)
+	if err != nil {
+		log.Critical("BitbucketConsumer> Unable to read client secret value %s : %s", b.ClientSecret, err)
+		return "", err
+	}
+	return string(bytes.TrimSpace(s)), nil
+}
+
+//Data returns a serilized version of specific data
+func (b *BitbucketConsumer) Data() string {
+	data, _ := json.Marshal(b)
+	return string(data)
+}
+
+//AuthorizeRedirect returns the state and the Authorize URL
+//doc: https://developer.atlassian.com/bitbucket/api/2/reference/meta/authentication
+func (b *BitbucketConsumer) AuthorizeRedirect() (string, string, error) {
+	state, err := generateState()
+	if err != nil {
+		return "", "", err
+	}
+
+	val := url.Values{}
+	val.Add("client_id", b.ClientID)
+	val.Add("response_type", "code")
+	val.Add("state", state)
+
+	return state, fmt.Sprintf("%s/site/oauth2/authorize?%s", b.url, val.Encode()), nil
+}
+
+//AuthorizeToken returns the access token and the refresh token
+//from the code got on authorize url
+func (b *BitbucketConsumer) AuthorizeToken(state, code string) (string, string, error) {
+	log.Debug("AuthorizeToken> Bitbucket send code %s for state %s", code, state)
+
+	params := url.Values{}
+	params.Add("grant_type", "authorization_code")
+	params.Add("code", code)
+
+	t, err := b.token(params)
+	if err != nil {
+		return "", "", err
+	}
+	return t.AccessToken, t.RefreshToken, nil
+}
+
+//refresh gets a new access token, Bitbucket access tokens expire after two hours
+func (b *BitbucketConsumer) refresh(refreshToken string) (string, string, error) {
+	params := url.Values{}
+	params.Add("grant_type", "refresh_token")
+	params.Add("refresh_token", refreshToken)
+
+	t, err := b.token(params)
+	if err != nil {
+		return "", "", err
+	}
+	return t.AccessToken, t.RefreshToken, nil
+}
+
+//keep client in memory
+var (
+	instancesAuthorizedClient = map[string]*BitbucketClient{}
+	instancesMutex            sync.Mutex
+)
+
+//GetAuthorized returns an authorized client
+func (b *BitbucketConsumer) GetAuthorized(accessToken, refreshToken string) (sdk.RepositoriesManagerClient, error) {
+	instancesMutex.Lock()
+	defer instancesMutex.Unlock()
+
+	c := instancesAuthorizedClient[refreshToken]
+	if c == nil {
+		c = &BitbucketClient{
+			consumer:     b,
+			accessToken:  accessToken,
+			refreshToken: refreshToken,
+		}
+		instancesAuthorizedClient[refreshToken] = c
+	}
+	return c, nil
+}
+
+//HooksSupported returns true if the driver technically support hook
+func (b *BitbucketConsumer) HooksSupported() bool {
+	return true
+}
+
+//PollingSupported returns true if the driver technically support polling
+func (b *BitbucketConsumer) PollingSupported() bool {
+	return true
+}
@@ -0,0 +1,95 @@
+package repobitbucket
+
+import (
+	"net/mail"
+	"time"
+)
+
+//Link is a link of a Bitbucket resource
+type Link struct {
+	Name string `json:"name,omitempty"`
+	Href string `json:"href"`
+}
+
+//User is a Bitbucket account
+type User struct {
+	UUID        string `json:"uuid"`
+	Nickname    string `json:"nickname"`
+	DisplayName string `json:"display_name"`
+	Links       struct {
+		Avatar Link `json:"avatar"`
+	} `json:"links"`
+}
+
+//Repository is a Bitbucket repository
+type Repository struct {
+	UUID       string `json:"uuid"`
+	Name       string `json:"name"`
+	FullName   string `json:"full_name"`
+	Mainbranch struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+	Links struct {
+		HTML  Link   `json:"html"`
+		Clone []Link `json:"clone"`
+	} `json:"links"`
+}
+
+//Author is the author of a commit, Raw is "Name <email>"
+type Author struct {
+	Raw  string `json:"raw"`
+	User *User  `json:"user"`
+}
+
+//Commit is a Bitbucket commit
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+	Author  Author    `json:"author"`
+	Links   struct {
+		HTML Link `json:"html"`
+	} `json:"links"`
+}
+
+//Branch is a Bitbucket branch
+type Branch struct {
+	Name   string `json:"name"`
+	Target Commit `json:"target"`
+}
+
+//Tag is a Bitbucket tag, its date is only known for annotated tags
+type Tag struct {
+	Name   string     `json:"name"`
+	Date   *time.Time `json:"date"`
+	Target Commit     `json:"target"`
+}
+
+//DiffStat is the change of a file between two commits, old is null for added files and new for removed files
+type DiffStat struct {
+	Status string `json:"status"`
+	Old    *struct {
+		Path string `json:"path"`
+	} `json:"old"`
+	New *struct {
+		Path string `json:"path"`
+	} `json:"new"`
+}
+
+//Hook is a Bitbucket webhook
+type Hook struct {
+	UUID        string   `json:"uuid,omitempty"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Active      bool     `json:"active"`
+	Events      []string `json:"events"`
+}
+
+//parseAuthor splits "Name <email>"
+func parseAuthor(raw string) (string, string) {
+	a, err := mail.ParseAddress(raw)
+	if err != nil {
+		return raw, ""
+	}
+	return a.Name, a.Address
+}
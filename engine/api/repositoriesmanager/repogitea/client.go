@@ -0,0 +1,372 @@
+package repogitea
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+//pollingInterval is the number of seconds between two calls of PushEvents, Gitea has no events API for repositories
+const pollingInterval time.Duration = 60
+
+//maxCommitsPages bounds the number of pages of commits fetched to find a commit
+const maxCommitsPages = 10
+
+//GiteaClient is a Gitea wrapper for CDS RepositoriesManagerClient interface
+type GiteaClient struct {
+	consumer     *GiteaConsumer
+	accessToken  string
+	refreshToken string
+	mutex        sync.Mutex
+}
+
+func toVCSRepo(r Repository) sdk.VCSRepo {
+	return sdk.VCSRepo{
+		ID:           fmt.Sprintf("%d", r.ID),
+		Name:         r.Name,
+		Slug:         r.Name,
+		Fullname:     r.FullName,
+		URL:          r.HTMLURL,
+		HTTPCloneURL: r.CloneURL,
+		SSHCloneURL:  r.SSHURL,
+	}
+}
+
+func toVCSCommit(c Commit) sdk.VCSCommit {
+	commit := sdk.VCSCommit{
+		Hash:      c.SHA,
+		Message:   c.Commit.Message,
+		Timestamp: c.Commit.Author.Date.Unix() * 1000,
+		URL:       c.HTMLURL,
+		Author: sdk.VCSAuthor{
+			Name:        c.Commit.Author.Name,
+			DisplayName: c.Commit.Author.Name,
+			Email:       c.Commit.Author.Email,
+		},
+	}
+	if c.Author != nil {
+		commit.Author.Name = c.Author.Login
+		commit.Author.Avatar = c.Author.AvatarURL
+	}
+	return commit
+}
+
+func toVCSBranch(b Branch, defaultBranch string) sdk.VCSBranch {
+	return sdk.VCSBranch{
+		ID:           b.Name,
+		DisplayID:    b.Name,
+		LatestCommit: b.Commit.ID,
+		Default:      b.Name == defaultBranch,
+	}
+}
+
+// Repos list repositories that are accessible to the authenticated user
+// https://try.gitea.io/api/swagger#/user/userCurrentListRepos
+func (c *GiteaClient) Repos() ([]sdk.VCSRepo, error) {
+	repos := []sdk.VCSRepo{}
+	for next := "/user/repos?limit=50"; next != ""; {
+		page := []Repository{}
+		var err error
+		if next, err = c.do(http.MethodGet, next, nil, &page); err != nil {
+			log.Warning("GiteaClient.Repos> Error %s", err)
+			return nil, err
+		}
+		for _, r := range page {
+			repos = append(repos, toVCSRepo(r))
+		}
+	}
+	return repos, nil
+}
+
+func (c *GiteaClient) repoByFullname(fullname string) (Repository, error) {
+	var r Repository
+	_, err := c.do(http.MethodGet, "/repos/"+fullname, nil, &r)
+	return r, err
+}
+
+// RepoByFullname Get only one repo
+func (c *GiteaClient) RepoByFullname(fullname string) (sdk.VCSRepo, error) {
+	r, err := c.repoByFullname(fullname)
+	if err != nil {
+		log.Warning("GiteaClient.RepoByFullname> Error %s", err)
+		return sdk.VCSRepo{}, err
+	}
+	return toVCSRepo(r), nil
+}
+
+func (c *GiteaClient) branches(fullname string) ([]Branch, error) {
+	branches := []Branch{}
+	for next := "/repos/" + fullname + "/branches?limit=50"; next != ""; {
+		page := []Branch{}
+		var err error
+		if next, err = c.do(http.MethodGet, next, nil, &page); err != nil {
+			return nil, err
+		}
+		branches = append(branches, page...)
+	}
+	return branches, nil
+}
+
+// Branches returns list of branches for a repo
+// https://try.gitea.io/api/swagger#/repository/repoListBranches
+func (c *GiteaClient) Branches(fullname string) ([]sdk.VCSBranch, error) {
+	repo, err := c.repoByFullname(fullname)
+	if err != nil {
+		return nil, err
+	}
+
+	branches, err := c.branches(fullname)
+	if err != nil {
+		log.Warning("GiteaClient.Branches> Error %s", err)
+		return nil, err
+	}
+
+	res := []sdk.VCSBranch{}
+	for _, b := range branches {
+		res = append(res, toVCSBranch(b, repo.DefaultBranch))
+	}
+	return res, nil
+}
+
+// Branch returns only detail of a branch
+func (c *GiteaClient) Branch(fullname, branch string) (sdk.VCSBranch, error) {
+	repo, err := c.repoByFullname(fullname)
+	if err != nil {
+		return sdk.VCSBranch{}, err
+	}
+
+	var b Branch
+	if _, err := c.do(http.MethodGet, "/repos/"+fullname+"/branches/"+url.QueryEscape(branch), nil, &b); err != nil {
+		if err == sdk.ErrRepoNotFound {
+			return sdk.VCSBranch{}, sdk.ErrNoBranch
+		}
+		return sdk.VCSBranch{}, err
+	}
+	return toVCSBranch(b, repo.DefaultBranch), nil
+}
+
+// Commits returns the commits from until back to since excluded, newest first
+// https://try.gitea.io/api/swagger#/repository/repoGetAllCommits
+func (c *GiteaClient) Commits(repo, since, until string) ([]sdk.VCSCommit, error) {
+	if since == "" {
+		commit, err := c.Commit(repo, until)
+		if err != nil {
+			return nil, err
+		}
+		return []sdk.VCSCommit{commit}, nil
+	}
+
+	commits, err := c.commits(repo, since, until)
+	if err != nil {
+		log.Warning("GiteaClient.Commits> Error %s", err)
+		return nil, err
+	}
+
+	res := []sdk.VCSCommit{}
+	for _, cm := range commits {
+		res = append(res, toVCSCommit(cm))
+	}
+	return res, nil
+}
+
+//commits lists commits from since excluded to until, walking back the history of until for at most maxCommitsPages pages.
+//It fails when since is not found, ie. history has been rewritten
+func (c *GiteaClient) commits(repo, since, until string) ([]Commit, error) {
+	val := url.Values{}
+	val.Add("sha", until)
+	val.Add("limit", "50")
+
+	commits := []Commit{}
+	next := "/repos/" + repo + "/commits?" + val.Encode()
+	for i := 0; i < maxCommitsPages && next != ""; i++ {
+		page := []Commit{}
+		var err error
+		if next, err = c.do(http.MethodGet, next, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, cm := range page {
+			if cm.SHA == since {
+				return commits, nil
+			}
+			commits = append(commits, cm)
+		}
+	}
+	return nil, fmt.Errorf("commit %s not found in the %d last commits of %s on %s", since, len(commits), until, repo)
+}
+
+// Commit Get a single commit
+// https://try.gitea.io/api/swagger#/repository/repoGetSingleCommit
+func (c *GiteaClient) Commit(repo, hash string) (sdk.VCSCommit, error) {
+	cm, err := c.commit(repo, hash)
+	if err != nil {
+		log.Warning("GiteaClient.Commit> Error %s", err)
+		return sdk.VCSCommit{}, err
+	}
+	return toVCSCommit(cm), nil
+}
+
+func (c *GiteaClient) commit(repo, hash string) (Commit, error) {
+	var cm Commit
+	_, err := c.do(http.MethodGet, "/repos/"+repo+"/git/commits/"+hash, nil, &cm)
+	return cm, err
+}
+
+// ChangedFiles returns the paths of files changed by commits from since excluded to until.
+// When since is empty, only the files changed by until are returned
+func (c *GiteaClient) ChangedFiles(repo, since, until string) ([]string, error) {
+	var commits []Commit
+	if since == "" {
+		cm, err := c.commit(repo, until)
+		if err != nil {
+			log.Warning("GiteaClient.ChangedFiles> Error %s", err)
+			return nil, err
+		}
+		commits = []Commit{cm}
+	} else {
+		var err error
+		if commits, err = c.commits(repo, since, until); err != nil {
+			log.Warning("GiteaClient.ChangedFiles> Error %s", err)
+			return nil, err
+		}
+	}
+
+	files := []string{}
+	seen := map[string]bool{}
+	for _, cm := range commits {
+		for _, f := range cm.Files {
+			if !seen[f.Filename] {
+				seen[f.Filename] = true
+				files = append(files, f.Filename)
+			}
+		}
+	}
+	return files, nil
+}
+
+func (c *GiteaClient) hooks(repo string) ([]Hook, error) {
+	hooks := []Hook{}
+	for next := "/repos/" + repo + "/hooks"; next != ""; {
+		page := []Hook{}
+		var err error
+		if next, err = c.do(http.MethodGet, next, nil, &page); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, page...)
+	}
+	return hooks, nil
+}
+
+//CreateHook creates a webhook on push events of the repository, unless it already exists
+// https://try.gitea.io/api/swagger#/repository/repoCreateHook
+func (c *GiteaClient) CreateHook(repo, hookURL string) error {
+	hooks, err := c.hooks(repo)
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.Config["url"] == hookURL {
+			return nil
+		}
+	}
+
+	log.Notice("CreateHook> Ask Gitea to create Hook on %s : %s", repo, hookURL)
+	h := Hook{
+		Type:   "gitea",
+		Config: map[string]string{"url": hookURL, "content_type": "json"},
+		Events: []string{"push", "delete"},
+		Active: true,
+	}
+	_, err = c.do(http.MethodPost, "/repos/"+repo+"/hooks", h, &h)
+	return err
+}
+
+//DeleteHook removes the webhook of the repository with given url
+func (c *GiteaClient) DeleteHook(repo, hookURL string) error {
+	hooks, err := c.hooks(repo)
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.Config["url"] == hookURL {
+			log.Notice("DeleteHook> Ask Gitea to delete Hook %d on %s", h.ID, repo)
+			_, err := c.do(http.MethodDelete, fmt.Sprintf("/repos/%s/hooks/%d", repo, h.ID), nil, nil)
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *GiteaClient) tags(fullname string) ([]Tag, error) {
+	tags := []Tag{}
+	for next := "/repos/" + fullname + "/tags?limit=50"; next != ""; {
+		page := []Tag{}
+		var err error
+		if next, err = c.do(http.MethodGet, next, nil, &page); err != nil {
+			return nil, err
+		}
+		tags = append(tags, page...)
+	}
+	return tags, nil
+}
+
+//PushEvents returns the last commit of branches updated after dateRef, and tags on commits authored after dateRef.
+//Gitea does not give the creation date of tags
+func (c *GiteaClient) PushEvents(fullname string, dateRef time.Time) ([]sdk.VCSPushEvent, time.Duration, error) {
+	repo, err := c.repoByFullname(fullname)
+	if err != nil {
+		return nil, pollingInterval, err
+	}
+
+	branches, err := c.branches(fullname)
+	if err != nil {
+		log.Warning("GiteaClient.PushEvents> Error %s", err)
+		return nil, pollingInterval, err
+	}
+
+	events := []sdk.VCSPushEvent{}
+	for _, b := range branches {
+		if !b.Commit.Timestamp.After(dateRef) {
+			continue
+		}
+		events = append(events, sdk.VCSPushEvent{
+			Branch: toVCSBranch(b, repo.DefaultBranch),
+			Commit: sdk.VCSCommit{
+				Hash:      b.Commit.ID,
+				Message:   b.Commit.Message,
+				Timestamp: b.Commit.Timestamp.Unix() * 1000,
+				URL:       b.Commit.URL,
+				Author: sdk.VCSAuthor{
+					Name:        b.Commit.Author.Username,
+					DisplayName: b.Commit.Author.Name,
+					Email:       b.Commit.Author.Email,
+				},
+			},
+		})
+	}
+
+	tags, err := c.tags(fullname)
+	if err != nil {
+		log.Warning("GiteaClient.PushEvents> Error %s", err)
+		return nil, pollingInterval, err
+	}
+	for _, t := range tags {
+		cm, err := c.commit(fullname, t.Commit.SHA)
+		if err != nil {
+			log.Warning("GiteaClient.PushEvents> Error %s", err)
+			return nil, pollingInterval, err
+		}
+		if !cm.Commit.Author.Date.After(dateRef) {
+			continue
+		}
+		events = append(events, sdk.VCSPushEvent{
+			Commit: toVCSCommit(cm),
+			Tag:    t.Name,
+		})
+	}
+	return events, pollingInterval, nil
+}
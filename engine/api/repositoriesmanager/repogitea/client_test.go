@@ -0,0 +1,281 @@
+package repogitea
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// fixtureServer replays responses recorded from Gitea API, stored in testdata
+type fixtureServer struct {
+	*httptest.Server
+	t        *testing.T
+	requests []string
+	bodies   []string
+}
+
+func newFixtureServer(t *testing.T) *fixtureServer {
+	s := &fixtureServer{t: t}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *fixtureServer) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	s.bodies = append(s.bodies, string(body))
+
+	if r.Header.Get("Authorization") == "Bearer expired-token" {
+		s.reply(w, http.StatusUnauthorized, "unauthorized.json")
+		return
+	}
+
+	switch p := r.URL.Path; {
+	case r.Method == "POST" && p == "/login/oauth/access_token":
+		s.reply(w, http.StatusOK, "token.json")
+	case p == "/api/v1/user/repos" && r.URL.Query().Get("page") == "2":
+		s.reply(w, http.StatusOK, "user_repos_2.json")
+	case p == "/api/v1/user/repos":
+		w.Header().Set("Link", `<`+s.URL+`/api/v1/user/repos?limit=50&page=2>; rel="next", <`+s.URL+`/api/v1/user/repos?limit=50&page=2>; rel="last"`)
+		s.reply(w, http.StatusOK, "user_repos_1.json")
+	case p == "/api/v1/repos/ovh/api":
+		s.reply(w, http.StatusOK, "repository.json")
+	case p == "/api/v1/repos/ovh/api/branches":
+		s.reply(w, http.StatusOK, "branches.json")
+	case p == "/api/v1/repos/ovh/api/tags":
+		s.reply(w, http.StatusOK, "tags.json")
+	case p == "/api/v1/repos/ovh/api/commits" && r.URL.Query().Get("sha") == "endless":
+		w.Header().Set("Link", `<`+s.URL+`/api/v1/repos/ovh/api/commits?sha=endless&page=`+r.URL.Query().Get("page")+`0>; rel="next"`)
+		s.reply(w, http.StatusOK, "commits.json")
+	case p == "/api/v1/repos/ovh/api/commits":
+		s.reply(w, http.StatusOK, "commits.json")
+	case strings.HasPrefix(p, "/api/v1/repos/ovh/api/git/commits/"):
+		s.reply(w, http.StatusOK, "commit.json")
+	case r.Method == "GET" && p == "/api/v1/repos/ovh/api/hooks":
+		s.reply(w, http.StatusOK, "hooks.json")
+	case r.Method == "POST" && p == "/api/v1/repos/ovh/api/hooks":
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	case r.Method == "DELETE" && strings.HasPrefix(p, "/api/v1/repos/ovh/api/hooks/"):
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *fixtureServer) reply(w http.ResponseWriter, status int, fixture string) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+func (s *fixtureServer) client(accessToken string) *GiteaClient {
+	return &GiteaClient{
+		consumer:     New(s.URL, "client-id", filepath.Join("testdata", "client_secret"), s.URL+"/callback"),
+		accessToken:  accessToken,
+		refreshToken: "refresh-token",
+	}
+}
+
+func TestRepos(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+
+	repos, err := s.client("token").Repos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("expected 2 repositories over 2 pages, got %d", len(repos))
+	}
+	r := repos[0]
+	if r.Fullname != "ovh/api" || r.HTTPCloneURL != "https://gitea.example.com/ovh/api.git" || r.SSHCloneURL != "git@gitea.example.com:ovh/api.git" {
+		t.Errorf("unexpected repository %+v", r)
+	}
+	if got := s.requests[1]; got != "GET /api/v1/user/repos?limit=50&page=2" {
+		t.Errorf("unexpected request %s", got)
+	}
+}
+
+func TestBranches(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+
+	branches, err := s.client("token").Branches("ovh/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 2 {
+		t.Fatalf("expected 2 branches, got %d", len(branches))
+	}
+	if !branches[0].Default || branches[0].LatestCommit != "9fceb02d0ae598e95dc970b74767f19372d61af8" {
+		t.Errorf("unexpected branch %+v", branches[0])
+	}
+	if branches[1].Default {
+		t.Errorf("branch %s should not be default", branches[1].DisplayID)
+	}
+}
+
+func TestCommits(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+
+	commits, err := s.client("token").Commits("ovh/api", "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c", "9fceb02d0ae598e95dc970b74767f19372d61af8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(commits))
+	}
+	if got := s.requests[len(s.requests)-1]; got != "GET /api/v1/repos/ovh/api/commits?limit=50&sha=9fceb02d0ae598e95dc970b74767f19372d61af8" {
+		t.Errorf("unexpected request %s", got)
+	}
+	if a := commits[0].Author; a.Name != "jdoe" || a.Email != "jane.doe@example.com" || a.Avatar != "https://gitea.example.com/avatars/5" {
+		t.Errorf("unexpected author %+v", a)
+	}
+	if a := commits[1].Author; a.Name != "John Smith" || a.Email != "john@example.com" {
+		t.Errorf("unexpected author %+v", a)
+	}
+
+	c, err := s.client("token").Commit("ovh/api", "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Timestamp != time.Date(2016, 11, 1, 18, 40, 22, 0, time.UTC).Unix()*1000 {
+		t.Errorf("unexpected timestamp %d", c.Timestamp)
+	}
+}
+
+func TestCommitsSinceNotFound(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+
+	if _, err := s.client("token").Commits("ovh/api", "unknown", "endless"); err == nil {
+		t.Errorf("expected an error when since is not found")
+	}
+	if len(s.requests) != maxCommitsPages {
+		t.Errorf("expected %d pages fetched, got %d", maxCommitsPages, len(s.requests))
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+	c := s.client("token")
+
+	files, err := c.ChangedFiles("ovh/api", "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c", "9fceb02d0ae598e95dc970b74767f19372d61af8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"services/api/main.go", "services/api/webhook.go"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+
+	files, err = c.ChangedFiles("ovh/api", "", "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+	c := s.client("token")
+
+	// Existing hook is not created again
+	if err := c.CreateHook("ovh/api", "https://cds.example.com/hook?uid=existing&project=ovh&name=api"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.requests) != 1 {
+		t.Errorf("unexpected requests %v", s.requests)
+	}
+
+	if err := c.CreateHook("ovh/api", "https://cds.example.com/hook?uid=new&project=ovh&name=api"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.requests[len(s.requests)-1]; got != "POST /api/v1/repos/ovh/api/hooks" {
+		t.Errorf("unexpected request %s", got)
+	}
+	if body := s.bodies[len(s.bodies)-1]; !strings.Contains(body, `"events":["push","delete"]`) || !strings.Contains(body, `uid=new`) {
+		t.Errorf("unexpected hook %s", body)
+	}
+
+	if err := c.DeleteHook("ovh/api", "https://cds.example.com/hook?uid=existing&project=ovh&name=api"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.requests[len(s.requests)-1]; got != "DELETE /api/v1/repos/ovh/api/hooks/7" {
+		t.Errorf("unexpected request %s", got)
+	}
+}
+
+func TestPushEvents(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+
+	events, _, err := s.client("token").PushEvents("ovh/api", time.Date(2016, 10, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Branch.DisplayID != "master" || events[0].Commit.Hash != "9fceb02d0ae598e95dc970b74767f19372d61af8" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if a := events[0].Commit.Author; a.Name != "jdoe" || a.DisplayName != "Jane Doe" {
+		t.Errorf("unexpected author %+v", a)
+	}
+	if e := events[1]; e.Tag != "v1.0.0" || e.Branch.ID != "" || e.Commit.Hash != "6dcb09b5b57875f334f61aebed695e2e4193db5e" {
+		t.Errorf("unexpected tag event %+v", e)
+	}
+
+	// Tagged commit is older than the date of reference
+	events, _, err = s.client("token").PushEvents("ovh/api", time.Date(2016, 11, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Tag != "" {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	s := newFixtureServer(t)
+	defer s.Close()
+	c := s.client("expired-token")
+	var saved []string
+	c.consumer.TokenRefreshed = func(old, access, refresh string) {
+		saved = []string{old, access, refresh}
+	}
+
+	if _, err := c.RepoByFullname("ovh/api"); err != nil {
+		t.Fatal(err)
+	}
+	if c.accessToken != "refreshed-access-token" || c.refreshToken != "rotated-refresh-token" {
+		t.Errorf("tokens were not refreshed")
+	}
+	if !reflect.DeepEqual(saved, []string{"refresh-token", "refreshed-access-token", "rotated-refresh-token"}) {
+		t.Errorf("rotated tokens were not saved: %v", saved)
+	}
+	if s.bodies[1] != "client_id=client-id&client_secret=secret&grant_type=refresh_token&refresh_token=refresh-token" {
+		t.Errorf("unexpected refresh request %s", s.bodies[1])
+	}
+
+	c.refreshToken = ""
+	c.accessToken = "expired-token"
+	if _, err := c.RepoByFullname("ovh/api"); err != sdk.ErrNoReposManagerClientAuth {
+		t.Errorf("expected ErrNoReposManagerClientAuth, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package repogitea
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/facebookgo/httpcontrol"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+var (
+	httpClient = &http.Client{
+		Transport: &httpcontrol.Transport{
+			RequestTimeout: time.Second * 30,
+			MaxTries:       3,
+		},
+	}
+
+	linkNext = regexp.MustCompile(`<([^>]*)>;\s*rel="next"`)
+)
+
+//accessToken is the response of the token endpoint
+type accessToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+//apiError wraps Gitea error format
+type apiError struct {
+	Message string `json:"message"`
+}
+
+func errorAPI(status int, body []byte) error {
+	e := apiError{}
+	if err := json.Unmarshal(body, &e); err == nil && e.Message != "" {
+		return fmt.Errorf("gitea error (%d) %s", status, e.Message)
+	}
+	return fmt.Errorf("gitea error (%d) %s", status, string(body))
+}
+
+func (g *GiteaConsumer) token(params url.Values) (*accessToken, error) {
+	secret, err := g.getClientSecretValue()
+	if err != nil {
+		return nil, err
+	}
+	params.Add("client_id", g.ClientID)
+	params.Add("client_secret", secret)
+
+	req, err := http.NewRequest(http.MethodPost, g.URL+"/login/oauth/access_token", strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		return nil, errorAPI(res.StatusCode, body)
+	}
+
+	t := &accessToken{}
+	if err := json.Unmarshal(body, t); err != nil {
+		return nil, fmt.Errorf("Unable to parse gitea response (%d) %s", res.StatusCode, string(body))
+	}
+	return t, nil
+}
+
+//do sends a request to Gitea API, refreshing access token once when it has expired.
+//When out is not nil, the response is decoded in it. It returns the URL of next page if any
+func (c *GiteaClient) do(method, path string, in, out interface{}) (string, error) {
+	c.mutex.Lock()
+	refreshToken := c.refreshToken
+	c.mutex.Unlock()
+
+	status, body, headers, err := c.send(method, path, in)
+	if err == nil && status == http.StatusUnauthorized && refreshToken != "" {
+		if err := c.refresh(refreshToken); err != nil {
+			log.Warning("GiteaClient> Cannot refresh access token: %s", err)
+			return "", sdk.ErrNoReposManagerClientAuth
+		}
+		status, body, headers, err = c.send(method, path, in)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "", sdk.ErrNoReposManagerClientAuth
+	case status == http.StatusNotFound:
+		return "", sdk.NewError(sdk.ErrRepoNotFound, errorAPI(status, body))
+	case status >= 400:
+		return "", sdk.NewError(sdk.ErrUnknownError, errorAPI(status, body))
+	}
+
+	if out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			log.Warning("GiteaClient> Unable to parse response of %s: %s", path, err)
+			return "", err
+		}
+	}
+
+	var next string
+	if s := linkNext.FindStringSubmatch(headers.Get("Link")); len(s) == 2 {
+		next = s[1]
+	}
+	return next, nil
+}
+
+//refresh gets a new access token unless another request already did it since refreshToken was read,
+//then stores new tokens. Gitea rotates the refresh token on each use
+func (c *GiteaClient) refresh(refreshToken string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.refreshToken != refreshToken {
+		return nil
+	}
+
+	log.Debug("Gitea API>> Refresh access token")
+	access, refresh, err := c.consumer.refresh(refreshToken)
+	if err != nil {
+		return err
+	}
+	c.accessToken = access
+	if refresh != "" && refresh != refreshToken {
+		c.refreshToken = refresh
+		instancesMutex.Lock()
+		instancesAuthorizedClient[refresh] = c
+		instancesMutex.Unlock()
+	}
+	if c.consumer.TokenRefreshed != nil {
+		c.consumer.TokenRefreshed(refreshToken, c.accessToken, c.refreshToken)
+	}
+	return nil
+}
+
+func (c *GiteaClient) send(method, path string, in interface{}) (int, []byte, http.Header, error) {
+	if !strings.HasPrefix(path, "http") {
+		path = c.consumer.URL + "/api/v1" + path
+	}
+
+	var reqBody *bytes.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		reqBody = bytes.NewReader(b)
+	} else {
+		reqBody = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, path, reqBody)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	c.mutex.Lock()
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	c.mutex.Unlock()
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	log.Debug("Gitea API>> Request %s %s", method, req.URL.String())
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	return res.StatusCode, body, res.Header, err
+}
@@ -0,0 +1,141 @@
+package repogitea
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+//GiteaConsumer embeds a Gitea oauth2 consumer
+type GiteaConsumer struct {
+	URL                      string `json:"-"`
+	ClientID                 string `json:"client-id"`
+	ClientSecret             string `json:"client-secret"`
+	AuthorizationCallbackURL string `json:"-"`
+	//TokenRefreshed is called with the new tokens of a client whose access token has been refreshed
+	TokenRefreshed func(oldRefreshToken, accessToken, refreshToken string) `json:"-"`
+}
+
+//New creates a new GiteaConsumer
+func New(URL, clientID, clientSecret, authorizationCallbackURL string) *GiteaConsumer {
+	return &GiteaConsumer{
+		URL:                      strings.TrimSuffix(URL, "/"),
+		ClientID:                 clientID,
+		ClientSecret:             clientSecret,
+		AuthorizationCallbackURL: authorizationCallbackURL,
+	}
+}
+
+func generateState() (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		log.Critical("repogitea.generateState> rand.Read failed: %s\n", err)
+		return "", err
+	}
+	return hex.EncodeToString(bs), nil
+}
+
+//getClientSecretValue reads client secret written from Vault
+func (g *GiteaConsumer) getClientSecretValue() (string, error) {
+	s, err := ioutil.ReadFile(g.ClientSecret)
+	if err != nil {
+		log.Critical("GiteaConsumer> Unable to read client secret value %s : %s", g.ClientSecret, err)
+		return "", err
+	}
+	return string(bytes.TrimSpace(s)), nil
+}
+
+//Data returns a serilized version of specific data
+func (g *GiteaConsumer) Data() string {
+	b, _ := json.Marshal(g)
+	return string(b)
+}
+
+//AuthorizeRedirect returns the state and the Authorize URL
+//doc: https://docs.gitea.io/en-us/oauth2-provider/
+func (g *GiteaConsumer) AuthorizeRedirect() (string, string, error) {
+	state, err := generateState()
+	if err != nil {
+		return "", "", err
+	}
+
+	val := url.Values{}
+	val.Add("client_id", g.ClientID)
+	val.Add("redirect_uri", g.AuthorizationCallbackURL)
+	val.Add("response_type", "code")
+	val.Add("state", state)
+
+	return state, fmt.Sprintf("%s/login/oauth/authorize?%s", g.URL, val.Encode()), nil
+}
+
+//AuthorizeToken returns the access token and the refresh token
+//from the code got on authorize url
+func (g *GiteaConsumer) AuthorizeToken(state, code string) (string, string, error) {
+	log.Debug("AuthorizeToken> Gitea send code %s for state %s", code, state)
+
+	params := url.Values{}
+	params.Add("grant_type", "authorization_code")
+	params.Add("code", code)
+	params.Add("redirect_uri", g.AuthorizationCallbackURL)
+
+	t, err := g.token(params)
+	if err != nil {
+		return "", "", err
+	}
+	return t.AccessToken, t.RefreshToken, nil
+}
+
+//refresh gets a new access token, Gitea access tokens expire after one hour
+func (g *GiteaConsumer) refresh(refreshToken string) (string, string, error) {
+	params := url.Values{}
+	params.Add("grant_type", "refresh_token")
+	params.Add("refresh_token", refreshToken)
+
+	t, err := g.token(params)
+	if err != nil {
+		return "", "", err
+	}
+	return t.AccessToken, t.RefreshToken, nil
+}
+
+//keep client in memory
+var (
+	instancesAuthorizedClient = map[string]*GiteaClient{}
+	instancesMutex            sync.Mutex
+)
+
+//GetAuthorized returns an authorized client
+func (g *GiteaConsumer) GetAuthorized(accessToken, refreshToken string) (sdk.RepositoriesManagerClient, error) {
+	instancesMutex.Lock()
+	defer instancesMutex.Unlock()
+
+	c := instancesAuthorizedClient[refreshToken]
+	if c == nil {
+		c = &GiteaClient{
+			consumer:     g,
+			accessToken:  accessToken,
+			refreshToken: refreshToken,
+		}
+		instancesAuthorizedClient[refreshToken] = c
+	}
+	return c, nil
+}
+
+//HooksSupported returns true if the driver technically support hook
+func (g *GiteaConsumer) HooksSupported() bool {
+	return true
+}
+
+//PollingSupported returns true if the driver technically support polling
+func (g *GiteaConsumer) PollingSupported() bool {
+	return true
+}
@@ -0,0 +1,87 @@
+package repogitea
+
+import "time"
+
+//User is a Gitea account
+type User struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	FullName  string `json:"full_name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+//Repository is a Gitea repository
+type Repository struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	HTMLURL       string `json:"html_url"`
+	CloneURL      string `json:"clone_url"`
+	SSHURL        string `json:"ssh_url"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+//PayloadUser is the author of a commit as given in branches and hooks payloads
+type PayloadUser struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+//PayloadCommit is a commit as given in branches and hooks payloads
+type PayloadCommit struct {
+	ID        string      `json:"id"`
+	Message   string      `json:"message"`
+	URL       string      `json:"url"`
+	Author    PayloadUser `json:"author"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+//Branch is a Gitea branch
+type Branch struct {
+	Name   string        `json:"name"`
+	Commit PayloadCommit `json:"commit"`
+}
+
+//Tag is a Gitea tag
+type Tag struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+//CommitUser is the git author of a commit
+type CommitUser struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+//Commit is a Gitea commit
+type Commit struct {
+	SHA     string `json:"sha"`
+	HTMLURL string `json:"html_url"`
+	Commit  struct {
+		Message string     `json:"message"`
+		Author  CommitUser `json:"author"`
+	} `json:"commit"`
+	Author *User        `json:"author"`
+	Files  []CommitFile `json:"files"`
+}
+
+//CommitFile is a file changed by a commit
+type CommitFile struct {
+	Filename string `json:"filename"`
+	Status   string `json:"status"`
+}
+
+//Hook is a Gitea webhook
+type Hook struct {
+	ID     int64             `json:"id,omitempty"`
+	Type   string            `json:"type"`
+	Config map[string]string `json:"config"`
+	Events []string          `json:"events"`
+	Active bool              `json:"active"`
+}
@@ -380,6 +380,45 @@ func (g *GithubClient) Commit(repo, hash string) (sdk.VCSCommit, error) {
 	return commit, nil
 }
 
+// ChangedFiles returns the paths of files changed by commits from since excluded to until.
+// When since is empty, only the files changed by until are returned
+// https://developer.github.com/v3/repos/commits/#compare-two-commits
+func (g *GithubClient) ChangedFiles(repo, since, until string) ([]string, error) {
+	url := "/repos/" + repo + "/commits/" + until
+	if since != "" {
+		url = "/repos/" + repo + "/compare/" + since + "..." + until
+	}
+	status, body, _, err := g.get(url)
+	if err != nil {
+		log.Warning("GithubClient.ChangedFiles> Error %s", err)
+		return nil, err
+	}
+	if status >= 400 {
+		return nil, sdk.NewError(sdk.ErrRepoNotFound, ErrorAPI(body))
+	}
+	d := Diff{}
+
+	//Github may return 304 status because we are using conditionnal request with ETag based headers
+	if status == http.StatusNotModified {
+		cache.Get(cache.Key("reposmanager", "github", "diff", g.OAuthToken, url), &d)
+	} else {
+		if err := json.Unmarshal(body, &d); err != nil {
+			log.Warning("GithubClient.ChangedFiles> Unable to parse github diff: %s", err)
+			return nil, err
+		}
+		cache.SetWithTTL(cache.Key("reposmanager", "github", "diff", g.OAuthToken, url), d, 61*60)
+	}
+
+	files := []string{}
+	for _, f := range d.Files {
+		files = append(files, f.Filename)
+		if f.PreviousFilename != "" {
+			files = append(files, f.PreviousFilename)
+		}
+	}
+	return files, nil
+}
+
 //CreateHook is not implemented
 func (g *GithubClient) CreateHook(repo, url string) error {
 	return fmt.Errorf("Not yet implemented on github")
@@ -414,7 +453,7 @@ func (g *GithubClient) RateLimit() error {
 	return nil
 }
 
-//PushEvents returns push events as commits
+//PushEvents returns push events as commits, tags created and releases published as tag events
 func (g *GithubClient) PushEvents(fullname string, dateRef time.Time) ([]sdk.VCSPushEvent, time.Duration, error) {
 	log.Debug("GithubClient.PushEvents> loading events for %s after %v", fullname, dateRef)
 	var events = []Event{}
@@ -446,10 +485,10 @@ func (g *GithubClient) PushEvents(fullname string, dateRef time.Time) ([]sdk.VCS
 				cache.SetWithTTL(cache.Key("reposmanager", "github", "events", g.OAuthToken, nextPage), nextEvents, 61*60)
 			}
 
-			//Check here only events after the reference date and only of type PushEvent
+			//Check here only events after the reference date and only of type PushEvent, CreateEvent or ReleaseEvent
 			nextEventsAfterDateRef := []Event{}
 			for _, e := range nextEvents {
-				if e.CreatedAt.After(dateRef) && e.Type == "PushEvent" {
+				if e.CreatedAt.After(dateRef) && (e.Type == "PushEvent" || e.Type == "CreateEvent" || e.Type == "ReleaseEvent") {
 					nextEventsAfterDateRef = append(nextEventsAfterDateRef, e)
 				}
 			}
@@ -508,5 +547,29 @@ func (g *GithubClient) PushEvents(fullname string, dateRef time.Time) ([]sdk.VCS
 		})
 	}
 
+	//A release creates its tag: both events give the same tag event
+	tags := map[string]bool{}
+	for _, e := range events {
+		var tag string
+		switch {
+		case e.Type == "CreateEvent" && e.Payload.RefType == "tag":
+			tag = e.Payload.Ref
+		case e.Type == "ReleaseEvent":
+			tag = e.Payload.Release.TagName
+		}
+		if tag == "" || tags[tag] {
+			continue
+		}
+		tags[tag] = true
+		c, err := g.Commit(fullname, tag)
+		if err != nil {
+			return nil, 0.0, fmt.Errorf("Unable to find tag %s in %s : %s", tag, fullname, err)
+		}
+		res = append(res, sdk.VCSPushEvent{
+			Commit: c,
+			Tag:    tag,
+		})
+	}
+
 	return res, interval, fmt.Errorf("Not implemented on stash")
 }
@@ -334,6 +334,7 @@ type Event struct {
 		Size         int    `json:"size"`
 		DistinctSize int    `json:"distinct_size"`
 		Ref          string `json:"ref"`
+		RefType      string `json:"ref_type"`
 		Head         string `json:"head"`
 		Before       string `json:"before"`
 		Commits      []struct {
@@ -346,6 +347,9 @@ type Event struct {
 			Distinct bool   `json:"distinct"`
 			URL      string `json:"url"`
 		} `json:"commits"`
+		Release struct {
+			TagName string `json:"tag_name"`
+		} `json:"release"`
 	} `json:"payload"`
 	Public    bool      `json:"public"`
 	CreatedAt Timestamp `json:"created_at"`
@@ -382,3 +386,12 @@ type RateLimit struct {
 func (r *RateLimit) String() string {
 	return fmt.Sprintf("Limit: %d - Remaining: %d - Reset: %d", r.Rate.Limit, r.Rate.Remaining, r.Rate.Reset)
 }
+
+//Diff is the list of files changed by a commit, or between two commits
+type Diff struct {
+	Files []struct {
+		Filename         string `json:"filename"`
+		Status           string `json:"status"`
+		PreviousFilename string `json:"previous_filename"`
+	} `json:"files"`
+}
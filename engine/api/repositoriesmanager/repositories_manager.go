@@ -11,6 +11,8 @@ import (
 	"strings"
 
 	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/repositoriesmanager/repobitbucket"
+	"github.com/ovh/cds/engine/api/repositoriesmanager/repogitea"
 	"github.com/ovh/cds/engine/api/repositoriesmanager/repogithub"
 	"github.com/ovh/cds/engine/api/repositoriesmanager/repostash"
 	"github.com/ovh/cds/engine/api/vault"
@@ -183,11 +185,64 @@ func New(t sdk.RepositoriesManagerType, id int64, name, URL string, args map[str
 			PollingSupported: *withPolling && github.PollingSupported(),
 		}
 
+		return &rm, nil
+	case sdk.BitbucketCloud:
+		clientID, clientSecret, err := oauth2Credentials(t, id, args, consumerData)
+		if err != nil {
+			return nil, err
+		}
+		bitbucket := repobitbucket.New(clientID, clientSecret)
+		bitbucket.TokenRefreshed = saveRefreshedTokens(id)
+		rm := sdk.RepositoriesManager{
+			ID:               id,
+			Consumer:         bitbucket,
+			Name:             name,
+			URL:              repobitbucket.URL,
+			Type:             sdk.BitbucketCloud,
+			HooksSupported:   bitbucket.HooksSupported(),
+			PollingSupported: bitbucket.PollingSupported(),
+		}
+		return &rm, nil
+	case sdk.Gitea:
+		clientID, clientSecret, err := oauth2Credentials(t, id, args, consumerData)
+		if err != nil {
+			return nil, err
+		}
+		gitea := repogitea.New(URL, clientID, clientSecret, apiURL+"/repositories_manager/oauth2/callback")
+		gitea.TokenRefreshed = saveRefreshedTokens(id)
+		rm := sdk.RepositoriesManager{
+			ID:               id,
+			Consumer:         gitea,
+			Name:             name,
+			URL:              URL,
+			Type:             sdk.Gitea,
+			HooksSupported:   gitea.HooksSupported(),
+			PollingSupported: gitea.PollingSupported(),
+		}
 		return &rm, nil
 	}
 	return nil, fmt.Errorf("Unknown type %s. Cannot instanciate repositories manager t=%s id=%d name=%s url=%s args=%s consumerData=%s", t, t, id, name, URL, args, consumerData)
 }
 
+//oauth2Credentials returns client id and client secret of an oauth2 repositories manager,
+//from args on creation or from consumer data stored in DB
+func oauth2Credentials(t sdk.RepositoriesManagerType, id int64, args map[string]string, consumerData string) (string, string, error) {
+	//Check if it isn't comming from the DB
+	if id == 0 || consumerData == "" {
+		if args["client-id"] == "" || args["client-secret"] == "" {
+			return "", "", fmt.Errorf("client-id args and client-secret are mandatory to connect to %s", t)
+		}
+		return args["client-id"], args["client-secret"], nil
+	}
+
+	var data map[string]string
+	if err := json.Unmarshal([]byte(consumerData), &data); err != nil {
+		log.Warning("New> Error %s", err)
+		return "", "", err
+	}
+	return data["client-id"], data["client-secret"], nil
+}
+
 //Init initializes all repositories with secrets comming from Vault
 func initRepositoriesManager(db *sql.DB, rm *sdk.RepositoriesManager, directory string, secrets map[string]string) error {
 	if rm.Type == sdk.Stash {
@@ -227,5 +282,27 @@ func initRepositoriesManager(db *sql.DB, rm *sdk.RepositoriesManager, directory
 		}
 		return nil
 	}
+	if rm.Type == sdk.BitbucketCloud || rm.Type == sdk.Gitea {
+		clientSecret := secrets["client-secret"]
+		if clientSecret == "" {
+			return fmt.Errorf("Cannot init %s. Missing client secret", rm.Name)
+		}
+		path := filepath.Join(directory, fmt.Sprintf("%s.%s", rm.Name, "clientSecret"))
+		log.Notice("RepositoriesManager> Writing %s client secret %s", rm.Type, path)
+		if err := ioutil.WriteFile(path, []byte(clientSecret), 0600); err != nil {
+			log.Warning("RepositoriesManager> Unable to write client secret %s : %s", path, err)
+			return err
+		}
+		switch c := rm.Consumer.(type) {
+		case *repobitbucket.BitbucketConsumer:
+			c.ClientSecret = path
+		case *repogitea.GiteaConsumer:
+			c.ClientSecret = path
+		}
+		if err := Update(db, rm); err != nil {
+			return err
+		}
+		return nil
+	}
 	return fmt.Errorf("Unsupported repositories manager : %s: %s", rm.Name, rm.Type)
 }
@@ -1,12 +1,14 @@
 package repostash
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/facebookgo/httpcontrol"
 
+	"github.com/go-stash/go-stash/oauth1"
 	"github.com/go-stash/go-stash/stash"
 
 	"net/http"
@@ -276,6 +278,99 @@ func (s *StashClient) Commit(repo, hash string) (sdk.VCSCommit, error) {
 	return commit, nil
 }
 
+//stashChanges is a page of the changes REST resource, not available in go-stash
+type stashChanges struct {
+	Values []struct {
+		Path struct {
+			ToString string `json:"toString"`
+		} `json:"path"`
+		SrcPath *struct {
+			ToString string `json:"toString"`
+		} `json:"srcPath"`
+	} `json:"values"`
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+//ChangedFiles returns the paths of files changed by commits from since excluded to until.
+//When since is empty, only the files changed by until are returned
+func (s *StashClient) ChangedFiles(repo, since, until string) ([]string, error) {
+	t := strings.Split(repo, "/")
+	if len(t) != 2 {
+		return nil, fmt.Errorf("fullname %s must be <project>/<slug>", repo)
+	}
+	var stashURL, _ = url.Parse(s.url)
+	var stashChangesKey = cache.Key("reposmanager", "stash", stashURL.Host, repo, "changes", "since@"+since, "until@"+until)
+
+	files := []string{}
+	cache.Get(stashChangesKey, &files)
+	if len(files) > 0 {
+		return files, nil
+	}
+
+	for start := 0; ; {
+		params := url.Values{}
+		params.Add("limit", "500")
+		params.Add("start", fmt.Sprintf("%d", start))
+		if since != "" {
+			params.Add("since", since)
+		}
+
+		var page stashChanges
+		path := fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s/commits/%s/changes?%s", t[0], t[1], until, params.Encode())
+		if err := s.get(path, &page); err != nil {
+			return nil, err
+		}
+		for _, c := range page.Values {
+			files = append(files, c.Path.ToString)
+			if c.SrcPath != nil && c.SrcPath.ToString != "" && c.SrcPath.ToString != c.Path.ToString {
+				files = append(files, c.SrcPath.ToString)
+			}
+		}
+		if page.IsLastPage {
+			break
+		}
+		start = page.NextPageStart
+	}
+
+	cache.Set(stashChangesKey, files)
+	return files, nil
+}
+
+//get sends a request signed with the oauth token of the client
+func (s *StashClient) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, s.url+path, nil)
+	if err != nil {
+		return err
+	}
+
+	consumer := oauth1.Consumer{
+		ConsumerKey:           s.client.ConsumerKey,
+		ConsumerSecret:        s.client.ConsumerSecret,
+		ConsumerPrivateKeyPem: s.client.ConsumerPrivateKeyPem,
+	}
+	if err := consumer.Sign(req, oauth1.NewAccessToken(s.client.AccessToken, s.client.TokenSecret, nil)); err != nil {
+		return err
+	}
+
+	res, err := stash.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusUnauthorized:
+		return sdk.ErrNoReposManagerClientAuth
+	case http.StatusNotFound:
+		return sdk.ErrRepoNotFound
+	}
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("stash error (%d) on %s", res.StatusCode, path)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
 //CreateHook enables the defaut HTTP POST Hook in Stash
 func (s *StashClient) CreateHook(repo, url string) error {
 	var branchFilter, tagFilter, userFilter string
@@ -129,11 +129,15 @@ func checkNoWorkerModelMatchRequirement(db database.Querier, proj string, pip st
 	var warns []sdk.Warning
 	areqs := a.Requirements
 
-	// Check all binary requirement are present in at least one model
+	// Check all binary requirement are present in at least one model big enough for the action
+	memory, cpu := sdk.ResourceRequirements(areqs)
 	validModel := false
 	for _, wm := range wms {
-		ok := true
+		ok := wm.Fits(memory, cpu)
 		for _, ar := range areqs {
+			if !ok {
+				break
+			}
 			if ar.Type != sdk.BinaryRequirement {
 				continue
 			}
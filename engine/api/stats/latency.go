@@ -0,0 +1,69 @@
+package stats
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// SpawnLatencyEvent adds to today's stats of given worker model the delay between
+// the spawn of a worker booked for an action build and the start of this build
+func SpawnLatencyEvent(db *sql.DB, modelID int64, latency time.Duration) {
+	ms := int64(latency / time.Millisecond)
+	query := `UPDATE spawn_latency SET started = started + 1, total_latency = total_latency + $2, max_latency = GREATEST(max_latency, $2)
+	WHERE day = current_date AND worker_model_id = $1`
+
+	// Retry once if another API instance created today's row in the meantime
+	for i := 0; i < 2; i++ {
+		res, err := db.Exec(query, modelID, ms)
+		if err != nil {
+			log.Warning("SpawnLatencyEvent> Cannot update spawn latency of model %d: %s\n", modelID, err)
+			return
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			log.Warning("SpawnLatencyEvent> Cannot update spawn latency of model %d: %s\n", modelID, err)
+			return
+		}
+		if n > 0 {
+			return
+		}
+
+		_, err = db.Exec(`INSERT INTO spawn_latency (day, worker_model_id, started, total_latency, max_latency) VALUES (current_date, $1, 1, $2, $2)`, modelID, ms)
+		if err == nil {
+			return
+		}
+		log.Info("SpawnLatencyEvent> Cannot insert spawn latency of model %d: %s\n", modelID, err)
+	}
+}
+
+// LoadSpawnLatency returns daily spawn latency of all worker models over the last given days
+func LoadSpawnLatency(db *sql.DB, days int) ([]sdk.ModelSpawnLatency, error) {
+	query := `SELECT spawn_latency.day, spawn_latency.worker_model_id, worker_model.name, spawn_latency.started, spawn_latency.total_latency, spawn_latency.max_latency
+	FROM spawn_latency
+	JOIN worker_model ON worker_model.id = spawn_latency.worker_model_id
+	WHERE spawn_latency.day > current_date - $1::integer
+	ORDER BY spawn_latency.day DESC, worker_model.name`
+
+	rows, err := db.Query(query, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []sdk.ModelSpawnLatency
+	for rows.Next() {
+		var l sdk.ModelSpawnLatency
+		var total int64
+		if err := rows.Scan(&l.Day, &l.ModelID, &l.ModelName, &l.Started, &total, &l.Max); err != nil {
+			return nil, err
+		}
+		if l.Started > 0 {
+			l.Average = total / l.Started
+		}
+		res = append(res, l)
+	}
+	return res, nil
+}
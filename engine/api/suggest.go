@@ -117,6 +117,7 @@ func getVariablesHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *
 	gitVar := []string{
 		"{{.git.hash}}",
 		"{{.git.branch}}",
+		"{{.git.tag}}",
 		"{{.git.author}}",
 		"{{.git.project}}",
 		"{{.git.repository}}",
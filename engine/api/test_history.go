@@ -0,0 +1,124 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/build"
+	"github.com/ovh/cds/engine/api/context"
+	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/permission"
+	"github.com/ovh/cds/engine/api/pipeline"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+func getTestHistoryHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	suite := r.FormValue("suite")
+	name := r.FormValue("name")
+	if suite == "" || name == "" {
+		log.Warning("getTestHistoryHandler> suite and name are mandatory\n")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	limit := 50
+	if l := r.FormValue("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			log.Warning("getTestHistoryHandler> Invalid limit %s\n", l)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	a, p, env, err := loadTestedPipeline(db, r, c)
+	if err != nil {
+		log.Warning("getTestHistoryHandler> %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	h, err := build.LoadTestCaseHistory(db, a.ID, p.ID, env.ID, suite, name, limit)
+	if err != nil {
+		log.Warning("getTestHistoryHandler> Cannot load history of %s/%s: %s\n", suite, name, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, h, http.StatusOK)
+}
+
+func getFlakyTestsHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	days := 30
+	if d := r.FormValue("days"); d != "" {
+		var err error
+		days, err = strconv.Atoi(d)
+		if err != nil || days <= 0 {
+			log.Warning("getFlakyTestsHandler> Invalid number of days %s\n", d)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	a, p, env, err := loadTestedPipeline(db, r, c)
+	if err != nil {
+		log.Warning("getFlakyTestsHandler> %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	flaky, err := build.LoadFlakyTests(db, a.ID, p.ID, env.ID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Warning("getFlakyTestsHandler> Cannot load flaky tests of %s: %s\n", p.Name, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, flaky, http.StatusOK)
+}
+
+// loadTestedPipeline loads application, pipeline and environment of url, checking user can read tests run on this environment
+func loadTestedPipeline(db *sql.DB, r *http.Request, c *context.Context) (*sdk.Application, *sdk.Pipeline, *sdk.Environment, error) {
+	vars := mux.Vars(r)
+	projectKey := vars["key"]
+	pipelineName := vars["permPipelineKey"]
+	appName := vars["permApplicationName"]
+
+	var err error
+	var env *sdk.Environment
+	envName := r.FormValue("envName")
+	if envName == "" || envName == sdk.DefaultEnv.Name {
+		env = &sdk.DefaultEnv
+	} else {
+		env, err = environment.LoadEnvironmentByName(db, projectKey, envName)
+		if err != nil {
+			log.Warning("loadTestedPipeline> Cannot load environment %s: %s\n", envName, err)
+			return nil, nil, nil, sdk.ErrUnknownEnv
+		}
+	}
+
+	if env.ID != sdk.DefaultEnv.ID && !permission.AccessToEnvironment(env.ID, c.User, permission.PermissionRead) {
+		return nil, nil, nil, sdk.ErrForbidden
+	}
+
+	p, err := pipeline.LoadPipeline(db, projectKey, pipelineName, false)
+	if err != nil {
+		log.Warning("loadTestedPipeline> Cannot load pipeline %s: %s\n", pipelineName, err)
+		return nil, nil, nil, sdk.ErrPipelineNotFound
+	}
+
+	a, err := application.LoadApplicationByName(db, projectKey, appName)
+	if err != nil {
+		log.Warning("loadTestedPipeline> Cannot load application %s: %s\n", appName, err)
+		return nil, nil, nil, sdk.ErrApplicationNotFound
+	}
+
+	return a, p, env, nil
+}
@@ -0,0 +1,33 @@
+package trigger
+
+import (
+	"testing"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestCheckPrerequisitesOnTag(t *testing.T) {
+	trig := sdk.PipelineTrigger{
+		Prerequisites: []sdk.Prerequisite{{Parameter: "git.tag", ExpectedValue: "v.*"}},
+	}
+
+	tests := []struct {
+		name     string
+		params   []sdk.Parameter
+		expected bool
+	}{
+		{"tag build", []sdk.Parameter{{Name: "git.branch", Value: ""}, {Name: "git.tag", Value: "v1.0.0"}}, true},
+		{"other tag", []sdk.Parameter{{Name: "git.branch", Value: ""}, {Name: "git.tag", Value: "release-1"}}, false},
+		{"branch named like a tag", []sdk.Parameter{{Name: "git.branch", Value: "v2"}, {Name: "git.tag", Value: ""}}, false},
+	}
+
+	for _, tt := range tests {
+		ok, err := CheckPrerequisites(trig, sdk.PipelineBuild{Parameters: tt.params})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.expected {
+			t.Errorf("%s: CheckPrerequisites = %t, expected %t", tt.name, ok, tt.expected)
+		}
+	}
+}
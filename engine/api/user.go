@@ -13,6 +13,7 @@ import (
 	"github.com/ovh/cds/engine/api/auth"
 	"github.com/ovh/cds/engine/api/context"
 	"github.com/ovh/cds/engine/api/mail"
+	"github.com/ovh/cds/engine/api/permission"
 	"github.com/ovh/cds/engine/api/sessionstore"
 	"github.com/ovh/cds/engine/api/user"
 	"github.com/ovh/cds/engine/log"
@@ -104,6 +105,9 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *co
 		return
 	}
 	userBody.ID = userDB.ID
+	// Notification preferences are managed by the user, through /user/{name}/notification
+	userBody.Subscriptions = userDB.Subscriptions
+	userBody.Digest = userDB.Digest
 
 	if !user.IsValidEmail(userBody.Email) {
 		log.Warning("updateUserHandler: Email address %s is not valid", userBody.Email)
@@ -121,6 +125,100 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *co
 
 }
 
+func getUserNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	username := mux.Vars(r)["name"]
+	if username != c.User.Username && !c.User.Admin {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	u, err := user.LoadUserWithoutAuth(db, username)
+	if err != nil {
+		log.Warning("getUserNotificationPreferencesHandler> Cannot load user %s: %s\n", username, err)
+		WriteError(w, r, sdk.ErrUserNotFound)
+		return
+	}
+
+	p := sdk.UserNotificationPreferences{Subscriptions: u.Subscriptions, Digest: u.Digest}
+	if p.Subscriptions == nil {
+		p.Subscriptions = []sdk.NotificationSubscription{}
+	}
+	WriteJSON(w, r, p, http.StatusOK)
+}
+
+func updateUserNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	username := mux.Vars(r)["name"]
+	if username != c.User.Username && !c.User.Admin {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var p sdk.UserNotificationPreferences
+	if err := json.Unmarshal(data, &p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch p.Digest {
+	case sdk.DigestNever, sdk.DigestDaily, sdk.DigestWeekly:
+	default:
+		log.Warning("updateUserNotificationPreferencesHandler> Unknown digest frequency %s\n", p.Digest)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	u, err := user.LoadUserWithoutAuth(db, username)
+	if err != nil {
+		log.Warning("updateUserNotificationPreferencesHandler> Cannot load user %s: %s\n", username, err)
+		WriteError(w, r, sdk.ErrUserNotFound)
+		return
+	}
+
+	for _, s := range p.Subscriptions {
+		if s.ProjectKey == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// Users are notified only of what they can read
+		if username == c.User.Username && permission.ProjectPermission(s.ProjectKey, c.User) < permission.PermissionRead {
+			log.Warning("updateUserNotificationPreferencesHandler> %s cannot read project %s\n", username, s.ProjectKey)
+			WriteError(w, r, sdk.ErrForbidden)
+			return
+		}
+	}
+
+	u.Subscriptions = p.Subscriptions
+	u.Digest = p.Digest
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Warning("updateUserNotificationPreferencesHandler> Cannot start transaction: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := user.UpdateUser(tx, *u); err != nil {
+		log.Warning("updateUserNotificationPreferencesHandler> Cannot update user %s: %s\n", username, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Warning("updateUserNotificationPreferencesHandler> Cannot commit transaction: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, p, http.StatusOK)
+}
+
 // GetUsers fetches all users from databases
 func GetUsers(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
 	users, err := user.LoadUsers(db)
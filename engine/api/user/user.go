@@ -196,11 +196,32 @@ func CountUser(db *sql.DB) (int64, error) {
 }
 
 // UpdateUser update given user
-func UpdateUser(db *sql.DB, u sdk.User) error {
+func UpdateUser(db database.Executer, u sdk.User) error {
 	query := `UPDATE "user" SET username=$1, admin=$2, data=$3 WHERE id=$4`
 	u.Groups = nil
-	_, err := db.Exec(query, u.Username, u.Admin, u.JSON(), u.ID)
-	return err
+	if _, err := db.Exec(query, u.Username, u.Admin, u.JSON(), u.ID); err != nil {
+		return err
+	}
+	return updateUserSubscriptions(db, &u)
+}
+
+// updateUserSubscriptions indexes projects the user subscribed to
+func updateUserSubscriptions(db database.Executer, u *sdk.User) error {
+	if err := deleteUserSubscriptions(db, u); err != nil {
+		return err
+	}
+	found := map[string]bool{}
+	for _, s := range u.Subscriptions {
+		if found[s.ProjectKey] {
+			continue
+		}
+		found[s.ProjectKey] = true
+		query := `INSERT INTO "user_subscription" (user_id, project_key) VALUES ($1, $2)`
+		if _, err := db.Exec(query, u.ID, s.ProjectKey); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // UpdateUserAndAuth update given user
@@ -226,6 +247,18 @@ func DeleteUserWithDependencies(db database.Executer, u *sdk.User) error {
 		return err
 	}
 
+	err = deleteUserDigest(db, u)
+	if err != nil {
+		log.Warning("DeleteUserWithDependencies>Cannot remove user digest: %s", err)
+		return err
+	}
+
+	err = deleteUserSubscriptions(db, u)
+	if err != nil {
+		log.Warning("DeleteUserWithDependencies>Cannot remove user subscriptions: %s", err)
+		return err
+	}
+
 	err = deleteUser(db, u)
 	if err != nil {
 		log.Warning("DeleteUserWithDependencies> User cannot be removed from user table: %s", err)
@@ -240,6 +273,18 @@ func deleteUserKey(db database.Executer, u *sdk.User) error {
 	return err
 }
 
+func deleteUserDigest(db database.Executer, u *sdk.User) error {
+	query := `DELETE FROM "user_digest" WHERE user_id=$1`
+	_, err := db.Exec(query, u.ID)
+	return err
+}
+
+func deleteUserSubscriptions(db database.Executer, u *sdk.User) error {
+	query := `DELETE FROM "user_subscription" WHERE user_id=$1`
+	_, err := db.Exec(query, u.ID)
+	return err
+}
+
 func deleteUserFromUserGroup(db database.Executer, u *sdk.User) error {
 	query := `DELETE FROM "group_user" WHERE user_id=$1`
 	_, err := db.Exec(query, u.ID)
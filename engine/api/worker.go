@@ -1,19 +1,29 @@
 package main
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
 
 	"github.com/ovh/cds/engine/api/cache"
 	"github.com/ovh/cds/engine/api/context"
 	"github.com/ovh/cds/engine/api/hatchery"
+	"github.com/ovh/cds/engine/api/internal"
 	"github.com/ovh/cds/engine/api/sanity"
+	"github.com/ovh/cds/engine/api/stats"
 	"github.com/ovh/cds/engine/api/worker"
 	"github.com/ovh/cds/engine/log"
 	"github.com/ovh/cds/sdk"
@@ -47,7 +57,7 @@ func registerWorkerHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c
 	}
 
 	// Try to register worker
-	worker, err := worker.RegisterWorker(db, params.Name, params.UserKey, params.Model, params.Hatchery, params.BinaryCapabilities)
+	worker, err := worker.RegisterWorker(db, params.Name, params.UserKey, params.Model, params.Hatchery, params.ActionBuild, params.BinaryCapabilities)
 	if err != nil {
 		log.Warning("registerWorkerHandler: [%s] Registering failed: %s\n", params.Name, err)
 		w.WriteHeader(http.StatusUnauthorized)
@@ -151,13 +161,139 @@ func disableWorkerHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c
 }
 
 func refreshWorkerHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	// Old workers do not send heartbeat body
+	var hb worker.HeartbeatForm
+	if data, err := ioutil.ReadAll(r.Body); err == nil && len(data) > 0 {
+		if err := json.Unmarshal(data, &hb); err != nil {
+			log.Warning("refreshWorkerHandler> cannot unmarshal body: %s\n", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	wor, err := worker.LoadWorker(db, c.WorkerID)
+	if err != nil {
+		log.Warning("refreshWorkerHandler> cannot load worker %s: %s\n", c.WorkerID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if wor.Version != "" && hb.Version != wor.Version {
+		log.Notice("refreshWorkerHandler> worker %s upgraded from %s to %s\n", wor.Name, wor.Version, hb.Version)
+	}
+	wor.Version = hb.Version
 
-	err := worker.RefreshWorker(db, c.WorkerID)
+	err = worker.RefreshWorker(db, c.WorkerID, hb.Version)
 	if err != nil && (err != sql.ErrNoRows || err != worker.ErrNoWorker) {
 		log.Warning("refreshWorkerHandler> cannot refresh last beat of %s: %s\n", c.WorkerID, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+
+	// Let worker know whether it is draining, and which version it should run
+	WriteJSON(w, r, worker.HeartbeatResponse{Worker: *wor, APIVersion: internal.VERSION}, http.StatusOK)
+}
+
+func getWorkerVersionsHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	if !c.User.Admin {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	stats, err := worker.LoadWorkerVersions(db)
+	if err != nil {
+		log.Warning("getWorkerVersionsHandler> cannot load worker versions: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, stats, http.StatusOK)
+}
+
+func downloadWorkerChecksumHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	binaries := map[string]string{
+		"x86_64":         "worker",
+		"windows_x86_64": "worker.exe",
+	}
+	name, ok := binaries[vars["arch"]]
+	if !ok {
+		WriteError(w, r, sdk.ErrNotFound)
+		return
+	}
+
+	f, err := os.Open(path.Join(viper.GetString("download_directory"), name))
+	if err != nil {
+		log.Warning("downloadWorkerChecksumHandler> cannot open %s: %s\n", name, err)
+		WriteError(w, r, sdk.ErrNotFound)
+		return
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		log.Warning("downloadWorkerChecksumHandler> cannot read %s: %s\n", name, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, sdk.WorkerBinary{Version: internal.VERSION, Checksum: hex.EncodeToString(h.Sum(nil))}, http.StatusOK)
+}
+
+// ownedWorker loads worker with given id, which must be registered by the authenticated user unless user is admin
+func ownedWorker(db *sql.DB, c *context.Context, id string) (*sdk.Worker, error) {
+	wor, err := worker.LoadWorker(db, id)
+	if err == sql.ErrNoRows {
+		return nil, sdk.ErrNoWorker
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !c.User.Admin && wor.OwnerID != c.User.ID {
+		return nil, sdk.ErrForbidden
+	}
+	return wor, nil
+}
+
+func drainWorkerHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if _, err := ownedWorker(db, c, id); err != nil {
+		log.Warning("drainWorkerHandler> cannot drain worker %s: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := worker.DrainWorker(db, id); err != nil {
+		if err == worker.ErrNoWorker {
+			WriteError(w, r, sdk.ErrNoWorker)
+			return
+		}
+		log.Warning("drainWorkerHandler> cannot drain worker %s: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+}
+
+func undrainWorkerHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if _, err := ownedWorker(db, c, id); err != nil {
+		log.Warning("undrainWorkerHandler> cannot undrain worker %s: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := worker.UndrainWorker(db, id); err != nil {
+		if err == worker.ErrNoWorker {
+			WriteError(w, r, sdk.ErrNoWorker)
+			return
+		}
+		log.Warning("undrainWorkerHandler> cannot undrain worker %s: %s\n", id, err)
+		WriteError(w, r, err)
+		return
+	}
 }
 
 func generateUserKeyHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
@@ -228,6 +364,25 @@ func addWorkerModel(w http.ResponseWriter, r *http.Request, db *sql.DB, c *conte
 		return
 	}
 
+	if model.Memory < 0 || model.CPU < 0 || model.Disk < 0 {
+		log.Warning("addWorkerModel> invalid resources for model %s\n", model.Name)
+		WriteError(w, r, sdk.ErrInvalidResource)
+		return
+	}
+
+	// Warm pools are managed by administrators only, even if model creation is opened to users
+	if model.Pool != nil && !c.User.Admin {
+		log.Warning("addWorkerModel> %s cannot set pool of model %s\n", c.User.Username, model.Name)
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	if err := worker.ValidatePool(model.Pool); err != nil {
+		log.Warning("addWorkerModel> invalid pool for model %s\n", model.Name)
+		WriteError(w, r, err)
+		return
+	}
+
 	// Insert model in db
 	model.OwnerID = c.User.ID
 	err = worker.InsertWorkerModel(db, &model)
@@ -273,6 +428,12 @@ func updateWorkerModel(w http.ResponseWriter, r *http.Request, db *sql.DB, c *co
 		return
 	}
 
+	if model.Memory < 0 || model.CPU < 0 || model.Disk < 0 {
+		log.Warning("updateWorkerModel> invalid resources for model %s\n", model.Name)
+		WriteError(w, r, sdk.ErrInvalidResource)
+		return
+	}
+
 	modelID, err := strconv.ParseInt(idString, 10, 64)
 	if err != nil {
 		log.Warning("updateWorkerModel> modelID must be an integer : %s\n", err)
@@ -410,6 +571,11 @@ func addWorkerModelCapa(w http.ResponseWriter, r *http.Request, db *sql.DB, c *c
 		return
 	}
 
+	// Capabilities changed, model has to be validated again
+	if err := worker.RequestModelValidation(db, workerModelID); err != nil {
+		log.Warning("addWorkerModelCapa> cannot request validation of model %d: %s\n", workerModelID, err)
+	}
+
 	// Recompute warnings
 	go func() {
 		warnings, err := sanity.LoadAllWarnings(db, "")
@@ -478,6 +644,11 @@ func updateWorkerModelCapa(w http.ResponseWriter, r *http.Request, db *sql.DB, c
 		return
 	}
 
+	// Capabilities changed, model has to be validated again
+	if err := worker.RequestModelValidation(db, workerModelID); err != nil {
+		log.Warning("updateWorkerModelCapa> cannot request validation of model %d: %s\n", workerModelID, err)
+	}
+
 	// Recompute warnings
 	go func() {
 		warnings, err := sanity.LoadAllWarnings(db, "")
@@ -515,6 +686,11 @@ func deleteWorkerModelCapa(w http.ResponseWriter, r *http.Request, db *sql.DB, c
 		return
 	}
 
+	// Capabilities changed, model has to be validated again
+	if err := worker.RequestModelValidation(db, workerModelID); err != nil {
+		log.Warning("deleteWorkerModelCapa> cannot request validation of model %d: %s\n", workerModelID, err)
+	}
+
 	// Recompute warnings
 	go func() {
 		warnings, err := sanity.LoadAllWarnings(db, "")
@@ -549,6 +725,27 @@ func unregisterWorkerHandler(w http.ResponseWriter, r *http.Request, db *sql.DB,
 	}
 }
 
+func getWorkerModelsSpawnLatencyHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	days := 7
+	if d := r.FormValue("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n <= 0 || n > 90 {
+			WriteError(w, r, sdk.ErrWrongRequest)
+			return
+		}
+		days = n
+	}
+
+	res, err := stats.LoadSpawnLatency(db, days)
+	if err != nil {
+		log.Warning("getWorkerModelsSpawnLatencyHandler> cannot load spawn latency: %s\n", err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, res, http.StatusOK)
+}
+
 func getWorkerModelsStatsHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
 	res := []struct {
 		Model string
@@ -620,3 +817,235 @@ func getWorkerModelsStatsHandler(w http.ResponseWriter, r *http.Request, db *sql
 
 	WriteJSON(w, r, res, http.StatusAccepted)
 }
+
+func reportSpawnErrorHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	modelID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Warning("reportSpawnErrorHandler> cannot read body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var e sdk.SpawnError
+	if err := json.Unmarshal(data, &e); err != nil {
+		log.Warning("reportSpawnErrorHandler> cannot unmarshal body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	e.ModelID = modelID
+
+	// Only the user running an hatchery can report errors on its behalf, and its name is not taken from the report
+	h, err := callerHatchery(db, c, e.HatcheryID)
+	if err != nil {
+		log.Warning("reportSpawnErrorHandler> hatchery %d of %s: %s\n", e.HatcheryID, c.User.Username, err)
+		WriteError(w, r, err)
+		return
+	}
+	e.HatcheryID = h.ID
+	e.HatcheryName = h.Name
+
+	if err := worker.InsertSpawnError(db, &e); err != nil {
+		log.Warning("reportSpawnErrorHandler> cannot insert spawn error of model %d: %s\n", modelID, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, e, http.StatusOK)
+}
+
+func getSpawnErrorsHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	modelID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	stats, err := worker.LoadSpawnErrorStats(db, modelID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		log.Warning("getSpawnErrorsHandler> cannot load spawn errors of model %d: %s\n", modelID, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, stats, http.StatusOK)
+}
+
+func requestWorkerModelValidationHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	modelID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	if err := worker.RequestModelValidation(db, modelID); err != nil {
+		log.Warning("requestWorkerModelValidationHandler> cannot request validation of model %d: %s\n", modelID, err)
+		WriteError(w, r, err)
+		return
+	}
+}
+
+func getWorkerModelValidationHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	modelID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	var discover []string
+	for _, b := range strings.Split(viper.GetString("worker_model_discover"), ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			discover = append(discover, b)
+		}
+	}
+
+	v, err := worker.LoadModelValidation(db, modelID, discover)
+	if err != nil {
+		log.Warning("getWorkerModelValidationHandler> cannot load validation of model %d: %s\n", modelID, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, v, http.StatusOK)
+}
+
+func addWorkerModelValidationReportHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	modelID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	// Only a worker spawned from this model can validate it
+	if c.WorkerID == "" {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+	caller, err := worker.LoadWorker(db, c.WorkerID)
+	if err != nil {
+		log.Warning("addWorkerModelValidationReportHandler> cannot load calling worker: %s\n", err)
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+	if caller.Model != modelID {
+		log.Warning("addWorkerModelValidationReportHandler> worker %s (model %d) cannot validate model %d\n", caller.ID, caller.Model, modelID)
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Warning("addWorkerModelValidationReportHandler> cannot read body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var report sdk.ModelValidationReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		log.Warning("addWorkerModelValidationReportHandler> cannot unmarshal body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := worker.SaveModelValidationReport(db, modelID, &report); err != nil {
+		log.Warning("addWorkerModelValidationReportHandler> cannot save validation of model %d: %s\n", modelID, err)
+		WriteError(w, r, err)
+		return
+	}
+	log.Notice("addWorkerModelValidationReportHandler> model %d validated: %t (missing %d, undeclared %d)\n", modelID, report.Validated, len(report.Missing), len(report.Undeclared))
+
+	WriteJSON(w, r, report, http.StatusOK)
+}
+
+func updateWorkerModelPoolHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	if !c.User.Admin {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	modelID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Warning("updateWorkerModelPoolHandler> cannot read body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// A null body removes the pool
+	var pool *sdk.ModelPool
+	if err := json.Unmarshal(data, &pool); err != nil {
+		log.Warning("updateWorkerModelPoolHandler> cannot unmarshal body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := worker.ValidatePool(pool); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := worker.UpdateModelPool(db, modelID, pool); err != nil {
+		log.Warning("updateWorkerModelPoolHandler> cannot update pool of model %d: %s\n", modelID, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, pool, http.StatusOK)
+}
+
+func reserveWorkersHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	modelID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, sdk.ErrInvalidID)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Warning("reserveWorkersHandler> cannot read body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var res sdk.SpawnReservation
+	if err := json.Unmarshal(data, &res); err != nil {
+		log.Warning("reserveWorkersHandler> cannot unmarshal body: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res.ModelID = modelID
+
+	// Only the user running an hatchery can reserve workers on its behalf
+	h, err := callerHatchery(db, c, res.HatcheryID)
+	if err != nil {
+		log.Warning("reserveWorkersHandler> hatchery %d of %s: %s\n", res.HatcheryID, c.User.Username, err)
+		WriteError(w, r, err)
+		return
+	}
+	res.HatcheryID = h.ID
+
+	if err := worker.ReserveWorkers(db, &res); err != nil {
+		log.Warning("reserveWorkersHandler> cannot reserve workers of model %d: %s\n", modelID, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, res, http.StatusOK)
+}
@@ -78,6 +78,56 @@ Parse given file to extract Unit Test results.`
 		return err
 	}
 
+	// ----------------------------------- GitClone ---------------------------
+	gitclone := sdk.NewAction(sdk.GitCloneAction)
+	gitclone.Type = sdk.BuiltinAction
+	gitclone.Description = `CDS Builtin Action.
+Clone the repository attached to the application and checkout the commit being built.
+git.hash, git.branch, git.url, git.author and git.message are exported as build variables,
+they are available in next steps as {{.cds.build.git.hash}}...`
+	gitclone.Parameter(sdk.Parameter{
+		Name: "url",
+		Description: `Git URL to clone (optional).
+Default to the repository attached to the application in its repositories manager`,
+		Type: sdk.StringParameter})
+	gitclone.Parameter(sdk.Parameter{
+		Name:        "branch",
+		Description: "Branch to clone",
+		Value:       "{{.git.branch}}",
+		Type:        sdk.StringParameter})
+	gitclone.Parameter(sdk.Parameter{
+		Name:        "commit",
+		Description: "Commit to checkout, default to the head of branch",
+		Value:       "{{.git.hash}}",
+		Type:        sdk.StringParameter})
+	gitclone.Parameter(sdk.Parameter{
+		Name:        "directory",
+		Description: "Directory to clone into, default to the repository name",
+		Type:        sdk.StringParameter})
+	gitclone.Parameter(sdk.Parameter{
+		Name:        "depth",
+		Description: "Create a shallow clone with given number of commits, 0 for a full clone",
+		Value:       "50",
+		Type:        sdk.NumberParameter})
+	gitclone.Parameter(sdk.Parameter{
+		Name:        "submodules",
+		Description: "Initialize and update submodules recursively",
+		Value:       "false",
+		Type:        sdk.BooleanParameter})
+	gitclone.Parameter(sdk.Parameter{
+		Name:        "user",
+		Description: "User for HTTPS authentication (optional)",
+		Type:        sdk.StringParameter})
+	gitclone.Parameter(sdk.Parameter{
+		Name: "password",
+		Description: `Password or token for HTTPS authentication (optional).
+Token is used as user if user is empty. Use a password variable, example: {{.cds.app.gitToken}}`,
+		Type: sdk.StringParameter})
+	gitclone.Requirement("git", sdk.BinaryRequirement, "git")
+	if err := checkBuiltinAction(db, gitclone); err != nil {
+		return err
+	}
+
 	return nil
 }
 
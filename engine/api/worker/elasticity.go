@@ -3,6 +3,8 @@ package worker
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,13 +27,15 @@ func loadWorkerModelStatus(db *sql.DB, userID int64) ([]sdk.ModelStatus, error)
 	defer logTime("loadWorkerModelStatus", time.Now())
 
 	query := `
-	SELECT worker_model.id, worker_model.name, COALESCE(waiting.count, 0) as waiting, COALESCE(building.count,0) as building FROM worker_model
-	LEFT JOIN LATERAL (SELECT model, COUNT(id) as count FROM worker WHERE worker.status = 'Waiting' AND worker.model = worker_model.id AND worker.owner_id = $1 GROUP BY model) AS waiting ON waiting.model = worker_model.id
+	SELECT worker_model.id, worker_model.name, worker_model.memory, worker_model.cpu, worker_model.unhealthy_until, COALESCE(worker_model.need_validation, false), worker_model.pool, COALESCE(waiting.count, 0) as waiting, COALESCE(building.count,0) as building, COALESCE(reserved.count, 0) as reserved FROM worker_model
+	LEFT JOIN LATERAL (SELECT model, COUNT(id) as count FROM worker WHERE worker.status = 'Waiting' AND worker.draining IS NOT TRUE AND worker.model = worker_model.id AND worker.owner_id = $1 GROUP BY model) AS waiting ON waiting.model = worker_model.id
 	LEFT JOIN LATERAL (SELECT model, COUNT(id) as count FROM worker WHERE worker.status = 'Building' AND worker.model = worker_model.id AND worker.owner_id = $1 GROUP BY model) AS building ON building.model = worker_model.id
+	LEFT JOIN LATERAL (SELECT worker_model_id, COUNT(id) as count FROM worker_model_reservation WHERE worker_model_reservation.worker_model_id = worker_model.id AND action_build_id = 0 AND expire > $2 GROUP BY worker_model_id) AS reserved ON reserved.worker_model_id = worker_model.id
 	ORDER BY worker_model.name ASC;
 	`
 
-	rows, err := db.Query(query, userID)
+	now := time.Now()
+	rows, err := db.Query(query, userID, now)
 	if err != nil {
 		return nil, err
 	}
@@ -40,25 +44,40 @@ func loadWorkerModelStatus(db *sql.DB, userID int64) ([]sdk.ModelStatus, error)
 	var status []sdk.ModelStatus
 	for rows.Next() {
 		var ms sdk.ModelStatus
-		err := rows.Scan(&ms.ModelID, &ms.ModelName, &ms.CurrentCount, &ms.BuildingCount)
+		var pool sql.NullString
+		err := rows.Scan(&ms.ModelID, &ms.ModelName, &ms.Memory, &ms.CPU, &ms.UnhealthyUntil, &ms.NeedValidation, &pool, &ms.CurrentCount, &ms.BuildingCount, &ms.ReservedCount)
 		if err != nil {
 			return nil, err
 		}
+		p, err := unmarshalPool(pool)
+		if err != nil {
+			log.Warning("loadWorkerModelStatus> invalid pool of model %s: %s\n", ms.ModelName, err)
+		}
+		if p != nil {
+			l := poolLimits(p, now)
+			ms.Pool = &l
+		}
 		status = append(status, ms)
 	}
 
 	return status, nil
 }
 
-func modelCanRun(name string, req []sdk.Requirement, capa []sdk.Requirement) bool {
+func modelCanRun(m sdk.Model, req []sdk.Requirement, capa []sdk.Requirement) bool {
 	defer logTime("compareRequirements", time.Now())
 	log.Info("Comparing %d requirements to %d capa\n", len(req), len(capa))
+
+	// Workers of the model have to be big enough
+	if !m.Fits(sdk.ResourceRequirements(req)) {
+		return false
+	}
+
 	for _, r := range req {
 		found := false
 
 		// If requirement is a Model requirement, it's easy. It's either can or can't run
 		if r.Type == sdk.ModelRequirement {
-			return r.Value == name
+			return r.Value == m.Name
 		}
 
 		// If requirement is an hostname requirement, it's for a specific worker
@@ -76,6 +95,11 @@ func modelCanRun(name string, req []sdk.Requirement, capa []sdk.Requirement) boo
 			continue
 		}
 
+		// Services are started by hatcheries, resources have been checked above
+		if r.Type == sdk.ServiceRequirement || r.Type == sdk.MemoryRequirement || r.Type == sdk.CPURequirement {
+			continue
+		}
+
 		// Check binary requirement against worker model capabilities
 		for _, c := range capa {
 			log.Debug("Comparing [%s] and [%s]\n", r.Name, c.Name)
@@ -96,16 +120,24 @@ func modelCanRun(name string, req []sdk.Requirement, capa []sdk.Requirement) boo
 type actioncount struct {
 	Action sdk.Action
 	Count  int64
+	// IDs of queued action builds
+	IDs []int64
 }
 
 func scanActionCount(db *sql.DB, s database.Scanner) (actioncount, error) {
 	ac := actioncount{}
 	var actionID int64
+	var ids string
 
-	err := s.Scan(&ac.Count, &actionID)
+	err := s.Scan(&ac.Count, &actionID, &ids)
 	if err != nil {
 		return ac, fmt.Errorf("scanActionCount> cannot scan: %s", err)
 	}
+	for _, id := range strings.Split(ids, ",") {
+		if i, err := strconv.ParseInt(id, 10, 64); err == nil {
+			ac.IDs = append(ac.IDs, i)
+		}
+	}
 
 	actionRequirementsMutex.RLock()
 	req, ok := actionRequirements[actionID]
@@ -128,15 +160,16 @@ func scanActionCount(db *sql.DB, s database.Scanner) (actioncount, error) {
 func loadAllActionCount(db *sql.DB) ([]actioncount, error) {
 	acs := []actioncount{}
 	query := `
-	SELECT COUNT(action_build.id), pipeline_action.action_id
+	SELECT COUNT(action_build.id), pipeline_action.action_id, string_agg(action_build.id::text, ',')
 	FROM action_build
 	JOIN pipeline_action ON pipeline_action.id = action_build.pipeline_action_id
 	WHERE action_build.status = $1
+	AND NOT EXISTS (SELECT 1 FROM worker_model_reservation WHERE worker_model_reservation.action_build_id = action_build.id AND expire > $2)
 	GROUP BY pipeline_action.action_id
 	LIMIT 1000
 	`
 
-	rows, err := db.Query(query, string(sdk.StatusWaiting))
+	rows, err := db.Query(query, string(sdk.StatusWaiting), time.Now())
 	if err != nil {
 		return nil, fmt.Errorf("loadAllActionCount> cannot query> %s", err)
 	}
@@ -157,7 +190,7 @@ func loadAllActionCount(db *sql.DB) ([]actioncount, error) {
 func loadUserActionCount(db *sql.DB, userID int64) ([]actioncount, error) {
 	acs := []actioncount{}
 	query := `
-	SELECT COUNT(action_build.id), pipeline_action.action_id
+	SELECT COUNT(action_build.id), pipeline_action.action_id, string_agg(action_build.id::text, ',')
 	FROM action_build
 	JOIN pipeline_action ON pipeline_action.id = action_build.pipeline_action_id
   JOIN pipeline_build ON pipeline_build.id = action_build.pipeline_build_id
@@ -165,11 +198,12 @@ func loadUserActionCount(db *sql.DB, userID int64) ([]actioncount, error) {
 	JOIN pipeline_group ON pipeline_group.pipeline_id = pipeline.id
 	JOIN group_user ON group_user.group_id = pipeline_group.group_id
 	WHERE action_build.status = $1 AND group_user.user_id = $2
+	AND NOT EXISTS (SELECT 1 FROM worker_model_reservation WHERE worker_model_reservation.action_build_id = action_build.id AND expire > $3)
 	GROUP BY pipeline_action.action_id
 	LIMIT 1000
 	`
 
-	rows, err := db.Query(query, string(sdk.StatusWaiting), userID)
+	rows, err := db.Query(query, string(sdk.StatusWaiting), userID, time.Now())
 	if err != nil {
 		return nil, err
 	}
@@ -266,6 +300,7 @@ func EstimateWorkerModelNeeds(db *sql.DB, u *sdk.User) ([]sdk.ModelStatus, error
 	}
 
 	// Now for each unique action in queue, find a worker model able to run it
+	now := time.Now()
 	var capas []sdk.Requirement
 	var ok bool
 	for _, ac := range acs {
@@ -277,6 +312,16 @@ func EstimateWorkerModelNeeds(db *sql.DB, u *sdk.User) ([]sdk.ModelStatus, error
 			loopModels = false
 
 			for i := range ms {
+				// Unhealthy models keep failing to start, let other models take the job
+				if ms[i].UnhealthyUntil != nil && ms[i].UnhealthyUntil.After(now) {
+					continue
+				}
+
+				// Models with a full pool let other models take the job
+				if poolFull(&ms[i]) {
+					continue
+				}
+
 				modelCapaMutex.RLock()
 				capas, ok = modelCapabilities[ms[i].ModelID]
 				modelCapaMutex.RUnlock()
@@ -287,20 +332,24 @@ func EstimateWorkerModelNeeds(db *sql.DB, u *sdk.User) ([]sdk.ModelStatus, error
 					}
 				}
 
-				if modelCanRun(ms[i].ModelName, ac.Action.Requirements, capas) {
+				m := sdk.Model{Name: ms[i].ModelName, Memory: ms[i].Memory, CPU: ms[i].CPU}
+				if modelCanRun(m, ac.Action.Requirements, capas) {
 					if ac.Count > 0 {
 						ms[i].WantedCount++
 						ac.Count--
+						if int(ac.Count) < len(ac.IDs) {
+							id := ac.IDs[ac.Count]
+							ms[i].ActionBuilds = append(ms[i].ActionBuilds, id)
+							if ms[i].ActionBuildRequirements == nil {
+								ms[i].ActionBuildRequirements = map[int64][]sdk.Requirement{}
+							}
+							ms[i].ActionBuildRequirements[id] = spawnRequirements(ac.Action.Requirements)
+						}
 						loopModels = true
 					}
 
 					//Add model requirement if action has specific kind of requirements
-					ms[i].Requirements = []sdk.Requirement{}
-					for j := range ac.Action.Requirements {
-						if ac.Action.Requirements[j].Type == sdk.ServiceRequirement {
-							ms[i].Requirements = append(ms[i].Requirements, ac.Action.Requirements[j])
-						}
-					}
+					ms[i].Requirements = spawnRequirements(ac.Action.Requirements)
 
 					//break
 				}
@@ -308,5 +357,22 @@ func EstimateWorkerModelNeeds(db *sql.DB, u *sdk.User) ([]sdk.ModelStatus, error
 		} // !range loopModels
 	} // !range acs
 
+	// Keep idle workers of pools
+	for i := range ms {
+		applyPool(&ms[i])
+	}
+
 	return ms, nil
 }
+
+// spawnRequirements returns the requirements of an action a worker must be spawned with: services, memory and CPU
+func spawnRequirements(reqs []sdk.Requirement) []sdk.Requirement {
+	spawn := []sdk.Requirement{}
+	for _, r := range reqs {
+		switch r.Type {
+		case sdk.ServiceRequirement, sdk.MemoryRequirement, sdk.CPURequirement:
+			spawn = append(spawn, r)
+		}
+	}
+	return spawn
+}
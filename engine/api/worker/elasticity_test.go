@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestModelCanRunResources(t *testing.T) {
+	capa := []sdk.Requirement{{Name: "git", Type: sdk.BinaryRequirement, Value: "git"}}
+	req := []sdk.Requirement{
+		{Name: "git", Type: sdk.BinaryRequirement, Value: "git"},
+		{Name: "memory", Type: sdk.MemoryRequirement, Value: "2048"},
+		{Name: "cpu", Type: sdk.CPURequirement, Value: "2"},
+		{Name: "pg", Type: sdk.ServiceRequirement, Value: "postgres:9.6"},
+	}
+
+	tests := []struct {
+		model sdk.Model
+		want  bool
+	}{
+		{sdk.Model{Name: "unsized"}, true},
+		{sdk.Model{Name: "big", Memory: 4096, CPU: 4}, true},
+		{sdk.Model{Name: "exact", Memory: 2048, CPU: 2}, true},
+		{sdk.Model{Name: "small-memory", Memory: 1024, CPU: 4}, false},
+		{sdk.Model{Name: "small-cpu", Memory: 4096, CPU: 1}, false},
+	}
+
+	for _, tt := range tests {
+		if got := modelCanRun(tt.model, req, capa); got != tt.want {
+			t.Errorf("modelCanRun(%s) = %v, want %v", tt.model.Name, got, tt.want)
+		}
+	}
+
+	if modelCanRun(sdk.Model{Name: "nogit"}, req, nil) {
+		t.Errorf("modelCanRun should refuse a model without binary capability")
+	}
+}
+
+func TestSpawnRequirements(t *testing.T) {
+	req := []sdk.Requirement{
+		{Name: "git", Type: sdk.BinaryRequirement, Value: "git"},
+		{Name: "pg", Type: sdk.ServiceRequirement, Value: "postgres:9.5"},
+		{Name: "mem", Type: sdk.MemoryRequirement, Value: "4096"},
+		{Name: "cpu", Type: sdk.CPURequirement, Value: "2"},
+	}
+	spawn := spawnRequirements(req)
+	if len(spawn) != 3 || spawn[0].Name != "pg" || spawn[1].Name != "mem" || spawn[2].Name != "cpu" {
+		t.Errorf("unexpected spawn requirements %v", spawn)
+	}
+	if spawn := spawnRequirements(nil); spawn == nil || len(spawn) != 0 {
+		t.Errorf("spawn requirements should be empty, got %v", spawn)
+	}
+}
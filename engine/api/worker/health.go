@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+const (
+	// unhealthyThreshold is the number of consecutive spawn errors after which a model is marked unhealthy
+	unhealthyThreshold = 3
+	// spawn errors are kept this long for statistics
+	spawnErrorRetention = 7 * 24 * time.Hour
+)
+
+var (
+	minSpawnBackoff = time.Minute
+	maxSpawnBackoff = time.Hour
+)
+
+// spawnBackoff returns how long hatcheries should wait before spawning again a model
+// which failed to start the given number of consecutive times
+func spawnBackoff(failures int64) time.Duration {
+	if failures < unhealthyThreshold {
+		return 0
+	}
+	d := minSpawnBackoff
+	for i := int64(unhealthyThreshold); i < failures; i++ {
+		d *= 2
+		if d >= maxSpawnBackoff {
+			return maxSpawnBackoff
+		}
+	}
+	return d
+}
+
+// InsertSpawnError records a failure to start a worker of given model and updates model health
+func InsertSpawnError(db *sql.DB, e *sdk.SpawnError) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	e.Date = time.Now()
+	query := `INSERT INTO worker_model_spawn_error (worker_model_id, hatchery_id, hatchery_name, message, created) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.Exec(query, e.ModelID, e.HatcheryID, e.HatcheryName, e.Message, e.Date); err != nil {
+		return err
+	}
+
+	query = `DELETE FROM worker_model_spawn_error WHERE worker_model_id = $1 AND created < $2`
+	if _, err := tx.Exec(query, e.ModelID, e.Date.Add(-spawnErrorRetention)); err != nil {
+		return err
+	}
+
+	// Worker will not register, release its reservation
+	if e.HatcheryID != 0 {
+		if err := ReleaseReservation(tx, e.ModelID, e.HatcheryID, 0); err != nil {
+			return err
+		}
+	}
+
+	var failures int64
+	query = `UPDATE worker_model SET spawn_errors = spawn_errors + 1, last_spawn_error = $1 WHERE id = $2 RETURNING spawn_errors`
+	if err := tx.QueryRow(query, e.Message, e.ModelID).Scan(&failures); err != nil {
+		if err == sql.ErrNoRows {
+			return sdk.ErrNoWorkerModel
+		}
+		return err
+	}
+
+	if d := spawnBackoff(failures); d > 0 {
+		log.Notice("InsertSpawnError> model %d failed %d times, unhealthy for %s\n", e.ModelID, failures, d)
+		query = `UPDATE worker_model SET unhealthy_until = $1 WHERE id = $2`
+		if _, err := tx.Exec(query, e.Date.Add(d), e.ModelID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ResetModelHealth marks model healthy, once one of its workers managed to register
+func ResetModelHealth(db database.Executer, modelID int64) error {
+	query := `UPDATE worker_model SET spawn_errors = 0, last_spawn_error = '', unhealthy_until = NULL WHERE id = $1 AND spawn_errors > 0`
+	_, err := db.Exec(query, modelID)
+	return err
+}
+
+// LoadSpawnErrorStats returns spawn errors of given model since given date, per hatchery
+func LoadSpawnErrorStats(db database.Querier, modelID int64, since time.Time) ([]sdk.SpawnErrorStat, error) {
+	query := `SELECT DISTINCT ON (hatchery_id) hatchery_id, hatchery_name, COUNT(id) OVER (PARTITION BY hatchery_id), message, created
+	          FROM worker_model_spawn_error
+	          WHERE worker_model_id = $1 AND created > $2
+	          ORDER BY hatchery_id, created DESC`
+
+	rows, err := db.Query(query, modelID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []sdk.SpawnErrorStat{}
+	for rows.Next() {
+		var s sdk.SpawnErrorStat
+		if err := rows.Scan(&s.HatcheryID, &s.HatcheryName, &s.Count, &s.LastMessage, &s.LastDate); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,27 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpawnBackoff(t *testing.T) {
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{6, 8 * time.Minute},
+		{9, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := spawnBackoff(tt.failures); got != tt.want {
+			t.Errorf("spawnBackoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
@@ -10,19 +10,26 @@ import (
 
 // InsertWorkerModel insert a new worker model in database
 func InsertWorkerModel(db *sql.DB, model *sdk.Model) error {
-	query := `INSERT INTO worker_model (type, name, image, owner_id) VALUES ($1, $2, $3, $4) RETURNING id`
+	query := `INSERT INTO worker_model (type, name, image, owner_id, memory, cpu, disk, spawn_errors, last_spawn_error, validated, need_validation) VALUES ($1, $2, $3, $4, $5, $6, $7, 0, '', $8, $9) RETURNING id`
 
-	err := db.QueryRow(query, string(model.Type), model.Name, model.Image, model.OwnerID).Scan(&model.ID)
+	// New models are validated by the first worker started
+	model.Validated = false
+	model.NeedValidation = true
+	err := db.QueryRow(query, string(model.Type), model.Name, model.Image, model.OwnerID, model.Memory, model.CPU, model.Disk, model.Validated, model.NeedValidation).Scan(&model.ID)
 	if err != nil {
 		return err
 	}
 
+	if model.Pool != nil {
+		return UpdateModelPool(db, model.ID, model.Pool)
+	}
+
 	return nil
 }
 
 // LoadWorkerModels retrieves models from database
 func LoadWorkerModels(db database.Querier) ([]sdk.Model, error) {
-	query := `SELECT worker_model.id, worker_model.type, worker_model.name, worker_model.image, worker_model.owner_id , "user".username
+	query := `SELECT worker_model.id, worker_model.type, worker_model.name, worker_model.image, worker_model.owner_id, worker_model.memory, worker_model.cpu, worker_model.disk, worker_model.spawn_errors, worker_model.last_spawn_error, worker_model.unhealthy_until, worker_model.validated, worker_model.need_validation, worker_model.validation_report, worker_model.pool, "user".username
 	          FROM worker_model
 	          JOIN "user" ON "user".id =  worker_model.owner_id
 	          ORDER BY worker_model.name
@@ -39,7 +46,8 @@ func LoadWorkerModels(db database.Querier) ([]sdk.Model, error) {
 		var m sdk.Model
 		var u sdk.User
 		var typeS string
-		err = rows.Scan(&m.ID, &typeS, &m.Name, &m.Image, &m.OwnerID, &u.Username)
+		var lastSpawnError, report, pool sql.NullString
+		err = rows.Scan(&m.ID, &typeS, &m.Name, &m.Image, &m.OwnerID, &m.Memory, &m.CPU, &m.Disk, &m.SpawnErrors, &lastSpawnError, &m.UnhealthyUntil, &m.Validated, &m.NeedValidation, &report, &pool, &u.Username)
 		if err != nil {
 			return nil, err
 		}
@@ -55,6 +63,13 @@ func LoadWorkerModels(db database.Querier) ([]sdk.Model, error) {
 			break
 		}
 		m.Owner = u
+		m.LastSpawnError = lastSpawnError.String
+		if err := unmarshalValidationReport(&m, report); err != nil {
+			return nil, err
+		}
+		if m.Pool, err = unmarshalPool(pool); err != nil {
+			return nil, err
+		}
 		models = append(models, m)
 	}
 	rows.Close()
@@ -77,7 +92,7 @@ func LoadWorkerModels(db database.Querier) ([]sdk.Model, error) {
 
 // LoadWorkerModel retrieves a specific worker model in database
 func LoadWorkerModel(db *sql.DB, name string) (*sdk.Model, error) {
-	query := `SELECT worker_model.id, worker_model.type, worker_model.name, worker_model.image, worker_model.owner_id , "user".username
+	query := `SELECT worker_model.id, worker_model.type, worker_model.name, worker_model.image, worker_model.owner_id, worker_model.memory, worker_model.cpu, worker_model.disk, worker_model.spawn_errors, worker_model.last_spawn_error, worker_model.unhealthy_until, worker_model.validated, worker_model.need_validation, worker_model.validation_report, worker_model.pool, "user".username
 		  FROM worker_model
 		  JOIN "user" ON "user".id = worker_model.owner_id
 		  WHERE name = $1`
@@ -85,7 +100,8 @@ func LoadWorkerModel(db *sql.DB, name string) (*sdk.Model, error) {
 	var m sdk.Model
 	var u sdk.User
 	var typeS string
-	err := db.QueryRow(query, name).Scan(&m.ID, &typeS, &m.Name, &m.Image, &m.OwnerID, &u.Username)
+	var lastSpawnError, report, pool sql.NullString
+	err := db.QueryRow(query, name).Scan(&m.ID, &typeS, &m.Name, &m.Image, &m.OwnerID, &m.Memory, &m.CPU, &m.Disk, &m.SpawnErrors, &lastSpawnError, &m.UnhealthyUntil, &m.Validated, &m.NeedValidation, &report, &pool, &u.Username)
 	if err != nil && err == sql.ErrNoRows {
 		return nil, sdk.ErrNoWorkerModel
 	}
@@ -104,6 +120,13 @@ func LoadWorkerModel(db *sql.DB, name string) (*sdk.Model, error) {
 		break
 	}
 	m.Owner = u
+	m.LastSpawnError = lastSpawnError.String
+	if err := unmarshalValidationReport(&m, report); err != nil {
+		return nil, err
+	}
+	if m.Pool, err = unmarshalPool(pool); err != nil {
+		return nil, err
+	}
 
 	m.Capabilities, err = LoadWorkerModelCapabilities(db, m.ID)
 	if err != nil {
@@ -135,6 +158,24 @@ func DeleteWorkerModel(tx *sql.Tx, workerModelID int64) error {
 		return err
 	}
 
+	query = `DELETE FROM worker_model_spawn_error WHERE worker_model_id = $1`
+	_, err = tx.Exec(query, workerModelID)
+	if err != nil {
+		return err
+	}
+
+	query = `DELETE FROM worker_model_reservation WHERE worker_model_id = $1`
+	_, err = tx.Exec(query, workerModelID)
+	if err != nil {
+		return err
+	}
+
+	query = `DELETE FROM spawn_latency WHERE worker_model_id = $1`
+	_, err = tx.Exec(query, workerModelID)
+	if err != nil {
+		return err
+	}
+
 	query = `DELETE FROM worker_model WHERE id = $1`
 	_, err = tx.Exec(query, workerModelID)
 	if err != nil {
@@ -242,8 +283,8 @@ func UpdateWorkerModel(db *sql.DB, model sdk.Model) error {
 	}
 	defer tx.Rollback()
 
-	query := `UPDATE worker_model SET type=$1, name=$2, image=$3 WHERE id = $4`
-	_, err = tx.Exec(query, string(model.Type), model.Name, model.Image, model.ID)
+	query := `UPDATE worker_model SET type=$1, name=$2, image=$3, memory=$4, cpu=$5, disk=$6, validated=$7, need_validation=$8 WHERE id = $9`
+	_, err = tx.Exec(query, string(model.Type), model.Name, model.Image, model.Memory, model.CPU, model.Disk, false, true, model.ID)
 	if err != nil {
 		return err
 	}
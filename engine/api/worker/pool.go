@@ -0,0 +1,173 @@
+package worker
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/sdk"
+)
+
+var poolDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseHour parses "hh:mm" into minutes since midnight
+func parseHour(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidatePool checks limits and schedules of a pool
+func ValidatePool(p *sdk.ModelPool) error {
+	if p == nil {
+		return nil
+	}
+	if p.Location != "" {
+		if _, err := time.LoadLocation(p.Location); err != nil {
+			return sdk.ErrInvalidWorkerModelPool
+		}
+	}
+
+	limits := []sdk.ModelPoolLimits{p.ModelPoolLimits}
+	for _, s := range p.Schedules {
+		for _, d := range s.Days {
+			if _, ok := poolDays[strings.ToLower(d)]; !ok {
+				return sdk.ErrInvalidWorkerModelPool
+			}
+		}
+		if _, err := parseHour(s.From); err != nil {
+			return sdk.ErrInvalidWorkerModelPool
+		}
+		if _, err := parseHour(s.To); err != nil {
+			return sdk.ErrInvalidWorkerModelPool
+		}
+		limits = append(limits, s.ModelPoolLimits)
+	}
+
+	for _, l := range limits {
+		if l.MinIdle < 0 || l.MaxTotal < 0 || l.IdleTTL < 0 {
+			return sdk.ErrInvalidWorkerModelPool
+		}
+		if l.MaxTotal > 0 && l.MinIdle > l.MaxTotal {
+			return sdk.ErrInvalidWorkerModelPool
+		}
+	}
+	return nil
+}
+
+// poolLimits returns limits of the pool applied at given time: the first active schedule, or default limits
+func poolLimits(p *sdk.ModelPool, t time.Time) sdk.ModelPoolLimits {
+	if p.Location != "" {
+		if loc, err := time.LoadLocation(p.Location); err == nil {
+			t = t.In(loc)
+		}
+	}
+	now := t.Hour()*60 + t.Minute()
+
+	for _, s := range p.Schedules {
+		if len(s.Days) > 0 {
+			var today bool
+			for _, d := range s.Days {
+				if poolDays[strings.ToLower(d)] == t.Weekday() {
+					today = true
+					break
+				}
+			}
+			if !today {
+				continue
+			}
+		}
+
+		from, err := parseHour(s.From)
+		if err != nil {
+			continue
+		}
+		to, err := parseHour(s.To)
+		if err != nil {
+			continue
+		}
+
+		// Schedules may span midnight (ie. 22:00-06:00)
+		active := (from <= to && now >= from && now < to) || (from > to && (now >= from || now < to))
+		if active {
+			return s.ModelPoolLimits
+		}
+	}
+
+	return p.ModelPoolLimits
+}
+
+// applyPool adds idle workers wanted by the pool to those needed by queued jobs,
+// without exceeding the maximum number of workers of the model
+func applyPool(ms *sdk.ModelStatus) {
+	if ms.Pool == nil {
+		return
+	}
+	// Idle workers being spawned by hatcheries are already provisioned
+	if idle := ms.Pool.MinIdle - ms.ReservedCount; idle > 0 {
+		ms.WantedCount += idle
+	}
+	if ms.Pool.MaxTotal > 0 {
+		max := ms.Pool.MaxTotal - ms.BuildingCount
+		if max < 0 {
+			max = 0
+		}
+		if ms.WantedCount > max {
+			ms.WantedCount = max
+		}
+	}
+}
+
+// poolFull returns true when the model can not take more jobs
+func poolFull(ms *sdk.ModelStatus) bool {
+	return ms.Pool != nil && ms.Pool.MaxTotal > 0 && ms.WantedCount+ms.BuildingCount >= ms.Pool.MaxTotal
+}
+
+func unmarshalPool(data sql.NullString) (*sdk.ModelPool, error) {
+	if !data.Valid || data.String == "" {
+		return nil, nil
+	}
+	var p sdk.ModelPool
+	if err := json.Unmarshal([]byte(data.String), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpdateModelPool stores the pool configuration of a model, nil removing it
+func UpdateModelPool(db database.Executer, modelID int64, p *sdk.ModelPool) error {
+	var data sql.NullString
+	if p != nil {
+		b, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		data = sql.NullString{String: string(b), Valid: true}
+	}
+
+	query := `UPDATE worker_model SET pool = $1 WHERE id = $2`
+	res, err := db.Exec(query, data, modelID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sdk.ErrNoWorkerModel
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestPoolLimits(t *testing.T) {
+	p := &sdk.ModelPool{
+		ModelPoolLimits: sdk.ModelPoolLimits{MinIdle: 1, MaxTotal: 5},
+		Schedules: []sdk.ModelPoolSchedule{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "08:00", To: "19:00", ModelPoolLimits: sdk.ModelPoolLimits{MinIdle: 5, MaxTotal: 20}},
+			{From: "22:00", To: "06:00", ModelPoolLimits: sdk.ModelPoolLimits{MinIdle: 0, MaxTotal: 2}},
+		},
+	}
+
+	tests := []struct {
+		date string
+		want int64
+	}{
+		{"2016-10-17 10:00", 20}, // monday, office hours
+		{"2016-10-17 19:00", 5},  // monday, after office hours
+		{"2016-10-16 10:00", 5},  // sunday
+		{"2016-10-16 23:30", 2},  // night
+		{"2016-10-17 05:59", 2},  // night, next day
+	}
+	for _, tt := range tests {
+		d, err := time.ParseInLocation("2006-01-02 15:04", tt.date, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := poolLimits(p, d).MaxTotal; got != tt.want {
+			t.Errorf("poolLimits(%s).MaxTotal = %d, want %d", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestApplyPool(t *testing.T) {
+	ms := sdk.ModelStatus{WantedCount: 3, BuildingCount: 2, Pool: &sdk.ModelPoolLimits{MinIdle: 2, MaxTotal: 6}}
+	if poolFull(&ms) {
+		t.Errorf("pool should not be full with 5 workers out of 6")
+	}
+	applyPool(&ms)
+	if ms.WantedCount != 4 {
+		t.Errorf("WantedCount = %d, want 4", ms.WantedCount)
+	}
+	if !poolFull(&ms) {
+		t.Errorf("pool should be full")
+	}
+
+	ms = sdk.ModelStatus{WantedCount: 3}
+	applyPool(&ms)
+	if ms.WantedCount != 3 {
+		t.Errorf("model without pool should not change, WantedCount = %d", ms.WantedCount)
+	}
+}
+
+func TestValidatePool(t *testing.T) {
+	valid := &sdk.ModelPool{
+		ModelPoolLimits: sdk.ModelPoolLimits{MinIdle: 1, MaxTotal: 5, IdleTTL: 600},
+		Schedules:       []sdk.ModelPoolSchedule{{Days: []string{"Mon"}, From: "08:00", To: "19:00"}},
+	}
+	if err := ValidatePool(valid); err != nil {
+		t.Errorf("pool should be valid: %s", err)
+	}
+	if err := ValidatePool(nil); err != nil {
+		t.Errorf("no pool should be valid: %s", err)
+	}
+
+	invalid := []*sdk.ModelPool{
+		{ModelPoolLimits: sdk.ModelPoolLimits{MinIdle: -1}},
+		{ModelPoolLimits: sdk.ModelPoolLimits{MinIdle: 6, MaxTotal: 5}},
+		{Schedules: []sdk.ModelPoolSchedule{{Days: []string{"monday"}, From: "08:00", To: "19:00"}}},
+		{Schedules: []sdk.ModelPoolSchedule{{From: "8h", To: "19:00"}}},
+		{Location: "Nowhere/Somewhere"},
+	}
+	for i, p := range invalid {
+		if err := ValidatePool(p); err == nil {
+			t.Errorf("pool %d should be invalid", i)
+		}
+	}
+}
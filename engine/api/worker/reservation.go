@@ -0,0 +1,183 @@
+package worker
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/sdk"
+)
+
+// defaultReservationTTL is the number of seconds given to workers to register, when hatchery does not set it
+const defaultReservationTTL = 600
+
+// ReserveWorkers grants an hatchery the right to spawn workers of a model: one per queued action build
+// not reserved yet by another hatchery, plus idle workers wanted by the pool of the model.
+// Reservation count and action builds are updated with what has been granted.
+func ReserveWorkers(db *sql.DB, r *sdk.SpawnReservation) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock model, so that concurrent claims for the model are handled one after another
+	var pool sql.NullString
+	query := `SELECT pool FROM worker_model WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRow(query, r.ModelID).Scan(&pool); err != nil {
+		if err == sql.ErrNoRows {
+			return sdk.ErrNoWorkerModel
+		}
+		return err
+	}
+
+	now := time.Now()
+	query = `DELETE FROM worker_model_reservation WHERE expire < $1`
+	if _, err := tx.Exec(query, now); err != nil {
+		return err
+	}
+
+	if r.TTL <= 0 {
+		r.TTL = defaultReservationTTL
+	}
+	r.Expire = now.Add(time.Duration(r.TTL) * time.Second)
+
+	builds := []int64{}
+	for _, id := range r.ActionBuilds {
+		if int64(len(builds)) >= r.Count {
+			break
+		}
+		ok, err := reservableActionBuild(tx, id)
+		if err != nil {
+			return err
+		}
+		if ok {
+			builds = append(builds, id)
+		}
+	}
+	idle := r.Count - int64(len(r.ActionBuilds))
+	if idle < 0 {
+		idle = 0
+	}
+
+	p, err := unmarshalPool(pool)
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		l := poolLimits(p, now)
+		c, err := loadReservationCounts(tx, r.ModelID)
+		if err != nil {
+			return err
+		}
+		nb, ni := poolGrant(l, c, int64(len(builds)), idle)
+		builds = builds[:nb]
+		idle = ni
+	}
+
+	query = `INSERT INTO worker_model_reservation (worker_model_id, hatchery_id, action_build_id, expire) VALUES ($1, $2, $3, $4)`
+	for _, id := range builds {
+		if _, err := tx.Exec(query, r.ModelID, r.HatcheryID, id, r.Expire); err != nil {
+			return err
+		}
+	}
+	for i := int64(0); i < idle; i++ {
+		if _, err := tx.Exec(query, r.ModelID, r.HatcheryID, 0, r.Expire); err != nil {
+			return err
+		}
+	}
+
+	r.ActionBuilds = builds
+	r.Count = int64(len(builds)) + idle
+	return tx.Commit()
+}
+
+// reservableActionBuild returns true when action build is still waiting in queue, without reservation
+func reservableActionBuild(tx *sql.Tx, id int64) (bool, error) {
+	var status string
+	query := `SELECT status FROM action_build WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRow(query, id).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	if status != string(sdk.StatusWaiting) {
+		return false, nil
+	}
+
+	var n int64
+	query = `SELECT COUNT(id) FROM worker_model_reservation WHERE action_build_id = $1`
+	if err := tx.QueryRow(query, id).Scan(&n); err != nil {
+		return false, err
+	}
+	return n == 0, nil
+}
+
+// reservationCounts are the workers of a model, and those being spawned
+type reservationCounts struct {
+	Workers      int64
+	Waiting      int64
+	Reserved     int64
+	ReservedIdle int64
+}
+
+func loadReservationCounts(tx *sql.Tx, modelID int64) (reservationCounts, error) {
+	var c reservationCounts
+	query := `SELECT COUNT(id), COALESCE(SUM(CASE WHEN status = $2 THEN 1 ELSE 0 END), 0) FROM worker WHERE model = $1`
+	if err := tx.QueryRow(query, modelID, string(sdk.StatusWaiting)).Scan(&c.Workers, &c.Waiting); err != nil {
+		return c, err
+	}
+
+	query = `SELECT COUNT(id), COALESCE(SUM(CASE WHEN action_build_id = 0 THEN 1 ELSE 0 END), 0) FROM worker_model_reservation WHERE worker_model_id = $1`
+	if err := tx.QueryRow(query, modelID).Scan(&c.Reserved, &c.ReservedIdle); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// poolGrant returns how many workers may be spawned for action builds and how many idle workers,
+// so that the pool keeps its minimum of idle workers without exceeding its maximum of workers
+func poolGrant(l sdk.ModelPoolLimits, c reservationCounts, builds, idle int64) (int64, int64) {
+	if max := l.MinIdle - c.Waiting - c.ReservedIdle; idle > max {
+		idle = max
+	}
+	if idle < 0 {
+		idle = 0
+	}
+
+	if l.MaxTotal > 0 {
+		room := l.MaxTotal - c.Workers - c.Reserved
+		if room < 0 {
+			room = 0
+		}
+		if builds > room {
+			builds = room
+		}
+		if idle > room-builds {
+			idle = room - builds
+		}
+	}
+	return builds, idle
+}
+
+// ReleaseReservation releases one reservation of the hatchery for given model,
+// once a worker registered or failed to start. Workers spawned for an action build release its reservation.
+func ReleaseReservation(db database.Executer, modelID, hatcheryID, actionBuildID int64) error {
+	if actionBuildID != 0 {
+		query := `DELETE FROM worker_model_reservation WHERE worker_model_id = $1 AND hatchery_id = $2 AND action_build_id = $3`
+		res, err := db.Exec(query, modelID, hatcheryID, actionBuildID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return err
+		}
+	}
+
+	query := `DELETE FROM worker_model_reservation WHERE id IN (
+		SELECT id FROM worker_model_reservation WHERE worker_model_id = $1 AND hatchery_id = $2 ORDER BY expire LIMIT 1
+	)`
+	_, err := db.Exec(query, modelID, hatcheryID)
+	return err
+}
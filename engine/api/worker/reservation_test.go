@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestPoolGrant(t *testing.T) {
+	tests := []struct {
+		name         string
+		limits       sdk.ModelPoolLimits
+		counts       reservationCounts
+		builds, idle int64
+		wantB, wantI int64
+	}{
+		{"unlimited", sdk.ModelPoolLimits{MinIdle: 2}, reservationCounts{}, 5, 2, 5, 2},
+		{"idle workers waiting", sdk.ModelPoolLimits{MinIdle: 2}, reservationCounts{Workers: 1, Waiting: 1}, 0, 2, 0, 1},
+		{"idle workers reserved by another hatchery", sdk.ModelPoolLimits{MinIdle: 2}, reservationCounts{Reserved: 2, ReservedIdle: 2}, 0, 2, 0, 0},
+		{"max reached", sdk.ModelPoolLimits{MinIdle: 1, MaxTotal: 4}, reservationCounts{Workers: 3, Reserved: 1}, 2, 1, 0, 0},
+		{"builds first", sdk.ModelPoolLimits{MinIdle: 2, MaxTotal: 4}, reservationCounts{Workers: 1}, 2, 2, 2, 1},
+	}
+
+	for _, tt := range tests {
+		b, i := poolGrant(tt.limits, tt.counts, tt.builds, tt.idle)
+		if b != tt.wantB || i != tt.wantI {
+			t.Errorf("%s: poolGrant = %d builds, %d idle, want %d, %d", tt.name, b, i, tt.wantB, tt.wantI)
+		}
+	}
+}
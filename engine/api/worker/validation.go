@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/sdk"
+)
+
+func unmarshalValidationReport(m *sdk.Model, report sql.NullString) error {
+	if !report.Valid || report.String == "" {
+		return nil
+	}
+	m.Validation = &sdk.ModelValidationReport{}
+	return json.Unmarshal([]byte(report.String), m.Validation)
+}
+
+// RequestModelValidation marks model as not validated, until a worker reports its capabilities
+func RequestModelValidation(db database.Executer, modelID int64) error {
+	query := `UPDATE worker_model SET validated = false, need_validation = true WHERE id = $1`
+	res, err := db.Exec(query, modelID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sdk.ErrNoWorkerModel
+	}
+	return nil
+}
+
+// LoadModelValidation returns what a worker of given model has to check,
+// discover being the list of binaries to look for in addition to declared capabilities
+func LoadModelValidation(db *sql.DB, modelID int64, discover []string) (*sdk.ModelValidation, error) {
+	v := &sdk.ModelValidation{}
+	query := `SELECT need_validation FROM worker_model WHERE id = $1`
+	var needed sql.NullBool
+	if err := db.QueryRow(query, modelID).Scan(&needed); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sdk.ErrNoWorkerModel
+		}
+		return nil, err
+	}
+	if !needed.Bool {
+		return v, nil
+	}
+
+	capas, err := LoadWorkerModelCapabilities(db, modelID)
+	if err != nil {
+		return nil, err
+	}
+	v.Needed = true
+	v.Capabilities = capas
+	v.Discover = discover
+	return v, nil
+}
+
+// SaveModelValidationReport computes the diff between checks made by worker and declared capabilities,
+// then marks model as validated or not. Only the first report after a validation request is kept.
+func SaveModelValidationReport(db *sql.DB, modelID int64, r *sdk.ModelValidationReport) error {
+	capas, err := LoadWorkerModelCapabilities(db, modelID)
+	if err != nil {
+		return err
+	}
+	r.Date = time.Now()
+	diffCapabilities(r, capas)
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE worker_model SET validated = $1, need_validation = false, validation_report = $2 WHERE id = $3 AND need_validation = true`
+	_, err = db.Exec(query, r.Validated, string(data), modelID)
+	return err
+}
+
+// diffCapabilities fills missing capabilities and undeclared binaries of the report.
+// Model is validated when all declared capabilities have been found on the worker.
+func diffCapabilities(r *sdk.ModelValidationReport, declared []sdk.Requirement) {
+	valid := map[string]bool{}
+	for _, c := range r.Checks {
+		valid[c.Requirement.Name] = c.Valid
+	}
+
+	r.Missing = []sdk.Requirement{}
+	known := map[string]bool{}
+	for _, c := range declared {
+		known[c.Value] = true
+		known[c.Name] = true
+		if !valid[c.Name] {
+			r.Missing = append(r.Missing, c)
+		}
+	}
+
+	r.Undeclared = []string{}
+	for _, b := range r.Discovered {
+		if !known[b] {
+			r.Undeclared = append(r.Undeclared, b)
+		}
+	}
+
+	r.Validated = len(r.Missing) == 0
+}
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestDiffCapabilities(t *testing.T) {
+	declared := []sdk.Requirement{
+		{Name: "git", Type: sdk.BinaryRequirement, Value: "git"},
+		{Name: "golang", Type: sdk.BinaryRequirement, Value: "go"},
+		{Name: "npm", Type: sdk.BinaryRequirement, Value: "npm"},
+	}
+	r := &sdk.ModelValidationReport{
+		Checks: []sdk.CapabilityCheck{
+			{Requirement: declared[0], Valid: true},
+			{Requirement: declared[1], Valid: true},
+			{Requirement: declared[2], Valid: false, Error: "not found"},
+		},
+		Discovered: []string{"git", "go", "docker", "make"},
+	}
+
+	diffCapabilities(r, declared)
+	if r.Validated {
+		t.Errorf("model without npm should not be validated")
+	}
+	if !reflect.DeepEqual(r.Missing, []sdk.Requirement{declared[2]}) {
+		t.Errorf("unexpected missing capabilities: %v", r.Missing)
+	}
+	if !reflect.DeepEqual(r.Undeclared, []string{"docker", "make"}) {
+		t.Errorf("unexpected undeclared binaries: %v", r.Undeclared)
+	}
+
+	r.Checks[2].Valid = true
+	diffCapabilities(r, declared)
+	if !r.Validated || len(r.Missing) != 0 {
+		t.Errorf("model should be validated, missing %v", r.Missing)
+	}
+
+	// A capability declared but not checked by worker is missing
+	diffCapabilities(r, append(declared, sdk.Requirement{Name: "java", Type: sdk.BinaryRequirement, Value: "java"}))
+	if r.Validated || len(r.Missing) != 1 {
+		t.Errorf("unchecked capability should be missing, got %v", r.Missing)
+	}
+}
@@ -21,6 +21,25 @@ type ActionBuildInfo struct {
 	Secrets     []sdk.Variable
 }
 
+// TakeForm is sent by workers taking an action build
+type TakeForm struct {
+	// SpawnLatency is the delay in milliseconds between the spawn of a worker booked for
+	// this action build and the moment it takes it
+	SpawnLatency int64 `json:"spawn_latency"`
+}
+
+// HeartbeatForm is sent by workers to refresh their last beat
+type HeartbeatForm struct {
+	Version string `json:"version"`
+}
+
+// HeartbeatResponse is returned to worker in answer to refreshWorkerHandler
+type HeartbeatResponse struct {
+	sdk.Worker
+	// APIVersion is the version of engine, workers running another version update themselves
+	APIVersion string `json:"api_version"`
+}
+
 // ErrNoWorker means the given worker ID is not found
 var ErrNoWorker = fmt.Errorf("cds: no worker found")
 
@@ -75,8 +94,8 @@ func DeleteWorker(db *sql.DB, id string) error {
 
 // InsertWorker inserts worker representation into database
 func InsertWorker(db database.Executer, w *sdk.Worker, userID int64) error {
-	query := `INSERT INTO worker (id, name, last_beat, owner_id, model, status, hatchery_id) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := db.Exec(query, w.ID, w.Name, time.Now(), userID, w.Model, w.Status.String(), w.HatcheryID)
+	query := `INSERT INTO worker (id, name, last_beat, owner_id, model, status, hatchery_id, draining) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := db.Exec(query, w.ID, w.Name, time.Now(), userID, w.Model, w.Status.String(), w.HatcheryID, w.Draining)
 	return err
 }
 
@@ -84,13 +103,17 @@ func InsertWorker(db database.Executer, w *sdk.Worker, userID int64) error {
 func LoadWorker(db database.Querier, id string) (*sdk.Worker, error) {
 	w := &sdk.Worker{}
 	var statusS string
-	query := `SELECT id, name, last_beat, owner_id, model, status, hatchery_id FROM worker WHERE worker.id = $1 FOR UPDATE`
+	var draining sql.NullBool
+	var version sql.NullString
+	query := `SELECT id, name, last_beat, owner_id, model, status, hatchery_id, draining, version FROM worker WHERE worker.id = $1 FOR UPDATE`
 
-	err := db.QueryRow(query, id).Scan(&w.ID, &w.Name, &w.LastBeat, &w.OwnerID, &w.Model, &statusS, &w.HatcheryID)
+	err := db.QueryRow(query, id).Scan(&w.ID, &w.Name, &w.LastBeat, &w.OwnerID, &w.Model, &statusS, &w.HatcheryID, &draining, &version)
 	if err != nil {
 		return nil, err
 	}
 	w.Status = sdk.StatusFromString(statusS)
+	w.Draining = draining.Bool
+	w.Version = version.String
 
 	return w, nil
 }
@@ -99,7 +122,7 @@ func LoadWorker(db database.Querier, id string) (*sdk.Worker, error) {
 func LoadWorkersByModel(db database.Querier, modelID int64) ([]sdk.Worker, error) {
 	w := []sdk.Worker{}
 	var statusS string
-	query := `SELECT worker.id, worker.name, worker.last_beat, worker.owner_id, worker.model, worker.status, worker.hatchery_id,
+	query := `SELECT worker.id, worker.name, worker.last_beat, worker.owner_id, worker.model, worker.status, worker.hatchery_id, worker.draining, worker.version,
 						"user".username
 	          FROM worker
 	          JOIN "user" ON "user".id = worker.owner_id
@@ -115,12 +138,16 @@ func LoadWorkersByModel(db database.Querier, modelID int64) ([]sdk.Worker, error
 	for rows.Next() {
 		var worker sdk.Worker
 		var user sdk.User
+		var draining sql.NullBool
+		var version sql.NullString
 
-		err = rows.Scan(&worker.ID, &worker.Name, &worker.LastBeat, &worker.OwnerID, &worker.Model, &statusS, &worker.HatcheryID, &user.Username)
+		err = rows.Scan(&worker.ID, &worker.Name, &worker.LastBeat, &worker.OwnerID, &worker.Model, &statusS, &worker.HatcheryID, &draining, &version, &user.Username)
 		if err != nil {
 			return nil, err
 		}
 		worker.Status = sdk.StatusFromString(statusS)
+		worker.Draining = draining.Bool
+		worker.Version = version.String
 		worker.Owner = user
 		w = append(w, worker)
 	}
@@ -132,7 +159,7 @@ func LoadWorkersByModel(db database.Querier, modelID int64) ([]sdk.Worker, error
 func LoadWorkers(db *sql.DB) ([]sdk.Worker, error) {
 	w := []sdk.Worker{}
 	var statusS string
-	query := `SELECT id, name, last_beat, owner_id, model, status, hatchery_id FROM worker WHERE 1 = 1 ORDER BY name ASC`
+	query := `SELECT id, name, last_beat, owner_id, model, status, hatchery_id, draining, version FROM worker WHERE 1 = 1 ORDER BY name ASC`
 
 	rows, err := db.Query(query)
 	if err != nil {
@@ -142,11 +169,15 @@ func LoadWorkers(db *sql.DB) ([]sdk.Worker, error) {
 
 	for rows.Next() {
 		var worker sdk.Worker
-		err = rows.Scan(&worker.ID, &worker.Name, &worker.LastBeat, &worker.OwnerID, &worker.Model, &statusS, &worker.HatcheryID)
+		var draining sql.NullBool
+		var version sql.NullString
+		err = rows.Scan(&worker.ID, &worker.Name, &worker.LastBeat, &worker.OwnerID, &worker.Model, &statusS, &worker.HatcheryID, &draining, &version)
 		if err != nil {
 			return nil, err
 		}
 		worker.Status = sdk.StatusFromString(statusS)
+		worker.Draining = draining.Bool
+		worker.Version = version.String
 		w = append(w, worker)
 	}
 
@@ -184,10 +215,10 @@ func LoadDeadWorkers(db *sql.DB, timeout float64) ([]sdk.Worker, error) {
 	return w, nil
 }
 
-// RefreshWorker Update worker last_beat
-func RefreshWorker(db *sql.DB, workerID string) error {
-	query := `UPDATE worker SET last_beat = $1 WHERE id = $2`
-	res, err := db.Exec(query, time.Now(), workerID)
+// RefreshWorker Update worker last_beat and version
+func RefreshWorker(db *sql.DB, workerID string, version string) error {
+	query := `UPDATE worker SET last_beat = $1, version = $2 WHERE id = $3`
+	res, err := db.Exec(query, time.Now(), version, workerID)
 	if err != nil {
 		return err
 	}
@@ -226,10 +257,12 @@ type RegistrationForm struct {
 	Model              int64
 	Hatchery           int64
 	BinaryCapabilities []string
+	// ActionBuild is the only action build the worker has been spawned for, if any
+	ActionBuild int64
 }
 
 // RegisterWorker  Register new worker
-func RegisterWorker(db *sql.DB, name string, uk string, modelID int64, hatcheryID int64, binaryCapabilities []string) (*sdk.Worker, error) {
+func RegisterWorker(db *sql.DB, name string, uk string, modelID int64, hatcheryID int64, actionBuildID int64, binaryCapabilities []string) (*sdk.Worker, error) {
 
 	if name == "" {
 		return nil, fmt.Errorf("cannot register worker with empty name")
@@ -274,6 +307,22 @@ func RegisterWorker(db *sql.DB, name string, uk string, modelID int64, hatcheryI
 		return nil, err
 	}
 
+	// A worker of the model managed to start, hatcheries can spawn it again
+	if modelID != 0 {
+		if err := ResetModelHealth(tx, modelID); err != nil {
+			log.Warning("registerWorker> Cannot reset health of model %d: %s\n", modelID, err)
+			return nil, err
+		}
+	}
+
+	// Worker spawned by an hatchery registered, release its reservation
+	if modelID != 0 && hatcheryID != 0 {
+		if err := ReleaseReservation(tx, modelID, hatcheryID, actionBuildID); err != nil {
+			log.Warning("registerWorker> Cannot release reservation of model %d: %s\n", modelID, err)
+			return nil, err
+		}
+	}
+
 	if e == sdk.FirstUseExpire {
 		err = DeleteUserKey(tx, uk)
 		if err != nil {
@@ -385,3 +434,61 @@ func FindBuildingWorker(db database.Querier, actionBuildID string) (string, erro
 	err := db.QueryRow(query, actionBuildID).Scan(&id)
 	return id, err
 }
+
+// DrainWorker stops worker from taking new jobs, worker unregisters once its current job is done
+func DrainWorker(db database.Executer, workerID string) error {
+	query := `UPDATE worker SET draining = true WHERE id = $1`
+	res, err := db.Exec(query, workerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoWorker
+	}
+	return nil
+}
+
+// UndrainWorker lets a draining worker take new jobs again
+func UndrainWorker(db database.Executer, workerID string) error {
+	query := `UPDATE worker SET draining = false WHERE id = $1`
+	res, err := db.Exec(query, workerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoWorker
+	}
+	return nil
+}
+
+// LoadWorkerVersions returns the number of workers running each version
+func LoadWorkerVersions(db database.Querier) ([]sdk.WorkerVersionStat, error) {
+	query := `SELECT COALESCE(version, ''), COUNT(id) FROM worker GROUP BY version ORDER BY version`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []sdk.WorkerVersionStat{}
+	for rows.Next() {
+		var s sdk.WorkerVersionStat
+		if err := rows.Scan(&s.Version, &s.Count); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+
+	"github.com/ovh/cds/engine/api/context"
+	"github.com/ovh/cds/engine/api/project"
+	"github.com/ovh/cds/engine/api/workspacecache"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+func getWorkspaceCachesHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+
+	p, err := project.LoadProject(db, key, c.User)
+	if err != nil {
+		log.Warning("getWorkspaceCachesHandler> Cannot load project %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	caches, err := workspacecache.LoadAll(db, p)
+	if err != nil {
+		log.Warning("getWorkspaceCachesHandler> Cannot load caches of %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, caches, http.StatusOK)
+}
+
+func getWorkspaceCacheHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	cacheKey := vars["cacheKey"]
+
+	p, err := project.LoadProject(db, key, c.User)
+	if err != nil {
+		log.Warning("getWorkspaceCacheHandler> Cannot load project %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	cache, err := workspacecache.Find(db, p, cacheKey, r.URL.Query()["restore"])
+	if err == sdk.ErrWorkspaceCacheNotFound {
+		// Cache miss is not an error
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Warning("getWorkspaceCacheHandler> Cannot find cache %s/%s: %s\n", key, cacheKey, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, cache, http.StatusOK)
+}
+
+func uploadWorkspaceCacheHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	cacheKey := vars["cacheKey"]
+	defer r.Body.Close()
+
+	size, err := strconv.ParseInt(r.Header.Get(sdk.WorkspaceCacheSize), 10, 64)
+	if err != nil {
+		log.Warning("uploadWorkspaceCacheHandler> %s header is invalid: %s\n", sdk.WorkspaceCacheSize, err)
+		WriteError(w, r, sdk.ErrWorkspaceCacheInvalid)
+		return
+	}
+
+	p, err := project.LoadProject(db, key, c.User)
+	if err != nil {
+		log.Warning("uploadWorkspaceCacheHandler> Cannot load project %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	cache := &sdk.WorkspaceCache{
+		Key:    cacheKey,
+		Size:   size,
+		MD5sum: r.Header.Get(sdk.WorkspaceCacheMD5Sum),
+	}
+
+	maxSize, err := workspacecache.LoadQuota(db, p, int64(viper.GetInt("workspace_cache_size"))<<20)
+	if err != nil {
+		log.Warning("uploadWorkspaceCacheHandler> Cannot load cache quota of %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	// Content beyond announced size is refused, Store checks the size is not lower
+	body := http.MaxBytesReader(w, r.Body, size)
+	if err := workspacecache.Store(db, p, cache, body, maxSize); err != nil {
+		log.Warning("uploadWorkspaceCacheHandler> Cannot store cache %s/%s: %s\n", key, cacheKey, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, cache, http.StatusCreated)
+}
+
+func downloadWorkspaceCacheHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	cacheKey := vars["cacheKey"]
+
+	p, err := project.LoadProject(db, key, c.User)
+	if err != nil {
+		log.Warning("downloadWorkspaceCacheHandler> Cannot load project %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	cache, err := workspacecache.Load(db, p, cacheKey)
+	if err != nil {
+		log.Warning("downloadWorkspaceCacheHandler> Cannot load cache %s/%s: %s\n", key, cacheKey, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := workspacecache.Touch(db, cache); err != nil {
+		log.Warning("downloadWorkspaceCacheHandler> Cannot update cache %s/%s: %s\n", key, cacheKey, err)
+	}
+
+	w.Header().Add("Content-Type", "application/octet-stream")
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.tar.gz\"", cache.Key))
+
+	if err := workspacecache.StreamFile(w, *cache); err != nil {
+		log.Warning("downloadWorkspaceCacheHandler> Cannot stream cache %s/%s: %s\n", key, cacheKey, err)
+		WriteError(w, r, err)
+		return
+	}
+}
+
+func deleteWorkspaceCacheHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	cacheKey := vars["cacheKey"]
+
+	p, err := project.LoadProject(db, key, c.User)
+	if err != nil {
+		log.Warning("deleteWorkspaceCacheHandler> Cannot load project %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	cache, err := workspacecache.Load(db, p, cacheKey)
+	if err != nil {
+		log.Warning("deleteWorkspaceCacheHandler> Cannot load cache %s/%s: %s\n", key, cacheKey, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := workspacecache.Delete(db, cache); err != nil {
+		log.Warning("deleteWorkspaceCacheHandler> Cannot delete cache %s/%s: %s\n", key, cacheKey, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func getWorkspaceCacheQuotaHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+
+	p, err := project.LoadProject(db, key, c.User)
+	if err != nil {
+		log.Warning("getWorkspaceCacheQuotaHandler> Cannot load project %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	size, err := workspacecache.LoadQuota(db, p, int64(viper.GetInt("workspace_cache_size"))<<20)
+	if err != nil {
+		log.Warning("getWorkspaceCacheQuotaHandler> Cannot load cache quota of %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, sdk.WorkspaceCacheQuota{Project: p.Key, Quota: size >> 20}, http.StatusOK)
+}
+
+func updateWorkspaceCacheQuotaHandler(w http.ResponseWriter, r *http.Request, db *sql.DB, c *context.Context) {
+	if !c.User.Admin {
+		WriteError(w, r, sdk.ErrForbidden)
+		return
+	}
+
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, r, sdk.ErrWrongRequest)
+		return
+	}
+
+	var quota sdk.WorkspaceCacheQuota
+	if err := json.Unmarshal(data, &quota); err != nil {
+		WriteError(w, r, sdk.ErrWrongRequest)
+		return
+	}
+
+	p, err := project.LoadProject(db, key, c.User)
+	if err != nil {
+		log.Warning("updateWorkspaceCacheQuotaHandler> Cannot load project %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	if err := workspacecache.UpdateQuota(db, p, quota.Quota); err != nil {
+		log.Warning("updateWorkspaceCacheQuotaHandler> Cannot update cache quota of %s: %s\n", key, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	getWorkspaceCacheQuotaHandler(w, r, db, c)
+}
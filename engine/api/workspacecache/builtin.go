@@ -0,0 +1,76 @@
+package workspacecache
+
+import (
+	"database/sql"
+
+	"github.com/ovh/cds/engine/api/action"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// CreateBuiltinActions creates Cache Save and Cache Restore builtin actions if needed
+func CreateBuiltinActions(db *sql.DB) error {
+	save := sdk.NewAction(sdk.CacheSave)
+	save.Type = sdk.BuiltinAction
+	save.Description = `CDS Builtin Action.
+Save given paths of the working directory as a project cache entry,
+to be restored by next builds with Cache Restore.`
+	save.Parameter(sdk.Parameter{
+		Name: "key",
+		Description: `Key of the cache entry, example: {{.cds.application}}-{{hashFiles "glide.lock"}}
+hashFiles computes the sha256 of all files matching given patterns`,
+		Type: sdk.StringParameter})
+	save.Parameter(sdk.Parameter{
+		Name:        "path",
+		Description: "Paths to save, one per line, example: vendor",
+		Type:        sdk.TextParameter})
+	if err := checkBuiltinAction(db, save); err != nil {
+		return err
+	}
+
+	restore := sdk.NewAction(sdk.CacheRestore)
+	restore.Type = sdk.BuiltinAction
+	restore.Description = `CDS Builtin Action.
+Restore a project cache entry saved by Cache Save in the working directory.`
+	restore.Parameter(sdk.Parameter{
+		Name:        "key",
+		Description: `Key of the cache entry, example: {{.cds.application}}-{{hashFiles "glide.lock"}}`,
+		Type:        sdk.StringParameter})
+	restore.Parameter(sdk.Parameter{
+		Name: "restore-keys",
+		Description: `Key prefixes to fall back on if key is not found, one per line.
+The most recent entry matching the first prefix possible is restored, example: {{.cds.application}}-`,
+		Type: sdk.TextParameter})
+	restore.Parameter(sdk.Parameter{
+		Name:        "path",
+		Description: "Directory where cache is extracted, default to working directory",
+		Value:       ".",
+		Type:        sdk.StringParameter})
+	return checkBuiltinAction(db, restore)
+}
+
+func checkBuiltinAction(db *sql.DB, a *sdk.Action) error {
+	var name string
+	query := `SELECT action.name FROM action WHERE action.name = $1`
+
+	err := db.QueryRow(query, a.Name).Scan(&name)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := action.InsertAction(tx, a, true); err != nil {
+		log.Warning("workspacecache.CreateBuiltinActions> Cannot insert %s: %s\n", a.Name, err)
+		return err
+	}
+
+	return tx.Commit()
+}
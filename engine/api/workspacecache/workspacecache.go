@@ -0,0 +1,264 @@
+package workspacecache
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/objectstore"
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+const selectQuery = `SELECT workspace_cache.id, workspace_cache.cache_key, workspace_cache.size, workspace_cache.md5sum,
+	workspace_cache.object_path, workspace_cache.created, workspace_cache.last_used
+	FROM workspace_cache`
+
+// Find returns cache entry key of given project. If it does not exist, the most recent entry
+// whose key starts with one of restoreKeys is returned, restoreKeys being tried in order.
+func Find(db database.Querier, p *sdk.Project, key string, restoreKeys []string) (*sdk.WorkspaceCache, error) {
+	c, err := Load(db, p, key)
+	if err != sdk.ErrWorkspaceCacheNotFound {
+		return c, err
+	}
+
+	for _, prefix := range restoreKeys {
+		if prefix == "" {
+			continue
+		}
+		query := selectQuery + ` WHERE project_id = $1 AND left(cache_key, length($2)) = $2 ORDER BY created DESC LIMIT 1`
+		c, err = scan(db.QueryRow(query, p.ID, prefix), p)
+		if err != sdk.ErrWorkspaceCacheNotFound {
+			return c, err
+		}
+	}
+
+	return nil, sdk.ErrWorkspaceCacheNotFound
+}
+
+// Load returns cache entry key of given project
+func Load(db database.Querier, p *sdk.Project, key string) (*sdk.WorkspaceCache, error) {
+	query := selectQuery + ` WHERE project_id = $1 AND cache_key = $2`
+	return scan(db.QueryRow(query, p.ID, key), p)
+}
+
+// LoadAll returns all cache entries of given project, most recently used first
+func LoadAll(db database.Querier, p *sdk.Project) ([]sdk.WorkspaceCache, error) {
+	query := selectQuery + ` WHERE project_id = $1 ORDER BY last_used DESC`
+	rows, err := db.Query(query, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	caches := []sdk.WorkspaceCache{}
+	for rows.Next() {
+		c, err := scan(rows, p)
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, *c)
+	}
+
+	return caches, nil
+}
+
+func scan(s database.Scanner, p *sdk.Project) (*sdk.WorkspaceCache, error) {
+	c := &sdk.WorkspaceCache{Project: p.Key}
+	var md5sum, objectPath sql.NullString
+	err := s.Scan(&c.ID, &c.Key, &c.Size, &md5sum, &objectPath, &c.Created, &c.LastUsed)
+	if err == sql.ErrNoRows {
+		return nil, sdk.ErrWorkspaceCacheNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if md5sum.Valid {
+		c.MD5sum = md5sum.String
+	}
+	if objectPath.Valid {
+		c.ObjectPath = objectPath.String
+	}
+	return c, nil
+}
+
+// Touch marks given cache entry as used now, so it is the last one to be evicted
+func Touch(db database.Executer, c *sdk.WorkspaceCache) error {
+	query := `UPDATE workspace_cache SET last_used = $1 WHERE id = $2`
+	_, err := db.Exec(query, time.Now(), c.ID)
+	return err
+}
+
+// Store checks content against given size and md5sum, saves it in objectstore then
+// evicts least recently used entries of the project until maxSize is honored.
+// Content is written to a new object, so the previous entry of the key stays valid until
+// the new one is checked and committed.
+func Store(db *sql.DB, p *sdk.Project, c *sdk.WorkspaceCache, content io.Reader, maxSize int64) error {
+	if c.Size > maxSize {
+		return sdk.ErrWorkspaceCacheTooLarge
+	}
+	c.Project = p.Key
+	c.Created = time.Now()
+	c.LastUsed = c.Created
+
+	hash := md5.New()
+	counter := &countingReader{r: io.TeeReader(content, hash)}
+	objectPath, err := objectstore.StoreWorkspaceCache(*c, ioutil.NopCloser(counter))
+	if err != nil {
+		return err
+	}
+	c.ObjectPath = objectPath
+
+	md5sum := hex.EncodeToString(hash.Sum(nil))
+	if counter.n != c.Size || (c.MD5sum != "" && md5sum != c.MD5sum) {
+		log.Warning("workspacecache.Store> %s/%s: got %d bytes (md5 %s), expected %d bytes (md5 %s)\n", p.Key, c.Key, counter.n, md5sum, c.Size, c.MD5sum)
+		deleteObject(*c)
+		return sdk.ErrWorkspaceCacheInvalid
+	}
+	c.MD5sum = md5sum
+
+	previous, err := replace(db, p, c)
+	if err != nil {
+		deleteObject(*c)
+		return err
+	}
+	if previous != nil {
+		deleteObject(*previous)
+	}
+
+	return evict(db, p, c.ID, maxSize)
+}
+
+// replace inserts c in place of the current entry of its key, which is returned if any
+func replace(db *sql.DB, p *sdk.Project, c *sdk.WorkspaceCache) (*sdk.WorkspaceCache, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	previous, err := Load(tx, p, c.Key)
+	if err != nil && err != sdk.ErrWorkspaceCacheNotFound {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM workspace_cache WHERE project_id = $1 AND cache_key = $2`, p.ID, c.Key); err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO workspace_cache (project_id, cache_key, size, md5sum, object_path, created, last_used)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	if err := tx.QueryRow(query, p.ID, c.Key, c.Size, c.MD5sum, c.ObjectPath, c.Created, c.LastUsed).Scan(&c.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return previous, nil
+}
+
+func deleteObject(c sdk.WorkspaceCache) {
+	if err := objectstore.DeleteWorkspaceCache(c); err != nil {
+		log.Warning("workspacecache.Store> Cannot delete cache object %s of %s/%s: %s\n", c.ObjectPath, c.Project, c.Key, err)
+	}
+}
+
+// LoadQuota returns the maximum total size of workspace caches of given project in bytes,
+// defaultSize if the project has no quota of its own
+func LoadQuota(db database.Querier, p *sdk.Project, defaultSize int64) (int64, error) {
+	var size int64
+	err := db.QueryRow(`SELECT size FROM workspace_cache_quota WHERE project_id = $1`, p.ID).Scan(&size)
+	if err == sql.ErrNoRows || (err == nil && size <= 0) {
+		return defaultSize, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return size << 20, nil
+}
+
+// UpdateQuota sets the workspace cache quota of given project, in MB. A zero size
+// removes the quota of the project, so the default one applies.
+func UpdateQuota(db database.Executer, p *sdk.Project, size int64) error {
+	if size < 0 {
+		return sdk.ErrWrongRequest
+	}
+	if _, err := db.Exec(`DELETE FROM workspace_cache_quota WHERE project_id = $1`, p.ID); err != nil {
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+	_, err := db.Exec(`INSERT INTO workspace_cache_quota (project_id, size) VALUES ($1, $2)`, p.ID, size)
+	return err
+}
+
+// evict deletes least recently used entries of given project, except keep,
+// until the total size of its entries is under maxSize
+func evict(db database.QueryExecuter, p *sdk.Project, keep int64, maxSize int64) error {
+	caches, err := LoadAll(db, p)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, c := range caches {
+		total += c.Size
+	}
+
+	// LoadAll returns most recently used first, so walk it backward
+	for i := len(caches) - 1; i >= 0 && total > maxSize; i-- {
+		if caches[i].ID == keep {
+			continue
+		}
+		log.Notice("workspacecache.evict> Evicting %s/%s (%d bytes, last used %s)\n", p.Key, caches[i].Key, caches[i].Size, caches[i].LastUsed)
+		if err := Delete(db, &caches[i]); err != nil {
+			return err
+		}
+		total -= caches[i].Size
+	}
+
+	return nil
+}
+
+// Delete removes cache tarball from objectstore then cache entry from database
+func Delete(db database.Executer, c *sdk.WorkspaceCache) error {
+	err := objectstore.DeleteWorkspaceCache(*c)
+	// If it's 404, it's lost anyway...
+	if err != nil && !strings.Contains(err.Error(), "404") && !strings.Contains(err.Error(), "no such file") {
+		return err
+	}
+
+	_, err = db.Exec(`DELETE FROM workspace_cache WHERE id = $1`, c.ID)
+	return err
+}
+
+// StreamFile writes cache tarball into w
+func StreamFile(w io.Writer, c sdk.WorkspaceCache) error {
+	f, err := objectstore.FetchWorkspaceCache(c)
+	if err != nil {
+		return fmt.Errorf("cannot fetch cache: %s", err)
+	}
+	defer f.Close()
+
+	return objectstore.StreamFile(w, f)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
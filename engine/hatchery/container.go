@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// containerSpec describes a container to run through the docker Engine API
+type containerSpec struct {
+	Name       string
+	Image      string
+	Cmd        []string
+	Env        []string
+	Labels     map[string]string
+	ExtraHosts []string
+	// Network to attach container to, with given aliases
+	Network string
+	Aliases []string
+	// Memory limit in MB, 0 for no limit
+	Memory int64
+	// CPUs limit, 0 for no limit
+	CPUs float64
+	// Disk size limit of container filesystem in MB, 0 for no limit.
+	// It requires a docker storage driver supporting size option.
+	Disk int64
+}
+
+// shortcut to create+start(=run) a container, returns the container ID
+func createAndStartContainer(c *docker.Client, spec containerSpec) (string, error) {
+	log.Debug("createAndStartContainer> Create container %s from %s\n", spec.Name, spec.Image)
+	opts := docker.CreateContainerOptions{
+		Name: spec.Name,
+		Config: &docker.Config{
+			Image:  spec.Image,
+			Cmd:    spec.Cmd,
+			Env:    spec.Env,
+			Labels: spec.Labels,
+		},
+		HostConfig: &docker.HostConfig{
+			ExtraHosts: spec.ExtraHosts,
+		},
+	}
+	if spec.Memory > 0 {
+		opts.HostConfig.Memory = spec.Memory * 1024 * 1024
+	}
+	if spec.CPUs > 0 {
+		opts.HostConfig.CPUPeriod = 100000
+		opts.HostConfig.CPUQuota = int64(spec.CPUs * 100000)
+	}
+	if spec.Disk > 0 {
+		opts.HostConfig.StorageOpt = map[string]string{"size": fmt.Sprintf("%dM", spec.Disk)}
+	}
+	if spec.Network != "" {
+		opts.HostConfig.NetworkMode = spec.Network
+		opts.NetworkingConfig = &docker.NetworkingConfig{
+			EndpointsConfig: map[string]*docker.EndpointConfig{
+				spec.Network: &docker.EndpointConfig{Aliases: spec.Aliases},
+			},
+		}
+	}
+
+	ctr, err := c.CreateContainer(opts)
+	if err != nil {
+		log.Warning("startAndCreateContainer> Unable to create container %s\n", err)
+		return "", err
+	}
+
+	if err := c.StartContainer(ctr.ID, nil); err != nil {
+		log.Warning("startAndCreateContainer> Unable to start container %s\n", err)
+		return ctr.ID, err
+	}
+	return ctr.ID, nil
+}
+
+// containerStatus returns state and health of a container.
+// The docker client does not expose health status, so the healthcheck of the image is run through docker exec.
+func containerStatus(c *docker.Client, ID string) (string, string, error) {
+	ctr, err := c.InspectContainer(ID)
+	if err != nil {
+		return "", "", err
+	}
+	state := ctr.State.Status
+	if state == "" {
+		state = "exited"
+		if ctr.State.Running {
+			state = "running"
+		}
+	}
+
+	if state != "running" || ctr.Config == nil || ctr.Config.Healthcheck == nil || len(ctr.Config.Healthcheck.Test) < 2 {
+		return state, "", nil
+	}
+
+	var cmd []string
+	switch test := ctr.Config.Healthcheck.Test; test[0] {
+	case "CMD":
+		cmd = test[1:]
+	case "CMD-SHELL":
+		cmd = []string{"/bin/sh", "-c", strings.Join(test[1:], " ")}
+	default:
+		return state, "", nil
+	}
+
+	exec, err := c.CreateExec(docker.CreateExecOptions{Container: ID, Cmd: cmd})
+	if err != nil {
+		return "", "", err
+	}
+	if err := c.StartExec(exec.ID, docker.StartExecOptions{}); err != nil {
+		return "", "", err
+	}
+	res, err := c.InspectExec(exec.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if res.Running || res.ExitCode != 0 {
+		return state, "starting", nil
+	}
+	return state, "healthy", nil
+}
+
+// startServices starts service containers required by worker on a dedicated network, and waits for them to be healthy.
+// It returns the name of the network the worker has to join, empty if there is no service.
+func startServices(c *docker.Client, worker string, req []sdk.Requirement) (string, error) {
+	svcs := workerServices(req)
+	if len(svcs) == 0 {
+		return "", nil
+	}
+
+	network := workerNetworkName(worker)
+	if _, err := c.CreateNetwork(docker.CreateNetworkOptions{
+		Name:           network,
+		Driver:         "bridge",
+		CheckDuplicate: true,
+		Labels:         map[string]string{"service_worker": worker},
+	}); err != nil {
+		return "", fmt.Errorf("unable to create network %s: %s", network, err)
+	}
+
+	for _, svc := range svcs {
+		serviceName := serviceContainerName(svc, worker)
+
+		//labels are used to make container cleanup easier. We "link" the service to its worker this way.
+		spec := containerSpec{
+			Name:  serviceName,
+			Image: svc.Image,
+			Env:   svc.Env,
+			Labels: map[string]string{
+				"service_worker": worker,
+				"service_name":   serviceName,
+			},
+			Network: network,
+			Aliases: []string{svc.Alias},
+		}
+		id, err := createAndStartContainer(c, spec)
+		if err != nil {
+			removeServices(c, worker)
+			return "", fmt.Errorf("unable to start service %s: %s", svc.Alias, err)
+		}
+
+		if err := waitHealthy(serviceName, serviceStartTimeout, func() (string, string, error) { return containerStatus(c, id) }); err != nil {
+			removeServices(c, worker)
+			return "", err
+		}
+	}
+
+	return network, nil
+}
+
+// removeServices removes service containers and network of a worker
+func removeServices(c *docker.Client, worker string) {
+	containers, err := c.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: map[string][]string{"label": {"service_worker=" + worker}},
+	})
+	if err != nil {
+		log.Warning("removeServices> Cannot list services of %s: %s", worker, err)
+	}
+	for _, ctr := range containers {
+		log.Debug("Remove service container : %s", ctr.Names[0])
+		c.RemoveContainer(docker.RemoveContainerOptions{
+			ID:            ctr.ID,
+			RemoveVolumes: true,
+			Force:         true,
+		})
+	}
+
+	networks, err := c.FilteredListNetworks(docker.NetworkFilterOpts{"label": {"service_worker=" + worker: true}})
+	if err != nil {
+		log.Warning("removeServices> Cannot list networks of %s: %s", worker, err)
+		return
+	}
+	for _, n := range networks {
+		if err := c.RemoveNetwork(n.ID); err != nil {
+			log.Warning("removeServices> Cannot remove network %s: %s", n.Name, err)
+		}
+	}
+}
+
+// pullProgress is a message of the docker pull JSON stream
+type pullProgress struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Progress string `json:"progress"`
+	Error    string `json:"error"`
+}
+
+// pullImage pulls image, logging progress of each layer
+func pullImage(c *docker.Client, image string) error {
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		defer r.Close()
+		dec := json.NewDecoder(r)
+		status := map[string]string{}
+		for {
+			var p pullProgress
+			if err := dec.Decode(&p); err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				done <- err
+				return
+			}
+			if p.Error != "" {
+				done <- fmt.Errorf("%s", p.Error)
+				return
+			}
+			// Only log status changes, progress bars are logged at info level
+			if status[p.ID] != p.Status {
+				status[p.ID] = p.Status
+				log.Notice("pullImage> %s %s %s\n", image, p.ID, p.Status)
+			} else if p.Progress != "" {
+				log.Info("pullImage> %s %s %s\n", image, p.ID, p.Progress)
+			}
+		}
+	}()
+
+	log.Notice("pullImage> pulling image %s\n", image)
+	err := c.PullImage(docker.PullImageOptions{
+		Repository:    image,
+		OutputStream:  w,
+		RawJSONStream: true,
+	}, docker.AuthConfiguration{})
+	w.Close()
+	if errStream := <-done; err == nil {
+		err = errStream
+	}
+	return err
+}
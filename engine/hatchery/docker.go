@@ -1,15 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"os"
-	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/fsouza/go-dockerclient"
 	"github.com/spf13/viper"
 
 	"github.com/ovh/cds/engine/api/hatchery"
@@ -18,16 +20,90 @@ import (
 )
 
 // HatcheryDocker spawns instances of worker model with type 'Docker'
-// by directly using available docker daemon
+// by directly using available docker daemon through its Engine API.
+// Containers are labeled with hatchery name and worker model,
+// so state is rebuilt from the docker daemon when hatchery restarts.
 type HatcheryDocker struct {
-	sync.Mutex
-	workers map[string]*exec.Cmd
+	client *docker.Client
+	hatch  *hatchery.Hatchery
 
-	hatch *hatchery.Hatchery
+	defaultLimits dockerLimits
+	modelLimits   map[string]dockerLimits
+
+	// Exits of containers removed by the hatchery itself, or of workers
+	// which registered on CDS, are not spawn errors
+	mu         sync.Mutex
+	removed    map[string]bool
+	registered map[string]bool
+}
+
+// dockerLimits are resources allowed to a worker container
+type dockerLimits struct {
+	// Memory in MB
+	Memory int64
+	CPUs   float64
 }
 
 // ParseConfig for docker mode
 func (hd *HatcheryDocker) ParseConfig() {
+	hd.defaultLimits = dockerLimits{
+		Memory: int64(viper.GetInt("docker-memory")),
+		CPUs:   viper.GetFloat64("docker-cpus"),
+	}
+
+	var err error
+	hd.modelLimits, err = parseDockerLimits(viper.GetString("docker-model-limits"))
+	if err != nil {
+		sdk.Exit("Invalid docker-model-limits: %s\n", err)
+	}
+}
+
+// parseDockerLimits reads per model limits such as "java=4096:2,tiny=256:0.5", memory being in MB
+func parseDockerLimits(s string) (map[string]dockerLimits, error) {
+	limits := map[string]dockerLimits{}
+	for _, l := range strings.Split(s, ",") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s should be model=memory:cpus", l)
+		}
+		res := strings.SplitN(kv[1], ":", 2)
+
+		var lim dockerLimits
+		var err error
+		if res[0] != "" {
+			if lim.Memory, err = strconv.ParseInt(res[0], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid memory for %s: %s", kv[0], err)
+			}
+		}
+		if len(res) == 2 && res[1] != "" {
+			if lim.CPUs, err = strconv.ParseFloat(res[1], 64); err != nil {
+				return nil, fmt.Errorf("invalid cpus for %s: %s", kv[0], err)
+			}
+		}
+		limits[kv[0]] = lim
+	}
+	return limits, nil
+}
+
+// limits returns resources allowed to workers of given model.
+// Resources declared on the model or required by the action take precedence over configured limits.
+func (hd *HatcheryDocker) limits(model *sdk.Model, req []sdk.Requirement) dockerLimits {
+	l, ok := hd.modelLimits[model.Name]
+	if !ok {
+		l = hd.defaultLimits
+	}
+	memory, cpus := workerResources(model, req)
+	if memory > 0 {
+		l.Memory = memory
+	}
+	if cpus > 0 {
+		l.CPUs = cpus
+	}
+	return l
 }
 
 // ID must returns hatchery id
@@ -55,28 +131,24 @@ func (hd *HatcheryDocker) Hatchery() *hatchery.Hatchery {
 }
 
 // CanSpawn return wether or not hatchery can spawn model
-// requirement are not supported
+// only service and resource requirements are supported
 func (hd *HatcheryDocker) CanSpawn(model *sdk.Model, req []sdk.Requirement) bool {
 	if model.Type != sdk.Docker {
 		return false
 	}
-	if len(req) > 0 {
-		return false
-	}
-	return true
+	return supportedRequirements(req, sdk.ServiceRequirement, sdk.MemoryRequirement, sdk.CPURequirement)
 }
 
-// Init starts cleaning routine
-// and check hatchery can run in docker mode with given configuration
+// Init connects to docker daemon, starts cleaning routine
+// and watches workers left running by a previous instance of the hatchery
 func (hd *HatcheryDocker) Init() error {
-	hd.workers = make(map[string]*exec.Cmd)
-
-	ok, err := checkRequirement(sdk.Requirement{Type: sdk.BinaryRequirement, Value: "docker"})
+	var err error
+	hd.client, err = docker.NewClient(viper.GetString("docker-host"))
 	if err != nil {
-		return err
+		return fmt.Errorf("Cannot connect to docker daemon: %s", err)
 	}
-	if !ok {
-		return fmt.Errorf("Docker not found on this host")
+	if err := hd.client.Ping(); err != nil {
+		return fmt.Errorf("Cannot ping docker daemon: %s", err)
 	}
 
 	// Register without declaring model
@@ -94,78 +166,29 @@ func (hd *HatcheryDocker) Init() error {
 		log.Warning("Cannot register hatchery: %s\n", err)
 	}
 
-	go hd.workerIndexCleanupRoutine()
-	go hd.killAwolWorkerRoutine()
-	return nil
-}
-
-// Refresh fetch worker model status from API
-// and spawn/delete workers if needed
-func (hd *HatcheryDocker) Refresh() error {
-
-	wms, err := sdk.GetWorkerModelStatus()
+	containers, err := hd.workerContainers(nil, true)
 	if err != nil {
-		return err
+		return fmt.Errorf("Cannot list workers: %s", err)
 	}
-
-	for _, ms := range wms {
-
-		if ms.CurrentCount == ms.WantedCount {
-			// ok, do nothing
-			continue
-		}
-
-		m, err := sdk.GetWorkerModel(ms.ModelName)
-		if err != nil {
-			return fmt.Errorf("cannot get model named '%s' (%s)", ms.ModelName, err)
-		}
-		// if model is not of docker type, ignore it
-		if m.Type != sdk.Docker {
-			continue
-		}
-
-		if ms.CurrentCount < ms.WantedCount {
-			diff := ms.WantedCount - ms.CurrentCount
-			log.Notice("I got to spawn %d %s worker !\n", diff, ms.ModelName)
-			for i := 0; i < int(diff); i++ {
-				err = hd.SpawnWorker(m, ms.Requirements)
-				if err != nil {
-					return err
-				}
-			}
-			continue
-		}
-
-		if ms.CurrentCount > ms.WantedCount {
-			diff := ms.CurrentCount - ms.WantedCount
-			log.Notice("I got to kill %d %s worker !\n", diff, ms.ModelName)
-			err = killWorker(hd, m)
-			if err != nil {
-				return err
-			}
-			continue
-		}
-
+	for _, c := range containers {
+		log.Notice("HatcheryDocker> Found worker %s from previous run\n", c.Labels["worker_name"])
+		go hd.waitWorker(c.ID, c.Labels)
 	}
 
+	go hd.killAwolWorkerRoutine()
 	return nil
-
 }
 
-func (hd *HatcheryDocker) workerIndexCleanupRoutine() {
-	for {
-		time.Sleep(1 * time.Second)
-		hd.Lock()
-
-		for name, cmd := range hd.workers {
-			if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
-				log.Info("HatcheryDocker.IndexCleanup: removing exited %s\n", name)
-				delete(hd.workers, name)
-				break
-			}
-		}
-		hd.Unlock()
+// workerContainers lists worker containers spawned by this hatchery, of given model if any
+func (hd *HatcheryDocker) workerContainers(model *sdk.Model, all bool) ([]docker.APIContainers, error) {
+	filters := []string{"hatchery=" + hd.hatch.Name}
+	if model != nil {
+		filters = append(filters, fmt.Sprintf("worker_model=%d", model.ID))
 	}
+	return hd.client.ListContainers(docker.ListContainersOptions{
+		All:     all,
+		Filters: map[string][]string{"label": filters},
+	})
 }
 
 func (hd *HatcheryDocker) killAwolWorkerRoutine() {
@@ -183,36 +206,27 @@ func (hd *HatcheryDocker) killAwolWorker() {
 		return
 	}
 
-	hd.Lock()
-	defer hd.Unlock()
-	log.Info("Hatchery has %d processes in index\n", len(hd.workers))
+	containers, err := hd.workerContainers(nil, false)
+	if err != nil {
+		log.Warning("HatcheryDocker.killAwolWorker: cannot list containers: %s\n", err)
+		return
+	}
+	log.Info("Hatchery has %d containers running\n", len(containers))
 
-	for name, cmd := range hd.workers {
+	for _, c := range containers {
+		name := c.Labels["worker_name"]
 		for _, n := range apiworkers {
+			if n.Name == name {
+				hd.setRegistered(name)
+			}
 			// If worker is disabled, kill it
 			if n.Name == name && n.Status == sdk.StatusDisabled {
 				log.Info("Worker %s is disabled. Kill it with fire !\n", name)
-
-				// if process not killed, kill it
-				if cmd.ProcessState == nil || (cmd.ProcessState != nil && !cmd.ProcessState.Exited()) {
-					err = cmd.Process.Kill()
-					if err != nil {
-						log.Warning("HatcheryDocker.killAwolWorker: cannot kill %s: %s\n", name, err)
-					}
+				if err := hd.removeWorker(c.ID, name); err != nil {
+					log.Warning("HatcheryDocker.killAwolWorker: cannot rm container %s: %s\n", name, err)
+					continue
 				}
-
-				// Remove container
-				go func() {
-					cmd := exec.Command("docker", "rm", "-f", name)
-					err = cmd.Run()
-					if err != nil {
-						log.Warning("HatcheryDocker.killAwolWorker: cannot rm container %s: %s\n", name, err)
-					}
-				}()
-
-				delete(hd.workers, name)
 				log.Notice("HatcheryDocker.killAwolWorker> Killed disabled worker %s\n", name)
-				return
 			}
 		}
 	}
@@ -221,18 +235,16 @@ func (hd *HatcheryDocker) killAwolWorker() {
 // WorkerStarted returns the number of instances of given model started but
 // not necessarily register on CDS yet
 func (hd *HatcheryDocker) WorkerStarted(model *sdk.Model) int {
-	var x int
-	for name := range hd.workers {
-		if strings.Contains(name, model.Name) {
-			x++
-		}
+	containers, err := hd.workerContainers(model, false)
+	if err != nil {
+		log.Warning("HatcheryDocker.WorkerStarted> cannot list containers: %s\n", err)
+		return 0
 	}
-
-	return x
+	return len(containers)
 }
 
 // SpawnWorker starts a new worker in a docker container locally
-func (hd *HatcheryDocker) SpawnWorker(wm *sdk.Model, req []sdk.Requirement) error {
+func (hd *HatcheryDocker) SpawnWorker(wm *sdk.Model, req []sdk.Requirement, actionBuildID int64) error {
 	var err error
 	uk, err = sdk.GenerateWorkerKey(sdk.FirstUseExpire)
 	if err != nil {
@@ -243,77 +255,180 @@ func (hd *HatcheryDocker) SpawnWorker(wm *sdk.Model, req []sdk.Requirement) erro
 		return fmt.Errorf("cannot handle %s worker model", wm.Type)
 	}
 
-	if len(hd.workers) >= maxWorker {
+	containers, err := hd.workerContainers(nil, false)
+	if err != nil {
+		return fmt.Errorf("cannot list containers: %s", err)
+	}
+	if len(containers) >= maxWorker {
 		return fmt.Errorf("Max capacity reached (%d)", maxWorker)
 	}
 
+	if _, err := hd.client.InspectImage(wm.Image); err == docker.ErrNoSuchImage {
+		if err := pullImage(hd.client, wm.Image); err != nil {
+			return fmt.Errorf("cannot pull %s: %s", wm.Image, err)
+		}
+	}
+
 	name, err := randSeq(16)
 	if err != nil {
 		return fmt.Errorf("cannot create worker name: %s", err)
 	}
 	name = wm.Name + "-" + name
 
-	addhost := viper.GetString("docker-add-host")
-
-	var args []string
-	args = append(args, "run", "--rm", "-a", "STDOUT", "-a", "STDERR")
-	args = append(args, fmt.Sprintf("--name=%s", name))
-	args = append(args, "-e", "CDS_SINGLE_USE=1")
-	args = append(args, "-e", fmt.Sprintf("CDS_API=%s", sdk.Host))
-	args = append(args, "-e", fmt.Sprintf("CDS_NAME=%s", name))
-	args = append(args, "-e", fmt.Sprintf("CDS_KEY=%s", uk))
-	args = append(args, "-e", fmt.Sprintf("CDS_MODEL=%d", wm.ID))
-	args = append(args, "-e", fmt.Sprintf("CDS_HATCHERY=%d", hd.hatch.ID))
-	if addhost != "" {
-		args = append(args, fmt.Sprintf("--add-host=%s", addhost))
+	// Start services on a private network shared with the worker
+	network, err := startServices(hd.client, name, req)
+	if err != nil {
+		return err
 	}
-	args = append(args, wm.Image)
-	args = append(args, "sh", "-c", fmt.Sprintf("rm -f worker && echo 'Download worker' && curl %s/download/worker/`uname -m` -o worker && echo 'chmod worker' && chmod +x worker && echo 'starting worker' && ./worker", sdk.Host))
 
-	cmd := exec.Command("docker", args...)
-	log.Debug("Running %s\n", cmd.Args)
+	limits := hd.limits(wm, req)
+	spec := containerSpec{
+		Name:  name,
+		Image: wm.Image,
+		Cmd:   []string{"sh", "-c", fmt.Sprintf("rm -f worker && echo 'Download worker' && curl %s/download/worker/`uname -m` -o worker && echo 'chmod worker' && chmod +x worker && echo 'starting worker' && ./worker", sdk.Host)},
+		Env: []string{
+			"CDS_SINGLE_USE=1",
+			fmt.Sprintf("CDS_API=%s", sdk.Host),
+			fmt.Sprintf("CDS_NAME=%s", name),
+			fmt.Sprintf("CDS_KEY=%s", uk),
+			fmt.Sprintf("CDS_MODEL=%d", wm.ID),
+			fmt.Sprintf("CDS_HATCHERY=%d", hd.hatch.ID),
+			fmt.Sprintf("CDS_ACTION_BUILD=%d", actionBuildID),
+			fmt.Sprintf("CDS_SPAWNED_AT=%d", time.Now().Unix()),
+		},
+		Labels: map[string]string{
+			"hatchery":          hd.hatch.Name,
+			"worker_model":      strconv.FormatInt(wm.ID, 10),
+			"worker_model_name": wm.Name,
+			"worker_name":       name,
+			"action_build":      strconv.FormatInt(actionBuildID, 10),
+		},
+		Network: network,
+		Memory:  limits.Memory,
+		CPUs:    limits.CPUs,
+		Disk:    wm.Disk,
+	}
+	if addhost := viper.GetString("docker-add-host"); addhost != "" {
+		spec.ExtraHosts = []string{addhost}
+	}
 
-	err = cmd.Start()
+	id, err := createAndStartContainer(hd.client, spec)
 	if err != nil {
+		if id != "" {
+			hd.client.RemoveContainer(docker.RemoveContainerOptions{ID: id, Force: true})
+		}
+		removeServices(hd.client, name)
 		return err
 	}
-	hd.Lock()
-	hd.workers[name] = cmd
-	hd.Unlock()
 
-	// Wait in a goroutine so that when process exits, Wait() update cmd.ProcessState
-	// ProcessState is then checked in nextAvailableLocalID
-	go func() {
-		cmd.Wait()
-	}()
+	go hd.waitWorker(id, spec.Labels)
 
 	// Do not spam docker daemon
 	time.Sleep(2 * time.Second)
 	return nil
 }
 
+// waitWorker waits for worker container to exit, reports non zero exit codes of workers which did not register
+// as spawn errors, then removes the container and its services
+func (hd *HatcheryDocker) waitWorker(id string, labels map[string]string) {
+	name := labels["worker_name"]
+	code, err := hd.client.WaitContainer(id)
+	if err != nil {
+		log.Warning("HatcheryDocker.waitWorker> cannot wait %s: %s\n", name, err)
+	}
+
+	if err == nil && code != 0 && !hd.exitExpected(id, name) {
+		var out bytes.Buffer
+		hd.client.Logs(docker.LogsOptions{
+			Container:    id,
+			OutputStream: &out,
+			ErrorStream:  &out,
+			Stdout:       true,
+			Stderr:       true,
+			Tail:         "20",
+		})
+		m := &sdk.Model{Name: labels["worker_model_name"]}
+		m.ID, _ = strconv.ParseInt(labels["worker_model"], 10, 64)
+		reportSpawnError(hd, m, fmt.Errorf("worker %s exited with code %d: %s", name, code, strings.TrimSpace(out.String())))
+	}
+
+	if err := hd.removeWorker(id, name); err != nil {
+		log.Warning("HatcheryDocker.waitWorker> cannot rm container %s: %s\n", name, err)
+	}
+
+	hd.mu.Lock()
+	delete(hd.removed, id)
+	delete(hd.registered, name)
+	hd.mu.Unlock()
+}
+
+// exitExpected returns true when the container has been removed by the hatchery,
+// or when its worker has been registered on CDS, now or before
+func (hd *HatcheryDocker) exitExpected(id, name string) bool {
+	hd.mu.Lock()
+	expected := hd.removed[id] || hd.registered[name]
+	hd.mu.Unlock()
+	if expected {
+		return true
+	}
+
+	workers, err := sdk.GetWorkers()
+	if err != nil {
+		log.Warning("HatcheryDocker.exitExpected> cannot get workers: %s\n", err)
+		return false
+	}
+	for _, w := range workers {
+		if w.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (hd *HatcheryDocker) setRegistered(name string) {
+	hd.mu.Lock()
+	if hd.registered == nil {
+		hd.registered = map[string]bool{}
+	}
+	hd.registered[name] = true
+	hd.mu.Unlock()
+}
+
+// removeWorker removes worker container and its services
+func (hd *HatcheryDocker) removeWorker(id, name string) error {
+	hd.mu.Lock()
+	if hd.removed == nil {
+		hd.removed = map[string]bool{}
+	}
+	hd.removed[id] = true
+	hd.mu.Unlock()
+
+	err := hd.client.RemoveContainer(docker.RemoveContainerOptions{
+		ID:            id,
+		RemoveVolumes: true,
+		Force:         true,
+	})
+	if _, ok := err.(*docker.NoSuchContainer); ok {
+		err = nil
+	}
+	removeServices(hd.client, name)
+	return err
+}
+
 // KillWorker stops a worker locally
 func (hd *HatcheryDocker) KillWorker(worker sdk.Worker) error {
-	hd.Lock()
-	defer hd.Unlock()
-
-	for name, cmd := range hd.workers {
-		if worker.Name == name {
-			log.Info("HatcheryDocker.KillWorker> %s\n", name)
-			err := cmd.Process.Kill()
-			if err != nil {
-				return err
-			}
-
-			// Remove container
-			cmd := exec.Command("docker", "rm", "-f", name)
-			err = cmd.Run()
-			if err != nil {
-				return fmt.Errorf("HatcheryDocker.KillWorker: cannot rm container %s: %s\n", name, err)
-			}
+	containers, err := hd.client.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: map[string][]string{"label": {"hatchery=" + hd.hatch.Name, "worker_name=" + worker.Name}},
+	})
+	if err != nil {
+		return err
+	}
 
-			delete(hd.workers, worker.Name)
-			return nil
+	for _, c := range containers {
+		log.Info("HatcheryDocker.KillWorker> %s\n", worker.Name)
+		if err := hd.removeWorker(c.ID, worker.Name); err != nil {
+			return fmt.Errorf("HatcheryDocker.KillWorker: cannot rm container %s: %s\n", worker.Name, err)
 		}
 	}
 
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDockerLimits(t *testing.T) {
+	limits, err := parseDockerLimits("java=4096:2, tiny=256:0.5,nocpu=512,nomem=:1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]dockerLimits{
+		"java":  {Memory: 4096, CPUs: 2},
+		"tiny":  {Memory: 256, CPUs: 0.5},
+		"nocpu": {Memory: 512},
+		"nomem": {CPUs: 1},
+	}, limits)
+
+	limits, err = parseDockerLimits("")
+	assert.NoError(t, err)
+	assert.Empty(t, limits)
+
+	_, err = parseDockerLimits("java")
+	assert.Error(t, err)
+	_, err = parseDockerLimits("java=lots")
+	assert.Error(t, err)
+}
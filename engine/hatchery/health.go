@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// registrations watches workers started by the hatchery which never register on engine
+var registrations = newRegistrationWatcher(10 * time.Minute)
+
+// registrationWatcher detects models whose workers are started but do not register on engine
+type registrationWatcher struct {
+	timeout time.Duration
+	mutex   sync.Mutex
+	pending map[int64]pendingRegistration
+}
+
+type pendingRegistration struct {
+	since      time.Time
+	registered int
+}
+
+func newRegistrationWatcher(timeout time.Duration) *registrationWatcher {
+	return &registrationWatcher{
+		timeout: timeout,
+		pending: map[int64]pendingRegistration{},
+	}
+}
+
+// check returns true when some workers of model have been started for longer than timeout
+// without any new worker registering on engine meanwhile
+func (r *registrationWatcher) check(modelID int64, started, registered int, now time.Time) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if started <= registered {
+		delete(r.pending, modelID)
+		return false
+	}
+
+	p, ok := r.pending[modelID]
+	if !ok || registered > p.registered {
+		r.pending[modelID] = pendingRegistration{since: now, registered: registered}
+		return false
+	}
+
+	if now.Sub(p.since) < r.timeout {
+		return false
+	}
+
+	// Report again after another timeout
+	r.pending[modelID] = pendingRegistration{since: now, registered: registered}
+	return true
+}
+
+// registeredWorkers returns the number of workers of given model spawned by hatchery and registered on engine
+func registeredWorkers(h HatcheryMode, model *sdk.Model, workers []sdk.Worker) int {
+	var n int
+	for _, w := range workers {
+		if w.Model == model.ID && w.HatcheryID != 0 && w.HatcheryID == h.ID() && w.Status != sdk.StatusDisabled {
+			n++
+		}
+	}
+	return n
+}
+
+// checkRegistrations reports a spawn error when workers of model are started but never register
+func checkRegistrations(h HatcheryMode, model *sdk.Model, workers []sdk.Worker) {
+	started := h.WorkerStarted(model)
+	registered := registeredWorkers(h, model, workers)
+	if registrations.check(model.ID, started, registered, time.Now()) {
+		reportSpawnError(h, model, fmt.Errorf("%d workers started but not registered after %s", started-registered, registrations.timeout))
+	}
+}
+
+// reportSpawnError is called when a worker of given model could not be started,
+// either when spawning it or when it exited before doing its job.
+// Engine tracks these errors and marks the model unhealthy when it keeps failing.
+func reportSpawnError(h HatcheryMode, model *sdk.Model, err error) {
+	log.Warning("Cannot spawn %s: %s\n", model.Name, err)
+
+	if h.Hatchery() == nil || h.ID() == 0 {
+		return
+	}
+	e := sdk.SpawnError{
+		HatcheryID:   h.ID(),
+		HatcheryName: h.Hatchery().Name,
+		Message:      err.Error(),
+	}
+	if err := sdk.ReportSpawnError(model.ID, e); err != nil {
+		log.Warning("reportSpawnError> Cannot report error of %s: %s\n", model.Name, err)
+	}
+}
+
+// spawnValidationWorker starts a worker of given model if none is started yet.
+// Worker validates capabilities of its model when registering.
+func spawnValidationWorker(h HatcheryMode, modelName string) error {
+	m, err := sdk.GetWorkerModel(modelName)
+	if err != nil {
+		return err
+	}
+	if !h.CanSpawn(m, nil) || !m.Healthy(time.Now()) || h.WorkerStarted(m) > 0 {
+		return nil
+	}
+
+	log.Notice("Starting a worker to validate model %s\n", m.Name)
+	if err := h.SpawnWorker(m, nil, 0); err != nil {
+		reportSpawnError(h, m, err)
+		return err
+	}
+	return nil
+}
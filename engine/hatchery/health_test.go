@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationWatcher(t *testing.T) {
+	r := newRegistrationWatcher(10 * time.Minute)
+	now := time.Now()
+
+	// Every started worker registered
+	assert.False(t, r.check(1, 2, 2, now))
+
+	// Pending workers are reported once timeout is reached
+	assert.False(t, r.check(1, 3, 2, now))
+	assert.False(t, r.check(1, 3, 2, now.Add(5*time.Minute)))
+	assert.True(t, r.check(1, 3, 2, now.Add(10*time.Minute)))
+	assert.False(t, r.check(1, 3, 2, now.Add(11*time.Minute)), "report again only after another timeout")
+
+	// A new registration restarts the watch
+	assert.False(t, r.check(1, 4, 3, now.Add(15*time.Minute)))
+	assert.False(t, r.check(1, 4, 3, now.Add(24*time.Minute)))
+	assert.True(t, r.check(1, 4, 3, now.Add(25*time.Minute)))
+
+	// All workers registered
+	assert.False(t, r.check(1, 3, 3, now.Add(40*time.Minute)))
+	assert.False(t, r.check(1, 4, 3, now.Add(41*time.Minute)))
+}
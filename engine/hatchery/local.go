@@ -62,12 +62,6 @@ func (h *HatcheryLocal) CanSpawn(model *sdk.Model, req []sdk.Requirement) bool {
 	return true
 }
 
-// Refresh retrieves worker models status from API
-// and tries to act if needed
-func (h *HatcheryLocal) Refresh() error {
-	return nil
-}
-
 // KillWorker kill a local process
 func (h *HatcheryLocal) KillWorker(worker sdk.Worker) error {
 	for name, cmd := range h.workers {
@@ -81,7 +75,7 @@ func (h *HatcheryLocal) KillWorker(worker sdk.Worker) error {
 }
 
 // SpawnWorker starts a new worker process
-func (h *HatcheryLocal) SpawnWorker(wm *sdk.Model, req []sdk.Requirement) error {
+func (h *HatcheryLocal) SpawnWorker(wm *sdk.Model, req []sdk.Requirement, actionBuildID int64) error {
 	var err error
 	uk, err = sdk.GenerateWorkerKey(sdk.FirstUseExpire)
 	if err != nil {
@@ -102,6 +96,10 @@ func (h *HatcheryLocal) SpawnWorker(wm *sdk.Model, req []sdk.Requirement) error
 	args = append(args, fmt.Sprintf("--name=%s", wName))
 	args = append(args, fmt.Sprintf("--hatchery=%d", h.hatch.ID))
 	args = append(args, "--single-use")
+	if actionBuildID != 0 {
+		args = append(args, fmt.Sprintf("--action-build=%d", actionBuildID))
+		args = append(args, fmt.Sprintf("--spawned-at=%d", time.Now().Unix()))
+	}
 
 	cmd := exec.Command("worker", args...)
 
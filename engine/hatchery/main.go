@@ -18,9 +18,8 @@ import (
 type HatcheryMode interface {
 	ParseConfig()
 	Init() error
-	Refresh() error
 	KillWorker(worker sdk.Worker) error
-	SpawnWorker(model *sdk.Model, req []sdk.Requirement) error
+	SpawnWorker(model *sdk.Model, req []sdk.Requirement, actionBuildID int64) error
 	CanSpawn(model *sdk.Model, req []sdk.Requirement) bool
 	WorkerStarted(model *sdk.Model) int
 	SetWorkerModelID(int64)
@@ -63,8 +62,23 @@ func init() {
 	flags.String("docker-add-host", "", "Start worker with a custom host-to-IP mapping (host:ip)")
 	viper.BindPFlag("docker-add-host", flags.Lookup("docker-add-host"))
 
+	flags.String("docker-host", "unix:///var/run/docker.sock", "Docker Engine API endpoint")
+	viper.BindPFlag("docker-host", flags.Lookup("docker-host"))
+
+	flags.Int("docker-memory", 0, "Memory limit of worker containers in MB, 0 for no limit")
+	viper.BindPFlag("docker-memory", flags.Lookup("docker-memory"))
+
+	flags.Float64("docker-cpus", 0, "CPU limit of worker containers, 0 for no limit")
+	viper.BindPFlag("docker-cpus", flags.Lookup("docker-cpus"))
+
+	flags.String("docker-model-limits", "", "Per worker model memory (MB) and CPU limits (model=memory:cpus,...)")
+	viper.BindPFlag("docker-model-limits", flags.Lookup("docker-model-limits"))
+
 	flags.Int("provision", 0, "Allowed worker model provisioning")
 	viper.BindPFlag("provision", flags.Lookup("provision"))
+
+	flags.Int("worker-registration-timeout", 600, "Seconds given to started workers to register before reporting their model as failing")
+	viper.BindPFlag("worker-registration-timeout", flags.Lookup("worker-registration-timeout"))
 }
 
 func main() {
@@ -72,6 +86,7 @@ func main() {
 
 	cmd.Execute()
 	h := parseConfig()
+	registrations.timeout = time.Duration(viper.GetInt("worker-registration-timeout")) * time.Second
 
 	if err := h.Init(); err != nil {
 		log.Critical("Init error: %s\n", err)
@@ -94,11 +109,36 @@ func hatcheryRoutine(h HatcheryMode) error {
 		return err
 	}
 
+	workers, err := sdk.GetWorkers()
+	if err != nil {
+		return err
+	}
+	idleWorkers.update(workers, time.Now())
+
+	// Drained hatchery leaves once all its workers are gone
+	if h.Hatchery().Draining && !hasWorkers(h, workers) {
+		if err := unregister(h.Hatchery()); err != nil {
+			return err
+		}
+		log.Notice("Hatchery is drained, exiting\n")
+		os.Exit(0)
+	}
+
 	provision := int64(viper.GetInt("provision"))
 
 	for _, ms := range wms {
-		// Provisionning
-		ms.WantedCount += provision
+		// Provisionning, unless engine manages a pool for the model
+		if ms.Pool == nil {
+			ms.WantedCount += provision
+		}
+
+		// Start a worker to validate a new or updated model
+		if ms.NeedValidation && ms.WantedCount == 0 && ms.CurrentCount == 0 && !h.Hatchery().Draining {
+			if err := spawnValidationWorker(h, ms.ModelName); err != nil {
+				log.Warning("Cannot validate %s: %s\n", ms.ModelName, err)
+			}
+			continue
+		}
 
 		if ms.CurrentCount == ms.WantedCount {
 			// ok, do nothing
@@ -113,19 +153,43 @@ func hatcheryRoutine(h HatcheryMode) error {
 			continue
 		}
 
+		checkRegistrations(h, m, workers)
+
 		if ms.CurrentCount < ms.WantedCount {
+			// Draining hatchery lets its workers finish their jobs, without spawning new ones
+			if h.Hatchery().Draining {
+				continue
+			}
+
+			// Model keeps failing, back off
+			if !m.Healthy(time.Now()) {
+				log.Info("%s is unhealthy until %s: %s\n", m.Name, m.UnhealthyUntil.Format(time.RFC3339), m.LastSpawnError)
+				continue
+			}
+
 			diff := ms.WantedCount - ms.CurrentCount
 			// Check the number of worker started by hatchery
-			if ms.WantedCount < int64(h.WorkerStarted(m))-ms.BuildingCount {
+			started := h.WorkerStarted(m)
+			if ms.WantedCount < int64(started)-ms.BuildingCount {
 				// Ok so they are starting...
-				log.Notice("%d wanted, but %d (%d building) %s workers started already...\n", ms.WantedCount, h.WorkerStarted(m), ms.BuildingCount, ms.ModelName)
+				log.Notice("%d wanted, but %d (%d building) %s workers started already...\n", ms.WantedCount, started, ms.BuildingCount, ms.ModelName)
 				continue
 			}
-			log.Notice("I got to spawn %d %s worker ! (%d/%d)\n", diff, ms.ModelName, ms.CurrentCount, ms.WantedCount)
+			// Do not exceed the pool of the model
+			if room := poolRoom(ms, started); room >= 0 && diff > room {
+				log.Notice("%s pool is limited to %d workers, %d started\n", ms.ModelName, ms.Pool.MaxTotal, started)
+				diff = room
+			}
+			// Claim workers to spawn, other hatcheries may already spawn workers for these jobs
+			jobs := reserveWorkers(h, ms, diff)
+			if len(jobs) == 0 {
+				continue
+			}
+			log.Notice("I got to spawn %d %s worker ! (%d/%d)\n", len(jobs), ms.ModelName, ms.CurrentCount, ms.WantedCount)
 
-			for i := 0; i < int(diff); i++ {
-				if err := h.SpawnWorker(m, ms.Requirements); err != nil {
-					log.Warning("Cannot spawn %s: %s\n", ms.ModelName, err)
+			for _, job := range jobs {
+				if err := h.SpawnWorker(m, jobRequirements(ms, job), job); err != nil {
+					reportSpawnError(h, m, err)
 					continue
 				}
 			}
@@ -134,11 +198,16 @@ func hatcheryRoutine(h HatcheryMode) error {
 
 		if ms.CurrentCount > ms.WantedCount {
 			diff := ms.CurrentCount - ms.WantedCount
-			if int(diff) < viper.GetInt("provision") { // Chill...
+			if ms.Pool == nil && int(diff) < viper.GetInt("provision") { // Chill...
 				continue
 			}
+			// Idle workers of a pool are kept until their ttl expires
+			var ttl time.Duration
+			if ms.Pool != nil {
+				ttl = time.Duration(ms.Pool.IdleTTL) * time.Second
+			}
 			log.Notice("I got to kill %d %s worker !\n", diff, ms.ModelName)
-			err = killWorker(h, m)
+			err = killWorker(h, m, workers, ttl)
 			if err != nil {
 				return err
 			}
@@ -151,13 +220,18 @@ func hatcheryRoutine(h HatcheryMode) error {
 
 }
 
-func killWorker(h HatcheryMode, model *sdk.Model) error {
-
-	workers, err := sdk.GetWorkers()
-	if err != nil {
-		return err
+// hasWorkers returns whether some workers spawned by hatchery are still registered
+func hasWorkers(h HatcheryMode, workers []sdk.Worker) bool {
+	for i := range workers {
+		if workers[i].HatcheryID != 0 && workers[i].HatcheryID == h.ID() {
+			return true
+		}
 	}
+	return false
+}
 
+// killWorker kills a worker of given model, waiting for a job for at least idle ttl
+func killWorker(h HatcheryMode, model *sdk.Model, workers []sdk.Worker, ttl time.Duration) error {
 	// Get list of worker for this model
 	for i := range workers {
 		if workers[i].Model != model.ID {
@@ -169,10 +243,15 @@ func killWorker(h HatcheryMode, model *sdk.Model) error {
 			continue
 		}
 
-		// If worker is not currently executing an action
-		if workers[i].Status == sdk.StatusWaiting {
+		// Draining workers leave by themselves
+		if workers[i].Draining {
+			continue
+		}
+
+		// If worker is not currently executing an action for long enough
+		if workers[i].Status == sdk.StatusWaiting && idleWorkers.expired(workers[i].ID, ttl, time.Now()) {
 			// then disable him
-			if err = sdk.DisableWorker(workers[i].ID); err != nil {
+			if err := sdk.DisableWorker(workers[i].ID); err != nil {
 				return err
 			}
 			log.Notice("KillWorker> Disabled %s\n", workers[i].Name)
@@ -32,10 +32,14 @@ type marathonPOSTAppParams struct {
 	WorkerName    string
 	WorkerModelID int64
 	HatcheryID    int64
+	ActionBuildID int64
+	SpawnedAt     int64
 
 	MarathonID    string
 	MarathonVHOST string
-	Memory        int
+	Memory        int64
+	CPUs          float64
+	Disk          int64
 }
 
 const marathonPOSTAppTemplate = `
@@ -50,19 +54,22 @@ const marathonPOSTAppTemplate = `
         "type": "DOCKER"
     },
 		"cmd": "rm -f worker && curl ${CDS_API}/download/worker/$(uname -m) -o worker &&  chmod +x worker && exec ./worker",
-		"cpus": 0.5,
+		"cpus": {{.CPUs}},
     "env": {
         "CDS_API": "{{.APIEndpoint}}",
         "CDS_KEY": "{{.WorkerKey}}",
         "CDS_NAME": "{{.WorkerName}}",
         "CDS_MODEL": "{{.WorkerModelID}}",
         "CDS_HATCHERY": "{{.HatcheryID}}",
+        "CDS_ACTION_BUILD": "{{.ActionBuildID}}",
+        "CDS_SPAWNED_AT": "{{.SpawnedAt}}",
         "CDS_SINGLE_USE": "1"
     },
     "id": "{{.MarathonID}}/{{.WorkerName}}",
     "instances": 1,
 		"ports": [],
-		"mem": {{.Memory}}
+		"mem": {{.Memory}},
+		"disk": {{.Disk}}
 }
 `
 
@@ -95,12 +102,6 @@ func (m *HatcheryMesos) Hatchery() *hatchery.Hatchery {
 	return m.hatch
 }
 
-// Refresh retrieves worker models status from API
-// and tries to act if needed
-func (m *HatcheryMesos) Refresh() error {
-	return nil
-}
-
 // KillWorker deletes an application on mesos via marathon
 func (m *HatcheryMesos) KillWorker(worker sdk.Worker) error {
 	appID := path.Join(marathonID, worker.Name)
@@ -109,20 +110,17 @@ func (m *HatcheryMesos) KillWorker(worker sdk.Worker) error {
 }
 
 // CanSpawn return wether or not hatchery can spawn model
-// requirements are not supported
+// only resource requirements are supported
 func (m *HatcheryMesos) CanSpawn(model *sdk.Model, req []sdk.Requirement) bool {
 	if model.Type != sdk.Docker {
 		return false
 	}
-	if len(req) > 0 {
-		return false
-	}
-	return true
+	return supportedRequirements(req, sdk.MemoryRequirement, sdk.CPURequirement)
 }
 
 // SpawnWorker creates an application on mesos via marathon
 // requirements are not supported
-func (m *HatcheryMesos) SpawnWorker(model *sdk.Model, req []sdk.Requirement) error {
+func (m *HatcheryMesos) SpawnWorker(model *sdk.Model, req []sdk.Requirement, actionBuildID int64) error {
 	log.Notice("Spawning worker %s (%s)\n", model.Name, model.Image)
 	var err error
 	uk, err = sdk.GenerateWorkerKey(sdk.NeverExpire)
@@ -166,7 +164,7 @@ func (m *HatcheryMesos) SpawnWorker(model *sdk.Model, req []sdk.Requirement) err
 
 	switch model.Type {
 	case sdk.Docker:
-		return spawnMesosDockerWorker(model, m.hatch.ID)
+		return spawnMesosDockerWorker(model, req, m.hatch.ID, actionBuildID)
 	}
 
 	return fmt.Errorf("Model not handled\n")
@@ -247,14 +245,9 @@ func suicide() {
 	os.Exit(0)
 }
 
-func spawnMesosDockerWorker(model *sdk.Model, hatcheryID int64) error {
-	tmpl, err := template.New("marathonPOST").Parse(marathonPOSTAppTemplate)
-	if err != nil {
-		return err
-	}
-
-	// Estimate needed memory
-	memory := 1024
+// estimateMemory returns memory in MB needed by a worker of given model, according to its capabilities
+func estimateMemory(model *sdk.Model) int64 {
+	var memory int64 = 1024
 	for _, c := range model.Capabilities {
 		if c.Value == "java" {
 			memory = 4096
@@ -269,6 +262,23 @@ func spawnMesosDockerWorker(model *sdk.Model, hatcheryID int64) error {
 			memory = 2048
 		}
 	}
+	return memory
+}
+
+func spawnMesosDockerWorker(model *sdk.Model, req []sdk.Requirement, hatcheryID int64, actionBuildID int64) error {
+	tmpl, err := template.New("marathonPOST").Parse(marathonPOSTAppTemplate)
+	if err != nil {
+		return err
+	}
+
+	// Resources declared by model or requirements, memory being estimated from capabilities otherwise
+	memory, cpus := workerResources(model, req)
+	if memory == 0 {
+		memory = estimateMemory(model)
+	}
+	if cpus == 0 {
+		cpus = 0.5
+	}
 
 	for {
 		params := marathonPOSTAppParams{
@@ -278,9 +288,13 @@ func spawnMesosDockerWorker(model *sdk.Model, hatcheryID int64) error {
 			WorkerName:    fmt.Sprintf("%s-%s", strings.ToLower(model.Name), strings.Replace(namesgenerator.GetRandomName(0), "_", "-", -1)),
 			WorkerModelID: model.ID,
 			HatcheryID:    hatcheryID,
+			ActionBuildID: actionBuildID,
+			SpawnedAt:     time.Now().Unix(),
 			MarathonID:    marathonID,
 			MarathonVHOST: marathonVHOST,
 			Memory:        memory,
+			CPUs:          cpus,
+			Disk:          model.Disk,
 		}
 
 		var buffer bytes.Buffer
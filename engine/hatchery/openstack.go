@@ -70,15 +70,12 @@ func (h *HatcheryCloud) Hatchery() *hatchery.Hatchery {
 }
 
 // CanSpawn return wether or not hatchery can spawn model
-// requirements are not supported
+// only resource requirements are supported
 func (h *HatcheryCloud) CanSpawn(model *sdk.Model, req []sdk.Requirement) bool {
 	if model.Type != sdk.Openstack {
 		return false
 	}
-	if len(req) > 0 {
-		return false
-	}
-	return true
+	return supportedRequirements(req, sdk.MemoryRequirement, sdk.CPURequirement)
 }
 
 // Init fetch uri from nova
@@ -312,11 +309,6 @@ func (h *HatcheryCloud) ParseConfig() {
 	}
 }
 
-// Refresh engine needs
-func (h *HatcheryCloud) Refresh() error {
-	return nil
-}
-
 // WorkerStarted returns the number of instances of given model started but
 // not necessarily register on CDS yet
 func (h *HatcheryCloud) WorkerStarted(model *sdk.Model) int {
@@ -348,7 +340,7 @@ func (h *HatcheryCloud) KillWorker(worker sdk.Worker) error {
 
 // SpawnWorker creates a new cloud instances
 // requirements are not supported
-func (h *HatcheryCloud) SpawnWorker(model *sdk.Model, req []sdk.Requirement) error {
+func (h *HatcheryCloud) SpawnWorker(model *sdk.Model, req []sdk.Requirement, actionBuildID int64) error {
 	var err error
 	var omd sdk.OpenstackModelData
 
@@ -377,8 +369,9 @@ func (h *HatcheryCloud) SpawnWorker(model *sdk.Model, req []sdk.Requirement) err
 		return err
 	}
 
-	// Get flavor ID
-	flavorID, err := h.flavorID(omd.Flavor)
+	// Get flavor ID, big enough for model and requirements
+	memory, cpus := workerResources(model, req)
+	flavorID, err := selectFlavor(h.flavors, omd.Flavor, memory, cpus, model.Disk)
 	if err != nil {
 		return err
 	}
@@ -421,7 +414,7 @@ cd $HOME
 # Download and start worker with curl
 curl  "{{.API}}/download/worker/$(uname -m)" -o worker --retry 10 --retry-max-time 0 -C - >> /tmp/user_data 2>&1
 chmod +x worker
-CDS_SINGLE_USE=1 ./worker --api={{.API}} --key={{.Key}} --name={{.Name}} --model={{.Model}} --hatchery={{.Hatchery}} --single-use && exit 0
+CDS_SINGLE_USE=1 ./worker --api={{.API}} --key={{.Key}} --name={{.Name}} --model={{.Model}} --hatchery={{.Hatchery}} --action-build={{.ActionBuild}} --spawned-at={{.SpawnedAt}} --single-use && exit 0
 `
 	var udata = udataBegin + string(udataModel) + udataEnd
 
@@ -430,17 +423,21 @@ CDS_SINGLE_USE=1 ./worker --api={{.API}} --key={{.Key}} --name={{.Name}} --model
 		return err
 	}
 	udataParam := struct {
-		API      string
-		Name     string
-		Key      string
-		Model    int64
-		Hatchery int64
+		API         string
+		Name        string
+		Key         string
+		Model       int64
+		Hatchery    int64
+		ActionBuild int64
+		SpawnedAt   int64
 	}{
-		API:      api,
-		Name:     name,
-		Key:      uk,
-		Model:    model.ID,
-		Hatchery: h.hatch.ID,
+		API:         api,
+		Name:        name,
+		Key:         uk,
+		Model:       model.ID,
+		Hatchery:    h.hatch.ID,
+		ActionBuild: actionBuildID,
+		SpawnedAt:   time.Now().Unix(),
 	}
 	var buffer bytes.Buffer
 	err = tmpl.Execute(&buffer, udataParam)
@@ -476,14 +473,41 @@ func (h *HatcheryCloud) imageID(img string) (string, error) {
 	return "", fmt.Errorf("image '%s' not found", img)
 }
 
-// Find flavor ID from flavor name
-func (h *HatcheryCloud) flavorID(flavor string) (string, error) {
-	for _, f := range h.flavors {
+// Find flavor ID from flavor name. If named flavor is too small for given
+// memory (MB), cpus and disk (MB), the smallest flavor big enough is used instead
+func selectFlavor(flavors []Flavor, flavor string, memory int64, cpus float64, disk int64) (string, error) {
+	fits := func(f Flavor) bool {
+		return int64(f.RAM) >= memory && float64(f.VCPUs) >= cpus && int64(f.Disk)*1024 >= disk
+	}
+
+	var found bool
+	for _, f := range flavors {
 		if f.Name == flavor {
-			return f.ID, nil
+			if fits(f) {
+				return f.ID, nil
+			}
+			found = true
+			break
 		}
 	}
-	return "", fmt.Errorf("flavor '%s' not found", flavor)
+	if !found && memory == 0 && cpus == 0 && disk == 0 {
+		return "", fmt.Errorf("flavor '%s' not found", flavor)
+	}
+
+	var best *Flavor
+	for i := range flavors {
+		f := &flavors[i]
+		if !fits(*f) {
+			continue
+		}
+		if best == nil || f.RAM < best.RAM || (f.RAM == best.RAM && f.VCPUs < best.VCPUs) {
+			best = f
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("no flavor with %dMB memory, %g cpus and %dMB disk", memory, cpus, disk)
+	}
+	return best.ID, nil
 }
 
 // Find network ID from network name
@@ -642,6 +666,9 @@ type Image struct {
 type Flavor struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
+	RAM   int    `json:"ram"`   // in MB
+	VCPUs int    `json:"vcpus"` // number of virtual CPUs
+	Disk  int    `json:"disk"`  // in GB
 	Links []Link `json:"links"`
 }
 
@@ -771,7 +798,7 @@ func deleteServer(endpoint, token, serverID string) error {
 }
 
 func getFlavors(endpoint string, token string) ([]Flavor, error) {
-	uri := fmt.Sprintf("%s/flavors", endpoint)
+	uri := fmt.Sprintf("%s/flavors/detail", endpoint)
 	req, err := http.NewRequest("GET", uri, nil)
 	if err != nil {
 		return nil, err
@@ -44,3 +44,33 @@ func TestCreateServer(t *testing.T) {
 	assert.NotZero(t, w.Code)
 
 }
+
+func TestSelectFlavor(t *testing.T) {
+	flavors := []Flavor{
+		{ID: "3", Name: "b2-30", RAM: 30000, VCPUs: 8, Disk: 200},
+		{ID: "1", Name: "b2-7", RAM: 7000, VCPUs: 2, Disk: 50},
+		{ID: "2", Name: "b2-15", RAM: 15000, VCPUs: 4, Disk: 100},
+	}
+
+	id, err := selectFlavor(flavors, "b2-7", 0, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", id)
+
+	id, err = selectFlavor(flavors, "b2-7", 8000, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", id, "named flavor is too small, smallest big enough is used")
+
+	id, err = selectFlavor(flavors, "b2-30", 2048, 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "3", id, "named flavor big enough is kept")
+
+	id, err = selectFlavor(flavors, "b2-7", 0, 6, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "3", id)
+
+	_, err = selectFlavor(flavors, "b2-7", 0, 16, 0)
+	assert.Error(t, err)
+
+	_, err = selectFlavor(flavors, "unknown", 0, 0, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"time"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// idleWorkers remembers since when workers are waiting for a job
+var idleWorkers = newIdleTracker()
+
+type idleTracker struct {
+	since map[string]time.Time
+}
+
+func newIdleTracker() *idleTracker {
+	return &idleTracker{since: map[string]time.Time{}}
+}
+
+// update records waiting workers seen for the first time, and forgets others
+func (t *idleTracker) update(workers []sdk.Worker, now time.Time) {
+	seen := map[string]bool{}
+	for _, w := range workers {
+		if w.Status != sdk.StatusWaiting {
+			continue
+		}
+		seen[w.ID] = true
+		if _, ok := t.since[w.ID]; !ok {
+			t.since[w.ID] = now
+		}
+	}
+	for id := range t.since {
+		if !seen[id] {
+			delete(t.since, id)
+		}
+	}
+}
+
+// expired returns true if worker has been waiting for a job longer than ttl
+func (t *idleTracker) expired(workerID string, ttl time.Duration, now time.Time) bool {
+	since, ok := t.since[workerID]
+	if !ok {
+		return ttl == 0
+	}
+	return now.Sub(since) >= ttl
+}
+
+// poolRoom returns how many workers of a model may still be spawned, -1 when not limited
+func poolRoom(ms sdk.ModelStatus, started int) int64 {
+	if ms.Pool == nil || ms.Pool.MaxTotal == 0 {
+		return -1
+	}
+	room := ms.Pool.MaxTotal - int64(started)
+	if room < 0 {
+		return 0
+	}
+	return room
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestIdleTracker(t *testing.T) {
+	idle := newIdleTracker()
+	now := time.Now()
+
+	idle.update([]sdk.Worker{
+		{ID: "w1", Status: sdk.StatusWaiting},
+		{ID: "w2", Status: sdk.StatusBuilding},
+	}, now)
+	assert.False(t, idle.expired("w1", time.Minute, now.Add(30*time.Second)))
+	assert.True(t, idle.expired("w1", time.Minute, now.Add(time.Minute)))
+	assert.False(t, idle.expired("w2", time.Minute, now.Add(time.Hour)), "building worker is not idle")
+	assert.True(t, idle.expired("w2", 0, now), "without ttl, workers can be killed at once")
+
+	// w1 took a job then waits again: idle time restarts
+	idle.update([]sdk.Worker{{ID: "w1", Status: sdk.StatusBuilding}}, now.Add(2*time.Minute))
+	idle.update([]sdk.Worker{{ID: "w1", Status: sdk.StatusWaiting}}, now.Add(3*time.Minute))
+	assert.False(t, idle.expired("w1", time.Minute, now.Add(3*time.Minute+30*time.Second)))
+}
+
+func TestPoolRoom(t *testing.T) {
+	assert.Equal(t, int64(-1), poolRoom(sdk.ModelStatus{}, 10))
+	assert.Equal(t, int64(-1), poolRoom(sdk.ModelStatus{Pool: &sdk.ModelPoolLimits{MinIdle: 2}}, 10))
+	assert.Equal(t, int64(3), poolRoom(sdk.ModelStatus{Pool: &sdk.ModelPoolLimits{MaxTotal: 5}}, 2))
+	assert.Equal(t, int64(0), poolRoom(sdk.ModelStatus{Pool: &sdk.ModelPoolLimits{MaxTotal: 5}}, 7))
+}
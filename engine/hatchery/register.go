@@ -34,6 +34,19 @@ func register(h *hatchery.Hatchery) error {
 	return nil
 }
 
+func unregister(h *hatchery.Hatchery) error {
+	_, code, err := client.CDSRequest("DELETE", fmt.Sprintf("/hatchery/%d", h.ID), nil)
+	if err != nil {
+		return err
+	}
+
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+
+	return nil
+}
+
 func checkCapabilities(req []sdk.Requirement) ([]sdk.Requirement, error) {
 	var capa []sdk.Requirement
 	var tmp map[string]sdk.Requirement
@@ -85,12 +98,18 @@ func hearbeat(m HatcheryMode) {
 			m.SetWorkerModelID(m.Hatchery().Model.ID)
 		}
 
-		_, _, err := client.CDSRequest("PUT", fmt.Sprintf("/hatchery/%d", m.Hatchery().ID), nil)
+		data, _, err := client.CDSRequest("PUT", fmt.Sprintf("/hatchery/%d", m.Hatchery().ID), nil)
 		if err != nil {
 			log.Notice("heartbeat> cannot refresh beat: %s\n", err)
 			m.Hatchery().ID = 0
 			continue
 		}
+
+		var h hatchery.Hatchery
+		if err := json.Unmarshal(data, &h); err == nil && h.Draining != m.Hatchery().Draining {
+			log.Notice("heartbeat> draining: %t\n", h.Draining)
+			m.Hatchery().Draining = h.Draining
+		}
 		log.Info("heartbeat> done")
 	}
 }
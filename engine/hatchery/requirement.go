@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/ovh/cds/sdk"
+)
+
+// supportedRequirements returns true if all given requirements are of one of the given types
+func supportedRequirements(req []sdk.Requirement, types ...sdk.RequirementType) bool {
+	for _, r := range req {
+		supported := false
+		for _, t := range types {
+			if r.Type == t {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return false
+		}
+	}
+	return true
+}
+
+// workerResources returns memory (in MB) and CPUs to allocate to a worker of given model,
+// the largest of model resources and requirements. 0 means not specified.
+func workerResources(model *sdk.Model, req []sdk.Requirement) (int64, float64) {
+	memory, cpu := sdk.ResourceRequirements(req)
+	if model.Memory > memory {
+		memory = model.Memory
+	}
+	if model.CPU > cpu {
+		cpu = model.CPU
+	}
+	return memory, cpu
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestSupportedRequirements(t *testing.T) {
+	req := []sdk.Requirement{
+		{Name: "bash", Type: sdk.BinaryRequirement, Value: "bash"},
+		{Name: "pg", Type: sdk.ServiceRequirement, Value: "postgres:9.6"},
+		{Name: "memory", Type: sdk.MemoryRequirement, Value: "2048"},
+	}
+
+	assert.False(t, supportedRequirements(req, sdk.ServiceRequirement, sdk.MemoryRequirement))
+	assert.True(t, supportedRequirements(req[1:], sdk.ServiceRequirement, sdk.MemoryRequirement))
+	assert.False(t, supportedRequirements(req[1:], sdk.ServiceRequirement))
+	assert.True(t, supportedRequirements(nil))
+}
+
+func TestWorkerResources(t *testing.T) {
+	req := []sdk.Requirement{
+		{Name: "memory", Type: sdk.MemoryRequirement, Value: "2048"},
+		{Name: "cpu", Type: sdk.CPURequirement, Value: "0.5"},
+	}
+
+	memory, cpu := workerResources(&sdk.Model{}, nil)
+	assert.Equal(t, int64(0), memory)
+	assert.Equal(t, 0.0, cpu)
+
+	memory, cpu = workerResources(&sdk.Model{Memory: 1024, CPU: 2}, req)
+	assert.Equal(t, int64(2048), memory)
+	assert.Equal(t, 2.0, cpu)
+}
+
+func TestEstimateMemory(t *testing.T) {
+	assert.Equal(t, int64(1024), estimateMemory(&sdk.Model{}))
+
+	model := &sdk.Model{Capabilities: []sdk.Requirement{
+		{Name: "npm", Type: sdk.BinaryRequirement, Value: "npm"},
+		{Name: "go", Type: sdk.BinaryRequirement, Value: "go"},
+	}}
+	assert.Equal(t, int64(3072), estimateMemory(model))
+}
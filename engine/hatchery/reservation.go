@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// reserveWorkers claims to engine the right to spawn workers of a model, so that hatcheries
+// serving the same model do not spawn workers for the same action builds.
+// It returns the action build of each worker the hatchery may spawn, 0 for workers not bound to a job.
+func reserveWorkers(h HatcheryMode, ms sdk.ModelStatus, count int64) []int64 {
+	r, err := sdk.ReserveWorkers(ms.ModelID, sdk.SpawnReservation{
+		HatcheryID:   h.ID(),
+		Count:        count,
+		ActionBuilds: ms.ActionBuilds,
+		TTL:          int64(registrations.timeout.Seconds()),
+	})
+	if err != nil {
+		log.Warning("Cannot reserve %d %s workers: %s\n", count, ms.ModelName, err)
+		return nil
+	}
+	if r.Count < count {
+		log.Notice("%d/%d %s workers reserved, others are spawned by other hatcheries\n", r.Count, count, ms.ModelName)
+	}
+
+	jobs := make([]int64, r.Count)
+	copy(jobs, r.ActionBuilds)
+	return jobs
+}
+
+// jobRequirements returns the requirements to spawn a worker bound to the given action build with,
+// those of the model status for workers not bound to a job
+func jobRequirements(ms sdk.ModelStatus, job int64) []sdk.Requirement {
+	if req, ok := ms.ActionBuildRequirements[job]; ok && job != 0 {
+		return req
+	}
+	return ms.Requirements
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestJobRequirements(t *testing.T) {
+	pg := sdk.Requirement{Name: "pg", Type: sdk.ServiceRequirement, Value: "postgres:9.5"}
+	mem := sdk.Requirement{Name: "mem", Type: sdk.MemoryRequirement, Value: "4096"}
+	ms := sdk.ModelStatus{
+		Requirements: []sdk.Requirement{mem},
+		ActionBuilds: []int64{1, 2},
+		ActionBuildRequirements: map[int64][]sdk.Requirement{
+			1: {pg},
+			2: {mem},
+		},
+	}
+
+	assert.Equal(t, []sdk.Requirement{pg}, jobRequirements(ms, 1))
+	assert.Equal(t, []sdk.Requirement{mem}, jobRequirements(ms, 2))
+	// Workers not bound to a job, or to a job reserved without requirements, use those of the model status
+	assert.Equal(t, []sdk.Requirement{mem}, jobRequirements(ms, 0))
+	assert.Equal(t, []sdk.Requirement{mem}, jobRequirements(ms, 3))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// serviceStartTimeout is how long service containers are given to become healthy before the worker is started
+var serviceStartTimeout = 2 * time.Minute
+
+// workerService is a container started alongside a worker from a service requirement
+type workerService struct {
+	// Alias is the hostname under which the worker reaches the service
+	Alias string
+	Image string
+	Env   []string
+}
+
+// workerServices reads service requirements: requirement name is the alias of the service on the worker network,
+// value is the image followed by environment variables (ie. "postgres:9.6 POSTGRES_USER=cds POSTGRES_PASSWORD=cds")
+func workerServices(req []sdk.Requirement) []workerService {
+	var services []workerService
+	for _, r := range req {
+		if r.Type != sdk.ServiceRequirement {
+			continue
+		}
+		tuple := strings.Fields(r.Value)
+		if len(tuple) == 0 {
+			continue
+		}
+		services = append(services, workerService{
+			Alias: r.Name,
+			Image: tuple[0],
+			Env:   tuple[1:],
+		})
+	}
+	return services
+}
+
+// serviceContainerName returns the name of the container running service s of given worker
+func serviceContainerName(s workerService, worker string) string {
+	return s.Alias + "-" + worker
+}
+
+// workerNetworkName returns the name of the private network shared by a worker and its services
+func workerNetworkName(worker string) string {
+	return "cds-" + worker
+}
+
+// waitHealthy calls status until it reports the container healthy, or fails once the container stopped or timeout is reached.
+// status returns the state of the container ("running", "exited"...) and its health ("starting", "healthy"...),
+// health being empty when image has no healthcheck.
+func waitHealthy(name string, timeout time.Duration, status func() (state string, health string, err error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		state, health, err := status()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case state != "running" && state != "created":
+			return fmt.Errorf("service %s is %s", name, state)
+		case health == "unhealthy":
+			return fmt.Errorf("service %s is unhealthy", name)
+		case state == "running" && (health == "" || health == "healthy"):
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service %s not healthy after %s", name, timeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
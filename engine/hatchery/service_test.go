@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestWorkerServices(t *testing.T) {
+	req := []sdk.Requirement{
+		{Name: "bash", Type: sdk.BinaryRequirement, Value: "bash"},
+		{Name: "pg", Type: sdk.ServiceRequirement, Value: "postgres:9.6 POSTGRES_USER=cds  POSTGRES_PASSWORD=cds"},
+		{Name: "redis", Type: sdk.ServiceRequirement, Value: "redis"},
+	}
+
+	services := workerServices(req)
+	assert.Equal(t, []workerService{
+		{Alias: "pg", Image: "postgres:9.6", Env: []string{"POSTGRES_USER=cds", "POSTGRES_PASSWORD=cds"}},
+		{Alias: "redis", Image: "redis", Env: []string{}},
+	}, services)
+	assert.Equal(t, "pg-worker1", serviceContainerName(services[0], "worker1"))
+}
+
+func TestWaitHealthy(t *testing.T) {
+	var calls int
+	err := waitHealthy("pg", time.Minute, func() (string, string, error) {
+		calls++
+		if calls < 2 {
+			return "running", "starting", nil
+		}
+		return "running", "healthy", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	err = waitHealthy("pg", time.Minute, func() (string, string, error) { return "running", "", nil })
+	assert.NoError(t, err, "container without healthcheck is healthy once running")
+
+	err = waitHealthy("pg", time.Minute, func() (string, string, error) { return "exited", "", nil })
+	assert.Error(t, err)
+
+	err = waitHealthy("pg", time.Minute, func() (string, string, error) { return "", "", fmt.Errorf("no such container") })
+	assert.Error(t, err)
+}
@@ -84,11 +84,6 @@ func (h *HatcherySwarm) Init() error {
 	return nil
 }
 
-// Refresh doesn't do anything
-func (h *HatcherySwarm) Refresh() error {
-	return nil
-}
-
 // KillWorker kill the worker
 func (h *HatcherySwarm) KillWorker(worker sdk.Worker) error {
 	log.Warning("killing container %s", worker.Name)
@@ -135,19 +130,6 @@ func (h *HatcherySwarm) killAndRemove(ID string) error {
 		return err
 	}
 
-	links := container.HostConfig.Links
-	for _, l := range links {
-		log.Debug("Remove linked containers : %s", l)
-		if strings.Contains(l, ":") {
-			c, _ := h.getContainer(strings.Split(l, ":")[0])
-			if c != nil {
-				defer h.killAndRemove(c.ID)
-			}
-		} else {
-			log.Warning("I dont know what to do with %s", l)
-		}
-	}
-
 	h.dockerClient.KillContainer(docker.KillContainerOptions{
 		ID:     ID,
 		Signal: docker.SIGKILL,
@@ -157,11 +139,16 @@ func (h *HatcherySwarm) killAndRemove(ID string) error {
 		ID: ID,
 	})
 
+	//Tear down services of the worker and their network
+	if name := container.Config.Labels["worker_name"]; name != "" {
+		removeServices(h.dockerClient, name)
+	}
+
 	return err
 }
 
 //SpawnWorker start a new docker container
-func (h *HatcherySwarm) SpawnWorker(model *sdk.Model, req []sdk.Requirement) error {
+func (h *HatcherySwarm) SpawnWorker(model *sdk.Model, req []sdk.Requirement, actionBuildID int64) error {
 	//uk is the worker key for worker auth
 	uk, err := sdk.GenerateWorkerKey(sdk.FirstUseExpire)
 	if err != nil {
@@ -173,35 +160,11 @@ func (h *HatcherySwarm) SpawnWorker(model *sdk.Model, req []sdk.Requirement) err
 
 	log.Debug("Spawning worker %s with requirements %v", name, req)
 
-	//Prepare worker services from requirements
-	//docker legacy links (https://docs.docker.com/engine/userguide/networking/default_network/dockerlinks/) are <name or id>:alias
-	links := []string{}
-	services := []string{}
-	for _, r := range req {
-		if r.Type == sdk.ServiceRequirement {
-			//name= <alias> => the name of the host put in /etc/hosts of the worker
-			//value= "postgres:latest env_1=blabla env_2=blabla"" => we can add env variables in requirement name
-			tuple := strings.Split(r.Value, " ")
-			img := tuple[0]
-			env := []string{}
-			if len(tuple) > 1 {
-				env = append(env, tuple[1:]...)
-			}
-			serviceName := r.Name + "-" + name
-
-			//labels are used to make container cleanup easier. We "link" the service to its worker this way.
-			labels := map[string]string{
-				"service_worker": name,
-				"service_name":   serviceName,
-			}
-			//Start the services
-			if err := h.createAndStartContainer(serviceName, img, []string{}, env, []string{}, labels); err != nil {
-				log.Warning("SpawnWorker>Unable to start required container: %s\n", err)
-				return err
-			}
-			services = append(services, serviceName)
-			links = append(links, serviceName+":"+r.Name)
-		}
+	//Start services on a private network shared with the worker, where they are reachable by their requirement name
+	network, err := startServices(h.dockerClient, name, req)
+	if err != nil {
+		log.Warning("SpawnWorker> %s\n", err)
+		return err
 	}
 
 	//cmd is the command to start the worker (we need curl to download current version of the worker binary)
@@ -214,49 +177,36 @@ func (h *HatcherySwarm) SpawnWorker(model *sdk.Model, req []sdk.Requirement) err
 		"CDS_KEY" + "=" + uk,
 		"CDS_MODEL" + "=" + strconv.FormatInt(model.ID, 10),
 		"CDS_HATCHERY" + "=" + strconv.FormatInt(h.hatch.ID, 10),
+		"CDS_ACTION_BUILD" + "=" + strconv.FormatInt(actionBuildID, 10),
+		"CDS_SPAWNED_AT" + "=" + strconv.FormatInt(time.Now().Unix(), 10),
 	}
 
 	//labels are used to make container cleanup easier
 	labels := map[string]string{
-		"worker_model":        strconv.FormatInt(model.ID, 10),
-		"worker_name":         name,
-		"worker_requirements": strings.Join(services, ","),
+		"worker_model":   strconv.FormatInt(model.ID, 10),
+		"worker_name":    name,
+		"worker_network": network,
 	}
 
 	//start the worker
-	if err := h.createAndStartContainer(name, model.Image, cmd, env, links, labels); err != nil {
+	memory, cpus := workerResources(model, req)
+	spec := containerSpec{
+		Name:    name,
+		Image:   model.Image,
+		Cmd:     cmd,
+		Env:     env,
+		Labels:  labels,
+		Network: network,
+		Memory:  memory,
+		CPUs:    cpus,
+		Disk:    model.Disk,
+	}
+	if _, err := createAndStartContainer(h.dockerClient, spec); err != nil {
 		log.Warning("SpawnWorker> Unable to start container %s\n", err)
-	}
-
-	return nil
-}
-
-//shortcut to create+start(=run) a container
-func (h *HatcherySwarm) createAndStartContainer(name, image string, cmd, env, links []string, labels map[string]string) error {
-	log.Debug("createAndStartContainer> Create container %s from %s\n", name, image)
-	opts := docker.CreateContainerOptions{
-		Name: name,
-		Config: &docker.Config{
-			Image:  image,
-			Cmd:    cmd,
-			Env:    env,
-			Labels: labels,
-		},
-		HostConfig: &docker.HostConfig{
-			Links: links,
-		},
-	}
-
-	c, err := h.dockerClient.CreateContainer(opts)
-	if err != nil {
-		log.Warning("startAndCreateContainer> Unable to create container %s\n", err)
+		removeServices(h.dockerClient, name)
 		return err
 	}
 
-	if err := h.dockerClient.StartContainer(c.ID, nil); err != nil {
-		log.Warning("startAndCreateContainer> Unable to start container %s\n", err)
-		return err
-	}
 	return nil
 }
 
@@ -272,15 +222,12 @@ func (h *HatcherySwarm) CanSpawn(model *sdk.Model, req []sdk.Requirement) bool {
 		return false
 	}
 
-	//Get links from requirements
-	var atLeastOneLink bool
+	//Get services from requirements
 	links := map[string]string{}
-	for _, r := range req {
-		if r.Type == sdk.ServiceRequirement {
-			atLeastOneLink = true
-			links[r.Name] = strings.Split(r.Value, " ")[0]
-		}
+	for _, svc := range workerServices(req) {
+		links[svc.Alias] = svc.Image
 	}
+	atLeastOneLink := len(links) > 0
 
 	//This hatchery may only manage container with links
 	if (!atLeastOneLink) && h.onlyWithServiceReq {
@@ -404,13 +351,13 @@ func (h *HatcherySwarm) killAwolWorker() {
 		}
 	}
 	//Checking services
+	orphans := map[string]bool{}
 	for _, c := range containers {
 		if c.Labels["service_worker"] == "" {
 			continue
 		}
 		if w, _ := h.getContainer(c.Labels["service_worker"]); w == nil {
-			oldContainers = append(oldContainers, c)
-			continue
+			orphans[c.Labels["service_worker"]] = true
 		}
 	}
 
@@ -419,4 +366,9 @@ func (h *HatcherySwarm) killAwolWorker() {
 		log.Notice("HatcherySwarm.killAwolWorker> Delete worker %s\n", c.Names[0])
 	}
 
+	for w := range orphans {
+		removeServices(h.dockerClient, w)
+		log.Notice("HatcherySwarm.killAwolWorker> Delete services of worker %s\n", w)
+	}
+
 }
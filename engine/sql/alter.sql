@@ -1 +1,43 @@
-ALTER TABLE action_build ADD COLUMN worker_model_name TEXT;
\ No newline at end of file
+ALTER TABLE action_build ADD COLUMN worker_model_name TEXT;
+ALTER TABLE artifact ADD COLUMN sha256sum TEXT;
+ALTER TABLE worker_model ADD COLUMN memory BIGINT DEFAULT 0;
+ALTER TABLE worker_model ADD COLUMN cpu REAL DEFAULT 0;
+ALTER TABLE worker_model ADD COLUMN disk BIGINT DEFAULT 0;
+ALTER TABLE worker_model ADD COLUMN spawn_errors INT DEFAULT 0;
+ALTER TABLE worker_model ADD COLUMN last_spawn_error TEXT DEFAULT '';
+ALTER TABLE worker_model ADD COLUMN unhealthy_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE worker_model ADD COLUMN validated BOOL DEFAULT false;
+ALTER TABLE worker_model ADD COLUMN need_validation BOOL DEFAULT false;
+ALTER TABLE worker_model ADD COLUMN validation_report TEXT;
+ALTER TABLE worker_model ADD COLUMN pool TEXT;
+ALTER TABLE worker ADD COLUMN draining BOOL DEFAULT false;
+ALTER TABLE hatchery ADD COLUMN draining BOOL DEFAULT false;
+ALTER TABLE worker ADD COLUMN version TEXT;
+ALTER TABLE hook ADD COLUMN include_paths JSONB;
+ALTER TABLE hook ADD COLUMN exclude_paths JSONB;
+ALTER TABLE poller ADD COLUMN include_paths JSONB;
+ALTER TABLE poller ADD COLUMN exclude_paths JSONB;
+ALTER TABLE received_hook ADD COLUMN skip_reason TEXT;
+ALTER TABLE hook ADD COLUMN events JSONB;
+ALTER TABLE poller ADD COLUMN events JSONB;
+ALTER TABLE received_hook ADD COLUMN created TIMESTAMP WITH TIME ZONE;
+ALTER TABLE received_hook ADD COLUMN uid TEXT;
+ALTER TABLE received_hook ADD COLUMN project_key TEXT;
+ALTER TABLE received_hook ADD COLUMN repository TEXT;
+ALTER TABLE received_hook ADD COLUMN branch TEXT;
+ALTER TABLE received_hook ADD COLUMN tag TEXT;
+ALTER TABLE received_hook ADD COLUMN hash TEXT;
+ALTER TABLE received_hook ADD COLUMN author TEXT;
+ALTER TABLE received_hook ADD COLUMN message TEXT;
+ALTER TABLE received_hook ADD COLUMN hooks JSONB;
+ALTER TABLE received_hook ADD COLUMN builds JSONB;
+ALTER TABLE received_hook ADD COLUMN error TEXT;
+ALTER TABLE worker_model ALTER COLUMN last_spawn_error SET DEFAULT '';
+UPDATE worker_model SET last_spawn_error = '' WHERE last_spawn_error IS NULL;
+CREATE TABLE IF NOT EXISTS "user_subscription" (user_id BIGINT, project_key TEXT, PRIMARY KEY(user_id, project_key));
+INSERT INTO user_subscription (user_id, project_key) SELECT DISTINCT id, s->>'project_key' FROM "user", json_array_elements(CASE WHEN json_typeof(data::json->'subscriptions') = 'array' THEN data::json->'subscriptions' ELSE '[]'::json END) s ON CONFLICT DO NOTHING;
+ALTER TABLE received_hook ADD COLUMN application_id BIGINT;
+UPDATE received_hook SET application_id = hook.application_id FROM hook WHERE hook.uid = received_hook.uid AND received_hook.application_id IS NULL;
+ALTER TABLE application_branch ADD COLUMN missing_since TIMESTAMP WITH TIME ZONE;
+DELETE FROM application_branch a USING application_branch b WHERE a.application_id = b.application_id AND a.branch = b.branch AND a.id > b.id;
+DROP INDEX IF EXISTS IDX_APPLICATION_BRANCH_BRANCH;
\ No newline at end of file
@@ -35,6 +35,10 @@ select create_foreign_key('FK_APPLICATION_PIPELINE_PIPELINE', 'application_pipel
 -- APPLICATION_VARIABLE
 select create_foreign_key('FK_APPLICATION_VARIABLE_APPLICATION', 'application_variable', 'application', 'application_id', 'id');
 
+-- APPLICATION BRANCH
+select create_foreign_key('FK_APPLICATION_BRANCH_APPLICATION', 'application_branch', 'application', 'application_id', 'id');
+select create_foreign_key('FK_APPLICATION_BRANCH_LIFECYCLE_APPLICATION', 'application_branch_lifecycle', 'application', 'application_id', 'id');
+
 -- APPLICATION PIPELINE NOTIF
 SELECT create_foreign_key('FK_APPLICATION_PIPELINE_NOTIF_APPLICATION_PIPELINE', 'application_pipeline_notif', 'application_pipeline', 'application_pipeline_id', 'id');
 SELECT create_foreign_key('FK_APPLICATION_PIPELINE_NOTIF_ENVIRONMENT', 'application_pipeline_notif', 'environment', 'environment_id', 'id');
@@ -60,6 +64,9 @@ select create_foreign_key('FK_GROUP_USER_USER', 'group_user', 'user', 'user_id',
 select create_foreign_key('FK_HOOK_PIPELINE', 'hook', 'pipeline', 'pipeline_id', 'id');
 select create_foreign_key('FK_HOOK_APPLICATION', 'hook', 'application', 'application_id', 'id');
 
+-- RECEIVED HOOK
+select create_foreign_key('FK_RECEIVED_HOOK_APPLICATION', 'received_hook', 'application', 'application_id', 'id');
+
 -- PIPELINE
 select create_foreign_key('FK_PIPELINE_PROJECT', 'pipeline', 'project', 'project_id', 'id');
 
@@ -118,12 +125,28 @@ select create_foreign_key('FK_PROJECT_GROUP', 'project_group', 'group', 'group_i
 -- PROJECT VARIABLE
 select create_foreign_key('FK_PROJECT_VARIABLE_PIPELINE', 'project_variable', 'project', 'project_id', 'id');
 
+-- PROJECT NOTIFICATION TEMPLATE
+select create_foreign_key('FK_PROJECT_NOTIFICATION_TEMPLATE_PROJECT', 'project_notification_template', 'project', 'project_id', 'id');
+
 -- USER KEY
 select create_foreign_key('FK_USER_KEY_USER', 'user_key', 'user', 'user_id', 'id');
 
+-- USER DIGEST
+select create_foreign_key('FK_USER_DIGEST_USER', 'user_digest', 'user', 'user_id', 'id');
+select create_foreign_key('FK_USER_SUBSCRIPTION_USER', 'user_subscription', 'user', 'user_id', 'id');
+
 -- WORKER CAPABILITY
 select create_foreign_key('FK_WORKER_CAPABILITY_WORKER_MODEL', 'worker_capability', 'worker_model', 'worker_model_id', 'id');
 
+-- WORKER MODEL SPAWN ERROR
+select create_foreign_key('FK_WORKER_MODEL_SPAWN_ERROR_WORKER_MODEL', 'worker_model_spawn_error', 'worker_model', 'worker_model_id', 'id');
+
+-- WORKER MODEL RESERVATION
+select create_foreign_key('FK_WORKER_MODEL_RESERVATION_WORKER_MODEL', 'worker_model_reservation', 'worker_model', 'worker_model_id', 'id');
+
+-- SPAWN LATENCY
+select create_foreign_key('FK_SPAWN_LATENCY_WORKER_MODEL', 'spawn_latency', 'worker_model', 'worker_model_id', 'id');
+
 -- WORKER
 select create_foreign_key('FK_WORKER_ACTION_BUILD', 'worker', 'action_build', 'action_build_id', 'id');
 
@@ -148,3 +171,23 @@ ALTER TABLE warning ADD CONSTRAINT fk_action FOREIGN KEY (action_id) references
 ALTER TABLE project_variable_audit ADD CONSTRAINT fk_project FOREIGN KEY (project_id) references project (id) ON delete cascade;
 ALTER TABLE application_variable_audit ADD CONSTRAINT fk_application FOREIGN KEY (application_id) references application (id) ON delete cascade;
 ALTER TABLE environment_variable_audit ADD CONSTRAINT fk_environment FOREIGN KEY (environment_id) references environment (id) ON delete cascade;
+
+-- WORKSPACE CACHE
+ALTER TABLE workspace_cache ADD CONSTRAINT fk_project FOREIGN KEY (project_id) references project (id) ON delete cascade;
+ALTER TABLE workspace_cache_quota ADD CONSTRAINT fk_project FOREIGN KEY (project_id) references project (id) ON delete cascade;
+
+-- ARTIFACT UPLOAD
+ALTER TABLE artifact_upload ADD CONSTRAINT fk_pipeline FOREIGN KEY (pipeline_id) references pipeline (id) ON delete cascade;
+ALTER TABLE artifact_upload ADD CONSTRAINT fk_application FOREIGN KEY (application_id) references application (id) ON delete cascade;
+ALTER TABLE artifact_upload ADD CONSTRAINT fk_environment FOREIGN KEY (environment_id) references environment (id) ON delete cascade;
+ALTER TABLE artifact_upload_chunk ADD CONSTRAINT fk_artifact_upload FOREIGN KEY (upload_id) references artifact_upload (id) ON delete cascade;
+
+-- ARTIFACT RELEASE
+ALTER TABLE artifact_release ADD CONSTRAINT fk_application FOREIGN KEY (application_id) references application (id) ON delete cascade;
+ALTER TABLE artifact_release_file ADD CONSTRAINT fk_artifact_release FOREIGN KEY (release_id) references artifact_release (id) ON delete cascade;
+ALTER TABLE artifact_release_consumer ADD CONSTRAINT fk_artifact_release FOREIGN KEY (release_id) references artifact_release (id) ON delete cascade;
+
+-- PIPELINE BUILD TEST CASE
+ALTER TABLE pipeline_build_test_case ADD CONSTRAINT fk_application FOREIGN KEY (application_id) references application (id) ON delete cascade;
+ALTER TABLE pipeline_build_test_case ADD CONSTRAINT fk_pipeline FOREIGN KEY (pipeline_id) references pipeline (id) ON delete cascade;
+ALTER TABLE pipeline_build_test_case ADD CONSTRAINT fk_environment FOREIGN KEY (environment_id) references environment (id) ON delete cascade;
@@ -98,6 +98,9 @@ select create_unique_index('user','IDX_USER_USERNAME','username');
 -- USER KEY
 select create_index('user_key','IDX_USER_KEY_USER_KEY','user_key');
 
+-- USER SUBSCRIPTION
+select create_index('user_subscription','IDX_USER_SUBSCRIPTION_PROJECT_KEY','project_key');
+
 -- WORKER
 select create_index('worker','IDX_WORKER_ID','id');
 select create_index('worker','IDX_WORKER_OWNER_ID','owner_id');
@@ -110,5 +113,47 @@ select create_index('worker_capability','IDX_WORKER_CAPABILITY_MODEL_ID','worker
 select create_unique_index('worker_model','IDX_WORKER_MODEL_NAME','name');
 select create_index('worker_model','IDX_WORKER_MODEL_OWNER_ID','owner_id');
 
+-- WORKER_MODEL_SPAWN_ERROR
+select create_index('worker_model_spawn_error','IDX_WORKER_MODEL_SPAWN_ERROR_MODEL','worker_model_id,created');
+
+-- WORKER_MODEL_RESERVATION
+select create_index('worker_model_reservation','IDX_WORKER_MODEL_RESERVATION_MODEL','worker_model_id,expire');
+select create_index('worker_model_reservation','IDX_WORKER_MODEL_RESERVATION_ACTION_BUILD','action_build_id');
+
 -- REPOSITORIES_MANAGER_PROJECT
 select create_unique_index('repositories_manager_project', 'IDX_REPOSITORIES_MANAGER_PROJECT_ID' ,'id_repositories_manager, id_project');
+
+-- WORKSPACE_CACHE
+select create_unique_index('workspace_cache','IDX_WORKSPACE_CACHE_KEY','project_id,cache_key');
+select create_index('workspace_cache','IDX_WORKSPACE_CACHE_LAST_USED','project_id,last_used');
+
+-- ARTIFACT_UPLOAD
+select create_unique_index('artifact_upload','IDX_ARTIFACT_UPLOAD_HASH','upload_hash');
+
+-- ARTIFACT_RELEASE
+select create_unique_index('artifact_release','IDX_ARTIFACT_RELEASE_NAME','application_id,name');
+select create_index('artifact_release_file','IDX_ARTIFACT_RELEASE_FILE_RELEASE_ID','release_id');
+select create_index('artifact_release_consumer','IDX_ARTIFACT_RELEASE_CONSUMER_RELEASE_ID','release_id');
+
+-- PIPELINE_BUILD_TEST_CASE
+select create_index('pipeline_build_test_case','IDX_PIPELINE_BUILD_TEST_CASE_BUILD','pipeline_build_id');
+select create_index('pipeline_build_test_case','IDX_PIPELINE_BUILD_TEST_CASE_NAME','application_id,pipeline_id,environment_id,suite,name');
+
+-- NOTIFICATION_WEBHOOK_DELIVERY
+select create_index('notification_webhook_delivery','IDX_NOTIFICATION_WEBHOOK_DELIVERY_PB','pipeline_build_id');
+
+-- NOTIFICATION_CHAT_THREAD
+select create_index('notification_chat_thread','IDX_NOTIFICATION_CHAT_THREAD_PB','pipeline_build_id');
+
+-- NOTIFICATION_OUTBOX
+select create_index('notification_outbox','IDX_NOTIFICATION_OUTBOX_STATUS','status,next_attempt');
+
+-- PROJECT_NOTIFICATION_TEMPLATE
+select create_index('project_notification_template','IDX_PROJECT_NOTIFICATION_TEMPLATE_NAME','project_id,name');
+
+-- RECEIVED_HOOK
+select create_index('received_hook','IDX_RECEIVED_HOOK_APPLICATION_ID','application_id,id');
+
+-- APPLICATION_BRANCH
+select create_unique_index('application_branch','IDX_APPLICATION_BRANCH_UNIQUE','application_id,branch');
+select create_index('application_branch','IDX_APPLICATION_BRANCH_DELETED','deleted');
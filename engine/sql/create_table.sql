@@ -6,7 +6,7 @@ CREATE TABLE IF NOT EXISTS "action_parameter" (id BIGSERIAL PRIMARY KEY, action_
 CREATE TABLE IF NOT EXISTS "action_build" (id BIGSERIAL PRIMARY KEY, pipeline_action_id INT, args TEXT, status TEXT, pipeline_build_id INT, queued TIMESTAMP WITH TIME ZONE, start TIMESTAMP WITH TIME ZONE, done TIMESTAMP WITH TIME ZONE, worker_model_name TEXT);
 CREATE TABLE IF NOT EXISTS "action_audit" (action_id BIGINT, user_id BIGINT, change TEXT, versionned TIMESTAMP WITH TIME ZONE, action_json JSONB);
 
-CREATE TABLE IF NOT EXISTS "artifact" (id BIGSERIAL PRIMARY KEY, name TEXT, tag TEXT, pipeline_id INT, application_id INT, environment_id INT, build_number INT, download_hash TEXT, size BIGINT, perm INT, md5sum TEXT, object_path TEXT);
+CREATE TABLE IF NOT EXISTS "artifact" (id BIGSERIAL PRIMARY KEY, name TEXT, tag TEXT, pipeline_id INT, application_id INT, environment_id INT, build_number INT, download_hash TEXT, size BIGINT, perm INT, md5sum TEXT, object_path TEXT, sha256sum TEXT);
 
 CREATE TABLE IF NOT EXISTS "application" (id BIGSERIAL PRIMARY KEY, name TEXT, project_id INT, description TEXT, repo_fullname TEXT, repositories_manager_id BIGINT, last_modified TIMESTAMP WITH TIME ZONE DEFAULT  LOCALTIMESTAMP);
 CREATE TABLE IF NOT EXISTS "application_group" (application_id INT, group_id INT, role INT, PRIMARY KEY(group_id, application_id));
@@ -14,6 +14,8 @@ CREATE TABLE IF NOT EXISTS "application_pipeline" (id BIGSERIAL PRIMARY KEY, app
 CREATE TABLE IF NOT EXISTS "application_variable" (id BIGSERIAL, application_id INT, var_name TEXT, var_value TEXT, cipher_value BYTEA, var_type TEXT,PRIMARY KEY(application_id, var_name) );
 CREATE TABLE IF NOT EXISTS "application_variable_audit" (id BIGSERIAL PRIMARY KEY, application_id BIGINT, data TEXT, author TEXT, versionned TIMESTAMP WITH TIME ZONE);
 CREATE TABLE IF NOT EXISTS "application_pipeline_notif" (application_pipeline_id BIGINT, environment_id BIGINT, settings JSONB);
+CREATE TABLE IF NOT EXISTS "application_branch_lifecycle" (application_id BIGINT PRIMARY KEY, environment_template_id BIGINT, cleanup_pipeline_id BIGINT, grace_period_hours INT);
+CREATE TABLE IF NOT EXISTS "application_branch" (id BIGSERIAL PRIMARY KEY, application_id BIGINT, branch TEXT, environment_id BIGINT, created TIMESTAMP WITH TIME ZONE, deleted TIMESTAMP WITH TIME ZONE, missing_since TIMESTAMP WITH TIME ZONE);
 
 CREATE TABLE IF NOT EXISTS "build_log" (id BIGSERIAL PRIMARY KEY, action_build_id INT, "timestamp" TIMESTAMP WITH TIME ZONE, step TEXT, value TEXT);
 
@@ -24,7 +26,7 @@ CREATE TABLE IF NOT EXISTS "environment_group" (id BIGSERIAL, environment_id INT
 
 CREATE TABLE IF NOT EXISTS "group" (id BIGSERIAL PRIMARY KEY, name TEXT);
 CREATE TABLE IF NOT EXISTS "group_user" (id BIGSERIAL, group_id INT, user_id INT, group_admin BOOL, PRIMARY KEY(group_id, user_id));
-CREATE TABLE IF NOT EXISTS "hook" (id BIGSERIAL PRIMARY KEY, pipeline_id BIGINT, application_id INT,  kind TEXT, host TEXT, project TEXT, repository TEXT, uid TEXT, enabled BOOL);
+CREATE TABLE IF NOT EXISTS "hook" (id BIGSERIAL PRIMARY KEY, pipeline_id BIGINT, application_id INT,  kind TEXT, host TEXT, project TEXT, repository TEXT, uid TEXT, enabled BOOL, include_paths JSONB, exclude_paths JSONB, events JSONB);
 CREATE TABLE IF NOT EXISTS "pipeline" (id BIGSERIAL PRIMARY KEY, name TEXT, project_id INT, type TEXT, last_modified TIMESTAMP WITH TIME ZONE DEFAULT  LOCALTIMESTAMP);
 CREATE TABLE IF NOT EXISTS "pipeline_action" (id BIGSERIAL PRIMARY KEY, pipeline_stage_id INT, action_id INT, args TEXT, enabled BOOLEAN, last_modified TIMESTAMP WITH TIME ZONE DEFAULT  LOCALTIMESTAMP);
 CREATE TABLE IF NOT EXISTS "pipeline_build" (id BIGSERIAL PRIMARY KEY, environment_id INT, application_id INT, pipeline_id INT, build_number INT, version BIGINT, status TEXT, args TEXT, start TIMESTAMP WITH TIME ZONE, done TIMESTAMP WITH TIME ZONE, manual_trigger BOOLEAN, triggered_by BIGINT, parent_pipeline_build_id BIGINT, vcs_changes_branch TEXT, vcs_changes_hash TEXT, vcs_changes_author TEXT);
@@ -42,7 +44,7 @@ CREATE TABLE IF NOT EXISTS "pipeline_trigger_prerequisite" (id BIGSERIAL PRIMARY
 
 CREATE TABLE IF NOT EXISTS "plugin" (id BIGSERIAL PRIMARY KEY, name TEXT, size BIGINT, perm INT, md5sum TEXT, object_path TEXT);
 
-CREATE TABLE IF NOT EXISTS "poller" (application_id BIGINT, pipeline_id BIGINT, enabled BOOLEAN, name TEXT, date_creation TIMESTAMP WITH TIME ZONE, PRIMARY KEY(application_id, pipeline_id));
+CREATE TABLE IF NOT EXISTS "poller" (application_id BIGINT, pipeline_id BIGINT, enabled BOOLEAN, name TEXT, date_creation TIMESTAMP WITH TIME ZONE, include_paths JSONB, exclude_paths JSONB, events JSONB, PRIMARY KEY(application_id, pipeline_id));
 CREATE TABLE IF NOT EXISTS "poller_execution" (id BIGSERIAL PRIMARY KEY, application_id BIGINT, pipeline_id BIGINT, execution_date TIMESTAMP WITH TIME ZONE, status TEXT, data JSONB);
 
 CREATE TABLE IF NOT EXISTS "project" (id BIGSERIAL PRIMARY KEY, projectKey TEXT , name TEXT, last_modified TIMESTAMP WITH TIME ZONE DEFAULT  LOCALTIMESTAMP);
@@ -50,19 +52,27 @@ CREATE TABLE IF NOT EXISTS "project_group" (id BIGSERIAL, project_id INT, group_
 CREATE TABLE IF NOT EXISTS "project_variable" (id BIGSERIAL, project_id INT, var_name TEXT, var_value TEXT, cipher_value BYTEA, var_type TEXT,PRIMARY KEY(project_id, var_name));
 CREATE TABLE IF NOT EXISTS "project_variable_audit" (id BIGSERIAL PRIMARY KEY, project_id BIGINT, versionned TIMESTAMP WITH TIME ZONE, data TEXT, author TEXT);
 
-CREATE TABLE IF NOT EXISTS "received_hook" (id BIGSERIAL PRIMARY KEY, link TEXT, data TEXT);
+CREATE TABLE IF NOT EXISTS "received_hook" (id BIGSERIAL PRIMARY KEY, application_id BIGINT, link TEXT, data TEXT, skip_reason TEXT, created TIMESTAMP WITH TIME ZONE, uid TEXT, project_key TEXT, repository TEXT, branch TEXT, tag TEXT, hash TEXT, author TEXT, message TEXT, hooks JSONB, builds JSONB, error TEXT);
 CREATE TABLE IF NOT EXISTS "system_log" (id BIGSERIAL PRIMARY KEY, logged TIMESTAMP WITH TIME ZONE, level TEXT, log TEXT);
 CREATE TABLE IF NOT EXISTS "user" (id BIGSERIAL PRIMARY KEY, username TEXT, admin BOOL, data TEXT, auth TEXT, created TIMESTAMP WITH TIME ZONE, origin TEXT);
 
 CREATE TABLE IF NOT EXISTS "user_key" (user_id INT, user_key TEXT, expiry INT DEFAULT 0);
 
+CREATE TABLE IF NOT EXISTS "user_digest" (user_id BIGINT PRIMARY KEY, last_sent TIMESTAMP WITH TIME ZONE);
+CREATE TABLE IF NOT EXISTS "user_subscription" (user_id BIGINT, project_key TEXT, PRIMARY KEY(user_id, project_key));
 CREATE TABLE IF NOT EXISTS "user_notification" (id BIGSERIAL PRIMARY KEY, type TEXT, content JSONB, status TEXT, creation_date INT);
+CREATE TABLE IF NOT EXISTS "notification_webhook_delivery" (id BIGSERIAL PRIMARY KEY, pipeline_build_id BIGINT, url TEXT, attempt INT, status_code INT, error TEXT, duration BIGINT, created TIMESTAMP WITH TIME ZONE);
+CREATE TABLE IF NOT EXISTS "notification_outbox" (id BIGSERIAL PRIMARY KEY, kind TEXT, target TEXT, payload TEXT, status TEXT, attempts INT, next_attempt TIMESTAMP WITH TIME ZONE, last_error TEXT, user_notification_id BIGINT, created TIMESTAMP WITH TIME ZONE);
+CREATE TABLE IF NOT EXISTS "notification_chat_thread" (id BIGSERIAL PRIMARY KEY, pipeline_build_id BIGINT, url TEXT, channel TEXT, thread_ts TEXT, created TIMESTAMP WITH TIME ZONE);
+CREATE TABLE IF NOT EXISTS "project_notification_template" (id BIGSERIAL PRIMARY KEY, project_id BIGINT, name TEXT, subject TEXT, body TEXT, html BOOL, last_modified TIMESTAMP WITH TIME ZONE);
 
-CREATE TABLE IF NOT EXISTS "worker" (id TEXT PRIMARY KEY, name TEXT, last_beat TIMESTAMP WITH TIME ZONE, owner_id INT, model INT, status TEXT, action_build_id BIGINT, hatchery_id BIGINT DEFAULT 0);
+CREATE TABLE IF NOT EXISTS "worker" (id TEXT PRIMARY KEY, name TEXT, last_beat TIMESTAMP WITH TIME ZONE, owner_id INT, model INT, status TEXT, action_build_id BIGINT, hatchery_id BIGINT DEFAULT 0, draining BOOL, version TEXT);
 CREATE TABLE IF NOT EXISTS "worker_capability" (worker_model_id INT, type TEXT, name TEXT, argument TEXT);
-CREATE TABLE IF NOT EXISTS "worker_model" (id BIGSERIAL PRIMARY KEY, type TEXT, name TEXT, image TEXT, owner_id INT);
+CREATE TABLE IF NOT EXISTS "worker_model" (id BIGSERIAL PRIMARY KEY, type TEXT, name TEXT, image TEXT, owner_id INT, memory BIGINT DEFAULT 0, cpu REAL DEFAULT 0, disk BIGINT DEFAULT 0, spawn_errors INT DEFAULT 0, last_spawn_error TEXT DEFAULT $$$$, unhealthy_until TIMESTAMP WITH TIME ZONE, validated BOOL DEFAULT false, need_validation BOOL DEFAULT false, validation_report TEXT, pool TEXT);
+CREATE TABLE IF NOT EXISTS "worker_model_spawn_error" (id BIGSERIAL PRIMARY KEY, worker_model_id BIGINT, hatchery_id BIGINT, hatchery_name TEXT, message TEXT, created TIMESTAMP WITH TIME ZONE);
+CREATE TABLE IF NOT EXISTS "worker_model_reservation" (id BIGSERIAL PRIMARY KEY, worker_model_id BIGINT, hatchery_id BIGINT, action_build_id BIGINT, expire TIMESTAMP WITH TIME ZONE);
 
-CREATE TABLE IF NOT EXISTS "hatchery" (id BIGSERIAL PRIMARY KEY, name TEXT, last_beat TIMESTAMP WITH TIME ZONE, owner_id INT, status TEXT);
+CREATE TABLE IF NOT EXISTS "hatchery" (id BIGSERIAL PRIMARY KEY, name TEXT, last_beat TIMESTAMP WITH TIME ZONE, owner_id INT, status TEXT, draining BOOL);
 CREATE TABLE IF NOT EXISTS "hatchery_model" (hatchery_id BIGINT, worker_model_id BIGINT, PRIMARY KEY(hatchery_id, worker_model_id));
 
 CREATE TABLE IF NOT EXISTS "repositories_manager" (id BIGSERIAL PRIMARY KEY , type TEXT, name TEXT UNIQUE, url TEXT UNIQUE, data JSONB );
@@ -70,6 +80,19 @@ CREATE TABLE IF NOT EXISTS "repositories_manager_project" ( id_repositories_mana
 
 CREATE TABLE IF NOT EXISTS "stats" (day DATE PRIMARY KEY, build BIGINT, unit_test BIGINT, testing BIGINT, deployment BIGINT, max_building_worker BIGINT, max_building_pipeline BIGINT);
 CREATE TABLE IF NOT EXISTS "activity" (day DATE, project_id BIGINT, application_id BIGINT, build BIGINT, unit_test BIGINT, testing BIGINT, deployment BIGINT, PRIMARY KEY(day, project_id, application_id));
+CREATE TABLE IF NOT EXISTS "spawn_latency" (day DATE, worker_model_id BIGINT, started BIGINT, total_latency BIGINT, max_latency BIGINT, PRIMARY KEY(day, worker_model_id));
+
+CREATE TABLE IF NOT EXISTS "workspace_cache" (id BIGSERIAL PRIMARY KEY, project_id BIGINT, cache_key TEXT, size BIGINT, md5sum TEXT, object_path TEXT, created TIMESTAMP WITH TIME ZONE, last_used TIMESTAMP WITH TIME ZONE);
+CREATE TABLE IF NOT EXISTS "workspace_cache_quota" (project_id BIGINT PRIMARY KEY, size BIGINT);
+
+CREATE TABLE IF NOT EXISTS "artifact_upload" (id BIGSERIAL PRIMARY KEY, upload_hash TEXT, pipeline_id INT, application_id INT, environment_id INT, build_number INT, tag TEXT, name TEXT, size BIGINT, perm INT, md5sum TEXT, sha256sum TEXT, chunk_size BIGINT, created TIMESTAMP WITH TIME ZONE);
+CREATE TABLE IF NOT EXISTS "artifact_upload_chunk" (upload_id BIGINT, chunk_index INT, size BIGINT, sha256sum TEXT, object_path TEXT, PRIMARY KEY(upload_id, chunk_index));
+
+CREATE TABLE IF NOT EXISTS "artifact_release" (id BIGSERIAL PRIMARY KEY, application_id BIGINT, name TEXT, pipeline TEXT, environment TEXT, build_number INT, author TEXT, created TIMESTAMP WITH TIME ZONE);
+CREATE TABLE IF NOT EXISTS "artifact_release_file" (id BIGSERIAL PRIMARY KEY, release_id BIGINT, name TEXT, size BIGINT, perm INT, md5sum TEXT, sha256sum TEXT, object_path TEXT);
+CREATE TABLE IF NOT EXISTS "artifact_release_consumer" (release_id BIGINT, pipeline TEXT, environment TEXT, build_number INT, consumed TIMESTAMP WITH TIME ZONE);
+
+CREATE TABLE IF NOT EXISTS "pipeline_build_test_case" (id BIGSERIAL PRIMARY KEY, pipeline_build_id BIGINT, application_id BIGINT, pipeline_id BIGINT, environment_id BIGINT, build_number INT, branch TEXT, hash TEXT, suite TEXT, name TEXT, status TEXT, duration TEXT, created TIMESTAMP WITH TIME ZONE);
 
 CREATE TABLE IF NOT EXISTS "warning" (id BIGSERIAL PRIMARY KEY, project_id BIGINT, app_id BIGINT, pip_id BIGINT, env_id BIGINT, action_id BIGINT, warning_id BIGINT, message_param JSONB);
 
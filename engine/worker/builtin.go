@@ -22,6 +22,12 @@ func runBuiltin(a *sdk.Action, actionBuild sdk.ActionBuild) sdk.Result {
 		return runNotifAction(a, actionBuild)
 	case sdk.JUnitAction:
 		return runParseJunitTestResultAction(a, actionBuild)
+	case sdk.CacheSave:
+		return runCacheSave(a, actionBuild)
+	case sdk.CacheRestore:
+		return runCacheRestore(a, actionBuild)
+	case sdk.GitCloneAction:
+		return runGitClone(a, actionBuild)
 	}
 
 	sendLog(actionBuild.ID, name, fmt.Sprintf("Unknown builtin step: %s\n", name))
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/ovh/cds/engine/log"
@@ -89,13 +90,18 @@ func runArtifactUpload(a *sdk.Action, actionBuild sdk.ActionBuild) sdk.Result {
 func runArtifactDownload(a *sdk.Action, actionBuild sdk.ActionBuild) sdk.Result {
 	res := sdk.Result{Status: sdk.StatusSuccess}
 
-	var project, pipeline, application, environment, tag, filePath string
+	var project, pipeline, application, environment, tag, filePath, release string
+	var currentPipeline, buildNumber string
 
 	for _, p := range actionBuild.Args {
 		switch p.Name {
 		case "cds.pipeline":
 			fmt.Printf("runArtifactDownload: cds.pipeline=%s\n", p.Value)
 			pipeline = p.Value
+			currentPipeline = p.Value
+			break
+		case "cds.buildNumber":
+			buildNumber = p.Value
 			break
 		case "cds.project":
 			fmt.Printf("runArtifactDownload: cds.project=%s\n", p.Value)
@@ -129,9 +135,16 @@ func runArtifactDownload(a *sdk.Action, actionBuild sdk.ActionBuild) sdk.Result
 		case "application":
 			fmt.Printf("runArtifactDownload: application=%s\n", p.Value)
 			application = p.Value
+		case "release":
+			fmt.Printf("runArtifactDownload: release=%s\n", p.Value)
+			release = resolvedValue(p.Value)
 		}
 	}
 
+	if release != "" {
+		return runReleaseDownload(actionBuild, project, application, release, filePath, currentPipeline, environment, buildNumber)
+	}
+
 	if tag == "" {
 		res.Status = sdk.StatusFail
 		sendLog(actionBuild.ID, sdk.ArtifactDownload, fmt.Sprintf("tag variable is empty. aborting\n"))
@@ -154,3 +167,24 @@ func runArtifactDownload(a *sdk.Action, actionBuild sdk.ActionBuild) sdk.Result
 
 	return res
 }
+
+func runReleaseDownload(actionBuild sdk.ActionBuild, project, application, release, filePath, pipeline, environment, buildNumber string) sdk.Result {
+	res := sdk.Result{Status: sdk.StatusSuccess}
+
+	sendLog(actionBuild.ID, sdk.ArtifactDownload, fmt.Sprintf("Downloading artifacts of release %s-%s/%s into '%s'...\n", project, application, release, filePath))
+	if err := sdk.DownloadReleaseArtifacts(project, application, release, filePath); err != nil {
+		res.Status = sdk.StatusFail
+		log.Warning("Cannot download release artifacts: %s\n", err)
+		sendLog(actionBuild.ID, sdk.ArtifactDownload, fmt.Sprintf("%s\n", err))
+		return res
+	}
+
+	// Keep track of where the release is used
+	bn, _ := strconv.Atoi(buildNumber)
+	if err := sdk.AddReleaseConsumer(project, application, release, pipeline, environment, bn); err != nil {
+		log.Warning("Cannot record release consumer: %s\n", err)
+		sendLog(actionBuild.ID, sdk.ArtifactDownload, fmt.Sprintf("Cannot record use of release %s: %s\n", release, err))
+	}
+
+	return res
+}
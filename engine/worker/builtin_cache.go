@@ -0,0 +1,382 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func runCacheSave(a *sdk.Action, actionBuild sdk.ActionBuild) sdk.Result {
+	res := sdk.Result{Status: sdk.StatusFail}
+
+	var keyTmpl, paths, project string
+	for _, p := range a.Parameters {
+		switch p.Name {
+		case "key":
+			keyTmpl = p.Value
+		case "path":
+			paths = p.Value
+		}
+	}
+	for _, p := range actionBuild.Args {
+		if p.Name == "cds.project" {
+			project = p.Value
+		}
+	}
+
+	key, err := renderCacheKey(keyTmpl)
+	if err != nil {
+		sendLog(actionBuild.ID, sdk.CacheSave, fmt.Sprintf("Invalid cache key '%s': %s\n", keyTmpl, err))
+		return res
+	}
+
+	existing, err := sdk.GetWorkspaceCache(project, key, nil)
+	if err != nil {
+		sendLog(actionBuild.ID, sdk.CacheSave, fmt.Sprintf("Cannot check cache %s: %s\n", key, err))
+		return res
+	}
+	if existing != nil {
+		sendLog(actionBuild.ID, sdk.CacheSave, fmt.Sprintf("Cache %s already exists, nothing to save\n", key))
+		res.Status = sdk.StatusSuccess
+		return res
+	}
+
+	tmp, err := ioutil.TempFile("", "cds-cache")
+	if err != nil {
+		sendLog(actionBuild.ID, sdk.CacheSave, fmt.Sprintf("Cannot create temporary file: %s\n", err))
+		return res
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := tarPaths(tmp, splitLines(paths))
+	tmp.Close()
+	if err != nil {
+		sendLog(actionBuild.ID, sdk.CacheSave, fmt.Sprintf("Cannot archive %s: %s\n", strings.Join(splitLines(paths), ", "), err))
+		return res
+	}
+	if n == 0 {
+		sendLog(actionBuild.ID, sdk.CacheSave, fmt.Sprintf("Paths matched no file, nothing to save\n"))
+		res.Status = sdk.StatusSuccess
+		return res
+	}
+
+	sendLog(actionBuild.ID, sdk.CacheSave, fmt.Sprintf("Saving %d files into cache %s...\n", n, key))
+	if err := sdk.UploadWorkspaceCache(project, key, tmp.Name()); err != nil {
+		sendLog(actionBuild.ID, sdk.CacheSave, fmt.Sprintf("Cannot upload cache %s: %s\n", key, err))
+		return res
+	}
+
+	res.Status = sdk.StatusSuccess
+	return res
+}
+
+func runCacheRestore(a *sdk.Action, actionBuild sdk.ActionBuild) sdk.Result {
+	res := sdk.Result{Status: sdk.StatusFail}
+
+	var keyTmpl, restoreKeys, dest, project string
+	for _, p := range a.Parameters {
+		switch p.Name {
+		case "key":
+			keyTmpl = p.Value
+		case "restore-keys":
+			restoreKeys = p.Value
+		case "path":
+			dest = p.Value
+		}
+	}
+	for _, p := range actionBuild.Args {
+		if p.Name == "cds.project" {
+			project = p.Value
+		}
+	}
+	if dest == "" {
+		dest = "."
+	}
+
+	key, err := renderCacheKey(keyTmpl)
+	if err != nil {
+		sendLog(actionBuild.ID, sdk.CacheRestore, fmt.Sprintf("Invalid cache key '%s': %s\n", keyTmpl, err))
+		return res
+	}
+
+	var prefixes []string
+	for _, k := range splitLines(restoreKeys) {
+		p, err := renderCacheKey(k)
+		if err != nil {
+			sendLog(actionBuild.ID, sdk.CacheRestore, fmt.Sprintf("Invalid restore key '%s': %s\n", k, err))
+			return res
+		}
+		prefixes = append(prefixes, p)
+	}
+
+	c, err := sdk.GetWorkspaceCache(project, key, prefixes)
+	if err != nil {
+		sendLog(actionBuild.ID, sdk.CacheRestore, fmt.Sprintf("Cannot look for cache %s: %s\n", key, err))
+		return res
+	}
+	res.Status = sdk.StatusSuccess
+	if c == nil {
+		sendLog(actionBuild.ID, sdk.CacheRestore, fmt.Sprintf("Cache miss for %s\n", key))
+		return res
+	}
+
+	tmp, err := ioutil.TempFile("", "cds-cache")
+	if err != nil {
+		sendLog(actionBuild.ID, sdk.CacheRestore, fmt.Sprintf("Cannot create temporary file: %s\n", err))
+		res.Status = sdk.StatusFail
+		return res
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	sendLog(actionBuild.ID, sdk.CacheRestore, fmt.Sprintf("Restoring cache %s (%d bytes) into '%s'...\n", c.Key, c.Size, dest))
+	if err := sdk.DownloadWorkspaceCache(project, *c, tmp); err != nil {
+		sendLog(actionBuild.ID, sdk.CacheRestore, fmt.Sprintf("Cannot download cache %s: %s\n", c.Key, err))
+		res.Status = sdk.StatusFail
+		return res
+	}
+
+	if _, err := tmp.Seek(0, 0); err != nil {
+		res.Status = sdk.StatusFail
+		return res
+	}
+
+	if err := untar(tmp, dest); err != nil {
+		sendLog(actionBuild.ID, sdk.CacheRestore, fmt.Sprintf("Cannot extract cache %s: %s\n", c.Key, err))
+		res.Status = sdk.StatusFail
+		return res
+	}
+
+	return res
+}
+
+// renderCacheKey processes hashFiles calls remaining in key once
+// build variables have been replaced
+func renderCacheKey(key string) (string, error) {
+	funcs := template.FuncMap{"hashFiles": hashFiles}
+	t, err := template.New("key").Funcs(funcs).Option("missingkey=error").Parse(key)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, map[string]interface{}{}); err != nil {
+		return "", err
+	}
+
+	// Keys are part of API urls
+	k := strings.TrimSpace(buf.String())
+	k = strings.Replace(k, "/", "-", -1)
+	if k == "" {
+		return "", fmt.Errorf("key is empty")
+	}
+	return k, nil
+}
+
+// hashFiles computes the sha256 of all files matching given patterns, in lexical order
+func hashFiles(patterns ...string) (string, error) {
+	var files []string
+	for _, p := range patterns {
+		m, err := filepath.Glob(p)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, m...)
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("hashFiles: %s matched no file", strings.Join(patterns, ", "))
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	var last string
+	for _, f := range files {
+		if f == last {
+			continue
+		}
+		last = f
+
+		fi, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		if fi.IsDir() {
+			continue
+		}
+
+		file, err := os.Open(f)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// tarPaths writes a gzipped tarball of all files matching given patterns into w
+// and returns the number of files archived
+func tarPaths(w io.Writer, patterns []string) (int, error) {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	var n int
+	for _, p := range patterns {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return n, err
+		}
+
+		for _, m := range matches {
+			err := filepath.Walk(m, func(file string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+
+				var link string
+				if fi.Mode()&os.ModeSymlink != 0 {
+					if link, err = os.Readlink(file); err != nil {
+						return err
+					}
+				}
+
+				hdr, err := tar.FileInfoHeader(fi, link)
+				if err != nil {
+					return err
+				}
+				hdr.Name = filepath.ToSlash(file)
+				if err := tw.WriteHeader(hdr); err != nil {
+					return err
+				}
+
+				if !fi.Mode().IsRegular() {
+					return nil
+				}
+
+				f, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				if _, err := io.Copy(tw, f); err != nil {
+					return err
+				}
+				n++
+				return nil
+			})
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return n, err
+	}
+	return n, gw.Close()
+}
+
+// untar extracts gzipped tarball r into dest
+func untar(r io.Reader, dest string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(hdr.Name))
+		if rel, err := filepath.Rel(dest, target); err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("invalid path %s in archive", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(hdr.Mode)); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := checkLink(dest, target, hdr.Linkname); err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// checkLink returns an error when the link target would point outside dest, so that next entries of the archive
+// cannot be written through it
+func checkLink(dest, target, linkname string) error {
+	if filepath.IsAbs(linkname) {
+		return fmt.Errorf("invalid link %s -> %s in archive", target, linkname)
+	}
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+	// Links already extracted in the directory of the link are followed
+	dir, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, filepath.Join(dir, linkname))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("invalid link %s -> %s in archive", target, linkname)
+	}
+	return nil
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
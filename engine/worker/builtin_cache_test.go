@@ -0,0 +1,149 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderCacheKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cds-cache-test")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	lock := filepath.Join(dir, "glide.lock")
+	if err := ioutil.WriteFile(lock, []byte("hash: 1234\n"), 0644); err != nil {
+		t.Fatalf("cannot write lockfile: %s", err)
+	}
+
+	k1, err := renderCacheKey(`myapp-{{hashFiles "` + lock + `"}}`)
+	if err != nil {
+		t.Fatalf("renderCacheKey should not fail: %s", err)
+	}
+
+	if err := ioutil.WriteFile(lock, []byte("hash: 5678\n"), 0644); err != nil {
+		t.Fatalf("cannot write lockfile: %s", err)
+	}
+	k2, err := renderCacheKey(`myapp-{{hashFiles "` + lock + `"}}`)
+	if err != nil {
+		t.Fatalf("renderCacheKey should not fail: %s", err)
+	}
+
+	if k1 == k2 {
+		t.Fatalf("key should change with lockfile content: %s", k1)
+	}
+
+	if _, err := renderCacheKey(`myapp-{{hashFiles "` + filepath.Join(dir, "nope") + `"}}`); err == nil {
+		t.Fatalf("renderCacheKey should fail when no file matches")
+	}
+
+	if _, err := renderCacheKey(`{{.cds.unknown}}`); err == nil {
+		t.Fatalf("renderCacheKey should fail on unresolved variable")
+	}
+}
+
+func TestTarUntar(t *testing.T) {
+	src, err := ioutil.TempDir("", "cds-cache-test")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(src)
+
+	dest, err := ioutil.TempDir("", "cds-cache-test")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dest)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(src)
+
+	os.MkdirAll(filepath.Join("vendor", "foo"), 0755)
+	ioutil.WriteFile(filepath.Join("vendor", "foo", "bar.go"), []byte("package foo"), 0644)
+
+	var buf bytes.Buffer
+	n, err := tarPaths(&buf, []string{"vendor"})
+	if err != nil {
+		t.Fatalf("tarPaths should not fail: %s", err)
+	}
+	if n != 1 {
+		t.Fatalf("tarPaths should archive 1 file, got %d", n)
+	}
+
+	if err := untar(&buf, dest); err != nil {
+		t.Fatalf("untar should not fail: %s", err)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "vendor", "foo", "bar.go"))
+	if err != nil {
+		t.Fatalf("file should have been extracted: %s", err)
+	}
+	if string(content) != "package foo" {
+		t.Fatalf("wrong content: %s", content)
+	}
+}
+
+func TestUntarLinks(t *testing.T) {
+	outside, err := ioutil.TempDir("", "cds-cache-test")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(outside)
+
+	type entry struct {
+		name, link, content string
+	}
+	archive := func(entries ...entry) *bytes.Buffer {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gw)
+		for _, e := range entries {
+			hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.content))}
+			if e.link != "" {
+				hdr = &tar.Header{Name: e.name, Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: e.link}
+			}
+			tw.WriteHeader(hdr)
+			tw.Write([]byte(e.content))
+		}
+		tw.Close()
+		gw.Close()
+		return &buf
+	}
+
+	tests := []struct {
+		name    string
+		entries []entry
+		valid   bool
+	}{
+		{"absolute link", []entry{{name: "a", link: outside}, {name: "a/x", content: "pwned"}}, false},
+		{"relative link", []entry{{name: "a", link: "../" + filepath.Base(outside)}, {name: "a/x", content: "pwned"}}, false},
+		{"chained links", []entry{{name: "d/l", link: ".."}, {name: "d/l/l2", link: "../" + filepath.Base(outside)}, {name: "d/l/l2/x", content: "pwned"}}, false},
+		{"inner link", []entry{{name: "lib/v1/foo.go", content: "package foo"}, {name: "lib/current", link: "v1"}}, true},
+	}
+
+	for _, tt := range tests {
+		dest, err := ioutil.TempDir("", "cds-cache-test")
+		if err != nil {
+			t.Fatalf("cannot create temporary directory: %s", err)
+		}
+		defer os.RemoveAll(dest)
+
+		err = untar(archive(tt.entries...), dest)
+		if tt.valid && err != nil {
+			t.Errorf("%s: untar should not fail: %s", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: untar should fail", tt.name)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "x")); err == nil {
+			t.Fatalf("%s: file has been written outside of destination", tt.name)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// gitaskpassscript answers git credential prompts with CDS_GIT_USER and CDS_GIT_PASSWORD,
+// so tokens never appear in command lines nor in remote URLs
+const gitaskpassscript = `#!/bin/sh
+case "$1" in
+	Username*) echo "$CDS_GIT_USER" ;;
+	*) echo "$CDS_GIT_PASSWORD" ;;
+esac
+`
+
+func runGitClone(a *sdk.Action, actionBuild sdk.ActionBuild) sdk.Result {
+	res := sdk.Result{Status: sdk.StatusFail}
+
+	var url, branch, commit, dir, user, password string
+	var depth int
+	var submodules bool
+	for _, p := range a.Parameters {
+		switch p.Name {
+		case "url":
+			url = resolvedValue(p.Value)
+		case "branch":
+			branch = resolvedValue(p.Value)
+		case "commit":
+			commit = resolvedValue(p.Value)
+		case "directory":
+			dir = resolvedValue(p.Value)
+		case "depth":
+			depth, _ = strconv.Atoi(p.Value)
+		case "submodules":
+			submodules, _ = strconv.ParseBool(p.Value)
+		case "user":
+			user = resolvedValue(p.Value)
+		case "password":
+			password = resolvedValue(p.Value)
+		}
+	}
+
+	if url == "" {
+		var err error
+		url, err = applicationRepositoryURL(actionBuild, password != "")
+		if err != nil {
+			sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("Cannot find repository to clone: %s\n", err))
+			return res
+		}
+	}
+	if dir == "" {
+		dir = gitCloneDirectory(url)
+	}
+
+	env := os.Environ()
+	env = append(env, "GIT_TERMINAL_PROMPT=0")
+	if pkey != "" && gitssh != "" {
+		env = append(env, fmt.Sprintf("%s=%s", pKEY, pkey))
+		env = append(env, fmt.Sprintf("%s=%s", GitSSH, gitssh))
+	}
+	if password != "" {
+		askpass, err := ioutil.TempFile("", "cds-askpass")
+		if err != nil {
+			sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("Cannot create temporary file: %s\n", err))
+			return res
+		}
+		defer os.Remove(askpass.Name())
+		_, err = askpass.Write([]byte(gitaskpassscript))
+		askpass.Close()
+		if err == nil {
+			err = os.Chmod(askpass.Name(), 0700)
+		}
+		if err != nil {
+			sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("Cannot write askpass script: %s\n", err))
+			return res
+		}
+
+		// Github and Gitlab accept tokens as user name
+		if user == "" {
+			user = password
+		}
+		env = append(env, "GIT_ASKPASS="+askpass.Name(), "CDS_GIT_USER="+user, "CDS_GIT_PASSWORD="+password)
+	}
+
+	git := func(wd string, args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = wd
+		cmd.Env = env
+		out, err := cmd.CombinedOutput()
+		return strings.TrimSpace(string(out)), err
+	}
+
+	args := []string{"clone"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth), "--no-single-branch")
+	}
+	// Builds of a tag have no branch, the tag is cloned instead
+	tag := buildArg(actionBuild, "git.tag")
+	if branch != "" {
+		args = append(args, "--branch", branch)
+	} else if tag != "" {
+		args = append(args, "--branch", tag)
+	}
+	args = append(args, url, dir)
+
+	sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("Cloning %s into '%s'...\n", url, dir))
+	if out, err := git("", args...); err != nil {
+		sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("%s\nCannot clone %s: %s\n", out, url, err))
+		return res
+	}
+
+	if commit != "" {
+		sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("Checking out %s...\n", commit))
+		out, err := git(dir, "checkout", "--quiet", commit)
+		if err != nil && depth > 0 {
+			// Commit is older than the shallow history, fetch the rest of it
+			sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("%s not found in last %d commits, fetching full history...\n", commit, depth))
+			if out, err = git(dir, "fetch", "--unshallow", "origin"); err == nil {
+				out, err = git(dir, "checkout", "--quiet", commit)
+			}
+		}
+		if err != nil {
+			sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("%s\nCannot checkout %s: %s\n", out, commit, err))
+			return res
+		}
+	}
+
+	if submodules {
+		sendLog(actionBuild.ID, sdk.GitCloneAction, "Updating submodules...\n")
+		if out, err := git(dir, "submodule", "update", "--init", "--recursive"); err != nil {
+			sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("%s\nCannot update submodules: %s\n", out, err))
+			return res
+		}
+	}
+
+	// Export what has actually been checked out
+	vars := map[string][]string{
+		"git.hash":    {"rev-parse", "HEAD"},
+		"git.author":  {"log", "-1", "--format=%an"},
+		"git.message": {"log", "-1", "--format=%s"},
+	}
+	if branch == "" && tag == "" {
+		vars["git.branch"] = []string{"rev-parse", "--abbrev-ref", "HEAD"}
+	}
+	exports := map[string]string{"git.url": url}
+	if branch != "" {
+		exports["git.branch"] = branch
+	}
+	for name, args := range vars {
+		out, err := git(dir, args...)
+		if err != nil {
+			sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("%s\nCannot get %s: %s\n", out, name, err))
+			return res
+		}
+		exports[name] = out
+	}
+
+	for name, value := range exports {
+		v := sdk.Variable{Name: name, Type: sdk.StringVariable, Value: value}
+		if err := exportBuildVariable(v); err != nil {
+			sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("Cannot export %s: %s\n", name, err))
+			return res
+		}
+	}
+	sendLog(actionBuild.ID, sdk.GitCloneAction, fmt.Sprintf("Checked out %s on %s: %s\n", exports["git.hash"], exports["git.branch"], exports["git.message"]))
+
+	res.Status = sdk.StatusSuccess
+	return res
+}
+
+// applicationRepositoryURL returns the clone URL of the repository attached to the application being built.
+// HTTPS URL is preferred when credentials are given or when no ssh key is available.
+func applicationRepositoryURL(actionBuild sdk.ActionBuild, https bool) (string, error) {
+	var project, app, gitURL string
+	for _, p := range actionBuild.Args {
+		switch p.Name {
+		case "cds.project":
+			project = p.Value
+		case "cds.application":
+			app = p.Value
+		case "git.url":
+			gitURL = p.Value
+		}
+	}
+
+	application, err := sdk.GetApplication(project, app)
+	if err != nil {
+		return "", err
+	}
+
+	if application.RepositoriesManager == nil || application.RepositoryFullname == "" {
+		// Build may have been triggered by a hook without repositories manager
+		if gitURL != "" {
+			return gitURL, nil
+		}
+		return "", fmt.Errorf("application %s is not attached to any repository", app)
+	}
+
+	repo, err := sdk.GetProjectRepoFromReposManager(project, application.RepositoriesManager.Name, application.RepositoryFullname)
+	if err != nil {
+		return "", err
+	}
+
+	if !https && pkey != "" && repo.SSHCloneURL != "" {
+		return repo.SSHCloneURL, nil
+	}
+	return repo.HTTPCloneURL, nil
+}
+
+// gitCloneDirectory returns the directory git clone would create for url
+func gitCloneDirectory(url string) string {
+	url = strings.TrimRight(url, "/")
+	if i := strings.LastIndexAny(url, "/:"); i >= 0 {
+		url = url[i+1:]
+	}
+	return strings.TrimSuffix(path.Base(url), ".git")
+}
+
+// buildArg returns the value of a parameter of the build
+func buildArg(actionBuild sdk.ActionBuild, name string) string {
+	for _, p := range actionBuild.Args {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// resolvedValue returns an empty string for parameters referencing an unknown variable
+func resolvedValue(v string) string {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "{{.") && strings.HasSuffix(v, "}}") {
+		return ""
+	}
+	return v
+}
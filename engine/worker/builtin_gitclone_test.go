@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestGitCloneDirectory(t *testing.T) {
+	urls := map[string]string{
+		"https://github.com/ovh/cds.git":         "cds",
+		"ssh://git@stash.local:7999/prj/app.git": "app",
+		"git@github.com:ovh/cds.git":             "cds",
+		"git@github.com:cds":                     "cds",
+		"https://gitea.local/prj/app/":           "app",
+	}
+	for url, dir := range urls {
+		if d := gitCloneDirectory(url); d != dir {
+			t.Fatalf("gitCloneDirectory(%s) should be %s, got %s", url, dir, d)
+		}
+	}
+}
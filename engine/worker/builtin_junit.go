@@ -65,15 +65,27 @@ func runParseJunitTestResultAction(a *sdk.Action, ab sdk.ActionBuild) sdk.Result
 			return res
 		}
 
-		err = xml.Unmarshal([]byte(data), &v)
-		if err != nil {
-			sendLog(ab.ID, sdk.JUnitAction, fmt.Sprintf("UnitTest parser: cannot interpret file %s (%s)", f, err))
-			return res
-		}
-
-		// Is it nosetests format ?
-		if s, ok := parseNoseTests(data); ok {
-			ftests.TestSuites = append(ftests.TestSuites, s)
+		switch {
+		case isTAP(data):
+			ftests.TestSuites = append(ftests.TestSuites, parseTAP(f, data))
+		case isTest2JSON(data):
+			suites, err := parseTest2JSON(data)
+			if err != nil {
+				sendLog(ab.ID, sdk.JUnitAction, fmt.Sprintf("UnitTest parser: cannot interpret file %s (%s)", f, err))
+				return res
+			}
+			ftests.TestSuites = append(ftests.TestSuites, suites...)
+		default:
+			err = xml.Unmarshal([]byte(data), &v)
+			if err != nil {
+				sendLog(ab.ID, sdk.JUnitAction, fmt.Sprintf("UnitTest parser: cannot interpret file %s (%s)", f, err))
+				return res
+			}
+
+			// Is it nosetests format ?
+			if s, ok := parseNoseTests(data); ok {
+				ftests.TestSuites = append(ftests.TestSuites, s)
+			}
 		}
 
 		v.TestSuites = append(v.TestSuites, ftests.TestSuites...)
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ovh/cds/sdk"
+)
+
+var tapResultRegexp = regexp.MustCompile(`^(not ok|ok)\b\s*(\d+)?\s*-?\s*(.*?)\s*(?:#\s*((?i)skip|todo)\S*\s*(.*))?$`)
+
+// isTAP returns true if data looks like a Test Anything Protocol report
+func isTAP(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return strings.HasPrefix(line, "TAP version") || strings.HasPrefix(line, "1..") || tapResultRegexp.MatchString(line)
+	}
+	return false
+}
+
+// parseTAP reads a TAP report into a test suite named after the report file.
+// Diagnostics following a failed test are kept as its failure message.
+func parseTAP(file string, data []byte) sdk.TestSuite {
+	s := sdk.TestSuite{Name: strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))}
+
+	var last *sdk.Test
+	var yaml bool
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+
+		if yaml {
+			if line == "..." {
+				yaml = false
+			} else if last != nil && last.Failure != "" {
+				last.Failure += "\n" + raw
+			}
+			continue
+		}
+
+		switch {
+		case line == "---":
+			yaml = true
+		case strings.HasPrefix(line, "Bail out!"):
+			// An aborted suite must fail the step, which only looks at failures
+			s.Tests = append(s.Tests, sdk.Test{Name: "Bail out", Failure: line})
+			s.Failures++
+			last = nil
+		case strings.HasPrefix(line, "#"):
+			if last != nil && last.Failure != "" {
+				last.Failure += "\n" + strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			}
+		default:
+			m := tapResultRegexp.FindStringSubmatch(line)
+			if m == nil || raw != strings.TrimLeft(raw, " \t") {
+				// Plan, version or subtest output
+				continue
+			}
+			t := sdk.Test{Name: m[3]}
+			if t.Name == "" {
+				t.Name = "test " + m[2]
+			}
+			switch {
+			case m[4] != "":
+				// TODO tests are expected to fail and do not count as failures
+				reason := m[5]
+				t.Skip = &reason
+				s.Skip++
+			case m[1] == "not ok":
+				t.Failure = line
+				s.Failures++
+			}
+			s.Tests = append(s.Tests, t)
+			last = &s.Tests[len(s.Tests)-1]
+		}
+	}
+
+	s.Total = len(s.Tests)
+	return s
+}
+
+// test2jsonEvent is a line of `go test -json` output
+type test2jsonEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// isTest2JSON returns true if data looks like `go test -json` output
+func isTest2JSON(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e test2jsonEvent
+		if err := json.Unmarshal(line, &e); err != nil {
+			return false
+		}
+		return e.Action != ""
+	}
+	return false
+}
+
+// parseTest2JSON reads `go test -json` output into one test suite per package.
+// A package failing without any failed test (build failure, panic in init...) is reported as a failed test named after the package.
+func parseTest2JSON(data []byte) ([]sdk.TestSuite, error) {
+	type pkg struct {
+		suite  sdk.TestSuite
+		output map[string]*bytes.Buffer
+		failed bool
+	}
+	var order []string
+	pkgs := map[string]*pkg{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e test2jsonEvent
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, err
+		}
+
+		p, ok := pkgs[e.Package]
+		if !ok {
+			p = &pkg{suite: sdk.TestSuite{Name: e.Package}, output: map[string]*bytes.Buffer{}}
+			pkgs[e.Package] = p
+			order = append(order, e.Package)
+		}
+
+		switch e.Action {
+		case "output":
+			if p.output[e.Test] == nil {
+				p.output[e.Test] = &bytes.Buffer{}
+			}
+			p.output[e.Test].WriteString(e.Output)
+		case "pass", "fail", "skip":
+			if e.Test == "" {
+				p.failed = e.Action == "fail"
+				continue
+			}
+			t := sdk.Test{Name: e.Test, Time: strconv.FormatFloat(e.Elapsed, 'f', 3, 64)}
+			var out string
+			if b := p.output[e.Test]; b != nil {
+				out = b.String()
+			}
+			switch e.Action {
+			case "fail":
+				t.Failure = out
+				p.suite.Failures++
+			case "skip":
+				t.Skip = &out
+				p.suite.Skip++
+			}
+			p.suite.Tests = append(p.suite.Tests, t)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	suites := make([]sdk.TestSuite, 0, len(order))
+	for _, name := range order {
+		p := pkgs[name]
+		if p.failed && p.suite.Failures == 0 {
+			var out string
+			if b := p.output[""]; b != nil {
+				out = b.String()
+			}
+			p.suite.Tests = append(p.suite.Tests, sdk.Test{Name: name, Failure: out})
+			p.suite.Failures++
+		}
+		p.suite.Total = len(p.suite.Tests)
+		suites = append(suites, p.suite)
+	}
+	return suites, nil
+}
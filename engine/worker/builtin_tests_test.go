@@ -0,0 +1,90 @@
+package main
+
+import "testing"
+
+func TestParseTAP(t *testing.T) {
+	data := []byte(`TAP version 13
+1..5
+ok 1 - parses config
+not ok 2 - connects to database
+  ---
+  message: connection refused
+  ...
+ok 3 - uploads artifact # SKIP no object store
+not ok 4 - handles retries # TODO not implemented
+ok 5
+`)
+	if !isTAP(data) {
+		t.Fatalf("report should be detected as TAP")
+	}
+
+	s := parseTAP("reports/api.tap", data)
+	if s.Name != "api" {
+		t.Fatalf("suite should be named api, got %s", s.Name)
+	}
+	if s.Total != 5 || s.Failures != 1 || s.Skip != 2 {
+		t.Fatalf("expected 5 tests, 1 failure, 2 skipped, got %d, %d, %d", s.Total, s.Failures, s.Skip)
+	}
+	if s.Tests[1].Name != "connects to database" || s.Tests[1].Status() != "fail" {
+		t.Fatalf("unexpected second test: %+v", s.Tests[1])
+	}
+	if s.Tests[2].Skip == nil || *s.Tests[2].Skip != "no object store" {
+		t.Fatalf("third test should be skipped: %+v", s.Tests[2])
+	}
+	if s.Tests[4].Name != "test 5" {
+		t.Fatalf("unnamed test should be named after its number, got %s", s.Tests[4].Name)
+	}
+}
+
+func TestParseTAPBailOut(t *testing.T) {
+	data := []byte(`TAP version 13
+1..3
+ok 1 - parses config
+Bail out! database is down
+`)
+
+	s := parseTAP("reports/api.tap", data)
+	if s.Failures != 1 {
+		t.Fatalf("bailed out suite should have 1 failure, got %d", s.Failures)
+	}
+	if s.Tests[1].Name != "Bail out" || s.Tests[1].Status() != "fail" {
+		t.Fatalf("unexpected bail out test: %+v", s.Tests[1])
+	}
+}
+
+func TestParseTest2JSON(t *testing.T) {
+	data := []byte(`{"Action":"run","Package":"a/b","Test":"TestOK"}
+{"Action":"output","Package":"a/b","Test":"TestOK","Output":"=== RUN   TestOK\n"}
+{"Action":"pass","Package":"a/b","Test":"TestOK","Elapsed":0.01}
+{"Action":"run","Package":"a/b","Test":"TestKO"}
+{"Action":"output","Package":"a/b","Test":"TestKO","Output":"    b_test.go:12: boom\n"}
+{"Action":"fail","Package":"a/b","Test":"TestKO","Elapsed":0.2}
+{"Action":"skip","Package":"a/b","Test":"TestSkip","Elapsed":0}
+{"Action":"fail","Package":"a/b","Elapsed":0.3}
+{"Action":"output","Package":"a/c","Output":"# a/c\nc.go:3: undefined: x\n"}
+{"Action":"fail","Package":"a/c","Elapsed":0}
+`)
+	if !isTest2JSON(data) {
+		t.Fatalf("report should be detected as test2json")
+	}
+	if isTAP(data) {
+		t.Fatalf("test2json report should not be detected as TAP")
+	}
+
+	suites, err := parseTest2JSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suites) != 2 {
+		t.Fatalf("expected 2 suites, got %d", len(suites))
+	}
+	if s := suites[0]; s.Total != 3 || s.Failures != 1 || s.Skip != 1 {
+		t.Fatalf("expected 3 tests, 1 failure, 1 skipped in a/b, got %d, %d, %d", s.Total, s.Failures, s.Skip)
+	}
+	if f := suites[0].Tests[1].Failure; f != "    b_test.go:12: boom\n" {
+		t.Fatalf("unexpected failure output: %q", f)
+	}
+	if s := suites[1]; s.Total != 1 || s.Failures != 1 || s.Tests[0].Name != "a/c" {
+		t.Fatalf("package failing to build should be reported as a failure: %+v", s)
+	}
+}
@@ -70,14 +70,21 @@ func addBuildVarHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := exportBuildVariable(v); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+}
+
+// exportBuildVariable adds v to current build variables, in worker and in API
+func exportBuildVariable(v sdk.Variable) error {
 	// OK, so now we got our new variable. We need to:
 	// - add it as a build var in API
 	buildVariables = append(buildVariables, v)
 	// - add it in current building Action
-	data, err = json.Marshal(v)
+	data, err := json.Marshal(v)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return err
 	}
 	// Retrieve build info
 	var proj, app, pip, bnS string
@@ -103,10 +110,7 @@ func addBuildVarHandler(w http.ResponseWriter, r *http.Request) {
 	if err == nil && code > 300 {
 		err = fmt.Errorf("HTTP %d", code)
 	}
-	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		return
-	}
+	return err
 }
 
 func exportCmd(cmd *cobra.Command, args []string) {
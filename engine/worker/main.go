@@ -35,6 +35,13 @@ var (
 	// Git ssh configuration
 	pkey   string
 	gitssh string
+	// action build the worker has been spawned for, the only one it takes
+	bookedActionBuild int64
+	spawnedAt         time.Time
+	// draining worker does not take new jobs, and exits once current one is done
+	draining bool
+	// version of engine, idle worker upgrades itself when running another one
+	apiVersion string
 )
 
 var mainCmd = &cobra.Command{
@@ -85,6 +92,24 @@ var mainCmd = &cobra.Command{
 
 		model = int64(viper.GetInt("model"))
 
+		// Worker re-executed after an upgrade keeps its registration
+		if id := viper.GetString("worker_id"); id != "" {
+			sdk.InitEndpoint(api)
+			WorkerID = id
+			sdk.Authorization(id)
+			log.Notice("Worker %s running version %s\n", name, VERSION)
+		}
+
+		upgradedFrom = viper.GetString("upgraded_from")
+		if upgradedFrom != "" {
+			log.Notice("Worker upgraded from %s to %s\n", upgradedFrom, VERSION)
+		}
+
+		bookedActionBuild = int64(viper.GetInt("action_build"))
+		if s := int64(viper.GetInt("spawned_at")); s > 0 {
+			spawnedAt = time.Unix(s, 0)
+		}
+
 		port, err := exportHandler()
 		if err != nil {
 			sdk.Exit("cannot bind port for worker export: %s\n", err)
@@ -133,6 +158,12 @@ func init() {
 	flags.String("basedir", "", "Worker working directory")
 	viper.BindPFlag("basedir", flags.Lookup("basedir"))
 
+	flags.Int("action-build", 0, "Action build the worker has been spawned for, the only one it takes before exiting")
+	viper.BindPFlag("action_build", flags.Lookup("action-build"))
+
+	flags.Int("spawned-at", 0, "Unix time the worker has been spawned at")
+	viper.BindPFlag("spawned_at", flags.Lookup("spawned-at"))
+
 	mainCmd.AddCommand(cmdExport)
 }
 
@@ -143,6 +174,7 @@ func main() {
 // Will be removed when websocket conn is implemented
 // for now, poll the /queue
 func queuePolling() {
+	var skippedVersion string
 	for {
 		if WorkerID == "" {
 			log.Notice("[WORKER] Disconnected from CDS engine, trying to register...\n")
@@ -154,6 +186,19 @@ func queuePolling() {
 			}
 		}
 
+		// Current job is done, leave
+		if draining {
+			exit("worker is drained")
+		}
+
+		// Long-lived idle worker runs the same version as engine
+		if !viper.GetBool("single_use") && bookedActionBuild == 0 && needUpgrade(VERSION, apiVersion) && apiVersion != skippedVersion {
+			if err := upgrade(apiVersion); err != nil {
+				log.Warning("Cannot upgrade worker to %s: %s\n", apiVersion, err)
+				skippedVersion = apiVersion
+			}
+		}
+
 		checkQueue()
 		time.Sleep(5 * time.Second)
 	}
@@ -169,7 +214,16 @@ func checkQueue() {
 		return
 	}
 
+	booked := false
 	for i := range queue {
+		// A worker spawned for an action build only takes this one
+		if bookedActionBuild != 0 {
+			if queue[i].ID != bookedActionBuild {
+				continue
+			}
+			booked = true
+		}
+
 		requirementsOK := true
 		// Check requirement
 		for _, r := range queue[i].Requirements {
@@ -187,8 +241,14 @@ func checkQueue() {
 
 		if requirementsOK {
 			takeAction(queue[i])
+		} else if booked {
+			exit(fmt.Sprintf("requirements of action build %d are not met", bookedActionBuild))
 		}
 	}
+
+	if bookedActionBuild != 0 && !booked {
+		exit(fmt.Sprintf("action build %d is not waiting anymore", bookedActionBuild))
+	}
 }
 
 func postCheckRequirementError(r *sdk.Requirement, err error) {
@@ -200,13 +260,24 @@ func postCheckRequirementError(r *sdk.Requirement, err error) {
 func takeAction(b sdk.ActionBuild) {
 	gitssh = ""
 	pkey = ""
+	var form worker.TakeForm
+	var latency time.Duration
+	if bookedActionBuild != 0 && !spawnedAt.IsZero() {
+		latency = time.Since(spawnedAt)
+		form.SpawnLatency = int64(latency / time.Millisecond)
+	}
+	body, _ := json.Marshal(form)
+
 	path := fmt.Sprintf("/queue/%d/take", b.ID)
-	data, code, err := sdk.Request("POST", path, nil)
+	data, code, err := sdk.Request("POST", path, body)
 	if err != nil {
 		log.Notice("takeAction> Cannot take action %d:%s\n", b.PipelineActionID, err)
 		return
 	}
 	if code != http.StatusOK {
+		if bookedActionBuild != 0 {
+			exit(fmt.Sprintf("action build %d has been taken by another worker", b.ID))
+		}
 		return
 	}
 
@@ -217,6 +288,17 @@ func takeAction(b sdk.ActionBuild) {
 		return
 	}
 
+	if latency > 0 {
+		log.Notice("takeAction> action build %d started %s after worker spawn\n", b.ID, latency)
+		sendLog(b.ID, "SYSTEM", fmt.Sprintf("Worker spawned for this job started it %s after spawn\n", latency))
+	}
+
+	// First job after an upgrade tells which version runs it
+	if upgradedFrom != "" {
+		sendLog(b.ID, "SYSTEM", fmt.Sprintf("Worker was upgraded from %s to %s before taking this job\n", upgradedFrom, VERSION))
+		upgradedFrom = ""
+	}
+
 	// Reset build variables
 	ab = abi.ActionBuild
 	buildVariables = nil
@@ -225,7 +307,7 @@ func takeAction(b sdk.ActionBuild) {
 	time.Sleep(3 * time.Second)
 
 	path = fmt.Sprintf("/queue/%d/result", b.ID)
-	body, err := json.MarshalIndent(res, " ", " ")
+	body, err = json.MarshalIndent(res, " ", " ")
 	if err != nil {
 		log.Notice("takeAction>Cannot marshal result: %s\n", err)
 		return
@@ -261,18 +343,26 @@ func takeAction(b sdk.ActionBuild) {
 	}
 
 	if viper.GetBool("single_use") {
-		// Give time to logs to be flushed
-		time.Sleep(2 * time.Second)
-		// Unregister from engine
-		err := unregister()
-		if err != nil {
-			log.Warning("takeAction> could not unregister: %s\n", err)
-		}
-		// then exit
-		log.Notice("takeAction> --single_use is on, exiting\n")
-		os.Exit(0)
+		exit("--single_use is on")
 	}
+	if bookedActionBuild != 0 {
+		exit(fmt.Sprintf("action build %d is done", b.ID))
+	}
+
+}
 
+// exit unregisters worker from engine then exits
+func exit(reason string) {
+	// Give time to logs to be flushed
+	time.Sleep(2 * time.Second)
+	// Unregister from engine
+	err := unregister()
+	if err != nil {
+		log.Warning("exit> could not unregister: %s\n", err)
+	}
+	// then exit
+	log.Notice("exit> %s, exiting\n", reason)
+	os.Exit(0)
 }
 
 func heartbeat() {
@@ -287,10 +377,26 @@ func heartbeat() {
 			}
 		}
 
-		_, code, err := sdk.Request("POST", "/worker/refresh", nil)
+		body, _ := json.Marshal(worker.HeartbeatForm{Version: VERSION})
+		data, code, err := sdk.Request("POST", "/worker/refresh", body)
 		if err != nil || code >= 300 {
 			log.Notice("heartbeat> cannot refresh beat: %d %s\n", code, err)
 			WorkerID = ""
+			continue
+		}
+
+		var hb worker.HeartbeatResponse
+		if err := json.Unmarshal(data, &hb); err != nil {
+			continue
+		}
+		apiVersion = hb.APIVersion
+		if hb.Draining && !draining {
+			log.Notice("heartbeat> worker is draining, no new job will be taken\n")
+			draining = true
+		}
+		if !hb.Draining && draining {
+			log.Notice("heartbeat> worker is not draining anymore\n")
+			draining = false
 		}
 	}
 }
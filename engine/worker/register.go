@@ -31,6 +31,7 @@ func register(cdsURI string, name string, uk string) error {
 		Model:              model,
 		Hatchery:           hatchery,
 		BinaryCapabilities: binaryCapabilities,
+		ActionBuild:        bookedActionBuild,
 	}
 
 	body, err := json.MarshalIndent(in, " ", " ")
@@ -60,6 +61,10 @@ func register(cdsURI string, name string, uk string) error {
 	WorkerID = w.ID
 	sdk.Authorization(w.ID)
 	log.Notice("Registered: %s\n", data)
+
+	if model != 0 {
+		validateModel(model)
+	}
 	return nil
 }
 
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net"
 	"os"
 	"os/exec"
 	"path"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ovh/cds/sdk"
@@ -27,6 +33,10 @@ func checkRequirement(r sdk.Requirement) (bool, error) {
 		return checkPluginRequirement(r)
 	case sdk.ServiceRequirement:
 		return checkServiceRequirement(r)
+	case sdk.MemoryRequirement:
+		return checkMemoryRequirement(r)
+	case sdk.CPURequirement:
+		return checkCPURequirement(r)
 	default:
 		log.Printf("checkRequirement> Unknown type of requirement: %s\n", r.Type)
 		return false, fmt.Errorf("unknown type of requirement %s", r.Type)
@@ -110,3 +120,97 @@ func checkServiceRequirement(r sdk.Requirement) (bool, error) {
 
 	return true, nil
 }
+
+// checkMemoryRequirement checks worker has at least the required memory, in MB
+func checkMemoryRequirement(r sdk.Requirement) (bool, error) {
+	required, err := strconv.ParseInt(r.Value, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid memory requirement %s: %s", r.Value, err)
+	}
+
+	data, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		// Cannot check on this OS
+		return true, nil
+	}
+	total := memTotal(data)
+
+	// Containers are limited by their cgroup
+	for _, f := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		if b, err := ioutil.ReadFile(f); err == nil {
+			if limit, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64); err == nil && limit < total {
+				total = limit
+			}
+			break
+		}
+	}
+
+	return total >= required*1024*1024, nil
+}
+
+// memTotal reads total memory in bytes from /proc/meminfo content
+func memTotal(meminfo []byte) int64 {
+	scanner := bufio.NewScanner(bytes.NewReader(meminfo))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
+
+// checkCPURequirement checks worker has at least the required number of CPUs
+func checkCPURequirement(r sdk.Requirement) (bool, error) {
+	required, err := strconv.ParseFloat(r.Value, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid cpu requirement %s: %s", r.Value, err)
+	}
+
+	cpus := float64(runtime.NumCPU())
+	if quota := cgroupCPUs(); quota > 0 && quota < cpus {
+		cpus = quota
+	}
+
+	return cpus >= required, nil
+}
+
+// cgroupCPUs returns the number of CPUs allowed by cgroup quota, 0 if not limited
+func cgroupCPUs() float64 {
+	// cgroup v2: "<quota> <period>", quota being "max" when not limited
+	if b, err := ioutil.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		return cpuQuota(strings.Fields(string(b)))
+	}
+
+	// cgroup v1
+	quota, err := ioutil.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0
+	}
+	period, err := ioutil.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0
+	}
+	return cpuQuota([]string{strings.TrimSpace(string(quota)), strings.TrimSpace(string(period))})
+}
+
+// cpuQuota computes CPUs from quota and period, 0 if not limited
+func cpuQuota(fields []string) float64 {
+	if len(fields) != 2 {
+		return 0
+	}
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || quota <= 0 {
+		return 0
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || period <= 0 {
+		return 0
+	}
+	return quota / period
+}
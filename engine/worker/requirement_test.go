@@ -95,3 +95,35 @@ func TestNetworkAccessRequirement(t *testing.T) {
 	}
 
 }
+
+func TestMemTotal(t *testing.T) {
+	meminfo := []byte("MemTotal:       16314436 kB\nMemFree:         1017344 kB\n")
+	if got := memTotal(meminfo); got != 16314436*1024 {
+		t.Fatalf("memTotal returned %d", got)
+	}
+	if got := memTotal([]byte("MemFree: 12 kB\n")); got != 0 {
+		t.Fatalf("memTotal without MemTotal returned %d", got)
+	}
+}
+
+func TestCPUQuota(t *testing.T) {
+	if got := cpuQuota([]string{"150000", "100000"}); got != 1.5 {
+		t.Fatalf("cpuQuota returned %g", got)
+	}
+	if got := cpuQuota([]string{"max", "100000"}); got != 0 {
+		t.Fatalf("unlimited cpuQuota returned %g", got)
+	}
+	if got := cpuQuota([]string{"-1", "100000"}); got != 0 {
+		t.Fatalf("unlimited cgroup v1 cpuQuota returned %g", got)
+	}
+}
+
+func TestCheckCPURequirement(t *testing.T) {
+	ok, err := checkRequirement(sdk.Requirement{Name: "cpu", Type: sdk.CPURequirement, Value: "0.01"})
+	if err != nil || !ok {
+		t.Fatalf("0.01 cpu should be available (%v, %s)", ok, err)
+	}
+	if _, err := checkRequirement(sdk.Requirement{Name: "cpu", Type: sdk.CPURequirement, Value: "two"}); err == nil {
+		t.Fatalf("invalid cpu requirement should fail")
+	}
+}
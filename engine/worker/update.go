@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/inconshreveable/go-update"
+	"github.com/kardianos/osext"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// VERSION of worker
+// injected at build time with -ldflags "-X main.VERSION=${version}"
+var VERSION = "snapshot"
+
+// upgradedFrom is the version run before the last upgrade, reported in the log of the next job
+var upgradedFrom string
+
+// needUpgrade returns true when engine runs a newer version than the worker.
+// Custom builds are never upgraded, and workers are never downgraded.
+func needUpgrade(current, engine string) bool {
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	e, ok := parseVersion(engine)
+	if !ok {
+		return false
+	}
+	for i := range c {
+		if e[i] != c[i] {
+			return e[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses major, minor and patch of a semantic version like v1.2.3,
+// pre-release and build metadata are ignored
+func parseVersion(v string) ([3]int, bool) {
+	var res [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return res, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return res, false
+		}
+		res[i] = n
+	}
+	return res, true
+}
+
+// workerArch returns the architecture of the worker binary served by engine
+func workerArch() (string, error) {
+	if runtime.GOARCH != "amd64" {
+		return "", fmt.Errorf("no worker binary for %s", runtime.GOARCH)
+	}
+	if runtime.GOOS == "windows" {
+		return "windows_x86_64", nil
+	}
+	return "x86_64", nil
+}
+
+func fileChecksum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// upgrade replaces worker binary with the one served by engine, after checking its checksum,
+// then re-executes the worker, keeping its registration
+func upgrade(version string) error {
+	arch, err := workerArch()
+	if err != nil {
+		return err
+	}
+
+	data, code, err := sdk.Request("GET", fmt.Sprintf("/download/worker/%s/checksum", arch), nil)
+	if err != nil {
+		return err
+	}
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+	var bin sdk.WorkerBinary
+	if err := json.Unmarshal(data, &bin); err != nil {
+		return err
+	}
+	checksum, err := hex.DecodeString(bin.Checksum)
+	if err != nil {
+		return err
+	}
+
+	exe, err := osext.Executable()
+	if err != nil {
+		return err
+	}
+	// Engine may not serve the binary of its own version, do not restart for nothing
+	if current, err := fileChecksum(exe); err == nil && bytes.Equal(current, checksum) {
+		return fmt.Errorf("binary served by engine is the current one")
+	}
+
+	body, code, err := sdk.Stream("GET", "/download/worker/"+arch, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+
+	if err := update.Apply(body, update.Options{Checksum: checksum}); err != nil {
+		return err
+	}
+
+	log.Notice("upgrade> worker upgraded from %s to %s, restarting\n", VERSION, version)
+	env := append(os.Environ(), "CDS_WORKER_ID="+WorkerID, "CDS_UPGRADED_FROM="+VERSION)
+	restart(exe, env)
+	return nil
+}
+
+// restart runs the new binary in place of the current process. Binary is already
+// replaced, so this worker never goes back to polling: if exec is not available,
+// as on windows, the new binary is spawned and this process exits.
+func restart(exe string, env []string) {
+	if runtime.GOOS != "windows" {
+		err := syscall.Exec(exe, os.Args, env)
+		log.Warning("restart> cannot exec %s: %s\n", exe, err)
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		// Old binary is gone, let hatchery or operator start a new worker
+		log.Critical("restart> cannot start %s: %s\n", exe, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestNeedUpgrade(t *testing.T) {
+	tests := []struct {
+		current, engine string
+		want            bool
+	}{
+		{"0.1.0", "0.2.0", true},
+		{"0.2.0", "0.2.0", false},
+		{"0.2.0", "0.1.0", false},
+		{"v0.9.1", "v0.10.0", true},
+		{"1.2.3-rc1", "1.2.3", false},
+		{"1.2.3", "1.3.0-rc1", true},
+		{"abcdef", "0.2.0", false},
+		{"snapshot", "0.2.0", false},
+		{"0.1.0", "snapshot", false},
+		{"0.1.0", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := needUpgrade(tt.current, tt.engine); got != tt.want {
+			t.Errorf("needUpgrade(%q, %q) = %v, want %v", tt.current, tt.engine, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os/exec"
+
+	"github.com/ovh/cds/engine/log"
+	"github.com/ovh/cds/sdk"
+)
+
+// validateModel checks capabilities declared on worker model when engine asks for it,
+// and reports them along with binaries discovered in PATH
+func validateModel(modelID int64) {
+	v, err := sdk.GetWorkerModelValidation(modelID)
+	if err != nil {
+		log.Warning("validateModel> cannot get validation of model %d: %s\n", modelID, err)
+		return
+	}
+	if !v.Needed {
+		return
+	}
+
+	log.Notice("validateModel> checking %d capabilities of model %d\n", len(v.Capabilities), modelID)
+	report := sdk.ModelValidationReport{
+		WorkerName: name,
+		Checks:     checkCapabilities(v.Capabilities),
+		Discovered: discoverBinaries(v.Discover),
+	}
+
+	res, err := sdk.SendWorkerModelValidationReport(modelID, report)
+	if err != nil {
+		log.Warning("validateModel> cannot send validation of model %d: %s\n", modelID, err)
+		return
+	}
+	log.Notice("validateModel> model %d validated: %t, missing: %v, undeclared: %v\n", modelID, res.Validated, res.Missing, res.Undeclared)
+}
+
+// checkCapabilities runs checkRequirement for every capability
+func checkCapabilities(capas []sdk.Requirement) []sdk.CapabilityCheck {
+	checks := make([]sdk.CapabilityCheck, 0, len(capas))
+	for _, c := range capas {
+		ok, err := checkRequirement(c)
+		check := sdk.CapabilityCheck{Requirement: c, Valid: ok && err == nil}
+		if err != nil {
+			check.Error = err.Error()
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// discoverBinaries returns given binaries found in PATH
+func discoverBinaries(binaries []string) []string {
+	found := []string{}
+	for _, b := range binaries {
+		if _, err := exec.LookPath(b); err == nil {
+			found = append(found, b)
+		}
+	}
+	return found
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestCheckCapabilities(t *testing.T) {
+	checks := checkCapabilities([]sdk.Requirement{
+		{Name: "sh", Type: sdk.BinaryRequirement, Value: "sh"},
+		{Name: "nope", Type: sdk.BinaryRequirement, Value: "cds-binary-which-does-not-exist"},
+		{Name: "memory", Type: sdk.MemoryRequirement, Value: "lots"},
+	})
+	if len(checks) != 3 {
+		t.Fatalf("expected 3 checks, got %d", len(checks))
+	}
+	if !checks[0].Valid {
+		t.Errorf("sh should be found")
+	}
+	if checks[1].Valid || checks[1].Error != "" {
+		t.Errorf("missing binary should be invalid without error: %+v", checks[1])
+	}
+	if checks[2].Valid || checks[2].Error == "" {
+		t.Errorf("invalid memory requirement should report an error: %+v", checks[2])
+	}
+
+	found := discoverBinaries([]string{"sh", "cds-binary-which-does-not-exist"})
+	if len(found) != 1 || found[0] != "sh" {
+		t.Errorf("unexpected discovered binaries: %v", found)
+	}
+}
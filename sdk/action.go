@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -57,9 +58,10 @@ const (
 
 // Builtin Action
 const (
-	ScriptAction = "Script"
-	NotifAction  = "Notif"
-	JUnitAction  = "JUnit"
+	ScriptAction   = "Script"
+	NotifAction    = "Notif"
+	JUnitAction    = "JUnit"
+	GitCloneAction = "GitClone"
 )
 
 // RequirementType define the type of requirement for an action to be run
@@ -78,6 +80,10 @@ const (
 	PluginRequirement RequirementType = "plugin"
 	//ServiceRequirement links a service to a worker
 	ServiceRequirement RequirementType = "service"
+	// MemoryRequirement is the memory needed by the action, in MB
+	MemoryRequirement RequirementType = "memory"
+	// CPURequirement is the number of CPUs needed by the action
+	CPURequirement RequirementType = "cpu"
 )
 
 var (
@@ -89,6 +95,8 @@ var (
 		string(HostnameRequirement),
 		string(PluginRequirement),
 		string(ServiceRequirement),
+		string(MemoryRequirement),
+		string(CPURequirement),
 	}
 )
 
@@ -101,6 +109,26 @@ type Requirement struct {
 	Value string          `json:"value" yaml:"-"`
 }
 
+// ResourceRequirements returns memory (in MB) and CPUs needed by given requirements,
+// 0 when not specified
+func ResourceRequirements(req []Requirement) (int64, float64) {
+	var memory int64
+	var cpu float64
+	for _, r := range req {
+		switch r.Type {
+		case MemoryRequirement:
+			if m, err := strconv.ParseInt(r.Value, 10, 64); err == nil && m > memory {
+				memory = m
+			}
+		case CPURequirement:
+			if c, err := strconv.ParseFloat(r.Value, 64); err == nil && c > cpu {
+				cpu = c
+			}
+		}
+	}
+	return memory, cpu
+}
+
 // NewAction instanciate a new Action
 func NewAction(name string) *Action {
 	a := &Action{
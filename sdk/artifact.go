@@ -1,19 +1,17 @@
 package sdk
 
 import (
-	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -33,9 +31,63 @@ type Artifact struct {
 	Size         int64  `json:"size,omitempty"`
 	Perm         uint32 `json:"perm,omitempty"`
 	MD5sum       string `json:"md5sum,omitempty"`
+	SHA256sum    string `json:"sha256sum,omitempty"`
 	ObjectPath   string `json:"object_path,omitempty"`
 }
 
+// ArtifactUploadSession tracks a chunked artifact upload, so an interrupted upload
+// can be resumed by sending only missing chunks
+type ArtifactUploadSession struct {
+	ID          string          `json:"id"`
+	Project     string          `json:"project"`
+	Pipeline    string          `json:"pipeline"`
+	Application string          `json:"application"`
+	Environment string          `json:"environment"`
+	BuildNumber int             `json:"build_number"`
+	Tag         string          `json:"tag"`
+	Name        string          `json:"name"`
+	Size        int64           `json:"size"`
+	Perm        uint32          `json:"perm"`
+	MD5sum      string          `json:"md5sum"`
+	SHA256sum   string          `json:"sha256sum"`
+	ChunkSize   int64           `json:"chunk_size"`
+	Chunks      []ArtifactChunk `json:"chunks"`
+	Created     time.Time       `json:"created"`
+}
+
+// ArtifactChunk is a part of an artifact received during an upload session
+type ArtifactChunk struct {
+	Upload     string `json:"-"`
+	Index      int    `json:"index"`
+	Size       int64  `json:"size"`
+	SHA256sum  string `json:"sha256sum"`
+	ObjectPath string `json:"-"`
+}
+
+// ChunkCount returns the number of chunks needed to upload the whole artifact
+func (s *ArtifactUploadSession) ChunkCount() int {
+	if s.ChunkSize <= 0 {
+		return 0
+	}
+	n := int(s.Size / s.ChunkSize)
+	if s.Size%s.ChunkSize != 0 || s.Size == 0 {
+		n++
+	}
+	return n
+}
+
+// ChunkLength returns the expected size of chunk i
+func (s *ArtifactUploadSession) ChunkLength(i int) int64 {
+	l := s.Size - int64(i)*s.ChunkSize
+	if l > s.ChunkSize {
+		l = s.ChunkSize
+	}
+	if l < 0 {
+		l = 0
+	}
+	return l
+}
+
 // Builtin artifact manipulation actions
 const (
 	ArtifactUpload   = "Artifact Upload"
@@ -44,9 +96,13 @@ const (
 
 // Header name for artifact upload
 const (
-	ArtifactFileName = "ARTIFACT-FILENAME"
+	ArtifactFileName    = "ARTIFACT-FILENAME"
+	ArtifactChunkSHA256 = "ARTIFACT-CHUNK-SHA256"
 )
 
+// ArtifactChunkSize is the size of chunks sent by UploadArtifact
+var ArtifactChunkSize int64 = 8 << 20
+
 // DownloadArtifacts retrieves and download artifacts related to given project-pipeline-tag
 // and download them into destdir
 func DownloadArtifacts(project string, application string, pipeline string, tag string, destdir string, env string) error {
@@ -67,46 +123,117 @@ func DownloadArtifacts(project string, application string, pipeline string, tag
 }
 
 func download(project, app, pip string, a Artifact, destdir string) error {
+	uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/artifact/download/%d", project, app, pip, a.ID)
+	return downloadFrom(uri, a, destdir)
+}
+
+// downloadFrom downloads artifact a served at uri into destdir, checking its checksum
+func downloadFrom(uri string, a Artifact, destdir string) error {
 	var lasterr error
+	var resume bool
+
+	destPath := path.Join(destdir, a.Name)
+	mode := os.FileMode(0644)
+	if a.Perm != uint32(0) {
+		mode = os.FileMode(a.Perm)
+	}
 
 	for retry := 5; retry >= 0; retry-- {
-		uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/artifact/download/%d", project, app, pip, a.ID)
-		reader, code, err := Stream("GET", uri, nil)
+		// Resume interrupted download from what has already been written
+		var offset int64
+		if fi, err := os.Stat(destPath); resume && err == nil && fi.Size() < a.Size {
+			offset = fi.Size()
+		}
+
+		var mods []RequestModifier
+		if offset > 0 {
+			mods = append(mods, SetHeader("Range", fmt.Sprintf("bytes=%d-", offset)))
+		}
+
+		reader, code, err := Stream("GET", uri, nil, mods...)
 		if err != nil {
 			lasterr = err
 			continue
 		}
 		if code >= 300 {
+			reader.Close()
 			lasterr = fmt.Errorf("HTTP %d", code)
 			continue
 		}
-		destPath := path.Join(destdir, a.Name)
 
-		mode := os.FileMode(0644)
-		if a.Perm != uint32(0) {
-			mode = os.FileMode(a.Perm)
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if code == http.StatusPartialContent {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
 		}
 
-		f, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY, mode)
+		f, err := os.OpenFile(destPath, flags, mode)
 		if err != nil {
+			reader.Close()
 			lasterr = err
 			continue
 		}
 
 		_, err = io.Copy(f, reader)
+		reader.Close()
+		f.Close()
 		if err != nil {
 			lasterr = err
+			resume = true
+			continue
 		}
 
-		f.Close()
-		if err == nil {
-			return nil
+		if err := verifyArtifact(destPath, a); err != nil {
+			lasterr = err
+			resume = false
+			continue
 		}
+		return nil
 	}
 
 	return fmt.Errorf("x5: %s", lasterr)
 }
 
+// verifyArtifact checks file at filePath against artifact size and checksums
+func verifyArtifact(filePath string, a Artifact) error {
+	if a.SHA256sum == "" && a.MD5sum == "" {
+		return nil
+	}
+
+	size, md5sum, sha256sum, err := fileSums(filePath)
+	if err != nil {
+		return err
+	}
+
+	if a.Size > 0 && size != a.Size {
+		return fmt.Errorf("%s: got %d bytes, expected %d", a.Name, size, a.Size)
+	}
+	if a.SHA256sum != "" && sha256sum != a.SHA256sum {
+		return fmt.Errorf("%s: sha256 is %s, expected %s", a.Name, sha256sum, a.SHA256sum)
+	}
+	if a.SHA256sum == "" && md5sum != a.MD5sum {
+		return fmt.Errorf("%s: md5 is %s, expected %s", a.Name, md5sum, a.MD5sum)
+	}
+	return nil
+}
+
+// fileSums returns size, md5sum and sha256sum of file at filePath
+func fileSums(filePath string) (int64, string, string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer f.Close()
+
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), f)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	return n, hex.EncodeToString(md5Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
+
 // DownloadArtifact downloads a single artifact from API
 func DownloadArtifact(project, app, pip, tag, destdir, env, filename string) error {
 	tag = url.QueryEscape(tag)
@@ -150,15 +277,28 @@ func ListArtifacts(project string, application string, pipeline string, tag stri
 	return arts, nil
 }
 
-// UploadArtifact read file at filePath and upload it in projet-pipeline-tag starage directory
+// UploadArtifact read file at filePath and upload it in projet-pipeline-tag starage directory.
+// File is sent by chunks, so that an interrupted upload only resends missing chunks.
 func UploadArtifact(project string, pipeline string, application string, tag string, filePath string, buildNumber int, env string) error {
 
 	tag = url.QueryEscape(tag)
 	tag = strings.Replace(tag, "/", "-", -1)
 
 	var err error
+	var s *ArtifactUploadSession
 	for i := 0; i < 5; i++ {
-		err = uploadArtifact(project, pipeline, application, tag, filePath, buildNumber, env)
+		s, err = newArtifactUploadSession(project, pipeline, application, tag, filePath, buildNumber, env)
+		if err == nil {
+			break
+		}
+		time.Sleep(1 * time.Second)
+	}
+	if err != nil {
+		return fmt.Errorf("x5: %s", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		err = uploadArtifact(s, filePath)
 		if err == nil {
 			return nil
 		}
@@ -168,62 +308,100 @@ func UploadArtifact(project string, pipeline string, application string, tag str
 	return fmt.Errorf("x5: %s", err)
 }
 
-func uploadArtifact(project string, pipeline string, application string, tag string, filePath string, buildNumber int, env string) error {
-	uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/%d/artifact/%s", project, application, pipeline, buildNumber, tag)
+func newArtifactUploadSession(project string, pipeline string, application string, tag string, filePath string, buildNumber int, env string) (*ArtifactUploadSession, error) {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
 
-	file, err := os.Open(filePath)
+	size, md5sum, sha256sum, err := fileSums(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	//File stat
-	stat, err := file.Stat()
+	s := &ArtifactUploadSession{
+		Environment: env,
+		Name:        filepath.Base(filePath),
+		Size:        size,
+		Perm:        uint32(stat.Mode().Perm()),
+		MD5sum:      md5sum,
+		SHA256sum:   sha256sum,
+		ChunkSize:   ArtifactChunkSize,
+	}
+
+	data, err := json.Marshal(s)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	//Compute md5sum
-	hash := md5.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return err
+	uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/%d/artifact/%s/upload", project, application, pipeline, buildNumber, tag)
+	data, code, err := Request("POST", uri, data)
+	if err != nil {
+		return nil, err
 	}
-	hashInBytes := hash.Sum(nil)[:16]
-	md5sumStr := hex.EncodeToString(hashInBytes)
-	file.Close()
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
 
-	//Reopen the file because we already read it for md5
-	file, err = os.Open(filePath)
+// uploadArtifact sends chunks of filePath not yet received by API, then completes the upload
+func uploadArtifact(s *ArtifactUploadSession, filePath string) error {
+	uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/artifact/upload/%s", s.Project, s.Application, s.Pipeline, s.ID)
+
+	// Check what API already received
+	data, code, err := Request("GET", uri, nil)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	_, name := filepath.Split(filePath)
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return err
+	}
+
+	received := make(map[int]bool, len(s.Chunks))
+	for _, c := range s.Chunks {
+		received[c.Index] = true
+	}
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile(name, filepath.Base(filePath))
+	file, err := os.Open(filePath)
 	if err != nil {
 		return err
 	}
-	_, err = io.Copy(part, file)
+	defer file.Close()
+
+	for i := 0; i < s.ChunkCount(); i++ {
+		if received[i] {
+			continue
+		}
 
-	writer.WriteField("env", env)
-	writer.WriteField("size", strconv.FormatInt(stat.Size(), 10))
-	writer.WriteField("perm", strconv.FormatUint(uint64(stat.Mode().Perm()), 10))
-	writer.WriteField("md5sum", md5sumStr)
+		chunk := make([]byte, s.ChunkLength(i))
+		if _, err := file.ReadAt(chunk, int64(i)*s.ChunkSize); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(chunk)
 
-	if err := writer.Close(); err != nil {
-		return err
+		_, code, err := Request("PUT", fmt.Sprintf("%s/%d", uri, i), chunk, SetHeader(ArtifactChunkSHA256, hex.EncodeToString(sum[:])))
+		if err != nil {
+			return err
+		}
+		if code >= 300 {
+			return fmt.Errorf("HTTP %d", code)
+		}
 	}
 
-	_, code, err := UploadMultiPart("POST", uri, body, SetHeader(ArtifactFileName, name), SetHeader("Content-Type", writer.FormDataContentType()))
+	_, code, err = Request("POST", uri+"/complete", nil)
 	if err != nil {
 		return err
 	}
-
 	if code >= 300 {
-		return fmt.Errorf("HTTP Error %d\n", code)
+		return fmt.Errorf("HTTP %d", code)
 	}
-
 	return nil
 }
@@ -0,0 +1,82 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// BranchLifecycle defines what happens to the branches of an application.
+// When EnvironmentTemplate is set, an environment is created from it on the first push on a branch
+// and destroyed with builds which ran in it once the branch is archived. CleanupPipeline is triggered when a branch is deleted.
+// Builds of deleted branches are archived after GracePeriod hours, 0 uses the engine default.
+type BranchLifecycle struct {
+	EnvironmentTemplate string `json:"environment_template,omitempty"`
+	CleanupPipeline     string `json:"cleanup_pipeline,omitempty"`
+	GracePeriod         int    `json:"grace_period,omitempty"`
+}
+
+// ApplicationBranch is a branch of an application repository known by CDS
+type ApplicationBranch struct {
+	Branch      string     `json:"branch"`
+	Environment string     `json:"environment,omitempty"`
+	Created     time.Time  `json:"created"`
+	Deleted     *time.Time `json:"deleted,omitempty"`
+}
+
+// GetBranchLifecycle retrieves the branch lifecycle of an application
+func GetBranchLifecycle(project, application string) (BranchLifecycle, error) {
+	var l BranchLifecycle
+	uri := fmt.Sprintf("/project/%s/application/%s/branch/lifecycle", project, application)
+
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return l, err
+	}
+	if code >= 300 {
+		return l, fmt.Errorf("HTTP %d", code)
+	}
+
+	if err := json.Unmarshal(data, &l); err != nil {
+		return l, err
+	}
+	return l, nil
+}
+
+// UpdateBranchLifecycle sets the branch lifecycle of an application
+func UpdateBranchLifecycle(project, application string, l BranchLifecycle) error {
+	uri := fmt.Sprintf("/project/%s/application/%s/branch/lifecycle", project, application)
+
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	data, code, err := Request("PUT", uri, data)
+	if err != nil {
+		return err
+	}
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+	return DecodeError(data)
+}
+
+// GetApplicationBranches retrieves the branches known by CDS for an application, deleted ones included
+func GetApplicationBranches(project, application string) ([]ApplicationBranch, error) {
+	uri := fmt.Sprintf("/project/%s/application/%s/branch/tracked", project, application)
+
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var branches []ApplicationBranch
+	if err := json.Unmarshal(data, &branches); err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
@@ -22,6 +22,8 @@ func Cmd() *cobra.Command {
 	cmd.AddCommand(applicationGroupCmd)
 	cmd.AddCommand(applicationPipelineCmd)
 	cmd.AddCommand(applicationRepositoriesManagerCmd)
+	cmd.AddCommand(applicationHookCmd)
+	cmd.AddCommand(applicationBranchCmd)
 
 	return cmd
 }
@@ -0,0 +1,105 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ovh/cds/sdk"
+)
+
+var applicationBranchCmd = &cobra.Command{
+	Use:   "branch",
+	Short: "",
+	Long:  ``,
+}
+
+var cmdBranchLifecycleTemplate string
+var cmdBranchLifecycleCleanup string
+var cmdBranchLifecycleGrace int
+
+func init() {
+	applicationBranchCmd.AddCommand(cmdApplicationBranchList())
+	applicationBranchCmd.AddCommand(cmdApplicationBranchLifecycle())
+}
+
+func cmdApplicationBranchList() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "cds application branch list <projectKey> <applicationName>",
+		Long:  `List branches pushed on the repository of the application, with their environment and when they were deleted.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 2 {
+				sdk.Exit("Wrong usage: %s\n", cmd.Short)
+			}
+			projectKey := args[0]
+			appName := args[1]
+			branches, err := sdk.GetApplicationBranches(projectKey, appName)
+			if err != nil {
+				sdk.Exit("✘ Error: Cannot retrieve branches of %s/%s (%s)\n", projectKey, appName, err)
+			}
+			for _, b := range branches {
+				line := fmt.Sprintf("- %s (created %s)", b.Branch, b.Created.Format("2006-01-02 15:04:05"))
+				if b.Environment != "" {
+					line += fmt.Sprintf(" environment: %s", b.Environment)
+				}
+				if b.Deleted != nil {
+					line += fmt.Sprintf(" deleted %s", b.Deleted.Format("2006-01-02 15:04:05"))
+				}
+				fmt.Println(line)
+			}
+		},
+	}
+}
+
+func cmdApplicationBranchLifecycle() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lifecycle",
+		Short: "cds application branch lifecycle <projectKey> <applicationName> [--template <environment>] [--cleanup <pipeline>] [--grace <hours>]",
+		Long: `Show or update what happens to the branches of the application.
+With --template, an environment copied from the given one is created on the first push on a branch, and destroyed when the deleted branch is archived.
+With --cleanup, the given pipeline is triggered when a branch is deleted, in the environment of the branch if any.
+Builds and artifacts of a deleted branch are archived after --grace hours, 0 uses the default of the engine.
+An empty value removes the template or the cleanup pipeline.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 2 {
+				sdk.Exit("Wrong usage: %s\n", cmd.Short)
+			}
+			projectKey := args[0]
+			appName := args[1]
+			l, err := sdk.GetBranchLifecycle(projectKey, appName)
+			if err != nil {
+				sdk.Exit("✘ Error: Cannot retrieve branch lifecycle of %s/%s (%s)\n", projectKey, appName, err)
+			}
+
+			flags := cmd.Flags()
+			if flags.Changed("template") || flags.Changed("cleanup") || flags.Changed("grace") {
+				if flags.Changed("template") {
+					l.EnvironmentTemplate = cmdBranchLifecycleTemplate
+				}
+				if flags.Changed("cleanup") {
+					l.CleanupPipeline = cmdBranchLifecycleCleanup
+				}
+				if flags.Changed("grace") {
+					l.GracePeriod = cmdBranchLifecycleGrace
+				}
+				if err := sdk.UpdateBranchLifecycle(projectKey, appName, l); err != nil {
+					sdk.Exit("✘ Error: Cannot update branch lifecycle of %s/%s (%s)\n", projectKey, appName, err)
+				}
+			}
+
+			fmt.Printf("Environment template: %s\n", l.EnvironmentTemplate)
+			fmt.Printf("Cleanup pipeline: %s\n", l.CleanupPipeline)
+			if l.GracePeriod > 0 {
+				fmt.Printf("Grace period: %d hours\n", l.GracePeriod)
+			} else {
+				fmt.Printf("Grace period: engine default\n")
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&cmdBranchLifecycleTemplate, "template", "", "", "Environment copied for each branch")
+	cmd.Flags().StringVarP(&cmdBranchLifecycleCleanup, "cleanup", "", "", "Pipeline triggered when a branch is deleted")
+	cmd.Flags().IntVarP(&cmdBranchLifecycleGrace, "grace", "", 0, "Hours before builds of a deleted branch are archived")
+	return cmd
+}
@@ -0,0 +1,124 @@
+package application
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ovh/cds/sdk"
+)
+
+var applicationHookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "",
+	Long:  ``,
+}
+
+var cmdHookDeliveriesLimit int
+
+func init() {
+	applicationHookCmd.AddCommand(cmdApplicationHookDeliveries())
+	applicationHookCmd.AddCommand(cmdApplicationHookDelivery())
+	applicationHookCmd.AddCommand(cmdApplicationHookReplay())
+}
+
+func cmdApplicationHookDeliveries() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deliveries",
+		Short: "cds application hook deliveries <projectKey> <applicationName> [--limit <n>]",
+		Long:  `List last pushes received by hooks of the application, with the builds they triggered or why they did not.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 2 {
+				sdk.Exit("Wrong usage: %s\n", cmd.Short)
+			}
+			projectKey := args[0]
+			appName := args[1]
+			deliveries, err := sdk.GetHookDeliveries(projectKey, appName, cmdHookDeliveriesLimit)
+			if err != nil {
+				sdk.Exit("✘ Error: Cannot retrieve hook deliveries of %s/%s (%s)\n", projectKey, appName, err)
+			}
+			for _, d := range deliveries {
+				printHookDelivery(d)
+			}
+		},
+	}
+
+	cmd.Flags().IntVarP(&cmdHookDeliveriesLimit, "limit", "", 20, "Number of deliveries to list")
+	return cmd
+}
+
+func cmdApplicationHookDelivery() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delivery",
+		Short: "cds application hook delivery <projectKey> <applicationName> <deliveryID>",
+		Long:  `Show a push received by hooks of the application, with its payload.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 3 {
+				sdk.Exit("Wrong usage: %s\n", cmd.Short)
+			}
+			projectKey := args[0]
+			appName := args[1]
+			id, err := strconv.ParseInt(args[2], 10, 64)
+			if err != nil {
+				sdk.Exit("✘ Error: Invalid delivery ID %s\n", args[2])
+			}
+			d, err := sdk.GetHookDelivery(projectKey, appName, id)
+			if err != nil {
+				sdk.Exit("✘ Error: Cannot retrieve hook delivery %d of %s/%s (%s)\n", id, projectKey, appName, err)
+			}
+			printHookDelivery(d)
+			fmt.Printf("  payload: %s\n", d.Payload)
+		},
+	}
+}
+
+func cmdApplicationHookReplay() *cobra.Command {
+	return &cobra.Command{
+		Use:   "replay",
+		Short: "cds application hook replay <projectKey> <applicationName> <deliveryID>",
+		Long:  `Process again a push received by a hook of the application, as if it was received now.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 3 {
+				sdk.Exit("Wrong usage: %s\n", cmd.Short)
+			}
+			projectKey := args[0]
+			appName := args[1]
+			id, err := strconv.ParseInt(args[2], 10, 64)
+			if err != nil {
+				sdk.Exit("✘ Error: Invalid delivery ID %s\n", args[2])
+			}
+			d, err := sdk.ReplayHookDelivery(projectKey, appName, id)
+			if err != nil {
+				sdk.Exit("✘ Error: Cannot replay hook delivery %d of %s/%s (%s)\n", id, projectKey, appName, err)
+			}
+			printHookDelivery(d)
+		},
+	}
+}
+
+func printHookDelivery(d sdk.HookDelivery) {
+	ref := d.Branch
+	if d.Tag != "" {
+		ref = "tag " + d.Tag
+	}
+	fmt.Printf("#%d %s %s/%s %s %s by %s (%s)\n", d.ID, d.Created.Format("2006-01-02 15:04:05"), d.Project, d.Repository, ref, d.Hash, d.Author, d.Message)
+
+	pipelines := []string{}
+	for _, h := range d.Hooks {
+		pipelines = append(pipelines, h.Pipeline.Name)
+	}
+	if len(pipelines) > 0 {
+		fmt.Printf("  hooks: %s\n", strings.Join(pipelines, ", "))
+	}
+	for _, b := range d.Builds {
+		fmt.Printf("  ✔ %s #%d\n", b.Pipeline, b.BuildNumber)
+	}
+	if d.SkipReason != "" {
+		fmt.Printf("  skipped: %s\n", d.SkipReason)
+	}
+	if d.Error != "" {
+		fmt.Printf("  ✘ error: %s\n", d.Error)
+	}
+}
@@ -57,4 +57,10 @@ func buildPipeline(cmd *cobra.Command, args []string) {
 	for _, s := range t.TestSuites {
 		fmt.Printf("%s: %d Total, %d Failures\n", s.Name, s.Total, s.Failures)
 	}
+	if len(t.NewFailures) > 0 {
+		fmt.Printf("New failures since previous build:\n")
+		for _, f := range t.NewFailures {
+			fmt.Printf("  %s/%s\n", f.Suite, f.Name)
+		}
+	}
 }
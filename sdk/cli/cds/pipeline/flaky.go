@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func pipelineFlakyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "flaky",
+		Short: "cds pipeline flaky <projectkey> <applicationName> <pipelineName> [envName]",
+		Long:  `List tests which both passed and failed on the same commit during the last 30 days`,
+		Run:   flakyTests,
+	}
+
+	return cmd
+}
+
+func flakyTests(cmd *cobra.Command, args []string) {
+	if len(args) < 3 || len(args) > 4 {
+		sdk.Exit("Wrong usage: see %s\n", cmd.Short)
+	}
+
+	var env string
+	if len(args) == 4 {
+		env = args[3]
+	}
+
+	flaky, err := sdk.GetFlakyTests(args[0], args[1], args[2], env)
+	if err != nil {
+		sdk.Exit("Error: Cannot get flaky tests (%s)\n", err)
+	}
+
+	for _, f := range flaky {
+		fmt.Printf("%s/%s on %s: %d success, %d failures\n", f.Suite, f.Name, f.Hash, f.Success, f.Failures)
+	}
+}
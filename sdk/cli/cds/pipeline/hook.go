@@ -13,8 +13,11 @@ func init() {
 	pipelineHookCmd.AddCommand(pipelineAddHookCmd())
 	pipelineHookCmd.AddCommand(pipelineDeleteHookCmd())
 	pipelineHookCmd.AddCommand(pipelineListHookCmd())
+	pipelineHookCmd.AddCommand(pipelineFilterHookCmd())
 }
 
+var cmdHookIncludePaths, cmdHookExcludePaths, cmdHookEvents []string
+
 var pipelineHookCmd = &cobra.Command{
 	Use:   "hook",
 	Short: "",
@@ -57,6 +60,23 @@ func pipelineListHookCmd() *cobra.Command {
 	return cmd
 }
 
+func pipelineFilterHookCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "filter",
+		Short: "cds pipeline hook filter <projectKey> <applicationName> <pipelineName> [--include <glob>] [--exclude <glob>] [--events push,tag]",
+		Long: `Trigger the pipeline only when pushed commits change files matching path filters.
+Globs are relative to the root of the repository: "*" does not match "/", "**" matches any number of directories and a directory matches all files below it.
+Path filters do not apply to tags: with "--events tag", the pipeline is triggered by each tag created on the repository, its name is available as {{.git.tag}}.
+Without any flag, filters are removed and the pipeline is triggered by pushes only.`,
+		Run: filterPipelineHook,
+	}
+
+	cmd.Flags().StringSliceVarP(&cmdHookIncludePaths, "include", "", nil, "Trigger only if one of the changed files matches one of these globs")
+	cmd.Flags().StringSliceVarP(&cmdHookExcludePaths, "exclude", "", nil, "Ignore changed files matching one of these globs")
+	cmd.Flags().StringSliceVarP(&cmdHookEvents, "events", "", nil, "Kinds of events triggering the pipeline: push, tag")
+	return cmd
+}
+
 func addPipelineHook(cmd *cobra.Command, args []string) {
 
 	if len(args) < 3 {
@@ -169,6 +189,50 @@ func listPipelineHook(cmd *cobra.Command, args []string) {
 
 	for _, h := range hooks {
 		fmt.Printf("- %s/%s/%s\n", h.Host, h.Project, h.Repository)
+		if len(h.IncludePaths) > 0 {
+			fmt.Printf("  include: %s\n", strings.Join(h.IncludePaths, ", "))
+		}
+		if len(h.ExcludePaths) > 0 {
+			fmt.Printf("  exclude: %s\n", strings.Join(h.ExcludePaths, ", "))
+		}
+		if len(h.Events) > 0 {
+			fmt.Printf("  events: %s\n", strings.Join(h.Events, ", "))
+		}
+	}
+
+}
+
+func filterPipelineHook(cmd *cobra.Command, args []string) {
+
+	if len(args) != 3 {
+		sdk.Exit("Wrong usage: See %s\n", cmd.Short)
+	}
+
+	pipelineProject := args[0]
+	appName := args[1]
+	pipelineName := args[2]
+
+	for _, e := range cmdHookEvents {
+		if e != sdk.PushEvent && e != sdk.TagEvent {
+			sdk.Exit("✘ Error: Unknown event %s, expected %s or %s\n", e, sdk.PushEvent, sdk.TagEvent)
+		}
 	}
 
+	hooks, err := sdk.GetHooks(pipelineProject, appName, pipelineName)
+	if err != nil {
+		sdk.Exit("✘ Error: Cannot retrieve hooks from %s/%s/%s (%s)\n", pipelineProject, appName, pipelineName, err)
+	}
+	if len(hooks) == 0 {
+		sdk.Exit("✘ Error: No hook on %s/%s/%s\n", pipelineProject, appName, pipelineName)
+	}
+
+	for _, h := range hooks {
+		h.IncludePaths = cmdHookIncludePaths
+		h.ExcludePaths = cmdHookExcludePaths
+		h.Events = cmdHookEvents
+		if err := sdk.UpdateHook(pipelineProject, appName, pipelineName, h); err != nil {
+			sdk.Exit("✘ Error: Cannot update hook %s/%s/%s (%s)\n", h.Host, h.Project, h.Repository, err)
+		}
+	}
+	fmt.Println("✔ Success")
 }
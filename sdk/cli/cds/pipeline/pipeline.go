@@ -29,6 +29,7 @@ func Cmd() *cobra.Command {
 	cmd.AddCommand(pipelineParameterCmd)
 	cmd.AddCommand(pipelineJoinedCmd())
 	cmd.AddCommand(pipelineBuildCmd())
+	cmd.AddCommand(pipelineFlakyCmd())
 
 	return cmd
 }
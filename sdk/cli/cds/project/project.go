@@ -15,6 +15,7 @@ func init() {
 	Cmd.AddCommand(cmdProjectList)
 	Cmd.AddCommand(group.CmdGroup)
 	Cmd.AddCommand(CmdVariable)
+	Cmd.AddCommand(CmdNotificationTemplate)
 	Cmd.AddCommand(repositoriesmanager.Cmd)
 }
 
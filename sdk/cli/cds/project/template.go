@@ -0,0 +1,145 @@
+package project
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ovh/cds/sdk"
+)
+
+var (
+	templateSubject     string
+	templateBody        string
+	templateHTML        bool
+	templateEnvironment string
+)
+
+// CmdNotificationTemplate Command to manage notification templates of project
+var CmdNotificationTemplate = &cobra.Command{
+	Use:     "template",
+	Short:   "Notification templates, rendered with Go text/template or html/template",
+	Long:    ``,
+	Aliases: []string{"t"},
+}
+
+func init() {
+	CmdNotificationTemplate.AddCommand(cmdProjectListTemplate())
+	CmdNotificationTemplate.AddCommand(cmdProjectSaveTemplate())
+	CmdNotificationTemplate.AddCommand(cmdProjectRemoveTemplate())
+	CmdNotificationTemplate.AddCommand(cmdProjectPreviewTemplate())
+}
+
+func cmdProjectListTemplate() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "cds project template list <projectKey>",
+		Run:   listTemplates,
+	}
+}
+
+func listTemplates(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+
+	templates, err := sdk.GetNotificationTemplates(args[0])
+	if err != nil {
+		sdk.Exit("Error: cannot list templates of project %s (%s)\n", args[0], err)
+	}
+
+	for _, t := range templates {
+		kind := "text"
+		if t.HTML {
+			kind = "html"
+		}
+		fmt.Printf("- %-20s %-4s %s\n", t.Name, kind, t.Subject)
+	}
+}
+
+func cmdProjectSaveTemplate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "save",
+		Short: "cds project template save <projectKey> <name> --subject <subject> --body <file> [--html]",
+		Run:   saveTemplate,
+	}
+
+	cmd.Flags().StringVarP(&templateSubject, "subject", "", "", "Subject template")
+	cmd.Flags().StringVarP(&templateBody, "body", "", "", "File containing body template")
+	cmd.Flags().BoolVarP(&templateHTML, "html", "", false, "Body is HTML")
+	return cmd
+}
+
+func saveTemplate(cmd *cobra.Command, args []string) {
+	if len(args) != 2 || templateBody == "" {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+
+	body, err := ioutil.ReadFile(templateBody)
+	if err != nil {
+		sdk.Exit("Error: cannot read %s (%s)\n", templateBody, err)
+	}
+
+	t := sdk.NotificationTemplate{Name: args[1], Subject: templateSubject, Body: string(body), HTML: templateHTML}
+	if err := sdk.SaveNotificationTemplate(args[0], t); err != nil {
+		sdk.Exit("Error: cannot save template %s (%s)\n", args[1], err)
+	}
+	fmt.Println("OK")
+}
+
+func cmdProjectRemoveTemplate() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove",
+		Short: "cds project template remove <projectKey> <name>",
+		Run:   removeTemplate,
+	}
+}
+
+func removeTemplate(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+
+	if err := sdk.DeleteNotificationTemplate(args[0], args[1]); err != nil {
+		sdk.Exit("Error: cannot remove template %s (%s)\n", args[1], err)
+	}
+	fmt.Println("OK")
+}
+
+func cmdProjectPreviewTemplate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "preview",
+		Short: "cds project template preview <projectKey> <name> <application> <pipeline> <buildNumber> [--env environment]",
+		Long:  `Render a template against a past build.`,
+		Run:   previewTemplate,
+	}
+
+	cmd.Flags().StringVarP(&templateEnvironment, "env", "", "", "Environment of the build")
+	return cmd
+}
+
+func previewTemplate(cmd *cobra.Command, args []string) {
+	if len(args) != 5 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+
+	var bn int64
+	if _, err := fmt.Sscanf(args[4], "%d", &bn); err != nil {
+		sdk.Exit("Error: invalid build number %s\n", args[4])
+	}
+
+	p := sdk.NotificationTemplatePreview{
+		Template:    sdk.UserNotificationTemplate{Name: args[1]},
+		Application: args[2],
+		Pipeline:    args[3],
+		Environment: templateEnvironment,
+		BuildNumber: bn,
+	}
+	r, err := sdk.PreviewNotificationTemplate(args[0], p)
+	if err != nil {
+		sdk.Exit("Error: cannot preview template %s (%s)\n", args[1], err)
+	}
+
+	fmt.Printf("%s\n\n%s\n", r.Subject, r.Body)
+}
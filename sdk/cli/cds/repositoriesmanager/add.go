@@ -12,7 +12,7 @@ import (
 func addReposManagerCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "add",
-		Short: "cds reposmanager add <STASH|GITHUB> <name> <url> <option=value> ...",
+		Short: "cds reposmanager add <STASH|GITHUB|BITBUCKETCLOUD|GITEA> <name> <url> <option=value> ...",
 		Long:  ``,
 		Run:   addReposManager,
 	}
@@ -0,0 +1,179 @@
+package user
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ovh/cds/sdk"
+)
+
+var (
+	subApplication string
+	subPipeline    string
+	subOnSuccess   string
+	subOnFailure   string
+	subOnStart     bool
+	subOnlyMine    bool
+	subMute        bool
+)
+
+func cmdUserSubscribe() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "subscribe",
+		Short: "cds user subscribe <username> <projectKey> [--application app] [--pipeline pip] [--on-failure always] [--only-mine] [--mute]",
+		Long:  `Notify user by mail of builds of a project, or opt out of notifications of the project with --mute.`,
+		Run:   subscribe,
+	}
+
+	cmd.Flags().StringVarP(&subApplication, "application", "", "", "Only builds of this application")
+	cmd.Flags().StringVarP(&subPipeline, "pipeline", "", "", "Only builds of this pipeline")
+	cmd.Flags().StringVarP(&subOnSuccess, "on-success", "", string(sdk.UserNotificationChange), "Notify on success: always, never or change")
+	cmd.Flags().StringVarP(&subOnFailure, "on-failure", "", string(sdk.UserNotificationAlways), "Notify on failure: always, never or change")
+	cmd.Flags().BoolVarP(&subOnStart, "on-start", "", false, "Notify when builds start")
+	cmd.Flags().BoolVarP(&subOnlyMine, "only-mine", "", false, "Only builds triggered by user")
+	cmd.Flags().BoolVarP(&subMute, "mute", "", false, "Do not notify user, even through its groups")
+	return cmd
+}
+
+func subscribe(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+	username, key := args[0], args[1]
+
+	p, err := sdk.GetUserNotificationPreferences(username)
+	if err != nil {
+		sdk.Exit("Error: cannot get notification preferences of %s (%s)\n", username, err)
+	}
+
+	s := sdk.NotificationSubscription{
+		ProjectKey:  key,
+		Application: subApplication,
+		Pipeline:    subPipeline,
+		OnSuccess:   sdk.UserNotificationEventType(subOnSuccess),
+		OnFailure:   sdk.UserNotificationEventType(subOnFailure),
+		OnStart:     subOnStart,
+		OnlyMine:    subOnlyMine,
+		Mute:        subMute,
+	}
+	p.Subscriptions = append(withoutSubscription(p.Subscriptions, key, subApplication, subPipeline), s)
+
+	if err := sdk.UpdateUserNotificationPreferences(username, p); err != nil {
+		sdk.Exit("Error: cannot subscribe %s to %s (%s)\n", username, key, err)
+	}
+	fmt.Println("OK")
+}
+
+func cmdUserUnsubscribe() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unsubscribe",
+		Short: "cds user unsubscribe <username> <projectKey> [--application app] [--pipeline pip]",
+		Run:   unsubscribe,
+	}
+
+	cmd.Flags().StringVarP(&subApplication, "application", "", "", "Subscription to this application")
+	cmd.Flags().StringVarP(&subPipeline, "pipeline", "", "", "Subscription to this pipeline")
+	return cmd
+}
+
+func unsubscribe(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+	username, key := args[0], args[1]
+
+	p, err := sdk.GetUserNotificationPreferences(username)
+	if err != nil {
+		sdk.Exit("Error: cannot get notification preferences of %s (%s)\n", username, err)
+	}
+	p.Subscriptions = withoutSubscription(p.Subscriptions, key, subApplication, subPipeline)
+
+	if err := sdk.UpdateUserNotificationPreferences(username, p); err != nil {
+		sdk.Exit("Error: cannot unsubscribe %s from %s (%s)\n", username, key, err)
+	}
+	fmt.Println("OK")
+}
+
+func withoutSubscription(subs []sdk.NotificationSubscription, key, app, pip string) []sdk.NotificationSubscription {
+	res := []sdk.NotificationSubscription{}
+	for _, s := range subs {
+		if s.ProjectKey != key || s.Application != app || s.Pipeline != pip {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func cmdUserSubscriptions() *cobra.Command {
+	return &cobra.Command{
+		Use:   "subscriptions",
+		Short: "cds user subscriptions <username>",
+		Run:   subscriptions,
+	}
+}
+
+func subscriptions(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+
+	p, err := sdk.GetUserNotificationPreferences(args[0])
+	if err != nil {
+		sdk.Exit("Error: cannot get notification preferences of %s (%s)\n", args[0], err)
+	}
+
+	for _, s := range p.Subscriptions {
+		target := s.ProjectKey
+		if s.Application != "" {
+			target += "/" + s.Application
+		}
+		if s.Pipeline != "" {
+			target += " " + s.Pipeline
+		}
+		if s.Mute {
+			fmt.Printf("- %-40s muted\n", target)
+			continue
+		}
+		mine := ""
+		if s.OnlyMine {
+			mine = "(only mine)"
+		}
+		fmt.Printf("- %-40s success:%-7s failure:%-7s start:%-5t %s\n", target, s.OnSuccess, s.OnFailure, s.OnStart, mine)
+	}
+	if p.Digest != sdk.DigestNever {
+		fmt.Printf("Digest: %s\n", p.Digest)
+	}
+}
+
+func cmdUserDigest() *cobra.Command {
+	return &cobra.Command{
+		Use:   "digest",
+		Short: "cds user digest <username> <daily|weekly|never>",
+		Long:  `Mail user a summary of failures, slowest pipelines and pending approvals of subscribed projects.`,
+		Run:   digest,
+	}
+}
+
+func digest(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+	username := args[0]
+
+	freq := sdk.DigestFrequency(args[1])
+	if args[1] == "never" {
+		freq = sdk.DigestNever
+	}
+
+	p, err := sdk.GetUserNotificationPreferences(username)
+	if err != nil {
+		sdk.Exit("Error: cannot get notification preferences of %s (%s)\n", username, err)
+	}
+	p.Digest = freq
+
+	if err := sdk.UpdateUserNotificationPreferences(username, p); err != nil {
+		sdk.Exit("Error: cannot set digest of %s (%s)\n", username, err)
+	}
+	fmt.Println("OK")
+}
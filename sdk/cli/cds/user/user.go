@@ -10,6 +10,10 @@ func init() {
 	Cmd.AddCommand(cmdUserGenerate())
 	Cmd.AddCommand(cmdUserUpdate())
 	Cmd.AddCommand(cmdUserDelete())
+	Cmd.AddCommand(cmdUserSubscribe())
+	Cmd.AddCommand(cmdUserUnsubscribe())
+	Cmd.AddCommand(cmdUserSubscriptions())
+	Cmd.AddCommand(cmdUserDigest())
 }
 
 // Cmd user
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ovh/cds/sdk"
+)
+
+var drainHatchery int64
+
+var drainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "cds worker drain [<name>...] [--hatchery <id>]",
+	Long: `Drain workers: they stop taking new jobs, finish their current one then unregister.
+
+With --hatchery, the hatchery stops spawning workers and all its workers are drained.
+Once all its workers are gone, the hatchery unregisters and exits.`,
+	Run: workerDrain,
+}
+
+var undrainCmd = &cobra.Command{
+	Use:   "undrain",
+	Short: "cds worker undrain [<name>...] [--hatchery <id>]",
+	Long: `Undrain workers which did not unregister yet: they take new jobs again.
+
+With --hatchery, the hatchery spawns workers again and all its workers are undrained.`,
+	Run: workerUndrain,
+}
+
+func init() {
+	drainCmd.Flags().Int64VarP(&drainHatchery, "hatchery", "", 0, "Drain hatchery with given ID and all its workers")
+	undrainCmd.Flags().Int64VarP(&drainHatchery, "hatchery", "", 0, "Undrain hatchery with given ID and all its workers")
+}
+
+func workerDrain(cmd *cobra.Command, args []string) {
+	drain(cmd, args, "drain", sdk.DrainHatchery, sdk.DrainWorker)
+}
+
+func workerUndrain(cmd *cobra.Command, args []string) {
+	drain(cmd, args, "undrain", sdk.UndrainHatchery, sdk.UndrainWorker)
+}
+
+func drain(cmd *cobra.Command, args []string, verb string, hatcheryFunc func(int64) error, workerFunc func(string) error) {
+	if len(args) == 0 && drainHatchery == 0 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+
+	if drainHatchery != 0 {
+		if err := hatcheryFunc(drainHatchery); err != nil {
+			sdk.Exit("Error: Cannot %s hatchery %d (%s)\n", verb, drainHatchery, err)
+		}
+		fmt.Printf("Hatchery %d: %s done\n", drainHatchery, verb)
+	}
+	if len(args) == 0 {
+		return
+	}
+
+	workers, err := sdk.GetWorkers()
+	if err != nil {
+		sdk.Exit("Error: Cannot get workers (%s)\n", err)
+	}
+
+	for _, name := range args {
+		var found bool
+		for _, w := range workers {
+			if w.Name != name && w.ID != name {
+				continue
+			}
+			found = true
+			if err := workerFunc(w.ID); err != nil {
+				sdk.Exit("Error: Cannot %s worker %s (%s)\n", verb, name, err)
+			}
+			fmt.Printf("Worker %s: %s done\n", w.Name, verb)
+		}
+		if !found {
+			sdk.Exit("Error: worker %s not found\n", name)
+		}
+	}
+}
@@ -14,6 +14,9 @@ var (
 	imageP                 string
 	openstackFlavorP       string
 	openstackUserDataFileP string
+	memoryP                int64
+	cpuP                   float64
+	diskP                  int64
 )
 
 func cmdWorkerModelAdd() *cobra.Command {
@@ -31,6 +34,9 @@ func cmdWorkerModelAdd() *cobra.Command {
 	cmd.Flags().StringVar(&imageP, "image", "", "Image value (docker or openstack)")
 	cmd.Flags().StringVar(&openstackFlavorP, "flavor", "", "Flavor value (openstack)")
 	cmd.Flags().StringVar(&openstackUserDataFileP, "userdata", "", "Path to UserData file (openstack)")
+	cmd.Flags().Int64Var(&memoryP, "memory", 0, "Memory of workers in MB (0: sized by hatchery)")
+	cmd.Flags().Float64Var(&cpuP, "cpu", 0, "CPUs of workers (0: sized by hatchery)")
+	cmd.Flags().Int64Var(&diskP, "disk", 0, "Disk of workers in MB (0: sized by hatchery)")
 
 	return cmd
 }
@@ -82,7 +88,7 @@ func addWorkerModel(cmd *cobra.Command, args []string) {
 		sdk.Exit("Unknown worker type: %s\n", modelType)
 	}
 
-	_, err := sdk.AddWorkerModel(name, t, image)
+	_, err := sdk.AddWorkerModelWithResources(name, t, image, memoryP, cpuP, diskP)
 	if err != nil {
 		sdk.Exit("Error: cannot add worker model (%s)\n", err)
 	}
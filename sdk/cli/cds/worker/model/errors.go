@@ -0,0 +1,60 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func cmdWorkerModelErrors() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "errors",
+		Short: "cds worker model errors <name>",
+		Long:  `Show why hatcheries failed to start workers of a model during the last 24 hours.`,
+		Run:   workerModelErrors,
+	}
+
+	return cmd
+}
+
+func workerModelErrors(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+	name := args[0]
+
+	m, err := sdk.GetWorkerModel(name)
+	if err != nil {
+		sdk.Exit("Error: cannot retrieve worker model %s (%s)\n", name, err)
+	}
+
+	if !m.Healthy(time.Now()) {
+		fmt.Printf("%s is unhealthy until %s after %d consecutive errors\n", m.Name, m.UnhealthyUntil.Format(time.RFC3339), m.SpawnErrors)
+	} else {
+		fmt.Printf("%s is healthy (%d consecutive errors)\n", m.Name, m.SpawnErrors)
+	}
+	if m.LastSpawnError != "" {
+		fmt.Printf("Last error: %s\n", m.LastSpawnError)
+	}
+
+	stats, err := sdk.GetSpawnErrors(m.ID)
+	if err != nil {
+		sdk.Exit("Error: cannot retrieve spawn errors (%s)\n", err)
+	}
+	if len(stats) == 0 {
+		return
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 2, ' ', 0)
+	fmt.Fprintln(w, "HATCHERY\tERRORS\tLAST\tMESSAGE")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", s.HatcheryName, s.Count, s.LastDate.Format(time.RFC3339), s.LastMessage)
+	}
+	w.Flush()
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/ovh/cds/sdk"
 
@@ -33,7 +34,7 @@ func listWorkerModel(cmd *cobra.Command, args []string) {
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 27, 1, 2, ' ', 0)
-	titles := []string{"NAME", "TYPE", "IMAGE"}
+	titles := []string{"NAME", "TYPE", "MEMORY", "CPU", "VALIDATED", "HEALTH", "IMAGE"}
 	fmt.Fprintln(w, strings.Join(titles, "\t"))
 
 	for _, m := range models {
@@ -41,12 +42,39 @@ func listWorkerModel(cmd *cobra.Command, args []string) {
 			m.Image = m.Image[:97] + "..."
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\n",
+		fmt.Fprintf(w, "%s\t%s\t%d\t%g\t%s\t%s\t%s\n",
 			m.Name,
 			m.Type,
+			m.Memory,
+			m.CPU,
+			modelValidation(m),
+			modelHealth(m),
 			m.Image,
 		)
 
 		w.Flush()
 	}
 }
+
+// modelHealth sums up spawn errors of a model
+func modelHealth(m sdk.Model) string {
+	if !m.Healthy(time.Now()) {
+		return fmt.Sprintf("unhealthy until %s (%d errors)", m.UnhealthyUntil.Format("15:04:05"), m.SpawnErrors)
+	}
+	if m.SpawnErrors > 0 {
+		return fmt.Sprintf("%d errors", m.SpawnErrors)
+	}
+	return "ok"
+}
+
+// modelValidation sums up the validation state of a model
+func modelValidation(m sdk.Model) string {
+	switch {
+	case m.NeedValidation:
+		return "pending"
+	case m.Validated:
+		return "yes"
+	default:
+		return "no"
+	}
+}
@@ -7,6 +7,10 @@ func init() {
 	Cmd.AddCommand(cmdWorkerModelRemove())
 	Cmd.AddCommand(cmdWorkerModelUpdate())
 	Cmd.AddCommand(cmdWorkerModelList())
+	Cmd.AddCommand(cmdWorkerModelErrors())
+	Cmd.AddCommand(cmdWorkerModelValidate())
+	Cmd.AddCommand(cmdWorkerModelValidation())
+	Cmd.AddCommand(cmdWorkerModelPool())
 	Cmd.AddCommand(cmdWorkerModelCapability())
 }
 
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ovh/cds/sdk"
+)
+
+var (
+	poolMinIdle   int64
+	poolMaxTotal  int64
+	poolIdleTTL   int64
+	poolLocation  string
+	poolSchedules []string
+	poolRemove    bool
+)
+
+func cmdWorkerModelPool() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pool",
+		Short: "cds worker model pool <name> [--min-idle 2] [--max 10] [--idle-ttl 600] [--schedule mon,tue,wed,thu,fri@08:00-19:00=5/20] [--remove]",
+		Long: `Configure the pool of workers hatcheries keep for a model.
+
+A schedule overrides min idle and max workers during given hours, for given days (every day if omitted):
+	--schedule "mon,tue,wed,thu,fri@08:00-19:00=5/20"`,
+		Run: workerModelPool,
+	}
+
+	cmd.Flags().Int64VarP(&poolMinIdle, "min-idle", "", 0, "Number of workers kept waiting for jobs")
+	cmd.Flags().Int64VarP(&poolMaxTotal, "max", "", 0, "Maximum number of workers, 0 for no limit")
+	cmd.Flags().Int64VarP(&poolIdleTTL, "idle-ttl", "", 0, "Seconds an idle worker is kept before being killed")
+	cmd.Flags().StringVarP(&poolLocation, "location", "", "", "Time zone of schedules (ie. Europe/Paris)")
+	cmd.Flags().StringSliceVarP(&poolSchedules, "schedule", "", nil, "Limits during office hours: days@from-to=min_idle/max")
+	cmd.Flags().BoolVarP(&poolRemove, "remove", "", false, "Remove the pool, hatcheries provisioning applies")
+	return cmd
+}
+
+func workerModelPool(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+	name := args[0]
+
+	m, err := sdk.GetWorkerModel(name)
+	if err != nil {
+		sdk.Exit("Error: cannot retrieve worker model %s (%s)\n", name, err)
+	}
+
+	var pool *sdk.ModelPool
+	if !poolRemove {
+		pool = &sdk.ModelPool{
+			ModelPoolLimits: sdk.ModelPoolLimits{MinIdle: poolMinIdle, MaxTotal: poolMaxTotal, IdleTTL: poolIdleTTL},
+			Location:        poolLocation,
+		}
+		for _, s := range poolSchedules {
+			sc, err := parsePoolSchedule(s)
+			if err != nil {
+				sdk.Exit("Error: %s\n", err)
+			}
+			sc.IdleTTL = poolIdleTTL
+			pool.Schedules = append(pool.Schedules, sc)
+		}
+	}
+
+	if err := sdk.SetWorkerModelPool(m.ID, pool); err != nil {
+		sdk.Exit("Error: cannot set pool of worker model %s (%s)\n", name, err)
+	}
+	fmt.Printf("Pool of %s updated\n", name)
+}
+
+// parsePoolSchedule parses "mon,tue@08:00-19:00=5/20"
+func parsePoolSchedule(s string) (sdk.ModelPoolSchedule, error) {
+	var sc sdk.ModelPoolSchedule
+	wrong := fmt.Errorf("invalid schedule '%s', expected days@from-to=min_idle/max", s)
+
+	if i := strings.Index(s, "@"); i >= 0 {
+		sc.Days = strings.Split(s[:i], ",")
+		s = s[i+1:]
+	}
+
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return sc, wrong
+	}
+	hours := strings.SplitN(parts[0], "-", 2)
+	limits := strings.SplitN(parts[1], "/", 2)
+	if len(hours) != 2 || len(limits) != 2 {
+		return sc, wrong
+	}
+	sc.From, sc.To = hours[0], hours[1]
+
+	var err error
+	if sc.MinIdle, err = strconv.ParseInt(limits[0], 10, 64); err != nil {
+		return sc, wrong
+	}
+	if sc.MaxTotal, err = strconv.ParseInt(limits[1], 10, 64); err != nil {
+		return sc, wrong
+	}
+	return sc, nil
+}
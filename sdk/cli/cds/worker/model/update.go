@@ -58,13 +58,16 @@ func cmdWorkerModelUpdate() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "update",
 		Short: "cds worker model update <oldname> <name> <type>",
-		Long:  `Update name, type and image value only.`,
+		Long:  `Update name, type, image value and resources only.`,
 		Run:   updateWorkerModel,
 	}
 
 	cmd.Flags().StringVar(&imageP, "image", "", "Image value (docker or openstack)")
 	cmd.Flags().StringVar(&openstackFlavorP, "flavor", "", "Flavor value (openstack)")
 	cmd.Flags().StringVar(&openstackUserDataFileP, "userdata", "", "Path to UserData file (openstack)")
+	cmd.Flags().Int64Var(&memoryP, "memory", 0, "Memory of workers in MB (0: sized by hatchery)")
+	cmd.Flags().Float64Var(&cpuP, "cpu", 0, "CPUs of workers (0: sized by hatchery)")
+	cmd.Flags().Int64Var(&diskP, "disk", 0, "Disk of workers in MB (0: sized by hatchery)")
 	return cmd
 }
 
@@ -125,7 +128,17 @@ func updateWorkerModel(cmd *cobra.Command, args []string) {
 	if err != nil {
 		sdk.Exit("Error: cannot retrieve worker model %s (%s)\n", workerModelName, err)
 	}
-	err = sdk.UpdateWorkerModel(m.ID, name, t, value)
+	// Keep current resources unless given
+	if !cmd.Flags().Changed("memory") {
+		memoryP = m.Memory
+	}
+	if !cmd.Flags().Changed("cpu") {
+		cpuP = m.CPU
+	}
+	if !cmd.Flags().Changed("disk") {
+		diskP = m.Disk
+	}
+	err = sdk.UpdateWorkerModelWithResources(m.ID, name, t, value, memoryP, cpuP, diskP)
 	if err != nil {
 		sdk.Exit("Error: cannot update model (%s)\n", err)
 	}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func cmdWorkerModelValidate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "cds worker model validate <name>",
+		Long:  `Ask hatcheries to start a worker checking capabilities of the model.`,
+		Run:   validateWorkerModel,
+	}
+
+	return cmd
+}
+
+func validateWorkerModel(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+	name := args[0]
+
+	m, err := sdk.GetWorkerModel(name)
+	if err != nil {
+		sdk.Exit("Error: cannot retrieve worker model %s (%s)\n", name, err)
+	}
+
+	if err := sdk.RequestWorkerModelValidation(m.ID); err != nil {
+		sdk.Exit("Error: cannot request validation of %s (%s)\n", name, err)
+	}
+	fmt.Printf("Validation of %s requested\n", name)
+}
+
+func cmdWorkerModelValidation() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validation",
+		Short: "cds worker model validation <name>",
+		Long:  `Show the result of the last validation of the model: declared capabilities missing on workers and binaries found but not declared.`,
+		Run:   showWorkerModelValidation,
+	}
+
+	return cmd
+}
+
+func showWorkerModelValidation(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		sdk.Exit("Wrong usage: %s\n", cmd.Short)
+	}
+	name := args[0]
+
+	m, err := sdk.GetWorkerModel(name)
+	if err != nil {
+		sdk.Exit("Error: cannot retrieve worker model %s (%s)\n", name, err)
+	}
+
+	if m.NeedValidation {
+		fmt.Printf("%s is waiting for a worker to validate it\n", m.Name)
+	}
+	r := m.Validation
+	if r == nil {
+		fmt.Printf("%s has never been validated\n", m.Name)
+		return
+	}
+
+	fmt.Printf("Validated: %t (by %s at %s)\n", r.Validated, r.WorkerName, r.Date.Format(time.RFC3339))
+	for _, c := range r.Missing {
+		fmt.Printf("- %s (%s: %s)\n", c.Name, c.Type, c.Value)
+	}
+	for _, b := range r.Undeclared {
+		fmt.Printf("+ %s\n", b)
+	}
+	for _, c := range r.Checks {
+		if c.Error != "" {
+			fmt.Printf("! %s: %s\n", c.Requirement.Name, c.Error)
+		}
+	}
+}
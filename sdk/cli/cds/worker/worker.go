@@ -13,6 +13,9 @@ func init() {
 	Cmd.AddCommand(model.Cmd)
 	Cmd.AddCommand(statusCmd)
 	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(drainCmd)
+	Cmd.AddCommand(undrainCmd)
+	Cmd.AddCommand(versionsCmd)
 }
 
 // Cmd worker
@@ -59,6 +62,33 @@ func workerList(cmd *cobra.Command, args []string) {
 	}
 
 	for _, w := range workers {
-		fmt.Printf("- %-30s %s\n", w.Name, w.Status)
+		var draining string
+		if w.Draining {
+			draining = "(draining)"
+		}
+		fmt.Printf("- %-30s %-10s %-10s %s\n", w.Name, w.Status, w.Version, draining)
+	}
+}
+
+var versionsCmd = &cobra.Command{
+	Use:   "versions",
+	Short: "cds worker versions",
+	Long:  `Show how many workers run each version, across all hatcheries and hosts.`,
+	Run:   workerVersions,
+}
+
+func workerVersions(cmd *cobra.Command, args []string) {
+
+	stats, err := sdk.GetWorkerVersions()
+	if err != nil {
+		sdk.Exit("Error: Cannot get worker versions (%s)\n", err)
+	}
+
+	for _, s := range stats {
+		version := s.Version
+		if version == "" {
+			version = "unknown"
+		}
+		fmt.Printf("- %-20s %d\n", version, s.Count)
 	}
 }
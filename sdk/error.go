@@ -85,6 +85,27 @@ var (
 	ErrInfiniteTriggerLoop          = &Error{ID: 71, Status: http.StatusBadRequest}
 	ErrInvalidResetUser             = &Error{ID: 72, Status: http.StatusBadRequest}
 	ErrUserConflict                 = &Error{ID: 73, Status: http.StatusBadRequest}
+	ErrWorkspaceCacheNotFound       = &Error{ID: 74, Status: http.StatusNotFound}
+	ErrWorkspaceCacheTooLarge       = &Error{ID: 75, Status: http.StatusRequestEntityTooLarge}
+	ErrWorkspaceCacheInvalid        = &Error{ID: 76, Status: http.StatusBadRequest}
+	ErrArtifactUploadNotFound       = &Error{ID: 77, Status: http.StatusNotFound}
+	ErrArtifactChecksumMismatch     = &Error{ID: 78, Status: http.StatusBadRequest}
+	ErrArtifactUploadIncomplete     = &Error{ID: 79, Status: http.StatusBadRequest}
+	ErrInvalidChunkSize             = &Error{ID: 80, Status: http.StatusBadRequest}
+	ErrReleaseNotFound              = &Error{ID: 81, Status: http.StatusNotFound}
+	ErrReleaseExists                = &Error{ID: 82, Status: http.StatusConflict}
+	ErrInvalidReleaseName           = &Error{ID: 83, Status: http.StatusBadRequest}
+	ErrReleaseNoArtifact            = &Error{ID: 84, Status: http.StatusBadRequest}
+	ErrInvalidResource              = &Error{ID: 85, Status: http.StatusBadRequest}
+	ErrInvalidWorkerModelPool       = &Error{ID: 86, Status: http.StatusBadRequest}
+	ErrNoWorker                     = &Error{ID: 87, Status: http.StatusNotFound}
+	ErrUserNotFound                 = &Error{ID: 88, Status: http.StatusNotFound}
+	ErrNotificationTemplateNotFound = &Error{ID: 89, Status: http.StatusNotFound}
+	ErrInvalidNotificationTemplate  = &Error{ID: 90, Status: http.StatusBadRequest}
+	ErrInvalidBranchLifecycle       = &Error{ID: 91, Status: http.StatusBadRequest}
+	ErrWrongRequest                 = &Error{ID: 92, Status: http.StatusBadRequest}
+	ErrNotificationTemplateUsed     = &Error{ID: 93, Status: http.StatusConflict}
+	ErrPathFiltersWithoutClient     = &Error{ID: 94, Status: http.StatusBadRequest}
 )
 
 // SupportedLanguages on API errors
@@ -169,6 +190,27 @@ You can safely use them in a String or Text parameter`,
 	ErrInfiniteTriggerLoop.ID:          "infinite trigger loop are forbidden",
 	ErrInvalidResetUser.ID:             "invalid user or email",
 	ErrUserConflict.ID:                 "this user already exist",
+	ErrWorkspaceCacheNotFound.ID:       "workspace cache not found",
+	ErrWorkspaceCacheTooLarge.ID:       "workspace cache exceeds project quota",
+	ErrWorkspaceCacheInvalid.ID:        "workspace cache content does not match its size or checksum",
+	ErrArtifactUploadNotFound.ID:       "artifact upload not found",
+	ErrArtifactChecksumMismatch.ID:     "artifact content does not match its size or checksum",
+	ErrArtifactUploadIncomplete.ID:     "artifact upload is missing chunks",
+	ErrInvalidChunkSize.ID:             "artifact chunk size is invalid",
+	ErrReleaseNotFound.ID:              "release not found",
+	ErrReleaseExists.ID:                "release already exists and cannot be changed",
+	ErrInvalidReleaseName.ID:           "invalid release name (should match ^[a-zA-Z0-9._-]+$)",
+	ErrReleaseNoArtifact.ID:            "build has no artifact to promote",
+	ErrInvalidResource.ID:              "Invalid resource: memory and disk must be a positive number of MB, cpu a positive number of CPUs",
+	ErrInvalidWorkerModelPool.ID:       "Invalid worker model pool: limits must be positive, schedules need valid days and hours (hh:mm)",
+	ErrNoWorker.ID:                     "worker does not exist",
+	ErrUserNotFound.ID:                 "user not found",
+	ErrNotificationTemplateNotFound.ID: "notification template not found",
+	ErrInvalidNotificationTemplate.ID:  "invalid notification template",
+	ErrInvalidBranchLifecycle.ID:       "invalid branch lifecycle: environment template must be a project environment and grace period cannot be negative",
+	ErrWrongRequest.ID:                 "wrong request",
+	ErrNotificationTemplateUsed.ID:     "notification template is used by notifications of applications",
+	ErrPathFiltersWithoutClient.ID:     "path filters need an application attached to an authorized repositories manager",
 }
 
 var errorsFrench = map[int]string{
@@ -247,6 +289,27 @@ Vous pouvez les utiliser sans problème dans un paramêtre de type String ou Tex
 	ErrInfiniteTriggerLoop.ID:          "création d'une boucle de trigger infinie interdite",
 	ErrInvalidResetUser.ID:             "mauvaise combinaison compte/mail utilisateur",
 	ErrUserConflict.ID:                 "cet utilisateur existe deja",
+	ErrWorkspaceCacheNotFound.ID:       "le cache de l'espace de travail n'existe pas",
+	ErrWorkspaceCacheTooLarge.ID:       "le cache de l'espace de travail dépasse le quota du projet",
+	ErrWorkspaceCacheInvalid.ID:        "le contenu du cache ne correspond pas à sa taille ou sa somme de contrôle",
+	ErrArtifactUploadNotFound.ID:       "l'envoi de l'artefact n'existe pas",
+	ErrArtifactChecksumMismatch.ID:     "le contenu de l'artefact ne correspond pas à sa taille ou sa somme de contrôle",
+	ErrArtifactUploadIncomplete.ID:     "il manque des morceaux à l'envoi de l'artefact",
+	ErrInvalidChunkSize.ID:             "la taille des morceaux de l'artefact est invalide",
+	ErrReleaseNotFound.ID:              "la release n'existe pas",
+	ErrReleaseExists.ID:                "la release existe déjà et ne peut pas être modifiée",
+	ErrInvalidReleaseName.ID:           "nom de release invalide (doit respecter ^[a-zA-Z0-9._-]+$)",
+	ErrReleaseNoArtifact.ID:            "le build n'a pas d'artefact à promouvoir",
+	ErrInvalidResource.ID:              "Ressource invalide : la mémoire et le disque doivent être un nombre positif de Mo, cpu un nombre positif de CPUs",
+	ErrInvalidWorkerModelPool.ID:       "Pool de modèle de worker invalide : les limites doivent être positives, les plannings nécessitent des jours et heures (hh:mm) valides",
+	ErrNoWorker.ID:                     "le worker n'existe pas",
+	ErrUserNotFound.ID:                 "utilisateur introuvable",
+	ErrNotificationTemplateNotFound.ID: "modèle de notification introuvable",
+	ErrInvalidNotificationTemplate.ID:  "modèle de notification invalide",
+	ErrInvalidBranchLifecycle.ID:       "cycle de vie des branches invalide : le modèle d'environnement doit être un environnement du projet et le délai de grâce ne peut pas être négatif",
+	ErrWrongRequest.ID:                 "la requête est incorrecte",
+	ErrNotificationTemplateUsed.ID:     "le modèle de notification est utilisé par des notifications d'applications",
+	ErrPathFiltersWithoutClient.ID:     "les filtres de chemins nécessitent une application liée à un gestionnaire de dépôts autorisé",
 }
 
 var matcher = language.NewMatcher(SupportedLanguages)
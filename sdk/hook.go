@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // Hook used to link a git repository to a given pipeline
@@ -18,6 +19,35 @@ type Hook struct {
 	Repository    string   `json:"repository"`
 	Enabled       bool     `json:"enabled"`
 	Link          string   `json:"link"`
+	IncludePaths  []string `json:"include_paths,omitempty"`
+	ExcludePaths  []string `json:"exclude_paths,omitempty"`
+	Events        []string `json:"events,omitempty"`
+}
+
+// HookDelivery is a push received by a hook from a repositories manager, and what was done with it
+type HookDelivery struct {
+	ID         int64               `json:"id"`
+	Created    time.Time           `json:"created"`
+	URL        string              `json:"url"`
+	Payload    string              `json:"payload,omitempty"`
+	Project    string              `json:"project"`
+	Repository string              `json:"repository"`
+	Branch     string              `json:"branch,omitempty"`
+	Tag        string              `json:"tag,omitempty"`
+	Hash       string              `json:"hash"`
+	Author     string              `json:"author"`
+	Message    string              `json:"message"`
+	Hooks      []Hook              `json:"hooks"`
+	Builds     []HookDeliveryBuild `json:"builds"`
+	SkipReason string              `json:"skip_reason,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// HookDeliveryBuild is a pipeline build triggered by a hook delivery
+type HookDeliveryBuild struct {
+	PipelineBuildID int64  `json:"pipeline_build_id"`
+	Pipeline        string `json:"pipeline"`
+	BuildNumber     int64  `json:"build_number"`
 }
 
 // AddHook creates a new hook between a pipeline and a repository
@@ -77,6 +107,26 @@ func GetHooks(project, application, pipeline string) ([]Hook, error) {
 	return hooks, nil
 }
 
+// UpdateHook updates a hook, its path filters for instance
+func UpdateHook(project, application, pipeline string, h Hook) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/hook/%d", project, application, pipeline, h.ID)
+	_, code, err := Request("PUT", uri, data)
+	if err != nil {
+		return err
+	}
+
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+
+	return nil
+}
+
 // DeleteHook remove a hook previously created
 func DeleteHook(project, application, pipeline string, id int64) error {
 	uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/hook/%d", project, application, pipeline, id)
@@ -92,3 +142,60 @@ func DeleteHook(project, application, pipeline string, id int64) error {
 
 	return nil
 }
+
+// GetHookDeliveries retrieves the last pushes received by hooks of an application, without their payload
+func GetHookDeliveries(project, application string, limit int) ([]HookDelivery, error) {
+	uri := fmt.Sprintf("/project/%s/application/%s/hook/delivery?limit=%d", project, application, limit)
+
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var deliveries []HookDelivery
+	if err := json.Unmarshal(data, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// GetHookDelivery retrieves a push received by a hook of an application, with its payload
+func GetHookDelivery(project, application string, id int64) (HookDelivery, error) {
+	var d HookDelivery
+	uri := fmt.Sprintf("/project/%s/application/%s/hook/delivery/%d", project, application, id)
+
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return d, err
+	}
+	if code >= 300 {
+		return d, fmt.Errorf("HTTP %d", code)
+	}
+
+	if err := json.Unmarshal(data, &d); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// ReplayHookDelivery processes again a push received by a hook of an application, the new delivery is returned
+func ReplayHookDelivery(project, application string, id int64) (HookDelivery, error) {
+	var d HookDelivery
+	uri := fmt.Sprintf("/project/%s/application/%s/hook/delivery/%d/replay", project, application, id)
+
+	data, code, err := Request("POST", uri, nil)
+	if err != nil {
+		return d, err
+	}
+	if code >= 300 {
+		return d, fmt.Errorf("HTTP %d", code)
+	}
+
+	if err := json.Unmarshal(data, &d); err != nil {
+		return d, err
+	}
+	return d, nil
+}
@@ -1,5 +1,12 @@
 package sdk
 
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
 // NotifType reprensents a type of notification
 type NotifType string
 
@@ -25,9 +32,11 @@ type UserNotificationSettingsType string
 
 //const
 const (
-	EmailUserNotification  UserNotificationSettingsType = "email"
-	JabberUserNotification UserNotificationSettingsType = "jabber"
-	TATUserNotification    UserNotificationSettingsType = "tat"
+	EmailUserNotification   UserNotificationSettingsType = "email"
+	JabberUserNotification  UserNotificationSettingsType = "jabber"
+	TATUserNotification     UserNotificationSettingsType = "tat"
+	WebhookUserNotification UserNotificationSettingsType = "webhook"
+	ChatUserNotification    UserNotificationSettingsType = "chat"
 )
 
 //UserNotificationEventType always/never/change
@@ -56,6 +65,7 @@ type Notif struct {
 	Recipients  []string       `json:"recipients,omitempty"`
 	Title       string         `json:"title,omitempty"`
 	Message     string         `json:"message,omitempty"`
+	HTML        bool           `json:"html,omitempty"`
 }
 
 // UserNotification is a settings on application_pipeline/env
@@ -124,8 +134,321 @@ func (n *TATUserNotificationSettings) Start() bool {
 	return n.OnStart
 }
 
-// UserNotificationTemplate is the notification content
+// WebhookUserNotificationSettings are settings of a notification posted to any URL.
+// Body is a template rendered with build parameters, payload is signed with Secret
+type WebhookUserNotificationSettings struct {
+	OnSuccess UserNotificationEventType `json:"on_success"`
+	OnFailure UserNotificationEventType `json:"on_failure"`
+	OnStart   bool                      `json:"on_start"`
+	URL       string                    `json:"url"`
+	Headers   map[string]string         `json:"headers,omitempty"`
+	Secret    string                    `json:"secret,omitempty"`
+	Template  string                    `json:"template,omitempty"`
+}
+
+//Success returns always/never/change
+func (n *WebhookUserNotificationSettings) Success() UserNotificationEventType {
+	return n.OnSuccess
+}
+
+//Failure returns always/never/change
+func (n *WebhookUserNotificationSettings) Failure() UserNotificationEventType {
+	return n.OnFailure
+}
+
+//Start returns always/never/change
+func (n *WebhookUserNotificationSettings) Start() bool {
+	return n.OnStart
+}
+
+// ChatUserNotificationSettings are settings of notifications posted to Slack or Mattermost channels.
+// Rules are set per channel, so settings always ask for a notification
+type ChatUserNotificationSettings struct {
+	Channels []ChatChannel `json:"channels"`
+}
+
+//Success returns always/never/change
+func (n *ChatUserNotificationSettings) Success() UserNotificationEventType {
+	return UserNotificationAlways
+}
+
+//Failure returns always/never/change
+func (n *ChatUserNotificationSettings) Failure() UserNotificationEventType {
+	return UserNotificationAlways
+}
+
+//Start returns always/never/change
+func (n *ChatUserNotificationSettings) Start() bool {
+	return true
+}
+
+// ChatChannel is a channel notified through an incoming webhook URL.
+// With Thread and Token, URL is a Slack Web API chat.postMessage endpoint
+// and deployment pipelines post their end in the thread of their start
+type ChatChannel struct {
+	URL       string                    `json:"url"`
+	Channel   string                    `json:"channel,omitempty"`
+	Token     string                    `json:"token,omitempty"`
+	Thread    bool                      `json:"thread"`
+	OnSuccess UserNotificationEventType `json:"on_success"`
+	OnFailure UserNotificationEventType `json:"on_failure"`
+	OnStart   bool                      `json:"on_start"`
+}
+
+//Success returns always/never/change
+func (c *ChatChannel) Success() UserNotificationEventType {
+	return c.OnSuccess
+}
+
+//Failure returns always/never/change
+func (c *ChatChannel) Failure() UserNotificationEventType {
+	return c.OnFailure
+}
+
+//Start returns always/never/change
+func (c *ChatChannel) Start() bool {
+	return c.OnStart
+}
+
+// WebhookDelivery is an attempt to post a webhook notification of a pipeline build
+type WebhookDelivery struct {
+	ID              int64     `json:"id"`
+	PipelineBuildID int64     `json:"pipeline_build_id"`
+	URL             string    `json:"url"`
+	Attempt         int       `json:"attempt"`
+	StatusCode      int       `json:"status_code"`
+	Error           string    `json:"error,omitempty"`
+	Duration        int64     `json:"duration"`
+	Created         time.Time `json:"created"`
+}
+
+// NotificationOutboxEntry is a notification waiting for delivery, or dead after too many attempts
+type NotificationOutboxEntry struct {
+	ID          int64     `json:"id"`
+	Kind        string    `json:"kind"`
+	Target      string    `json:"target"`
+	Payload     string    `json:"payload"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+// NotificationSubscription notifies a user of builds of a project, or of some of its applications and pipelines,
+// whatever the notification settings of the project. Mute opts the user out of notifications sent to groups
+type NotificationSubscription struct {
+	ProjectKey  string                    `json:"project_key"`
+	Application string                    `json:"application,omitempty"`
+	Pipeline    string                    `json:"pipeline,omitempty"`
+	OnSuccess   UserNotificationEventType `json:"on_success"`
+	OnFailure   UserNotificationEventType `json:"on_failure"`
+	OnStart     bool                      `json:"on_start"`
+	OnlyMine    bool                      `json:"only_mine"`
+	Mute        bool                      `json:"mute"`
+}
+
+//Success returns always/never/change
+func (s *NotificationSubscription) Success() UserNotificationEventType {
+	return s.OnSuccess
+}
+
+//Failure returns always/never/change
+func (s *NotificationSubscription) Failure() UserNotificationEventType {
+	return s.OnFailure
+}
+
+//Start returns always/never/change
+func (s *NotificationSubscription) Start() bool {
+	return s.OnStart
+}
+
+// Covers returns true when subscription applies to given application and pipeline of project
+func (s *NotificationSubscription) Covers(projectKey, application, pipeline string) bool {
+	return s.ProjectKey == projectKey &&
+		(s.Application == "" || s.Application == application) &&
+		(s.Pipeline == "" || s.Pipeline == pipeline)
+}
+
+// DigestFrequency is how often a user receives the summary of subscribed projects
+type DigestFrequency string
+
+// Digest frequencies
+const (
+	DigestNever  DigestFrequency = ""
+	DigestDaily  DigestFrequency = "daily"
+	DigestWeekly DigestFrequency = "weekly"
+)
+
+// UserNotificationPreferences are the subscriptions and digest of a user
+type UserNotificationPreferences struct {
+	Subscriptions []NotificationSubscription `json:"subscriptions"`
+	Digest        DigestFrequency            `json:"digest"`
+}
+
+// UserNotificationTemplate is the notification content, rendered with Go templates.
+// Name refers to a template of the project, used instead of Subject and Body
 type UserNotificationTemplate struct {
+	Name    string `json:"name,omitempty"`
 	Subject string `json:"subject,omitempty"`
 	Body    string `json:"body,omitempty"`
+	HTML    bool   `json:"html,omitempty"`
+}
+
+// NotificationTemplate is a notification template defined once on a project
+type NotificationTemplate struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Subject      string    `json:"subject"`
+	Body         string    `json:"body"`
+	HTML         bool      `json:"html"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// NotificationTemplatePreview asks to render a template against a past pipeline build
+type NotificationTemplatePreview struct {
+	Template    UserNotificationTemplate `json:"template"`
+	Application string                   `json:"application"`
+	Pipeline    string                   `json:"pipeline"`
+	Environment string                   `json:"environment,omitempty"`
+	BuildNumber int64                    `json:"build_number"`
+}
+
+// NotificationTemplateRendering is a rendered notification template
+type NotificationTemplateRendering struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	HTML    bool   `json:"html"`
+}
+
+// GetWebhookDeliveries retrieves attempts to post webhook notifications of a pipeline build
+func GetWebhookDeliveries(proj, app, pip, env string, bn int) ([]WebhookDelivery, error) {
+	if env == "" {
+		env = DefaultEnv.Name
+	}
+	uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/build/%d/webhook?envName=%s", proj, app, pip, bn, url.QueryEscape(env))
+
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var deliveries []WebhookDelivery
+	if err := json.Unmarshal(data, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// GetUserNotificationPreferences retrieves subscriptions and digest of a user
+func GetUserNotificationPreferences(username string) (UserNotificationPreferences, error) {
+	var p UserNotificationPreferences
+	data, code, err := Request("GET", fmt.Sprintf("/user/%s/notification", username), nil)
+	if err != nil {
+		return p, err
+	}
+	if code >= 300 {
+		return p, fmt.Errorf("HTTP %d", code)
+	}
+
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+// UpdateUserNotificationPreferences sets subscriptions and digest of a user
+func UpdateUserNotificationPreferences(username string, p UserNotificationPreferences) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	data, code, err := Request("PUT", fmt.Sprintf("/user/%s/notification", username), data)
+	if err != nil {
+		return err
+	}
+	if code >= 300 {
+		if e := DecodeError(data); e != nil {
+			return e
+		}
+		return fmt.Errorf("HTTP %d", code)
+	}
+	return nil
+}
+
+// GetNotificationTemplates retrieves notification templates of a project
+func GetNotificationTemplates(key string) ([]NotificationTemplate, error) {
+	data, code, err := Request("GET", fmt.Sprintf("/project/%s/notification/template", key), nil)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var templates []NotificationTemplate
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// SaveNotificationTemplate creates or updates a notification template of a project
+func SaveNotificationTemplate(key string, t NotificationTemplate) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	data, code, err := Request("PUT", fmt.Sprintf("/project/%s/notification/template/%s", key, t.Name), data)
+	if err != nil {
+		return err
+	}
+	if code >= 300 {
+		if e := DecodeError(data); e != nil {
+			return e
+		}
+		return fmt.Errorf("HTTP %d", code)
+	}
+	return nil
+}
+
+// DeleteNotificationTemplate removes a notification template of a project
+func DeleteNotificationTemplate(key, name string) error {
+	data, code, err := Request("DELETE", fmt.Sprintf("/project/%s/notification/template/%s", key, name), nil)
+	if err != nil {
+		return err
+	}
+	if code >= 300 {
+		if e := DecodeError(data); e != nil {
+			return e
+		}
+		return fmt.Errorf("HTTP %d", code)
+	}
+	return nil
+}
+
+// PreviewNotificationTemplate renders a template against a past pipeline build
+func PreviewNotificationTemplate(key string, p NotificationTemplatePreview) (NotificationTemplateRendering, error) {
+	var res NotificationTemplateRendering
+	data, err := json.Marshal(p)
+	if err != nil {
+		return res, err
+	}
+
+	data, code, err := Request("POST", fmt.Sprintf("/project/%s/notification/template/preview", key), data)
+	if err != nil {
+		return res, err
+	}
+	if code >= 300 {
+		if e := DecodeError(data); e != nil {
+			return res, e
+		}
+		return res, fmt.Errorf("HTTP %d", code)
+	}
+
+	err = json.Unmarshal(data, &res)
+	return res, err
 }
@@ -0,0 +1,146 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// Release is a named and immutable copy of the artifacts of a build,
+// so what is deployed under a release name never changes
+type Release struct {
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	Project     string            `json:"project"`
+	Application string            `json:"application"`
+	Pipeline    string            `json:"pipeline"`
+	Environment string            `json:"environment"`
+	BuildNumber int               `json:"build_number"`
+	Author      string            `json:"author"`
+	Created     time.Time         `json:"created"`
+	Artifacts   []Artifact        `json:"artifacts,omitempty"`
+	Consumers   []ReleaseConsumer `json:"consumers,omitempty"`
+}
+
+// ReleaseConsumer records a build which fetched release artifacts
+type ReleaseConsumer struct {
+	Pipeline    string    `json:"pipeline"`
+	Environment string    `json:"environment"`
+	BuildNumber int       `json:"build_number"`
+	Date        time.Time `json:"date"`
+}
+
+// ReleaseNamePattern is the pattern release names must match
+var ReleaseNamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// PromoteRelease copies artifacts of given build into a new release
+func PromoteRelease(project, application, pipeline, env string, buildNumber int, name string) (*Release, error) {
+	r := Release{
+		Name:        name,
+		Pipeline:    pipeline,
+		Environment: env,
+		BuildNumber: buildNumber,
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	uri := fmt.Sprintf("/project/%s/application/%s/release", project, application)
+	data, code, err := Request("POST", uri, data)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListReleases returns all releases of given application
+func ListReleases(project, application string) ([]Release, error) {
+	uri := fmt.Sprintf("/project/%s/application/%s/release", project, application)
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var releases []Release
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// GetRelease returns release with its artifacts and consumers
+func GetRelease(project, application, name string) (*Release, error) {
+	uri := fmt.Sprintf("/project/%s/application/%s/release/%s", project, application, url.QueryEscape(name))
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if code == http.StatusNotFound {
+		return nil, ErrReleaseNotFound
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var r Release
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// DownloadReleaseArtifacts downloads all artifacts of release into destdir
+func DownloadReleaseArtifacts(project, application, name, destdir string) error {
+	r, err := GetRelease(project, application, name)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range r.Artifacts {
+		uri := fmt.Sprintf("/project/%s/application/%s/release/%s/artifact/%d/download", project, application, url.QueryEscape(name), a.ID)
+		if err := downloadFrom(uri, a, destdir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddReleaseConsumer records that given build fetched release artifacts
+func AddReleaseConsumer(project, application, name, pipeline, env string, buildNumber int) error {
+	c := ReleaseConsumer{
+		Pipeline:    pipeline,
+		Environment: env,
+		BuildNumber: buildNumber,
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	uri := fmt.Sprintf("/project/%s/application/%s/release/%s/consumer", project, application, url.QueryEscape(name))
+	_, code, err := Request("POST", uri, data)
+	if err != nil {
+		return err
+	}
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+	return nil
+}
@@ -15,6 +15,10 @@ const (
 	Stash RepositoriesManagerType = "STASH"
 	//Github is valued to "GITHUB"
 	Github RepositoriesManagerType = "GITHUB"
+	//BitbucketCloud is valued to "BITBUCKETCLOUD"
+	BitbucketCloud RepositoriesManagerType = "BITBUCKETCLOUD"
+	//Gitea is valued to "GITEA"
+	Gitea RepositoriesManagerType = "GITEA"
 )
 
 //RepositoriesManager is the struct for every repositories manager.
@@ -36,6 +40,9 @@ type RepositoryPoller struct {
 	Pipeline     Pipeline    `json:"pipeline"`
 	Enabled      bool        `json:"enabled"`
 	DateCreation time.Time   `json:"date_creation"`
+	IncludePaths []string    `json:"include_paths,omitempty"`
+	ExcludePaths []string    `json:"exclude_paths,omitempty"`
+	Events       []string    `json:"events,omitempty"`
 }
 
 //RepositoriesManagerDriver is the consumer interface
@@ -187,6 +194,25 @@ func GetProjectReposFromReposManager(k, n string) ([]VCSRepo, error) {
 	return repos, nil
 }
 
+//GetProjectRepoFromReposManager returns the repository identified by its fullname in the reposManager
+func GetProjectRepoFromReposManager(k, n, repoFullname string) (*VCSRepo, error) {
+	uri := fmt.Sprintf("/project/%s/repositories_manager/%s/repo?repo=%s", k, n, url.QueryEscape(repoFullname))
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var repo VCSRepo
+	if err := json.Unmarshal(data, &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
 //GetCommits returns the commits
 func GetCommits(key, repoManagername, repoFullname, since, until string) ([]VCSCommit, error) {
 	var commits []VCSCommit
@@ -302,6 +328,7 @@ type RepositoriesManagerClient interface {
 	//Commits
 	Commits(repo, since, until string) ([]VCSCommit, error)
 	Commit(repo, hash string) (VCSCommit, error)
+	ChangedFiles(repo, since, until string) ([]string, error)
 
 	//Hooks
 	CreateHook(repo, url string) error
@@ -347,8 +374,28 @@ type VCSBranch struct {
 	Default      bool   `json:"default"`
 }
 
-//VCSPushEvent represents a push events for polling
+//VCSPushEvent represents a push events for polling. Tag is set when a tag has been created on Commit
 type VCSPushEvent struct {
 	Branch VCSBranch `json:"branch"`
 	Commit VCSCommit `json:"commit"`
+	Tag    string    `json:"tag,omitempty"`
+}
+
+// Kinds of repository events triggering pipelines through hooks and pollers
+const (
+	PushEvent = "push"
+	TagEvent  = "tag"
+)
+
+//HasEvent returns true if events contains kind. Without any event, pipelines are triggered by pushes only
+func HasEvent(events []string, kind string) bool {
+	if len(events) == 0 {
+		return kind == PushEvent
+	}
+	for _, e := range events {
+		if e == kind {
+			return true
+		}
+	}
+	return false
 }
@@ -41,3 +41,15 @@ type Week struct {
 		Deploy  int64 `json:"deploy"`
 	} `json:"runned_pipelines"`
 }
+
+// ModelSpawnLatency exposes, for a worker model and a day, the delay between
+// the spawn of workers booked for an action build and the start of this build
+type ModelSpawnLatency struct {
+	Day       time.Time `json:"day"`
+	ModelID   int64     `json:"model_id"`
+	ModelName string    `json:"model_name"`
+	Started   int64     `json:"started"`
+	// Average and Max are in milliseconds
+	Average int64 `json:"average_ms"`
+	Max     int64 `json:"max_ms"`
+}
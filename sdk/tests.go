@@ -3,16 +3,19 @@ package sdk
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"time"
 )
 
 // Tests contains all informations about tests in a pipeline build
 type Tests struct {
-	PipelineBuildID int64       `json:"pipeline_build_id"`
-	Total           int         `json:"total"`
-	TotalOK         int         `json:"ok"`
-	TotalKO         int         `json:"ko"`
-	TotalSkipped    int         `json:"skipped"`
-	TestSuites      []TestSuite `xml:"testsuite" json:"test_suites"`
+	PipelineBuildID int64         `json:"pipeline_build_id"`
+	Total           int           `json:"total"`
+	TotalOK         int           `json:"ok"`
+	TotalKO         int           `json:"ko"`
+	TotalSkipped    int           `json:"skipped"`
+	TestSuites      []TestSuite   `xml:"testsuite" json:"test_suites"`
+	NewFailures     []TestCaseRef `xml:"-" json:"new_failures,omitempty"`
 }
 
 // TestSuite defines the result of a group of tests
@@ -34,6 +37,55 @@ type Test struct {
 	Skip    *string `xml:"skipped" json:"skipped"`
 }
 
+// Test case status, as recorded in test history
+const (
+	TestStatusSuccess = "success"
+	TestStatusFail    = "fail"
+	TestStatusSkipped = "skipped"
+)
+
+// Status returns the outcome of the test
+func (t Test) Status() string {
+	switch {
+	case t.Failure != "" || t.Error != "":
+		return TestStatusFail
+	case t.Skip != nil:
+		return TestStatusSkipped
+	}
+	return TestStatusSuccess
+}
+
+// TestCaseRef identifies a test case across builds
+type TestCaseRef struct {
+	Suite string `json:"suite"`
+	Name  string `json:"name"`
+}
+
+// TestCaseResult is the outcome of a test case in a given build
+type TestCaseResult struct {
+	BuildNumber int64     `json:"build_number"`
+	Branch      string    `json:"branch"`
+	Hash        string    `json:"hash"`
+	Status      string    `json:"status"`
+	Time        string    `json:"time"`
+	Date        time.Time `json:"date"`
+}
+
+// TestCaseHistory lists the outcomes of a test case over past builds, most recent first
+type TestCaseHistory struct {
+	TestCaseRef
+	Flaky   bool             `json:"flaky"`
+	Results []TestCaseResult `json:"results"`
+}
+
+// FlakyTest is a test case which both passed and failed on the same commit
+type FlakyTest struct {
+	TestCaseRef
+	Hash     string `json:"hash"`
+	Success  int    `json:"success"`
+	Failures int    `json:"failures"`
+}
+
 // GetTestResults retrieves tests results for a specific build
 func GetTestResults(proj, app, pip, env string, bn int) (Tests, error) {
 	if env == "" {
@@ -57,3 +109,53 @@ func GetTestResults(proj, app, pip, env string, bn int) (Tests, error) {
 
 	return t, nil
 }
+
+// GetTestHistory retrieves the outcomes of a test case over the last builds of a pipeline
+func GetTestHistory(proj, app, pip, env, suite, name string) (TestCaseHistory, error) {
+	if env == "" {
+		env = DefaultEnv.Name
+	}
+	q := url.Values{}
+	q.Set("envName", env)
+	q.Set("suite", suite)
+	q.Set("name", name)
+	uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/test/history?%s", proj, app, pip, q.Encode())
+	var h TestCaseHistory
+
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return h, err
+	}
+	if code >= 300 {
+		return h, DecodeError(data)
+	}
+
+	if err := json.Unmarshal(data, &h); err != nil {
+		return h, err
+	}
+
+	return h, nil
+}
+
+// GetFlakyTests retrieves test cases of a pipeline which both passed and failed on the same commit
+func GetFlakyTests(proj, app, pip, env string) ([]FlakyTest, error) {
+	if env == "" {
+		env = DefaultEnv.Name
+	}
+	uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/test/flaky?envName=%s", proj, app, pip, url.QueryEscape(env))
+	var flaky []FlakyTest
+
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, DecodeError(data)
+	}
+
+	if err := json.Unmarshal(data, &flaky); err != nil {
+		return nil, err
+	}
+
+	return flaky, nil
+}
@@ -16,6 +16,9 @@ type User struct {
 	Auth     Auth    `json:"-"`
 	Groups   []Group `json:"groups"`
 	Origin   string  `json:"-"`
+
+	Subscriptions []NotificationSubscription `json:"subscriptions,omitempty"`
+	Digest        DigestFrequency            `json:"digest,omitempty"`
 }
 
 // UserAPIRequest  request for rest API
@@ -16,6 +16,23 @@ type Worker struct {
 	Model      int64     `json:"model"`
 	HatcheryID int64     `json:"hatchery_id"`
 	Status     Status    `json:"status"` // Waiting, Building, Disabled, Unknown
+	// Draining workers do not take new jobs, and unregister once their current job is done
+	Draining bool `json:"draining"`
+	// Version of the worker binary, sent in heartbeats
+	Version string `json:"version"`
+}
+
+// WorkerBinary describes the worker binary served by engine
+type WorkerBinary struct {
+	Version string `json:"version"`
+	// Checksum is the hex encoded sha256 of the binary
+	Checksum string `json:"checksum"`
+}
+
+// WorkerVersionStat is the number of workers running a given version
+type WorkerVersionStat struct {
+	Version string `json:"version"`
+	Count   int64  `json:"count"`
 }
 
 // WorkerType defines where worker can be started
@@ -49,6 +66,126 @@ type Model struct {
 	OwnerID      int64         `json:"-"`
 	Owner        User          `json:"owner"`
 	Validated    bool          `json:"validated"` // Model is tested and marked as functionnal
+	Memory       int64         `json:"memory"`    // Memory of workers in MB, 0 to let hatcheries size them
+	CPU          float64       `json:"cpu"`       // CPUs of workers, 0 to let hatcheries size them
+	Disk         int64         `json:"disk"`      // Disk of workers in MB, 0 to let hatcheries size them
+	// SpawnErrors is the number of consecutive failures of hatcheries to start a worker of this model
+	SpawnErrors    int64      `json:"spawn_errors"`
+	LastSpawnError string     `json:"last_spawn_error,omitempty"`
+	UnhealthyUntil *time.Time `json:"unhealthy_until,omitempty"` // Hatcheries back off until then
+	// NeedValidation is set when model changed, until a worker reports its capabilities
+	NeedValidation bool                   `json:"need_validation"`
+	Validation     *ModelValidationReport `json:"validation,omitempty"`
+	// Pool of workers kept by hatcheries, nil to use hatcheries provisioning
+	Pool *ModelPool `json:"pool,omitempty"`
+}
+
+// ModelPoolLimits bounds the number of workers of a model
+type ModelPoolLimits struct {
+	// MinIdle is the number of workers kept waiting for jobs
+	MinIdle int64 `json:"min_idle" yaml:"min_idle"`
+	// MaxTotal is the maximum number of workers, 0 for no limit
+	MaxTotal int64 `json:"max_total" yaml:"max_total"`
+	// IdleTTL is the number of seconds an idle worker is kept before being killed, when not needed anymore
+	IdleTTL int64 `json:"idle_ttl" yaml:"idle_ttl"`
+}
+
+// ModelPool configures the pool of workers of a model,
+// with limits applied during some hours (ie. bigger pool during office hours)
+type ModelPool struct {
+	ModelPoolLimits
+	Schedules []ModelPoolSchedule `json:"schedules,omitempty"`
+	// Location is the time zone of schedules (ie. "Europe/Paris"), engine local time if empty
+	Location string `json:"location,omitempty"`
+}
+
+// ModelPoolSchedule overrides pool limits during given hours of given days
+type ModelPoolSchedule struct {
+	// Days of the week ("mon", "tue"...), every day if empty
+	Days []string `json:"days,omitempty"`
+	// From and To are hours of the day ("08:00", "19:30")
+	From string `json:"from"`
+	To   string `json:"to"`
+	ModelPoolLimits
+}
+
+// Fits returns true if workers of the model have enough memory (in MB) and CPUs.
+// Resources not set on the model are sized by hatcheries, and fit any need.
+func (m *Model) Fits(memory int64, cpu float64) bool {
+	if m.Memory > 0 && m.Memory < memory {
+		return false
+	}
+	if m.CPU > 0 && m.CPU < cpu {
+		return false
+	}
+	return true
+}
+
+// Healthy returns false while hatcheries have to back off from spawning workers of the model
+func (m *Model) Healthy(now time.Time) bool {
+	return m.UnhealthyUntil == nil || !m.UnhealthyUntil.After(now)
+}
+
+// ModelValidation is sent to a worker which has to validate its model
+type ModelValidation struct {
+	Needed bool `json:"needed"`
+	// Capabilities declared on the model
+	Capabilities []Requirement `json:"capabilities"`
+	// Discover lists binaries looked up in the worker PATH
+	Discover []string `json:"discover"`
+}
+
+// CapabilityCheck is the result of checking a capability on a worker
+type CapabilityCheck struct {
+	Requirement Requirement `json:"requirement"`
+	Valid       bool        `json:"valid"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// ModelValidationReport is the result of a worker model validation.
+// Workers fill checks and discovered binaries, engine computes the diff with declared capabilities.
+type ModelValidationReport struct {
+	WorkerName string            `json:"worker_name"`
+	Date       time.Time         `json:"date"`
+	Checks     []CapabilityCheck `json:"checks"`
+	Discovered []string          `json:"discovered"`
+	// Missing are declared capabilities not found on worker
+	Missing []Requirement `json:"missing"`
+	// Undeclared are discovered binaries not declared as capabilities
+	Undeclared []string `json:"undeclared"`
+	Validated  bool     `json:"validated"`
+}
+
+// SpawnError is reported by an hatchery which could not start a worker
+type SpawnError struct {
+	ModelID      int64     `json:"model_id"`
+	HatcheryID   int64     `json:"hatchery_id"`
+	HatcheryName string    `json:"hatchery_name"`
+	Message      string    `json:"message"`
+	Date         time.Time `json:"date"`
+}
+
+// SpawnReservation is claimed by an hatchery before spawning workers of a model,
+// so that other hatcheries do not spawn workers for the same action builds.
+// Reservations are released when workers register, fail to start or when they expire.
+type SpawnReservation struct {
+	ModelID    int64 `json:"model_id"`
+	HatcheryID int64 `json:"hatchery_id"`
+	// Count is the number of workers to spawn, some of them for given action builds
+	Count        int64   `json:"count"`
+	ActionBuilds []int64 `json:"action_builds"`
+	// TTL is the number of seconds given to workers to register
+	TTL    int64     `json:"ttl"`
+	Expire time.Time `json:"expire"`
+}
+
+// SpawnErrorStat sums up spawn errors of a model reported by an hatchery
+type SpawnErrorStat struct {
+	HatcheryID   int64     `json:"hatchery_id"`
+	HatcheryName string    `json:"hatchery_name"`
+	Count        int64     `json:"count"`
+	LastMessage  string    `json:"last_message"`
+	LastDate     time.Time `json:"last_date"`
 }
 
 // ModelStatus sums up the number of worker deployed and wanted for a given model
@@ -59,6 +196,19 @@ type ModelStatus struct {
 	WantedCount   int64         `json:"wanted_count" yaml:"wanted"`
 	BuildingCount int64         `json:"building_count" yaml:"building"`
 	Requirements  []Requirement `json:"requirements"`
+	Memory        int64         `json:"memory" yaml:"-"`
+	CPU           float64       `json:"cpu" yaml:"-"`
+	// UnhealthyUntil is set when workers of the model keep failing to start
+	UnhealthyUntil *time.Time `json:"unhealthy_until,omitempty" yaml:"unhealthy_until,omitempty"`
+	NeedValidation bool       `json:"need_validation" yaml:"-"`
+	// Pool are the limits of the model pool, currently applied
+	Pool *ModelPoolLimits `json:"pool,omitempty" yaml:"pool,omitempty"`
+	// ActionBuilds are the queued action builds not reserved yet by an hatchery
+	ActionBuilds []int64 `json:"action_builds,omitempty" yaml:"-"`
+	// ActionBuildRequirements are the requirements a worker bound to each of ActionBuilds must be spawned with
+	ActionBuildRequirements map[int64][]Requirement `json:"action_build_requirements,omitempty" yaml:"-"`
+	// ReservedCount is the number of workers being spawned by hatcheries without a job to run (pool provisioning)
+	ReservedCount int64 `json:"reserved_count" yaml:"reserved"`
 }
 
 // OpenstackModelData type details the "Image" field of Openstack type model
@@ -110,14 +260,105 @@ func DisableWorker(workerID string) error {
 	return nil
 }
 
-// AddWorkerModel registers a new worker model available
+// GetWorkerVersions retrieves the number of workers running each version
+func GetWorkerVersions() ([]WorkerVersionStat, error) {
+	data, code, err := Request("GET", "/worker/version", nil)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var stats []WorkerVersionStat
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// DrainWorker stops worker from taking new jobs, it unregisters once its current job is done
+func DrainWorker(workerID string) error {
+	uri := fmt.Sprintf("/worker/%s/drain", workerID)
+
+	_, code, err := Request("POST", uri, nil)
+	if err != nil {
+		return err
+	}
+
+	if code != 200 {
+		return fmt.Errorf("API error (%d)", code)
+	}
+
+	return nil
+}
+
+// DrainHatchery stops hatchery from spawning workers, and drains all its workers
+func DrainHatchery(hatcheryID int64) error {
+	uri := fmt.Sprintf("/hatchery/%d/drain", hatcheryID)
+
+	_, code, err := Request("POST", uri, nil)
+	if err != nil {
+		return err
+	}
+
+	if code != 200 {
+		return fmt.Errorf("API error (%d)", code)
+	}
+
+	return nil
+}
+
+// UndrainWorker lets a draining worker take new jobs again
+func UndrainWorker(workerID string) error {
+	uri := fmt.Sprintf("/worker/%s/undrain", workerID)
+
+	_, code, err := Request("POST", uri, nil)
+	if err != nil {
+		return err
+	}
+
+	if code != 200 {
+		return fmt.Errorf("API error (%d)", code)
+	}
+
+	return nil
+}
+
+// UndrainHatchery lets a draining hatchery spawn workers again, and stops draining its workers
+func UndrainHatchery(hatcheryID int64) error {
+	uri := fmt.Sprintf("/hatchery/%d/undrain", hatcheryID)
+
+	_, code, err := Request("POST", uri, nil)
+	if err != nil {
+		return err
+	}
+
+	if code != 200 {
+		return fmt.Errorf("API error (%d)", code)
+	}
+
+	return nil
+}
+
+// AddWorkerModel registers a new worker model available, sized by hatcheries
 func AddWorkerModel(name string, t WorkerType, img string) (*Model, error) {
+	return AddWorkerModelWithResources(name, t, img, 0, 0, 0)
+}
+
+// AddWorkerModelWithResources registers a new worker model available
+// memory and disk are in MB, resources set to 0 are sized by hatcheries
+func AddWorkerModelWithResources(name string, t WorkerType, img string, memory int64, cpu float64, disk int64) (*Model, error) {
 	uri := fmt.Sprintf("/worker/model")
 
 	m := Model{
-		Name:  name,
-		Type:  t,
-		Image: img,
+		Name:   name,
+		Type:   t,
+		Image:  img,
+		Memory: memory,
+		CPU:    cpu,
+		Disk:   disk,
 	}
 	data, err := json.Marshal(m)
 	if err != nil {
@@ -161,11 +402,17 @@ func GetWorkerModel(name string) (*Model, error) {
 	return &m, nil
 }
 
-// UpdateWorkerModel updates all characteristics of a worker model
+// UpdateWorkerModel updates all characteristics of a worker model, its resources being left to hatcheries
 func UpdateWorkerModel(id int64, name string, t WorkerType, value string) error {
+	return UpdateWorkerModelWithResources(id, name, t, value, 0, 0, 0)
+}
+
+// UpdateWorkerModelWithResources updates all characteristics of a worker model, including its resources
+// memory and disk are in MB, resources set to 0 are sized by hatcheries
+func UpdateWorkerModelWithResources(id int64, name string, t WorkerType, value string, memory int64, cpu float64, disk int64) error {
 	uri := fmt.Sprintf("/worker/model/%d", id)
 
-	data, err := json.Marshal(Model{ID: id, Name: name, Type: t, Image: value})
+	data, err := json.Marshal(Model{ID: id, Name: name, Type: t, Image: value, Memory: memory, CPU: cpu, Disk: disk})
 	if err != nil {
 		return err
 	}
@@ -296,3 +543,148 @@ func GetWorkerModelStatus() ([]ModelStatus, error) {
 
 	return ms, nil
 }
+
+// ReportSpawnError notifies engine that an hatchery could not start a worker of given model
+func ReportSpawnError(modelID int64, e SpawnError) error {
+	uri := fmt.Sprintf("/worker/model/%d/error", modelID)
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, code, err := Request("POST", uri, data)
+	if err != nil {
+		return err
+	}
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+
+	return nil
+}
+
+// GetSpawnErrors retrieves spawn errors of given model, per hatchery
+func GetSpawnErrors(modelID int64) ([]SpawnErrorStat, error) {
+	uri := fmt.Sprintf("/worker/model/%d/error", modelID)
+
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var stats []SpawnErrorStat
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// ReserveWorkers claims the right to spawn workers of a model. Returned reservation
+// holds the number of workers the hatchery is allowed to spawn, and for which action builds
+func ReserveWorkers(modelID int64, r SpawnReservation) (*SpawnReservation, error) {
+	uri := fmt.Sprintf("/worker/model/%d/reservation", modelID)
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, code, err := Request("POST", uri, data)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var granted SpawnReservation
+	if err := json.Unmarshal(data, &granted); err != nil {
+		return nil, err
+	}
+
+	return &granted, nil
+}
+
+// RequestWorkerModelValidation asks hatcheries to start a worker validating model capabilities
+func RequestWorkerModelValidation(modelID int64) error {
+	uri := fmt.Sprintf("/worker/model/%d/validation", modelID)
+
+	_, code, err := Request("POST", uri, nil)
+	if err != nil {
+		return err
+	}
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+
+	return nil
+}
+
+// GetWorkerModelValidation retrieves what a worker of given model has to check
+func GetWorkerModelValidation(modelID int64) (*ModelValidation, error) {
+	uri := fmt.Sprintf("/worker/model/%d/validation", modelID)
+
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var v ModelValidation
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// SendWorkerModelValidationReport sends capabilities checked by a worker of given model
+func SendWorkerModelValidationReport(modelID int64, r ModelValidationReport) (*ModelValidationReport, error) {
+	uri := fmt.Sprintf("/worker/model/%d/validation/report", modelID)
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, code, err := Request("POST", uri, data)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// SetWorkerModelPool configures the pool of workers of a model, nil to remove it
+func SetWorkerModelPool(modelID int64, pool *ModelPool) error {
+	uri := fmt.Sprintf("/worker/model/%d/pool", modelID)
+
+	data, err := json.Marshal(pool)
+	if err != nil {
+		return err
+	}
+
+	_, code, err := Request("PUT", uri, data)
+	if err != nil {
+		return err
+	}
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package sdk
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// WorkspaceCache is a tarball of a working directory subset, saved by a build
+// and restored by the next ones to avoid downloading dependencies again
+type WorkspaceCache struct {
+	ID         int64     `json:"id"`
+	Project    string    `json:"project"`
+	Key        string    `json:"key"`
+	Size       int64     `json:"size"`
+	MD5sum     string    `json:"md5sum,omitempty"`
+	ObjectPath string    `json:"object_path,omitempty"`
+	Created    time.Time `json:"created"`
+	LastUsed   time.Time `json:"last_used"`
+}
+
+// WorkspaceCacheQuota is the maximum total size, in MB, of the workspace caches of a project.
+// Setting a zero quota restores the API default.
+type WorkspaceCacheQuota struct {
+	Project string `json:"project"`
+	Quota   int64  `json:"quota"`
+}
+
+// Builtin workspace cache actions
+const (
+	CacheSave    = "Cache Save"
+	CacheRestore = "Cache Restore"
+)
+
+// Header names for workspace cache upload
+const (
+	WorkspaceCacheSize   = "CACHE-SIZE"
+	WorkspaceCacheMD5Sum = "CACHE-MD5SUM"
+)
+
+// UploadWorkspaceCache read tarball at filePath and store it as cache entry key of given project
+func UploadWorkspaceCache(project, key, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		file.Close()
+		return err
+	}
+	md5sumStr := hex.EncodeToString(hash.Sum(nil))
+	file.Close()
+
+	//Reopen the file because we already read it for md5
+	file, err = os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	uri := fmt.Sprintf("/project/%s/cache/%s", project, url.QueryEscape(key))
+	_, code, err := Upload("POST", uri, file,
+		SetHeader("Content-Type", "application/octet-stream"),
+		SetHeader(WorkspaceCacheSize, strconv.FormatInt(stat.Size(), 10)),
+		SetHeader(WorkspaceCacheMD5Sum, md5sumStr))
+	if err != nil {
+		return err
+	}
+
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+
+	return nil
+}
+
+// GetWorkspaceCache looks for cache entry key in given project. If there is none, the most recent
+// entry starting with one of restoreKeys is returned, in restoreKeys order.
+// It returns nil without error if nothing matches.
+func GetWorkspaceCache(project, key string, restoreKeys []string) (*WorkspaceCache, error) {
+	params := url.Values{}
+	for _, k := range restoreKeys {
+		params.Add("restore", k)
+	}
+
+	uri := fmt.Sprintf("/project/%s/cache/%s?%s", project, url.QueryEscape(key), params.Encode())
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if code == http.StatusNotFound {
+		return nil, nil
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	c := &WorkspaceCache{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// DownloadWorkspaceCache writes the tarball of cache entry key into w and checks its md5sum
+func DownloadWorkspaceCache(project string, c WorkspaceCache, w io.Writer) error {
+	uri := fmt.Sprintf("/project/%s/cache/%s/download", project, url.QueryEscape(c.Key))
+	reader, code, err := Stream("GET", uri, nil)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), reader); err != nil {
+		return err
+	}
+
+	if c.MD5sum != "" && hex.EncodeToString(hash.Sum(nil)) != c.MD5sum {
+		return fmt.Errorf("cache %s is corrupted: md5sum mismatch", c.Key)
+	}
+
+	return nil
+}
+
+// ListWorkspaceCaches returns all cache entries of given project
+func ListWorkspaceCaches(project string) ([]WorkspaceCache, error) {
+	uri := fmt.Sprintf("/project/%s/cache", project)
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	var caches []WorkspaceCache
+	if err := json.Unmarshal(data, &caches); err != nil {
+		return nil, err
+	}
+
+	return caches, nil
+}
+
+// DeleteWorkspaceCache removes cache entry key from given project
+func DeleteWorkspaceCache(project, key string) error {
+	uri := fmt.Sprintf("/project/%s/cache/%s", project, url.QueryEscape(key))
+	_, code, err := Request("DELETE", uri, nil)
+	if err != nil {
+		return err
+	}
+
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+
+	return nil
+}
+
+// GetWorkspaceCacheQuota returns workspace cache quota of given project
+func GetWorkspaceCacheQuota(project string) (*WorkspaceCacheQuota, error) {
+	uri := fmt.Sprintf("/project/%s/cache-quota", project)
+	data, code, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	q := &WorkspaceCacheQuota{}
+	if err := json.Unmarshal(data, q); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// UpdateWorkspaceCacheQuota sets workspace cache quota of given project, in MB
+func UpdateWorkspaceCacheQuota(project string, quota int64) error {
+	data, err := json.Marshal(WorkspaceCacheQuota{Project: project, Quota: quota})
+	if err != nil {
+		return err
+	}
+
+	uri := fmt.Sprintf("/project/%s/cache-quota", project)
+	_, code, err := Request("PUT", uri, data)
+	if err != nil {
+		return err
+	}
+
+	if code >= 300 {
+		return fmt.Errorf("HTTP %d", code)
+	}
+
+	return nil
+}